@@ -0,0 +1,288 @@
+// Package editing edits the documents of a peer by position. The positions
+// are resolved to the IDs of the characters and blocks of the current
+// document, and every edit is saved as one transaction of CRDT operations.
+package editing
+
+import (
+	"Node-tion/backend/peer/impl"
+	"Node-tion/backend/types"
+	"fmt"
+)
+
+// Node is the part of a peer the documents are edited through.
+type Node interface {
+	GetDocumentBlocks(docID string) ([]types.DocumentBlock, error)
+	CommitTransaction(transactions types.CRDTOperationsMessage) (types.TransactionResult, error)
+}
+
+// Client edits the documents of a node. Offsets count the characters of the
+// text of a block and indexes count the visible blocks among their siblings.
+type Client struct {
+	node Node
+}
+
+// NewClient returns a client editing the documents of a node.
+func NewClient(node Node) *Client {
+	return &Client{node: node}
+}
+
+// InsertText inserts a text at an offset of a block.
+func (c *Client) InsertText(docID, blockID string, offset int, text string) error {
+	block, err := c.textBlock(docID, blockID)
+	if err != nil {
+		return err
+	}
+	if offset < 0 || offset > len(block.CharIDs) {
+		return fmt.Errorf("offset %d out of the %d characters of block %s", offset, len(block.CharIDs), blockID)
+	}
+	if text == "" {
+		return nil
+	}
+
+	afterID := ""
+	if offset > 0 {
+		afterID = block.CharIDs[offset-1]
+	}
+	_, err = c.save(types.CRDTOperation{
+		Type:       types.CRDTInsertTextType,
+		DocumentID: docID,
+		BlockID:    blockID,
+		Operation:  types.CRDTInsertText{AfterID: afterID, Text: text},
+	})
+	return err
+}
+
+// Delete deletes length characters of a block from an offset.
+func (c *Client) Delete(docID, blockID string, offset, length int) error {
+	block, err := c.textBlock(docID, blockID)
+	if err != nil {
+		return err
+	}
+	charIDs, err := charRange(block, offset, length)
+	if err != nil {
+		return err
+	}
+
+	// consecutive IDs of an origin are deleted with a single operation
+	ops := make([]types.CRDTOperation, 0)
+	for i := 0; i < len(charIDs); {
+		run := 1
+		for i+run < len(charIDs) && followsID(charIDs[i], charIDs[i+run], run) {
+			run++
+		}
+		ops = append(ops, types.CRDTOperation{
+			Type:       types.CRDTDeleteRangeType,
+			DocumentID: docID,
+			BlockID:    blockID,
+			Operation:  types.CRDTDeleteRange{StartID: charIDs[i], Length: uint64(run)},
+		})
+		i += run
+	}
+	_, err = c.save(ops...)
+	return err
+}
+
+// AddBlock adds an empty block of the given type at an index among the
+// children of parentID, or among the root blocks if parentID is empty. It
+// returns the ID of the new block.
+func (c *Client) AddBlock(docID string, blockType types.BlockTypeName, parentID string, index int) (string, error) {
+	blocks, err := c.node.GetDocumentBlocks(docID)
+	if err != nil {
+		return "", err
+	}
+	afterID, err := afterBlock(blocks, parentID, index, "")
+	if err != nil {
+		return "", err
+	}
+
+	result, err := c.save(types.CRDTOperation{
+		Type:       types.CRDTAddBlockType,
+		DocumentID: docID,
+		BlockID:    "1@temp",
+		Operation: types.CRDTAddBlock{
+			AfterBlock:  afterID,
+			ParentBlock: parentID,
+			BlockType:   blockType,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.IDs["1@temp"], nil
+}
+
+// MoveBlock moves a block and its children at an index among the children of
+// parentID, or among the root blocks if parentID is empty. A block cannot be
+// moved under itself or one of its children.
+func (c *Client) MoveBlock(docID, blockID, parentID string, index int) error {
+	blocks, err := c.node.GetDocumentBlocks(docID)
+	if err != nil {
+		return err
+	}
+	block, found := impl.FindBlock(blocks, blockID)
+	if !found {
+		return fmt.Errorf("block %s not found in document %s", blockID, docID)
+	}
+	if _, inside := impl.FindBlock([]types.DocumentBlock{block}, parentID); parentID != "" && inside {
+		return fmt.Errorf("cannot move block %s under itself", blockID)
+	}
+	afterID, err := afterBlock(blocks, parentID, index, blockID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.save(types.CRDTOperation{
+		Type:       types.CRDTUpdateBlockType,
+		DocumentID: docID,
+		BlockID:    blockID,
+		Operation: types.CRDTUpdateBlock{
+			UpdatedBlock: blockID,
+			AfterBlock:   afterID,
+			ParentBlock:  parentID,
+			BlockType:    block.BlockType,
+		},
+	})
+	return err
+}
+
+// SetStyle gives a style to length characters of a block from an offset.
+// Only the marks the characters do not have yet are changed.
+func (c *Client) SetStyle(docID, blockID string, offset, length int, style types.TextStyle) error {
+	block, err := c.textBlock(docID, blockID)
+	if err != nil {
+		return err
+	}
+	charIDs, err := charRange(block, offset, length)
+	if err != nil {
+		return err
+	}
+	if len(charIDs) == 0 {
+		return nil
+	}
+	styles := block.Styles[offset : offset+length]
+	wanted := markOptions(style)
+
+	ops := make([]types.CRDTOperation, 0)
+	for _, markType := range markTypes {
+		options, set := wanted[markType]
+		changed := false
+		for _, current := range styles {
+			currentOptions, currentSet := markOptions(current)[markType]
+			changed = changed || currentSet != set || currentOptions != options
+		}
+		if !changed {
+			continue
+		}
+
+		op := types.CRDTOperation{DocumentID: docID, BlockID: blockID}
+		start := types.MarkStart{Type: "before", OpID: charIDs[0]}
+		end := types.MarkEnd{Type: "after", OpID: charIDs[len(charIDs)-1]}
+		// an empty color is set with an empty option, there is no removal of
+		// a color mark
+		if set || markType == types.TextColor || markType == types.BackgroundColor {
+			op.Type = types.CRDTAddMarkType
+			op.Operation = types.CRDTAddMark{Start: start, End: end, MarkType: markType, Options: options}
+		} else {
+			op.Type = types.CRDTRemoveMarkType
+			op.Operation = types.CRDTRemoveMark{Start: start, End: end, MarkType: markType}
+		}
+		ops = append(ops, op)
+	}
+	_, err = c.save(ops...)
+	return err
+}
+
+// save numbers the operations with temporary IDs and saves them as one
+// transaction. The node has applied the transaction when it returns, the next
+// edit sees it.
+func (c *Client) save(ops ...types.CRDTOperation) (types.TransactionResult, error) {
+	if len(ops) == 0 {
+		return types.TransactionResult{}, nil
+	}
+
+	next := uint64(1)
+	for i := range ops {
+		ops[i].OperationID = next
+		next += impl.OpSpan(ops[i])
+	}
+	return c.node.CommitTransaction(types.CRDTOperationsMessage{Operations: ops})
+}
+
+// textBlock returns a text block of a document.
+func (c *Client) textBlock(docID, blockID string) (types.DocumentBlock, error) {
+	blocks, err := c.node.GetDocumentBlocks(docID)
+	if err != nil {
+		return types.DocumentBlock{}, err
+	}
+	block, found := impl.FindBlock(blocks, blockID)
+	if !found {
+		return types.DocumentBlock{}, fmt.Errorf("block %s not found in document %s", blockID, docID)
+	}
+
+	switch block.BlockType {
+	case types.ParagraphBlockType, types.HeadingBlockType, types.BulletedListBlockType,
+		types.NumberedListBlockType:
+		return block, nil
+	default:
+		return types.DocumentBlock{}, fmt.Errorf("block %s of type %s has no text", blockID, block.BlockType)
+	}
+}
+
+// charRange returns the IDs of length characters of a block from an offset.
+func charRange(block types.DocumentBlock, offset, length int) ([]string, error) {
+	if offset < 0 || length < 0 || offset+length > len(block.CharIDs) {
+		return nil, fmt.Errorf("range [%d, %d) out of the %d characters of block %s",
+			offset, offset+length, len(block.CharIDs), block.ID)
+	}
+	return block.CharIDs[offset : offset+length], nil
+}
+
+// followsID tells if id is the ID of the distance-th character after startID
+// in a run of the same origin.
+func followsID(startID, id string, distance int) bool {
+	start, origin, err := impl.ParseID(startID)
+	next, nextOrigin, nextErr := impl.ParseID(id)
+	return err == nil && nextErr == nil && origin == nextOrigin && next == start+uint64(distance)
+}
+
+// afterBlock returns the block after which a block goes to be at an index
+// among the children of parentID, ignoring the moved block.
+func afterBlock(blocks []types.DocumentBlock, parentID string, index int, movedID string) (string, error) {
+	siblings := blocks
+	if parentID != "" {
+		parent, found := impl.FindBlock(blocks, parentID)
+		if !found {
+			return "", fmt.Errorf("parent block %s not found", parentID)
+		}
+		siblings = parent.Children
+	}
+
+	ids := make([]string, 0, len(siblings))
+	for _, sibling := range siblings {
+		if sibling.ID != movedID {
+			ids = append(ids, sibling.ID)
+		}
+	}
+	if index < 0 || index > len(ids) {
+		return "", fmt.Errorf("index %d out of the %d blocks", index, len(ids))
+	}
+	if index == 0 {
+		return "", nil
+	}
+	return ids[index-1], nil
+}
+
+// markTypes are the types of the marks making up a style.
+var markTypes = []string{
+	types.Bold, types.Italic, types.Underline, types.Strikethrough,
+	types.TextColor, types.BackgroundColor, types.Mention,
+}
+
+// markOptions returns the options of the marks set by a style, by type.
+func markOptions(style types.TextStyle) map[string]types.MarkOptions {
+	options := make(map[string]types.MarkOptions)
+	for _, mark := range impl.StyleMarks(style) {
+		options[mark.MarkType] = mark.Options
+	}
+	return options
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"Node-tion/backend/gui/httpnode"
+	"Node-tion/backend/identity"
+	"Node-tion/backend/oplog"
 	"Node-tion/backend/peer"
 	"Node-tion/backend/peer/impl"
 	"Node-tion/backend/registry/standard"
@@ -21,6 +24,7 @@ import (
 	"Node-tion/backend/storage/inmemory"
 
 	"Node-tion/backend/transport/udp"
+	"Node-tion/backend/workspace"
 	"golang.org/x/xerrors"
 )
 
@@ -139,6 +143,49 @@ func main() {
 						Usage: "The peer's paxos id. Must stat at 1. Can be 0 if total peers <= 1.",
 						Value: 0,
 					},
+					&urfave.StringFlag{
+						Name:  "identity",
+						Usage: "The file of the identity of the node, created if missing. Without it the address is the origin",
+					},
+					&urfave.StringFlag{
+						Name:  "workspace",
+						Usage: "The file of the key of the workspace of the node, created if missing. Without it the node is in no workspace",
+					},
+					&urfave.StringFlag{
+						Name:  "invite",
+						Usage: "An invite token to join the workspace of another node",
+					},
+					&urfave.BoolFlag{
+						Name:  "readonly",
+						Usage: "Runs the node as a viewer, which syncs the documents but never edits them",
+					},
+					&urfave.Float64Flag{
+						Name:  "ratelimit",
+						Usage: "The packets per second accepted from each source and message type. 0 means no limit",
+					},
+					&urfave.UintFlag{
+						Name:  "ratelimitburst",
+						Usage: "The packets accepted at once from each source and message type",
+						Value: 100,
+					},
+					&urfave.UintFlag{
+						Name:  "banthreshold",
+						Usage: "The packets dropped by the rate limit after which a source is banned. 0 means no ban",
+					},
+					&urfave.DurationFlag{
+						Name:  "banduration",
+						Usage: "How long an abusive source is banned",
+						Value: time.Minute,
+					},
+					&urfave.UintFlag{
+						Name:  "ratelimitsources",
+						Usage: "The sources tracked by the rate limit, the least recently seen are forgotten past it",
+						Value: 1024,
+					},
+					&urfave.DurationFlag{
+						Name:  "auditanchor",
+						Usage: "The interval at which the state of the changed documents is anchored. 0 means never",
+					},
 					&urfave.DurationFlag{
 						Name:  "paxosproposerretry",
 						Usage: "The timeout after which a paxos proposer retries",
@@ -147,6 +194,22 @@ func main() {
 				},
 				Action: start,
 			},
+			{
+				Name:  "checklog",
+				Usage: "Checks the operation log of a document, as exported by a node",
+				Flags: []urfave.Flag{
+					&urfave.StringFlag{
+						Name:     "file",
+						Usage:    "The JSON file of the log",
+						Required: true,
+					},
+					&urfave.StringFlag{
+						Name:  "repair",
+						Usage: "Writes to this file the log without its orphaned and duplicated operations",
+					},
+				},
+				Action: checkLog,
+			},
 		},
 
 		Action: func(c *urfave.Context) error {
@@ -223,10 +286,56 @@ func start(c *urfave.Context) error {
 		},
 		PaxosID:            paxosID,
 		PaxosProposerRetry: c.Duration("paxosproposerretry"),
+
+		ReadOnly: c.Bool("readonly"),
+
+		BanThreshold: c.Uint("banthreshold"),
+		BanDuration:  c.Duration("banduration"),
+
+		RateLimitSources: c.Uint("ratelimitsources"),
+
+		AuditAnchorInterval: c.Duration("auditanchor"),
+	}
+
+	if c.Float64("ratelimit") > 0 {
+		conf.RateLimits = map[string]peer.RateLimit{
+			"*": {Rate: c.Float64("ratelimit"), Burst: c.Uint("ratelimitburst")},
+		}
+	}
+
+	if c.String("identity") != "" {
+		id, err := identity.LoadOrCreate(c.String("identity"))
+		if err != nil {
+			return xerrors.Errorf("failed to load identity: %v", err)
+		}
+		conf.Identity = &id
+		conf.Counters, err = identity.LoadCounters(c.String("identity") + ".counters")
+		if err != nil {
+			return xerrors.Errorf("failed to load counters: %v", err)
+		}
+	}
+
+	var invite workspace.Invite
+	switch {
+	case c.String("invite") != "":
+		invite, err = workspace.ParseInvite(c.String("invite"))
+		if err != nil {
+			return xerrors.Errorf("failed to read invite: %v", err)
+		}
+		conf.WorkspaceKey = invite.Secret
+	case c.String("workspace") != "":
+		conf.WorkspaceKey, err = workspace.LoadOrCreateKey(c.String("workspace"))
+		if err != nil {
+			return xerrors.Errorf("failed to load workspace key: %v", err)
+		}
 	}
 
 	node := peerFactory(conf)
 
+	if invite.Address != "" {
+		node.AddPeer(invite.Address)
+	}
+
 	httpnode := httpnode.NewHTTPNode(node, conf)
 
 	notify := make(chan os.Signal, 1)
@@ -255,3 +364,40 @@ func start(c *urfave.Context) error {
 
 	return nil
 }
+
+// checkLog prints the integrity issues of an operation log, and writes the
+// log without its orphans if asked to.
+func checkLog(c *urfave.Context) error {
+	f, err := os.Open(c.String("file"))
+	if err != nil {
+		return xerrors.Errorf("failed to open log: %v", err)
+	}
+	defer f.Close()
+
+	opLog, err := oplog.Load(f)
+	if err != nil {
+		return xerrors.Errorf("failed to load log: %v", err)
+	}
+
+	report := oplog.Check(opLog)
+	fmt.Printf("document %s: %d operations, %d issues\n", report.DocumentID, report.Operations, len(report.Issues))
+	for _, issue := range report.Issues {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", issue.Kind, issue.BlockID, issue.OperationID, issue.Reference, issue.Detail)
+	}
+
+	if c.String("repair") == "" {
+		return nil
+	}
+
+	repaired, dropped := oplog.DropOrphans(opLog)
+	buf, err := json.MarshalIndent(repaired, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("failed to encode log: %v", err)
+	}
+	err = os.WriteFile(c.String("repair"), buf, 0o644)
+	if err != nil {
+		return xerrors.Errorf("failed to write log: %v", err)
+	}
+	fmt.Printf("dropped %d operations\n", len(dropped))
+	return nil
+}
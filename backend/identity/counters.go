@@ -0,0 +1,152 @@
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Counters are the last sequence numbers used by an identity: the sequence
+// of its rumors and the last operation ID it reserved in each document. The
+// peers drop the rumors and the operations whose IDs they already have, so a
+// node going on with the same identity after a restart must go on from them.
+// So must its audit chain in each document, from the hash of its last batch.
+// They are stored in a file next to the identity and saved on every change.
+type Counters struct {
+	mu   sync.Mutex
+	path string
+
+	RumorSeq   uint
+	Documents  map[string]uint64
+	AuditHeads map[string][]byte
+}
+
+// LoadCounters loads the counters stored in a file, or starts from zero if
+// the file does not exist yet.
+func LoadCounters(path string) (*Counters, error) {
+	counters := &Counters{
+		path:       path,
+		Documents:  make(map[string]uint64),
+		AuditHeads: make(map[string][]byte),
+	}
+
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return counters, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read counters: %w", err)
+	}
+
+	err = json.Unmarshal(buf, counters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid counters file %s: %w", path, err)
+	}
+	if counters.Documents == nil {
+		counters.Documents = make(map[string]uint64)
+	}
+	if counters.AuditHeads == nil {
+		counters.AuditHeads = make(map[string][]byte)
+	}
+	return counters, nil
+}
+
+// GetRumorSeq returns the sequence number of the last rumor.
+func (c *Counters) GetRumorSeq() uint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.RumorSeq
+}
+
+// GetDocument returns the last operation ID reserved in a document.
+func (c *Counters) GetDocument(docID string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Documents[docID]
+}
+
+// GetAuditHeads returns the hash of the last audit batch in each document.
+func (c *Counters) GetAuditHeads() map[string][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	heads := make(map[string][]byte, len(c.AuditHeads))
+	for docID, head := range c.AuditHeads {
+		heads[docID] = head
+	}
+	return heads
+}
+
+// SetRumorSeq saves the sequence number of the last rumor.
+func (c *Counters) SetRumorSeq(seq uint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if seq <= c.RumorSeq {
+		return nil
+	}
+	c.RumorSeq = seq
+	return c.save()
+}
+
+// SetDocuments saves the last operation ID reserved in some documents. The
+// counters never go back.
+func (c *Counters) SetDocuments(lastIDs map[string]uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed := false
+	for docID, lastID := range lastIDs {
+		if lastID > c.Documents[docID] {
+			c.Documents[docID] = lastID
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return c.save()
+}
+
+// SetAuditHeads saves the hash of the last audit batch in some documents.
+func (c *Counters) SetAuditHeads(heads map[string][]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(heads) == 0 {
+		return nil
+	}
+	for docID, head := range heads {
+		c.AuditHeads[docID] = head
+	}
+	return c.save()
+}
+
+// save writes the counters to a temporary file renamed over the file, a
+// crash leaves either the old or the new counters.
+func (c *Counters) save() error {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal counters: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("failed to create counters folder: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	err = os.WriteFile(tmp, buf, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to store counters: %w", err)
+	}
+	err = os.Rename(tmp, c.path)
+	if err != nil {
+		return fmt.Errorf("failed to store counters: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package identity manages the persistent identity of a node: an ed25519 key
+// pair generated on the first run and stored locally. The ID of an identity
+// is its encoded public key, it is used as the origin of the CRDT operations
+// and of the rumors so that a node keeps its origin when its transport
+// address changes.
+package identity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/edwards25519"
+)
+
+// encoding encodes the public keys into IDs. Its alphabet has neither "@" nor
+// ":", the IDs can be used wherever an origin is expected.
+var encoding = base64.RawURLEncoding
+
+// Identity is the key pair of a node.
+type Identity struct {
+	ID         string
+	PrivateKey ed25519.PrivateKey
+}
+
+// New returns the identity of a private key.
+func New(key ed25519.PrivateKey) Identity {
+	return Identity{
+		ID:         encoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		PrivateKey: key,
+	}
+}
+
+// Generate returns a new identity.
+func Generate() (Identity, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return New(key), nil
+}
+
+// LoadOrCreate loads the identity stored in a file, or generates one and
+// stores it if the file does not exist yet. The file holds the seed of the
+// private key, readable by its owner only.
+func LoadOrCreate(path string) (Identity, error) {
+	buf, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := encoding.DecodeString(strings.TrimSpace(string(buf)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return Identity{}, fmt.Errorf("invalid identity file %s", path)
+		}
+		return New(ed25519.NewKeyFromSeed(seed)), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return Identity{}, fmt.Errorf("failed to read identity: %w", err)
+	}
+
+	id, err := Generate()
+	if err != nil {
+		return Identity{}, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to create identity folder: %w", err)
+	}
+	err = os.WriteFile(path, []byte(encoding.EncodeToString(id.PrivateKey.Seed())+"\n"), 0o600)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to store identity: %w", err)
+	}
+	return id, nil
+}
+
+// PublicKey returns the public key of the identity.
+func (i Identity) PublicKey() ed25519.PublicKey {
+	return i.PrivateKey.Public().(ed25519.PublicKey)
+}
+
+// Sign signs data with the private key of the identity.
+func (i Identity) Sign(data []byte) []byte {
+	return ed25519.Sign(i.PrivateKey, data)
+}
+
+// PublicKeyOf returns the public key an ID encodes.
+func PublicKeyOf(id string) (ed25519.PublicKey, error) {
+	key, err := encoding.DecodeString(id)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%q is not an identity", id)
+	}
+	return key, nil
+}
+
+// Verify checks the signature of data by the identity with the given ID.
+func Verify(id string, data, signature []byte) error {
+	key, err := PublicKeyOf(id)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, data, signature) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// IsIdentity tells whether an origin is the ID of an identity rather than a
+// transport address.
+func IsIdentity(origin string) bool {
+	_, err := PublicKeyOf(origin)
+	return err == nil
+}
+
+// sealInfo separates the keys derived to seal data from other uses of X25519.
+const sealInfo = "Node-tion sealed box"
+
+// Seal encrypts data so that only the identity with the given ID can open
+// it. The data is encrypted with a key agreed between a new ephemeral X25519
+// key and the X25519 form of the public key of the identity.
+func Seal(id string, data []byte) ([]byte, error) {
+	key, err := PublicKeyOf(id)
+	if err != nil {
+		return nil, err
+	}
+	montgomery, err := montgomeryPublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of %s: %w", id, err)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(montgomery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of %s: %w", id, err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree on a key: %w", err)
+	}
+
+	aead, err := sealAEAD(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := append(ephemeral.PublicKey().Bytes(), nonce...)
+	return aead.Seal(sealed, nonce, data, nil), nil
+}
+
+// Open decrypts data sealed for the identity, see Seal.
+func (i Identity) Open(sealed []byte) ([]byte, error) {
+	digest := sha512.Sum512(i.PrivateKey.Seed())
+	private, err := ecdh.X25519().NewPrivateKey(digest[:32])
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	keySize := len(private.PublicKey().Bytes())
+	if len(sealed) < keySize {
+		return nil, fmt.Errorf("sealed data too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:keySize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("failed to agree on a key: %w", err)
+	}
+
+	aead, err := sealAEAD(shared, ephemeral.Bytes(), private.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	rest := sealed[keySize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed data too short")
+	}
+	data, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed data: %w", err)
+	}
+	return data, nil
+}
+
+// sealAEAD returns the cipher of a sealed box, keyed with the shared secret
+// and both public keys.
+func sealAEAD(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	digest := sha256.New()
+	digest.Write([]byte(sealInfo))
+	digest.Write(shared)
+	digest.Write(ephemeral)
+	digest.Write(recipient)
+
+	block, err := aes.NewCipher(digest.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// montgomeryPublicKey converts an ed25519 public key, a point of the twisted
+// Edwards curve, into the X25519 public key of the same private key.
+func montgomeryPublicKey(key ed25519.PublicKey) ([]byte, error) {
+	point, err := new(edwards25519.Point).SetBytes(key)
+	if err != nil {
+		return nil, err
+	}
+	return point.BytesMontgomery(), nil
+}
@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"testing"
 
+	"Node-tion/backend/identity"
 	"Node-tion/backend/peer"
 	"Node-tion/backend/registry"
 	"Node-tion/backend/registry/standard"
@@ -26,6 +27,7 @@ import (
 
 	"Node-tion/backend/transport"
 	"Node-tion/backend/types"
+	"Node-tion/backend/workspace"
 )
 
 // NewFakeMessage return a new fake message.
@@ -153,6 +155,23 @@ type configTemplate struct {
 	docTimestampThreshold time.Duration
 	docQueueSize          int
 	documentDir           string
+
+	compactionInterval time.Duration
+	documentRetention  time.Duration
+
+	identity          *identity.Identity
+	counters          *identity.Counters
+	requireSignatures bool
+
+	workspaceKey workspace.Key
+	readOnly     bool
+
+	rateLimits       map[string]peer.RateLimit
+	banThreshold     uint
+	banDuration      time.Duration
+	rateLimitSources uint
+
+	auditAnchorInterval time.Duration
 }
 
 func newConfigTemplate() configTemplate {
@@ -191,6 +210,8 @@ func newConfigTemplate() configTemplate {
 		docTimestampThreshold: time.Second * 10,
 		docQueueSize:          10,
 		documentDir:           "documents",
+
+		rateLimitSources: 1024,
 	}
 }
 
@@ -321,6 +342,84 @@ func WithDocumentDir(dir string) Option {
 	}
 }
 
+// WithCompactionInterval sets the interval at which documents are compacted.
+func WithCompactionInterval(d time.Duration) Option {
+	return func(ct *configTemplate) {
+		ct.compactionInterval = d
+	}
+}
+
+// WithDocumentRetention sets how long deleted documents are kept.
+func WithDocumentRetention(d time.Duration) Option {
+	return func(ct *configTemplate) {
+		ct.documentRetention = d
+	}
+}
+
+// WithIdentity sets the persistent identity of the node.
+func WithIdentity(id identity.Identity) Option {
+	return func(ct *configTemplate) {
+		ct.identity = &id
+	}
+}
+
+// WithCounters sets the counters of the identity of the node.
+func WithCounters(counters *identity.Counters) Option {
+	return func(ct *configTemplate) {
+		ct.counters = counters
+	}
+}
+
+// WithRequireSignatures requires every operation and rumor to be signed.
+func WithRequireSignatures() Option {
+	return func(ct *configTemplate) {
+		ct.requireSignatures = true
+	}
+}
+
+// WithWorkspaceKey sets the key of the workspace of the node.
+func WithWorkspaceKey(key workspace.Key) Option {
+	return func(ct *configTemplate) {
+		ct.workspaceKey = key
+	}
+}
+
+// WithReadOnly makes the node a viewer.
+func WithReadOnly() Option {
+	return func(ct *configTemplate) {
+		ct.readOnly = true
+	}
+}
+
+// WithRateLimits sets the rate limits of the sources, per type of message.
+func WithRateLimits(limits map[string]peer.RateLimit) Option {
+	return func(ct *configTemplate) {
+		ct.rateLimits = limits
+	}
+}
+
+// WithBan bans the sources that drop threshold packets for the given duration.
+func WithBan(threshold uint, d time.Duration) Option {
+	return func(ct *configTemplate) {
+		ct.banThreshold = threshold
+		ct.banDuration = d
+	}
+}
+
+// WithRateLimitSources sets the number of sources tracked by the rate limits.
+func WithRateLimitSources(n uint) Option {
+	return func(ct *configTemplate) {
+		ct.rateLimitSources = n
+	}
+}
+
+// WithAuditAnchorInterval sets the interval at which documents are anchored.
+func WithAuditAnchorInterval(d time.Duration) Option {
+	return func(ct *configTemplate) {
+		ct.auditAnchorInterval = d
+	}
+}
+
 // NewTestNode returns a new test node.
 func NewTestNode(t require.TestingT, f peer.Factory, trans transport.Transport,
 	addr string, opts ...Option) TestNode {
@@ -351,6 +450,18 @@ func NewTestNode(t require.TestingT, f peer.Factory, trans transport.Transport,
 	config.DocTimestampThreshold = template.docTimestampThreshold
 	config.DocQueueSize = template.docQueueSize
 	config.DocumentDir = template.documentDir
+	config.CompactionInterval = template.compactionInterval
+	config.DocumentRetention = template.documentRetention
+	config.Identity = template.identity
+	config.Counters = template.counters
+	config.RequireSignatures = template.requireSignatures
+	config.WorkspaceKey = template.workspaceKey
+	config.ReadOnly = template.readOnly
+	config.RateLimits = template.rateLimits
+	config.BanThreshold = template.banThreshold
+	config.BanDuration = template.banDuration
+	config.RateLimitSources = template.rateLimitSources
+	config.AuditAnchorInterval = template.auditAnchorInterval
 
 	node := f(config)
 
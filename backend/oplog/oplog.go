@@ -0,0 +1,441 @@
+// Package oplog checks the operation log of a document offline. A log is a
+// types.DocumentSnapshot: the operations of the document keyed like in the
+// editor of a peer, the block snapshots left by the compactions and the
+// version vector of the operations.
+package oplog
+
+import (
+	"Node-tion/backend/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// operationTypes gives an empty operation of each type, to decode the
+// operations of a log.
+var operationTypes = map[string]func() interface{}{
+	types.CRDTAddBlockType:    func() interface{} { return &types.CRDTAddBlock{} },
+	types.CRDTRemoveBlockType: func() interface{} { return &types.CRDTRemoveBlock{} },
+	types.CRDTUpdateBlockType: func() interface{} { return &types.CRDTUpdateBlock{} },
+	types.CRDTInsertCharType:  func() interface{} { return &types.CRDTInsertChar{} },
+	types.CRDTDeleteCharType:  func() interface{} { return &types.CRDTDeleteChar{} },
+	types.CRDTAddMarkType:     func() interface{} { return &types.CRDTAddMark{} },
+	types.CRDTRemoveMarkType:  func() interface{} { return &types.CRDTRemoveMark{} },
+	types.CRDTInsertTextType:  func() interface{} { return &types.CRDTInsertText{} },
+	types.CRDTDeleteRangeType: func() interface{} { return &types.CRDTDeleteRange{} },
+	types.CRDTSetMetadataType: func() interface{} { return &types.CRDTSetMetadata{} },
+	types.CRDTSetStatusType:   func() interface{} { return &types.CRDTSetStatus{} },
+	types.CRDTMovePageType:    func() interface{} { return &types.CRDTMovePage{} },
+	types.CRDTSetPropertyType: func() interface{} { return &types.CRDTSetProperty{} },
+	types.CRDTSetCellType:     func() interface{} { return &types.CRDTSetCell{} },
+	types.CRDTSetAccessType:   func() interface{} { return &types.CRDTSetAccess{} },
+}
+
+// Load reads a log encoded in JSON and decodes its operations.
+func Load(r io.Reader) (types.DocumentSnapshot, error) {
+	var log types.DocumentSnapshot
+	err := json.NewDecoder(r).Decode(&log)
+	if err != nil {
+		return log, fmt.Errorf("failed to decode log: %w", err)
+	}
+	if log.DocumentID == "" {
+		return log, fmt.Errorf("log without document ID")
+	}
+
+	for blockID, ops := range log.Operations {
+		for i := range ops {
+			err = decodeOperation(&ops[i])
+			if err != nil {
+				return log, fmt.Errorf("operation %s in %s: %w", opID(ops[i]), blockID, err)
+			}
+		}
+	}
+	return log, nil
+}
+
+// decodeOperation replaces the generic content of an operation by the
+// operation of its type.
+func decodeOperation(op *types.CRDTOperation) error {
+	newOp, known := operationTypes[op.Type]
+	if !known {
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+
+	buf, err := json.Marshal(op.Operation)
+	if err != nil {
+		return err
+	}
+	target := newOp()
+	err = json.Unmarshal(buf, target)
+	if err != nil {
+		return err
+	}
+
+	switch crdtOp := target.(type) {
+	case *types.CRDTAddBlock:
+		op.Operation = *crdtOp
+	case *types.CRDTRemoveBlock:
+		op.Operation = *crdtOp
+	case *types.CRDTUpdateBlock:
+		op.Operation = *crdtOp
+	case *types.CRDTInsertChar:
+		op.Operation = *crdtOp
+	case *types.CRDTDeleteChar:
+		op.Operation = *crdtOp
+	case *types.CRDTAddMark:
+		op.Operation = *crdtOp
+	case *types.CRDTRemoveMark:
+		op.Operation = *crdtOp
+	case *types.CRDTInsertText:
+		op.Operation = *crdtOp
+	case *types.CRDTDeleteRange:
+		op.Operation = *crdtOp
+	case *types.CRDTSetMetadata:
+		op.Operation = *crdtOp
+	case *types.CRDTSetStatus:
+		op.Operation = *crdtOp
+	case *types.CRDTMovePage:
+		op.Operation = *crdtOp
+	case *types.CRDTSetProperty:
+		op.Operation = *crdtOp
+	case *types.CRDTSetCell:
+		op.Operation = *crdtOp
+	case *types.CRDTSetAccess:
+		op.Operation = *crdtOp
+	}
+	return nil
+}
+
+// finding is an issue together with the position of its operation in the
+// log.
+type finding struct {
+	issue types.IntegrityIssue
+	key   string
+	index int
+}
+
+// Check reports the dangling references, the duplicated IDs, the gaps in the
+// per-origin sequences and the blocks whose parent chain loops. The gaps are
+// only looked for in logs that were never compacted.
+func Check(log types.DocumentSnapshot) types.IntegrityReport {
+	report := types.IntegrityReport{DocumentID: log.DocumentID}
+	for _, ops := range log.Operations {
+		report.Operations += len(ops)
+	}
+	for _, f := range check(log) {
+		report.Issues = append(report.Issues, f.issue)
+	}
+	return report
+}
+
+// DropOrphans returns a copy of the log without the duplicated operations and
+// the operations with dangling references, together with the issues of the
+// dropped operations. Dropping an operation can orphan the ones referencing
+// it, they are dropped as well.
+func DropOrphans(log types.DocumentSnapshot) (types.DocumentSnapshot, []types.IntegrityIssue) {
+	repaired := log
+	repaired.Operations = make(map[string][]types.CRDTOperation, len(log.Operations))
+	for blockID, ops := range log.Operations {
+		repaired.Operations[blockID] = append([]types.CRDTOperation(nil), ops...)
+	}
+
+	var dropped []types.IntegrityIssue
+	for {
+		drop := make(map[string]map[int]struct{})
+		for _, f := range check(repaired) {
+			if f.issue.Kind != types.DanglingReferenceIssue && f.issue.Kind != types.DuplicateIDIssue {
+				continue
+			}
+			if _, exists := drop[f.key]; !exists {
+				drop[f.key] = make(map[int]struct{})
+			}
+			if _, done := drop[f.key][f.index]; !done {
+				drop[f.key][f.index] = struct{}{}
+				dropped = append(dropped, f.issue)
+			}
+		}
+		if len(drop) == 0 {
+			return repaired, dropped
+		}
+
+		for key, indexes := range drop {
+			ops := repaired.Operations[key]
+			kept := make([]types.CRDTOperation, 0, len(ops)-len(indexes))
+			for i, op := range ops {
+				if _, dropped := indexes[i]; !dropped {
+					kept = append(kept, op)
+				}
+			}
+			repaired.Operations[key] = kept
+		}
+	}
+}
+
+// RequestVersion returns the version vector to send in a sync request so
+// that the peers send back the operations a report found missing: the
+// version of the origin of each missing ID is lowered below it. The origin of
+// the predecessor of a gap is unknown, every origin is lowered below it.
+func RequestVersion(log types.DocumentSnapshot, report types.IntegrityReport) types.VersionVector {
+	vv := make(types.VersionVector, len(log.VersionVector))
+	for origin, version := range log.VersionVector {
+		vv[origin] = version
+	}
+	lower := func(origin string, id uint64) {
+		// an origin missing from the vector is sent from its first ID
+		if version, exists := vv[origin]; !exists || version < id || id == 0 {
+			return
+		}
+		vv[origin] = id - 1
+	}
+
+	for _, issue := range report.Issues {
+		switch issue.Kind {
+		case types.DanglingReferenceIssue:
+			id, origin, err := parseID(issue.Reference)
+			if err == nil {
+				lower(origin, id)
+			}
+		case types.SequenceGapIssue:
+			id, err := strconv.ParseUint(issue.Reference, 10, 64)
+			if err != nil {
+				continue
+			}
+			for origin := range vv {
+				lower(origin, id)
+			}
+		}
+	}
+	return vv
+}
+
+// check returns the issues of a log in a deterministic order.
+func check(log types.DocumentSnapshot) []finding {
+	docID := log.DocumentID
+	keys := make([]string, 0, len(log.Operations))
+	for key := range log.Operations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []finding
+	report := func(kind, key string, index int, ref, detail string) {
+		issue := types.IntegrityIssue{
+			Kind:        kind,
+			BlockID:     key,
+			OperationID: opID(log.Operations[key][index]),
+			Reference:   ref,
+			Detail:      detail,
+		}
+		findings = append(findings, finding{issue: issue, key: key, index: index})
+	}
+
+	// the IDs given by the operations and the snapshots, the blocks of the
+	// document and the characters of each block
+	ids := make(map[uint64]struct{})
+	owners := make(map[string]string)
+	blocks := make(map[string]struct{})
+	chars := make(map[string]map[string]struct{})
+	addChar := func(blockID, charID string) {
+		if _, exists := chars[blockID]; !exists {
+			chars[blockID] = make(map[string]struct{})
+		}
+		chars[blockID][charID] = struct{}{}
+	}
+
+	for blockID, snapshot := range log.Blocks {
+		blocks[blockID] = struct{}{}
+		for _, run := range snapshot.Runs {
+			start, origin, err := parseID(run.StartID)
+			if err != nil {
+				continue
+			}
+			for i := 0; i < len(run.Text); i++ {
+				ids[start+uint64(i)] = struct{}{}
+				addChar(blockID, fmt.Sprintf("%d@%s", start+uint64(i), origin))
+			}
+		}
+		for charID := range snapshot.Anchors {
+			addChar(blockID, charID)
+		}
+	}
+
+	for _, key := range keys {
+		for i, op := range log.Operations[key] {
+			duplicate := false
+			for k := uint64(0); k < span(op); k++ {
+				id := fmt.Sprintf("%d@%s", op.OperationID+k, op.Origin)
+				if owner, exists := owners[id]; exists && !duplicate {
+					report(types.DuplicateIDIssue, key, i, id, fmt.Sprintf("ID already given by an operation of %s", owner))
+					duplicate = true
+				}
+				owners[id] = key
+				ids[op.OperationID+k] = struct{}{}
+
+				switch op.Operation.(type) {
+				case types.CRDTInsertChar, types.CRDTInsertText:
+					addChar(key, id)
+				}
+			}
+			if _, isAdd := op.Operation.(types.CRDTAddBlock); isAdd && key == docID {
+				blocks[opID(op)] = struct{}{}
+			}
+		}
+	}
+
+	// dangling references
+	for _, key := range keys {
+		if key == types.MetadataBlockID {
+			continue
+		}
+		for i, op := range log.Operations[key] {
+			refs := make([][2]string, 0, 3)
+			ref := func(field, id string) {
+				if id != "" {
+					refs = append(refs, [2]string{field, id})
+				}
+			}
+
+			if key == docID {
+				switch crdtOp := op.Operation.(type) {
+				case types.CRDTAddBlock:
+					ref("AfterBlock", crdtOp.AfterBlock)
+					ref("ParentBlock", crdtOp.ParentBlock)
+				case types.CRDTRemoveBlock:
+					ref("RemovedBlock", crdtOp.RemovedBlock)
+				case types.CRDTUpdateBlock:
+					ref("BlockID", op.BlockID)
+					ref("AfterBlock", crdtOp.AfterBlock)
+					ref("ParentBlock", crdtOp.ParentBlock)
+				}
+				for _, r := range refs {
+					if _, exists := blocks[r[1]]; !exists {
+						report(types.DanglingReferenceIssue, key, i, r[1], fmt.Sprintf("%s: unknown block", r[0]))
+					}
+				}
+				continue
+			}
+
+			if _, exists := blocks[key]; !exists {
+				report(types.DanglingReferenceIssue, key, i, key, "BlockID: unknown block")
+				continue
+			}
+			switch crdtOp := op.Operation.(type) {
+			case types.CRDTInsertChar:
+				ref("AfterID", crdtOp.AfterID)
+			case types.CRDTInsertText:
+				ref("AfterID", crdtOp.AfterID)
+			case types.CRDTDeleteChar:
+				ref("RemovedID", crdtOp.RemovedID)
+			case types.CRDTDeleteRange:
+				ref("StartID", crdtOp.StartID)
+			case types.CRDTAddMark:
+				ref("Start", crdtOp.Start.OpID)
+				ref("End", crdtOp.End.OpID)
+			case types.CRDTRemoveMark:
+				ref("Start", crdtOp.Start.OpID)
+				ref("End", crdtOp.End.OpID)
+			}
+			for _, r := range refs {
+				if _, exists := chars[key][r[1]]; !exists {
+					report(types.DanglingReferenceIssue, key, i, r[1], fmt.Sprintf("%s: unknown character", r[0]))
+				}
+			}
+		}
+	}
+
+	// the IDs of a document are Lamport timestamps: an origin only uses the
+	// ID n once it has seen the ID n-1, from whatever origin
+	if !log.Compacted {
+		for _, key := range keys {
+			for i, op := range log.Operations[key] {
+				if op.OperationID <= 1 {
+					continue
+				}
+				if _, exists := ids[op.OperationID-1]; !exists {
+					predecessor := strconv.FormatUint(op.OperationID-1, 10)
+					report(types.SequenceGapIssue, key, i, predecessor, "no operation has the preceding ID")
+				}
+			}
+		}
+	}
+
+	// parent loops, the block operations are replayed in the order they are
+	// applied
+	blockOps := append([]types.CRDTOperation(nil), log.Operations[docID]...)
+	sort.SliceStable(blockOps, func(i, j int) bool {
+		if blockOps[i].OperationID != blockOps[j].OperationID {
+			return blockOps[i].OperationID < blockOps[j].OperationID
+		}
+		return blockOps[i].Origin < blockOps[j].Origin
+	})
+	parents := make(map[string]string)
+	for _, op := range blockOps {
+		switch crdtOp := op.Operation.(type) {
+		case types.CRDTAddBlock:
+			parents[opID(op)] = crdtOp.ParentBlock
+		case types.CRDTUpdateBlock:
+			parents[op.BlockID] = crdtOp.ParentBlock
+		}
+	}
+	var looping []string
+	for blockID := range parents {
+		visited := make(map[string]struct{})
+		for parent := parents[blockID]; parent != ""; parent = parents[parent] {
+			if parent == blockID {
+				looping = append(looping, blockID)
+				break
+			}
+			if _, seen := visited[parent]; seen {
+				break
+			}
+			visited[parent] = struct{}{}
+		}
+	}
+	sort.Strings(looping)
+	for _, blockID := range looping {
+		findings = append(findings, finding{
+			issue: types.IntegrityIssue{
+				Kind:        types.ParentLoopIssue,
+				BlockID:     docID,
+				OperationID: blockID,
+				Reference:   parents[blockID],
+				Detail:      "the parent chain of the block loops",
+			},
+			key:   docID,
+			index: -1,
+		})
+	}
+
+	return findings
+}
+
+// span returns the number of IDs given by an operation.
+func span(op types.CRDTOperation) uint64 {
+	if insertOp, ok := op.Operation.(types.CRDTInsertText); ok {
+		if length := uint64(len([]rune(insertOp.Text))); length > 1 {
+			return length
+		}
+	}
+	return 1
+}
+
+// opID returns the ID of an operation.
+func opID(op types.CRDTOperation) string {
+	return fmt.Sprintf("%d@%s", op.OperationID, op.Origin)
+}
+
+// parseID splits an ID into its operation ID and its origin.
+func parseID(id string) (uint64, string, error) {
+	parts := strings.SplitN(id, "@", 2)
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("invalid ID %q", id)
+	}
+	n, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid ID %q: %w", id, err)
+	}
+	return n, parts[1], nil
+}
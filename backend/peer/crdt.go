@@ -1,6 +1,9 @@
 package peer
 
-import "Node-tion/backend/types"
+import (
+	"Node-tion/backend/types"
+	"time"
+)
 
 // CRDT defines the interface for a Conflict-free CRDT update.
 type CRDT interface {
@@ -16,6 +19,10 @@ type CRDT interface {
 	// CompileDocument compiles the document requested from the editor into a json string.
 	CompileDocument(docID string) (string, error)
 
+	// GetDocumentBlocks returns the tree of the visible blocks of a document
+	// with the characters of their text.
+	GetDocumentBlocks(docID string) ([]types.DocumentBlock, error)
+
 	// GetBlockOps returns the block of the CRDT.
 	GetBlockOps(docID, blockID string) []types.CRDTOperation
 
@@ -25,13 +32,231 @@ type CRDT interface {
 	// StoreDocument stores the document as a text file in a directory.
 	StoreDocument(docID, doc string) error
 
-	// SaveTransactions saves a list of CRDT operations.
+	// IsReadOnly tells whether the node is a viewer, see
+	// Configuration.ReadOnly. Its transactions and the changes of keys and
+	// members are refused.
+	IsReadOnly() bool
+
+	// SaveTransactions saves a list of CRDT operations, see CommitTransaction.
 	SaveTransactions(transactions types.CRDTOperationsMessage) error
 
+	// CommitTransaction validates a list of CRDT operations numbered with
+	// temporary IDs, gives them their real IDs and broadcasts them. The
+	// temporary IDs are only known to the transaction. It returns the real ID
+	// of every temporary ID, or a types.TransactionError naming the first
+	// invalid operation, in which case nothing is saved.
+	CommitTransaction(transactions types.CRDTOperationsMessage) (types.TransactionResult, error)
+
+	// GetQuarantinedOperations returns the last operations received from
+	// other peers and rejected by the validation, with the reason of their
+	// rejection.
+	GetQuarantinedOperations() []types.QuarantinedOperation
+
+	// ClearQuarantine drops the rejected operations.
+	ClearQuarantine()
+
+	// EncryptDocument gives a new key to a document. Its operations are sent
+	// encrypted from now on, only the collaborators holding the key can read
+	// and apply them, see ShareDocumentKey.
+	EncryptDocument(docID string) error
+
+	// ShareDocumentKey sends the key of an encrypted document to
+	// collaborators, given by the IDs of their identities. The key is sealed
+	// for each of them and sent within a PrivateMessage.
+	ShareDocumentKey(docID string, collaborators ...string) error
+
+	// IsDocumentEncrypted tells whether the node holds the key of a document.
+	IsDocumentEncrypted(docID string) bool
+
+	// ShareDocument gives a role to a member of a document, given by its
+	// origin, see types.DocumentACL. Once a document has members, only they
+	// receive its operations, and the operations of the members without the
+	// role they need are rejected.
+	ShareDocument(docID, member, role string) error
+
+	// RevokeDocumentAccess removes a member from a document.
+	RevokeDocumentAccess(docID, member string) error
+
+	// GetDocumentACL returns the access list of a document.
+	GetDocumentACL(docID string) types.DocumentACL
+
+	// RemoveDocumentMember revokes the access of a member of a document. The
+	// key of an encrypted document is rotated first and sent to the other
+	// members only, the removed member cannot read the operations after it.
+	RemoveDocumentMember(docID, member string) error
+
+	// GetMembershipLog returns the access operations applied to a document,
+	// chained by their hashes, see types.MembershipEntry.
+	GetMembershipLog(docID string) []types.MembershipEntry
+
+	// VerifyMembershipLog checks the chain and the signatures of a membership
+	// log.
+	VerifyMembershipLog(entries []types.MembershipEntry) error
+
+	// SetDocumentPrivate marks a document as private, or public again. The
+	// operations of a private document are not gossiped but unicast to its
+	// members until they acknowledge them, the other peers never receive
+	// them. Only its owners can change it.
+	SetDocumentPrivate(docID string, private bool) error
+
+	// GetAuditLog returns the audit log of a document: the batches of
+	// operations of each author, each chaining the previous one of its author.
+	GetAuditLog(docID string) ([]types.AuditBatch, error)
+
+	// VerifyAuditLog checks an audit log, e.g. exported by another peer: the
+	// chain of each author, the signatures of the operations and the hashes
+	// of the batches.
+	VerifyAuditLog(batches []types.AuditBatch) error
+
+	// ExportAuditReport returns the audit log of a document with the summary
+	// of each author, the state hash and anchors of the document and the
+	// problems found.
+	ExportAuditReport(docID string) (types.AuditReport, error)
+
+	// AnchorDocument tags the state hash of a document, see Tag, so that it
+	// is agreed on with Paxos and kept in the blockchain.
+	AnchorDocument(docID string) (types.AuditAnchor, error)
+
 	// GetCRDTState returns the CRDT state of the document.
 	GetCRDTState(docID string) uint64
 
-	GetTmpID(id uint64) uint64
+	// CompactDocument folds the causally stable operations of a document into
+	// block snapshots, drops the tombstones no operation references anymore
+	// and the blocks whose removal is stable.
+	CompactDocument(docID string) (types.CompactionStats, error)
+
+	// GetCompactionStats returns the statistics of the last compaction of a
+	// document.
+	GetCompactionStats(docID string) types.CompactionStats
+
+	// ExportDocumentLog returns the operation log of a document as it is
+	// stored, without compacting it first.
+	ExportDocumentLog(docID string) (types.DocumentSnapshot, error)
+
+	// CheckDocument reports the integrity issues of the operation log of a
+	// document, see oplog.Check.
+	CheckDocument(docID string) (types.IntegrityReport, error)
+
+	// RepairDocument asks the neighbors for the operations missing from the
+	// log of a document and waits for them up to the timeout, and/or drops
+	// the orphaned operations, depending on the options. It returns the
+	// issues left.
+	RepairDocument(docID string, opts types.RepairOptions, timeout time.Duration) (types.IntegrityReport, error)
+
+	// GetVersionVector returns, per origin, the highest operation ID applied
+	// to the document.
+	GetVersionVector(docID string) types.VersionVector
+
+	// BootstrapDocument asks a peer for the compacted state of a document,
+	// downloads it through the chunked data sharing, installs it, and then
+	// asks the peer for the operations newer than the snapshot. Local
+	// operations not covered by the snapshot are kept.
+	BootstrapDocument(docID string, peerAddr string, timeout time.Duration) error
+
+	// SetDocumentMetadata sets a metadata field of a document, see the
+	// types.Metadata* fields.
+	SetDocumentMetadata(docID, field, value string) error
+
+	// GetDocumentMetadata returns the merged metadata of a document.
+	GetDocumentMetadata(docID string) types.DocumentSummary
+
+	// GetDocumentSummaries returns the documents of GetDocumentList with their
+	// metadata.
+	GetDocumentSummaries() ([]types.DocumentSummary, error)
+
+	// ArchiveDocument hides a document on every peer until it is restored.
+	ArchiveDocument(docID string) error
+
+	// DeleteDocument hides a document on every peer. It can be restored until
+	// the DocumentRetention expires, then it is purged.
+	DeleteDocument(docID string) error
+
+	// RestoreDocument makes an archived or deleted document visible again.
+	RestoreDocument(docID string) error
+
+	// GetDocumentSummariesByStatus returns the documents with the given
+	// status, e.g. to list the archived or deleted ones.
+	GetDocumentSummariesByStatus(status string) ([]types.DocumentSummary, error)
+
+	// PurgeExpiredDocuments purges the deleted documents whose retention
+	// expired and returns their IDs.
+	PurgeExpiredDocuments() ([]string, error)
+
+	// CreateSubpage creates a new document with the given title as the last
+	// subpage of parentID, or as a root page if parentID is empty, and returns
+	// its ID.
+	CreateSubpage(parentID, title string) (string, error)
+
+	// MovePage moves a page and its subpages under parentID, the root if
+	// empty, at the given index among the other subpages. Moving a page under
+	// itself or one of its subpages fails.
+	MovePage(docID, parentID string, index int) error
+
+	// GetDocumentList returns the active documents in the depth-first order
+	// of the page tree.
+	GetDocumentList() ([]string, error)
+
+	// GetBacklinks returns the page mentions and the transclusions that
+	// reference a document from the active documents.
+	GetBacklinks(docID string) ([]types.Backlink, error)
+
+	// CreateDatabase creates a database document with the given title and
+	// returns its ID.
+	CreateDatabase(title string) (string, error)
+
+	// AddDatabaseProperty adds a typed property to the schema of a database
+	// and returns its ID, the ID of the given property is ignored.
+	AddDatabaseProperty(dbID string, property types.DatabaseProperty) (string, error)
+
+	// SetDatabaseProperty renames, retypes or changes the options of a
+	// property. Cells that do not fit the new type are read as empty.
+	SetDatabaseProperty(dbID string, property types.DatabaseProperty) error
+
+	// RemoveDatabaseProperty removes a property from the schema of a database.
+	RemoveDatabaseProperty(dbID, propertyID string) error
+
+	// GetDatabaseSchema returns the properties of a database in the order they
+	// were added.
+	GetDatabaseSchema(dbID string) ([]types.DatabaseProperty, error)
+
+	// AddDatabaseRow creates a row document in a database and returns its ID.
+	AddDatabaseRow(dbID, title string) (string, error)
+
+	// SetDatabaseCell sets the value of a property for a row, see the
+	// types.Property* types for the format of the values. The empty value
+	// clears the cell.
+	SetDatabaseCell(rowID, propertyID, value string) error
+
+	// QueryDatabase returns the active rows of a database that match the
+	// filters of the query, ordered by its sorts.
+	QueryDatabase(dbID string, query types.DatabaseQuery) ([]types.DatabaseRow, error)
+
+	// GetPageTree returns the tree of the active pages. A page whose parent
+	// is not known yet is shown at the root.
+	GetPageTree() ([]types.PageNode, error)
+
+	// SearchDocuments returns the blocks of the active documents that match
+	// a query, best first. A query is made of words, "quoted phrases" and
+	// prefixes ending with "*", all of which must match. The title of a
+	// document matches with an empty block ID. A limit of 0 returns all the
+	// hits.
+	SearchDocuments(query string, limit int) ([]types.SearchHit, error)
+
+	// SearchNetwork returns the documents of the node and of the other peers
+	// that match a full-text query, with the peers hosting them. The search
+	// request is sent with an expanding ring: the budget grows until a peer
+	// has a matching document or the retries are exhausted.
+	SearchNetwork(query string, conf ExpandingRing) ([]types.DocumentSearchResult, error)
+
+	// FindInDocuments returns the occurrences of a text or of a regular
+	// expression in the blocks of a document, or of the active documents.
+	FindInDocuments(opts types.FindOptions) ([]types.TextMatch, error)
+
+	// ReplaceInDocuments replaces the occurrences found by FindInDocuments
+	// with a text, where $1 stands for the first group of a regular
+	// expression. The replacement keeps the marks of the replaced text and is
+	// sent to the other peers. It returns the number of replaced occurrences.
+	ReplaceInDocuments(opts types.FindOptions, replacement string) (int, error)
 }
 
 // Editor tells, for a given document referenced by a key, a bag of blocks
@@ -0,0 +1,186 @@
+package impl
+
+import (
+	"Node-tion/backend/identity"
+	"Node-tion/backend/types"
+	"fmt"
+)
+
+// roleRanks orders the roles, a role grants everything a lower role grants.
+var roleRanks = map[string]int{
+	types.RoleViewer:    1,
+	types.RoleCommenter: 2,
+	types.RoleEditor:    3,
+	types.RoleOwner:     4,
+}
+
+// ShareDocument implements peer.CRDT
+func (n *node) ShareDocument(docID, member, role string) error {
+	if roleRanks[role] == 0 {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return n.setAccess(docID, member, role)
+}
+
+// RevokeDocumentAccess implements peer.CRDT
+func (n *node) RevokeDocumentAccess(docID, member string) error {
+	return n.setAccess(docID, member, "")
+}
+
+// setAccess saves the role of a member of a document.
+func (n *node) setAccess(docID, member, role string) error {
+	return n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			Type:        types.CRDTSetAccessType,
+			OperationID: 1,
+			DocumentID:  docID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   types.CRDTSetAccess{Member: member, Role: role},
+		}},
+	})
+}
+
+// GetDocumentACL implements peer.CRDT
+func (n *node) GetDocumentACL(docID string) types.DocumentACL {
+	return documentACL(docID, n.GetBlockOps(docID, types.MetadataBlockID))
+}
+
+// documentACL returns the access list given by the metadata operations of a
+// document.
+func documentACL(docID string, metadataOps []types.CRDTOperation) types.DocumentACL {
+	acl := types.DocumentACL{
+		DocumentID: docID,
+		Creator:    documentCreator(docID),
+		Members:    make(map[string]string),
+	}
+
+	for _, op := range lwwRegisters(metadataOps) {
+		accessOp, ok := op.Operation.(types.CRDTSetAccess)
+		if !ok || accessOp.Role == "" {
+			continue
+		}
+		acl.Members[accessOp.Member] = accessOp.Role
+	}
+
+	if len(acl.Members) > 0 && acl.Creator != "" {
+		acl.Members[acl.Creator] = types.RoleOwner
+	}
+	return acl
+}
+
+// documentCreator returns the origin that created a document, given by the ID
+// of the document, or "" if the ID is opaque.
+func documentCreator(docID string) string {
+	_, origin, err := ParseID(docID)
+	if err != nil {
+		return ""
+	}
+	return origin
+}
+
+// validateAccess checks the content of an access operation.
+func validateAccess(docID string, op types.CRDTSetAccess) error {
+	switch {
+	case op.Member == "":
+		return fmt.Errorf("missing member")
+	case op.Role != "" && roleRanks[op.Role] == 0:
+		return fmt.Errorf("unknown role %q", op.Role)
+	case op.Member == documentCreator(docID) && op.Role != types.RoleOwner:
+		return fmt.Errorf("the creator of a document stays its owner")
+	}
+	return nil
+}
+
+// roleOf returns the role of an origin in an access list. Without members,
+// every origin can edit the document, and its creator, or anyone if it has
+// none, can restrict it.
+func roleOf(acl types.DocumentACL, origin string) string {
+	switch {
+	case origin == acl.Creator:
+		return types.RoleOwner
+	case len(acl.Members) > 0:
+		return acl.Members[origin]
+	case acl.Creator == "":
+		return types.RoleOwner
+	default:
+		return types.RoleEditor
+	}
+}
+
+// requiredRole returns the role needed to send an operation.
+func requiredRole(crdtOp types.CRDTOp) string {
+	switch op := crdtOp.(type) {
+	case types.CRDTSetAccess:
+		return types.RoleOwner
+	case types.CRDTSetMetadata:
+		if op.Field == types.MetadataPrivate {
+			return types.RoleOwner
+		}
+		return types.RoleEditor
+	case types.CRDTAddMark, types.CRDTRemoveMark:
+		return types.RoleCommenter
+	default:
+		return types.RoleEditor
+	}
+}
+
+// checkAccess checks that an origin can send an operation on a document.
+func (n *node) checkAccess(docID, origin string, crdtOp types.CRDTOp) error {
+	required := requiredRole(crdtOp)
+	if roleRanks[roleOf(n.GetDocumentACL(docID), origin)] < roleRanks[required] {
+		return fmt.Errorf("%s has no %s access to document %s", origin, required, docID)
+	}
+	return nil
+}
+
+// canRead tells whether an origin can read a document.
+func (n *node) canRead(docID, origin string) bool {
+	return roleOf(n.GetDocumentACL(docID), origin) != ""
+}
+
+// canSync tells whether the operations of a document can be sent to an
+// origin asking for them. A private document is only sent to its members,
+// even if it has none yet.
+func (n *node) canSync(docID, origin string) bool {
+	if n.isPrivate(docID, nil) {
+		acl := n.GetDocumentACL(docID)
+		if _, member := acl.Members[origin]; !member && origin != acl.Creator {
+			return false
+		}
+	}
+	return n.canRead(docID, origin)
+}
+
+// memberAddresses returns the addresses of the members of a restricted
+// document, including the ones the operations about to be sent grant access
+// to, and ours. It returns false if the document is not restricted.
+func (n *node) memberAddresses(docID string, ops []types.CRDTOperation) (map[string]struct{}, bool) {
+	acl := n.GetDocumentACL(docID)
+	members := make([]string, 0, len(acl.Members)+len(ops))
+	for member := range acl.Members {
+		members = append(members, member)
+	}
+	for _, op := range ops {
+		if accessOp, ok := op.Operation.(types.CRDTSetAccess); ok && accessOp.Role != "" {
+			members = append(members, accessOp.Member)
+			if acl.Creator != "" {
+				members = append(members, acl.Creator)
+			}
+		}
+	}
+	if len(members) == 0 {
+		return nil, false
+	}
+
+	addrs := map[string]struct{}{n.conf.Socket.GetAddress(): {}}
+	for _, member := range members {
+		if !identity.IsIdentity(member) {
+			addrs[member] = struct{}{}
+			continue
+		}
+		if addr, known := n.ResolveIdentity(member); known {
+			addrs[addr] = struct{}{}
+		}
+	}
+	return addrs, true
+}
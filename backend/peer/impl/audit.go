@@ -0,0 +1,614 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuditLog keeps, per document, the batches of operations of each author, the
+// operations applied without a batch and the anchors of the document. It also
+// holds the hash of our last batch in each document, which our next batch
+// follows.
+type AuditLog struct {
+	mu sync.Mutex
+	// batches maps a document ID to the batches of each author, keyed by the
+	// hex hash of the batch they follow and their first operation ID
+	batches   map[string]map[string]map[string]*types.AuditBatch
+	unchained map[string][]string
+	anchors   map[string][]types.AuditAnchor
+	heads     map[string][]byte
+	// pruned maps a document ID to the pruned batch of each author, standing
+	// for the start of its chain dropped by the compaction
+	pruned map[string]map[string]types.AuditBatch
+}
+
+// Link makes the operations of each document of a transaction a batch of the
+// author, following its previous batch in the document. It returns the hashes
+// of the new batches, which become the heads once the batches are sent, see
+// SetHeads.
+func (a *AuditLog) Link(author string, ops []types.CRDTOperation) (map[string][]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	docOps := make(map[string][]int)
+	docOrder := make([]string, 0)
+	for i, op := range ops {
+		if _, exists := docOps[op.DocumentID]; !exists {
+			docOrder = append(docOrder, op.DocumentID)
+		}
+		docOps[op.DocumentID] = append(docOps[op.DocumentID], i)
+	}
+
+	heads := make(map[string][]byte, len(docOrder))
+	for _, docID := range docOrder {
+		prev := a.heads[docID]
+		first := ops[docOps[docID][0]].OperationID
+		for _, i := range docOps[docID] {
+			first = min(first, ops[i].OperationID)
+		}
+		batchOps := make([]types.CRDTOperation, 0, len(docOps[docID]))
+		for _, i := range docOps[docID] {
+			ops[i].Audit = &types.AuditLink{Prev: prev, First: first, Size: len(docOps[docID])}
+			batchOps = append(batchOps, ops[i])
+		}
+		hash, err := batchHash(docID, author, prev, batchOps)
+		if err != nil {
+			return nil, err
+		}
+		heads[docID] = hash
+	}
+	return heads, nil
+}
+
+// SetHeads sets the hash of our last batch in some documents.
+func (a *AuditLog) SetHeads(heads map[string][]byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for docID, head := range heads {
+		a.heads[docID] = head
+	}
+}
+
+// Record adds an applied operation to the batch of its author, or to the
+// unchained operations if it has none. The operation must be cast and whole,
+// as signed by its author.
+func (a *AuditLog) Record(op types.CRDTOperation, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if op.Audit == nil {
+		id := fmt.Sprintf("%d@%s", op.OperationID, op.Origin)
+		for _, unchained := range a.unchained[op.DocumentID] {
+			if unchained == id {
+				return
+			}
+		}
+		a.unchained[op.DocumentID] = append(a.unchained[op.DocumentID], id)
+		return
+	}
+
+	if _, exists := a.batches[op.DocumentID]; !exists {
+		a.batches[op.DocumentID] = make(map[string]map[string]*types.AuditBatch)
+	}
+	if _, exists := a.batches[op.DocumentID][op.Origin]; !exists {
+		a.batches[op.DocumentID][op.Origin] = make(map[string]*types.AuditBatch)
+	}
+	// the batches following the same one, e.g. after a restart of the
+	// author, are kept apart
+	key := batchKey(op.Audit.Prev, op.Audit.First)
+	batch, exists := a.batches[op.DocumentID][op.Origin][key]
+	if !exists {
+		batch = &types.AuditBatch{
+			DocumentID: op.DocumentID,
+			Author:     op.Origin,
+			PrevHash:   op.Audit.Prev,
+			Size:       op.Audit.Size,
+			ReceivedAt: now.Unix(),
+		}
+		a.batches[op.DocumentID][op.Origin][key] = batch
+	}
+
+	// the parts of a run cut on the way are recorded once, as signed
+	for _, batchOp := range batch.Operations {
+		if batchOp.OperationID == op.OperationID {
+			return
+		}
+	}
+	batch.Operations = append(batch.Operations, op)
+	sort.Slice(batch.Operations, func(i, j int) bool {
+		return batch.Operations[i].OperationID < batch.Operations[j].OperationID
+	})
+}
+
+// Get returns the chains of the authors of a document, author by author. The
+// batches of a chain come in order, after the pruned batch if the start of
+// the chain was dropped, followed by the ones whose previous batch is missing
+// or already followed by another batch.
+func (a *AuditLog) Get(docID string) ([]types.AuditBatch, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	authors := make([]string, 0, len(a.batches[docID]))
+	for author := range a.batches[docID] {
+		authors = append(authors, author)
+	}
+	for author := range a.pruned[docID] {
+		if _, exists := a.batches[docID][author]; !exists {
+			authors = append(authors, author)
+		}
+	}
+	sort.Strings(authors)
+
+	log := make([]types.AuditBatch, 0)
+	for _, author := range authors {
+		chained, orphans, err := a.chain(docID, author)
+		if err != nil {
+			return nil, err
+		}
+
+		index := uint(0)
+		if pruned, exists := a.pruned[docID][author]; exists {
+			log = append(log, pruned)
+			index = pruned.Index + 1
+		}
+		for _, batch := range append(chained, orphans...) {
+			batch.Index = index
+			log = append(log, batch)
+			index++
+		}
+	}
+	return log, nil
+}
+
+// chain returns the batches of an author in a document in the order of its
+// chain, from the pruned batch if any, and the others. When several batches
+// follow the same one, the one with the lowest first operation ID is chained.
+// The batches are copies, with their hash.
+func (a *AuditLog) chain(docID, author string) ([]types.AuditBatch, []types.AuditBatch, error) {
+	byPrev := make(map[string][]types.AuditBatch, len(a.batches[docID][author]))
+	for _, batch := range a.batches[docID][author] {
+		chained := *batch
+		chained.Operations = make([]types.CRDTOperation, len(batch.Operations))
+		copy(chained.Operations, batch.Operations)
+		hash, err := batchHash(docID, author, batch.PrevHash, batch.Operations)
+		if err != nil {
+			return nil, nil, err
+		}
+		chained.Hash = hash
+		prev := hex.EncodeToString(batch.PrevHash)
+		byPrev[prev] = append(byPrev[prev], chained)
+	}
+	for _, batches := range byPrev {
+		sort.Slice(batches, func(i, j int) bool {
+			return batchFirst(batches[i]) < batchFirst(batches[j])
+		})
+	}
+
+	chained := make([]types.AuditBatch, 0, len(a.batches[docID][author]))
+	head := hex.EncodeToString(a.pruned[docID][author].Hash)
+	for len(byPrev[head]) > 0 {
+		next := byPrev[head][0]
+		byPrev[head] = byPrev[head][1:]
+		chained = append(chained, next)
+		head = hex.EncodeToString(next.Hash)
+	}
+
+	orphans := make([]types.AuditBatch, 0)
+	for _, batches := range byPrev {
+		orphans = append(orphans, batches...)
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		if orphans[i].ReceivedAt == orphans[j].ReceivedAt {
+			return bytes.Compare(orphans[i].Hash, orphans[j].Hash) < 0
+		}
+		return orphans[i].ReceivedAt < orphans[j].ReceivedAt
+	})
+	return chained, orphans, nil
+}
+
+// Prune drops the start of the chain of each author of a document whose
+// operations are all below a frontier, i.e. folded into the snapshots by the
+// compaction. The last batch dropped is kept as the pruned batch of the
+// author.
+func (a *AuditLog) Prune(docID string, frontier types.VersionVector) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for author, batches := range a.batches[docID] {
+		chained, _, err := a.chain(docID, author)
+		if err != nil {
+			return err
+		}
+
+		pruned, exists := a.pruned[docID][author]
+		for _, batch := range chained {
+			if !batchStable(batch, frontier) {
+				break
+			}
+			index := uint(0)
+			if exists {
+				index = pruned.Index + 1
+			}
+			pruned = types.AuditBatch{
+				DocumentID: docID,
+				Author:     author,
+				Index:      index,
+				Size:       batch.Size,
+				Hash:       batch.Hash,
+				ReceivedAt: batch.ReceivedAt,
+				Pruned:     true,
+			}
+			exists = true
+			delete(batches, batchKey(batch.PrevHash, batchFirst(batch)))
+		}
+		if !exists {
+			continue
+		}
+
+		if _, exists := a.pruned[docID]; !exists {
+			a.pruned[docID] = make(map[string]types.AuditBatch)
+		}
+		a.pruned[docID][author] = pruned
+	}
+	return nil
+}
+
+// batchStable tells if a batch is whole and all its operations are below a
+// frontier.
+func batchStable(batch types.AuditBatch, frontier types.VersionVector) bool {
+	if len(batch.Operations) != batch.Size {
+		return false
+	}
+	for _, op := range batch.Operations {
+		if op.OperationID+OpSpan(op)-1 > frontier[op.Origin] {
+			return false
+		}
+	}
+	return true
+}
+
+// batchKey returns the key of a batch among the batches of its author.
+func batchKey(prev []byte, first uint64) string {
+	return fmt.Sprintf("%s/%d", hex.EncodeToString(prev), first)
+}
+
+// batchFirst returns the first operation ID of a batch, as linked by its
+// operations.
+func batchFirst(batch types.AuditBatch) uint64 {
+	if len(batch.Operations) == 0 || batch.Operations[0].Audit == nil {
+		return 0
+	}
+	return batch.Operations[0].Audit.First
+}
+
+// GetUnchained returns the IDs of the operations of a document applied without
+// a batch.
+func (a *AuditLog) GetUnchained(docID string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	unchained := make([]string, len(a.unchained[docID]))
+	copy(unchained, a.unchained[docID])
+	return unchained
+}
+
+// AddAnchor adds an anchor of a document.
+func (a *AuditLog) AddAnchor(anchor types.AuditAnchor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.anchors[anchor.DocumentID] = append(a.anchors[anchor.DocumentID], anchor)
+}
+
+// GetAnchors returns the anchors of a document, oldest first.
+func (a *AuditLog) GetAnchors(docID string) []types.AuditAnchor {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	anchors := make([]types.AuditAnchor, len(a.anchors[docID]))
+	copy(anchors, a.anchors[docID])
+	return anchors
+}
+
+// DeleteDocument drops the audit log of a document.
+func (a *AuditLog) DeleteDocument(docID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.batches, docID)
+	delete(a.unchained, docID)
+	delete(a.anchors, docID)
+	delete(a.heads, docID)
+	delete(a.pruned, docID)
+}
+
+// batchHash returns the hash of a batch, which covers the hash of the previous
+// batch of its author and the signed content of its operations, sorted by ID.
+func batchHash(docID, author string, prev []byte, ops []types.CRDTOperation) ([]byte, error) {
+	sorted := make([]types.CRDTOperation, len(ops))
+	copy(sorted, ops)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].OperationID < sorted[j].OperationID
+	})
+
+	hash := sha256.New()
+	buf, err := json.Marshal(struct {
+		DocumentID string
+		Author     string
+		Prev       []byte
+	}{docID, author, prev})
+	if err != nil {
+		return nil, err
+	}
+	hash.Write(buf)
+	for _, op := range sorted {
+		buf, err := operationBytes(op)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode operation %d@%s: %w", op.OperationID, op.Origin, err)
+		}
+		hash.Write(buf)
+	}
+	return hash.Sum(nil), nil
+}
+
+// recordAudit adds an applied operation to the audit log. A run cut on the way
+// is recorded as signed.
+func (n *node) recordAudit(op types.CRDTOperation) {
+	if op.Original != nil {
+		original := *op.Original
+		err := n.castOperation(&original)
+		if err != nil {
+			n.logCRDT.Error().Err(err).Msgf("Failed to audit the operation %d@%s", op.OperationID, op.Origin)
+			return
+		}
+		op = original
+	}
+	n.audit.Record(op, time.Now())
+}
+
+// GetAuditLog implements peer.CRDT
+func (n *node) GetAuditLog(docID string) ([]types.AuditBatch, error) {
+	return n.audit.Get(docID)
+}
+
+// VerifyAuditLog implements peer.CRDT
+func (n *node) VerifyAuditLog(batches []types.AuditBatch) error {
+	errs := n.auditErrors(batches)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// auditErrors checks the chain of each author of an audit log. Past a broken
+// batch, the chain is checked from the hash the batch claims. A chain whose
+// start was pruned is checked from its pruned batch.
+func (n *node) auditErrors(batches []types.AuditBatch) []error {
+	errs := make([]error, 0)
+	heads := make(map[string][]byte)
+	counts := make(map[string]uint)
+	for _, batch := range batches {
+		_, started := counts[batch.Author]
+		if batch.Pruned && !started {
+			counts[batch.Author] = batch.Index + 1
+			heads[batch.Author] = batch.Hash
+			continue
+		}
+		index := counts[batch.Author]
+		counts[batch.Author]++
+		prev := heads[batch.Author]
+		heads[batch.Author] = batch.Hash
+
+		err := n.checkBatch(batch, index, prev, batches[0].DocumentID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("batch %d of %s: %w", index, batch.Author, err))
+		}
+	}
+	return errs
+}
+
+// checkBatch checks a batch of an audit log: its place in the chain of its
+// author, its operations and their signatures, and its hash.
+func (n *node) checkBatch(batch types.AuditBatch, index uint, prev []byte, docID string) error {
+	switch {
+	case batch.DocumentID != docID:
+		return fmt.Errorf("belongs to document %s", batch.DocumentID)
+	case batch.Pruned:
+		return fmt.Errorf("is pruned past the start of the chain")
+	case batch.Index != index:
+		return fmt.Errorf("has index %d", batch.Index)
+	case !bytes.Equal(batch.PrevHash, prev):
+		return fmt.Errorf("does not follow the previous batch")
+	case len(batch.Operations) != batch.Size:
+		return fmt.Errorf("has %d of its %d operations", len(batch.Operations), batch.Size)
+	}
+
+	ops := make([]types.CRDTOperation, len(batch.Operations))
+	copy(ops, batch.Operations)
+	first := uint64(0)
+	for i, op := range ops {
+		if i == 0 || op.OperationID < first {
+			first = op.OperationID
+		}
+	}
+	for i := range ops {
+		op := &ops[i]
+		// the operations may come from another peer, they are cast before
+		// being checked and hashed again
+		if op.Type != "" {
+			err := n.castOperation(op)
+			if err != nil {
+				return fmt.Errorf("operation %d@%s: %w", op.OperationID, op.Origin, err)
+			}
+		}
+		switch {
+		case op.DocumentID != batch.DocumentID || op.Origin != batch.Author:
+			return fmt.Errorf("operation %d@%s is not part of the batch", op.OperationID, op.Origin)
+		case op.Audit == nil || !bytes.Equal(op.Audit.Prev, batch.PrevHash) || op.Audit.Size != batch.Size ||
+			op.Audit.First != first:
+			return fmt.Errorf("operation %d@%s is linked to another batch", op.OperationID, op.Origin)
+		}
+		err := n.verifyOperation(*op)
+		if err != nil {
+			return fmt.Errorf("operation %d@%s: %w", op.OperationID, op.Origin, err)
+		}
+	}
+
+	hash, err := batchHash(batch.DocumentID, batch.Author, batch.PrevHash, ops)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, batch.Hash) {
+		return fmt.Errorf("was altered")
+	}
+	return nil
+}
+
+// ExportAuditReport implements peer.CRDT
+func (n *node) ExportAuditReport(docID string) (types.AuditReport, error) {
+	batches, err := n.audit.Get(docID)
+	if err != nil {
+		return types.AuditReport{}, err
+	}
+	unchained := n.audit.GetUnchained(docID)
+	if _, exists := n.GetEditor()[docID]; !exists && len(batches) == 0 && len(unchained) == 0 {
+		return types.AuditReport{}, fmt.Errorf("unknown document %s", docID)
+	}
+
+	report := types.AuditReport{
+		DocumentID:  docID,
+		GeneratedAt: time.Now().Unix(),
+		Authors:     make([]types.AuditAuthor, 0),
+		Batches:     batches,
+		Anchors:     n.audit.GetAnchors(docID),
+		Problems:    make([]string, 0),
+	}
+
+	for _, batch := range batches {
+		last := len(report.Authors) - 1
+		if last < 0 || report.Authors[last].Author != batch.Author {
+			report.Authors = append(report.Authors, types.AuditAuthor{Author: batch.Author})
+			last++
+		}
+		// a pruned batch stands for every batch up to it
+		report.Authors[last].Batches = int(batch.Index) + 1
+		report.Authors[last].Operations += len(batch.Operations)
+		report.Authors[last].Head = batch.Hash
+	}
+
+	for _, err := range n.auditErrors(batches) {
+		report.Problems = append(report.Problems, err.Error())
+	}
+	for _, id := range unchained {
+		report.Problems = append(report.Problems, fmt.Sprintf("operation %s is not part of a batch", id))
+	}
+
+	report.StateHash, err = n.auditStateHash(docID, report.Authors)
+	if err != nil {
+		return types.AuditReport{}, err
+	}
+
+	// the anchors must still be in the naming store
+	for _, anchor := range report.Anchors {
+		tagged := n.conf.Storage.GetNamingStore().Get(anchor.Name)
+		if string(tagged) != hex.EncodeToString(anchor.StateHash) {
+			report.Problems = append(report.Problems, fmt.Sprintf("anchor %s is not in the naming store", anchor.Name))
+		}
+	}
+	return report, nil
+}
+
+// auditStateHash returns the hash of the state of a document: the heads of the
+// chains of its authors and its content.
+func (n *node) auditStateHash(docID string, authors []types.AuditAuthor) ([]byte, error) {
+	content := ""
+	if _, exists := n.GetEditor()[docID]; exists {
+		var err error
+		content, err = n.CompileDocument(docID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	heads := make(map[string][]byte, len(authors))
+	for _, author := range authors {
+		heads[author.Author] = author.Head
+	}
+	buf, err := json.Marshal(struct {
+		DocumentID string
+		Heads      map[string][]byte
+		Content    string
+	}{docID, heads, content})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf)
+	return sum[:], nil
+}
+
+// AnchorDocument implements peer.CRDT
+func (n *node) AnchorDocument(docID string) (types.AuditAnchor, error) {
+	report, err := n.ExportAuditReport(docID)
+	if err != nil {
+		return types.AuditAnchor{}, err
+	}
+
+	now := time.Now()
+	anchor := types.AuditAnchor{
+		DocumentID: docID,
+		Name:       fmt.Sprintf("audit/%s/%s/%d", docID, n.GetIdentity(), now.UnixNano()),
+		StateHash:  report.StateHash,
+		Timestamp:  now.Unix(),
+	}
+	err = n.Tag(anchor.Name, hex.EncodeToString(anchor.StateHash))
+	if err != nil {
+		return types.AuditAnchor{}, fmt.Errorf("failed to anchor document %s: %w", docID, err)
+	}
+	n.audit.AddAnchor(anchor)
+	return anchor, nil
+}
+
+// AuditAnchorTicker anchors the documents whose state changed since their last
+// anchor.
+func (n *node) AuditAnchorTicker() {
+	anchorTicker := time.NewTicker(n.conf.AuditAnchorInterval)
+	defer anchorTicker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			n.log.Info().Msg("Stopping audit anchoring")
+			return
+		case <-anchorTicker.C:
+			for docID := range n.GetEditor() {
+				err := n.anchorIfChanged(docID)
+				if err != nil {
+					n.logCRDT.Error().Err(err).Msgf("Failed to anchor document %s", docID)
+				}
+			}
+		}
+	}
+}
+
+// anchorIfChanged anchors a document if its state changed since its last
+// anchor.
+func (n *node) anchorIfChanged(docID string) error {
+	report, err := n.ExportAuditReport(docID)
+	if err != nil {
+		return err
+	}
+	anchors := report.Anchors
+	if len(anchors) > 0 && bytes.Equal(anchors[len(anchors)-1].StateHash, report.StateHash) {
+		return nil
+	}
+	_, err = n.AnchorDocument(docID)
+	return err
+}
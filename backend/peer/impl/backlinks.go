@@ -0,0 +1,255 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+	"sort"
+)
+
+// reference is a link from a block to a target document.
+type reference struct {
+	target string
+	link   types.Backlink
+}
+
+// Backlinks indexes the references of the blocks to other documents. The
+// blocks whose references may change are marked dirty as the operations
+// arrive, and their references are recomputed when the backlinks are read.
+type Backlinks struct {
+	blockIndex
+	sources map[string]map[string][]reference // source docID -> blockID -> references
+}
+
+// Track marks dirty the block of an operation if the operation may change
+// its references.
+func (b *Backlinks) Track(op types.CRDTOperation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch crdtOp := op.Operation.(type) {
+	case types.CRDTAddMark:
+		if crdtOp.MarkType == types.Mention {
+			b.dirty.mark(op.DocumentID, op.BlockID)
+		}
+	case types.CRDTRemoveMark:
+		if crdtOp.MarkType == types.Mention {
+			b.dirty.mark(op.DocumentID, op.BlockID)
+		}
+	case types.CRDTDeleteChar, types.CRDTDeleteRange:
+		if len(b.sources[op.DocumentID][op.BlockID]) > 0 {
+			b.dirty.mark(op.DocumentID, op.BlockID)
+		}
+	case types.CRDTAddBlock:
+		if crdtOp.BlockType == types.TransclusionBlockType {
+			b.dirty.mark(op.DocumentID, fmt.Sprintf("%d@%s", op.OperationID, op.Origin))
+		}
+	case types.CRDTUpdateBlock:
+		if crdtOp.BlockType == types.TransclusionBlockType || len(b.sources[op.DocumentID][op.BlockID]) > 0 {
+			b.dirty.mark(op.DocumentID, op.BlockID)
+		}
+	case types.CRDTRemoveBlock:
+		// the children of the removed block are removed as well
+		for blockID := range b.sources[op.DocumentID] {
+			b.dirty.mark(op.DocumentID, blockID)
+		}
+	}
+}
+
+// SetReferences replaces the references of a block.
+func (b *Backlinks) SetReferences(docID, blockID string, refs []reference) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(refs) == 0 {
+		delete(b.sources[docID], blockID)
+		return
+	}
+	if _, exists := b.sources[docID]; !exists {
+		b.sources[docID] = make(map[string][]reference)
+	}
+	b.sources[docID][blockID] = refs
+}
+
+// GetReferences returns the references to a target document.
+func (b *Backlinks) GetReferences(target string) []types.Backlink {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	links := make([]types.Backlink, 0)
+	for _, blocks := range b.sources {
+		for _, refs := range blocks {
+			for _, ref := range refs {
+				if ref.target == target {
+					links = append(links, ref.link)
+				}
+			}
+		}
+	}
+	return links
+}
+
+// GetSourceBlocks returns the blocks of a document that have references.
+func (b *Backlinks) GetSourceBlocks(docID string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockIDs := make([]string, 0, len(b.sources[docID]))
+	for blockID := range b.sources[docID] {
+		blockIDs = append(blockIDs, blockID)
+	}
+	return blockIDs
+}
+
+// DeleteDocument forgets the references of a document.
+func (b *Backlinks) DeleteDocument(docID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.sources, docID)
+	delete(b.dirty, docID)
+}
+
+// GetBacklinks implements peer.CRDT
+func (n *node) GetBacklinks(docID string) ([]types.Backlink, error) {
+	n.refreshBacklinks()
+
+	links := make([]types.Backlink, 0)
+	for _, link := range n.backlinks.GetReferences(docID) {
+		// archived and deleted documents are hidden
+		if n.GetDocumentMetadata(link.SourceDocument).Status != types.DocumentActive {
+			continue
+		}
+		links = append(links, link)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].SourceDocument != links[j].SourceDocument {
+			return links[i].SourceDocument < links[j].SourceDocument
+		}
+		if links[i].SourceBlock != links[j].SourceBlock {
+			return links[i].SourceBlock < links[j].SourceBlock
+		}
+		if links[i].TargetBlock != links[j].TargetBlock {
+			return links[i].TargetBlock < links[j].TargetBlock
+		}
+		return links[i].Type < links[j].Type
+	})
+	return links, nil
+}
+
+// refreshBacklinks recomputes the references of the dirty blocks.
+func (n *node) refreshBacklinks() {
+	n.refreshIndex(&n.backlinks.blockIndex, n.backlinks.GetSourceBlocks,
+		func(docID, blockID string, block types.BlockFactory, found bool) {
+			if !found || block.Deleted {
+				n.backlinks.SetReferences(docID, blockID, nil)
+				return
+			}
+			n.backlinks.SetReferences(docID, blockID, n.blockReferences(docID, block))
+		})
+}
+
+// blockReferences returns the page mentions of a block and the block it
+// transcludes.
+func (n *node) blockReferences(docID string, block types.BlockFactory) []reference {
+	refs := make([]reference, 0)
+	seen := make(map[reference]struct{})
+	add := func(ref reference) {
+		if _, exists := seen[ref]; !exists {
+			seen[ref] = struct{}{}
+			refs = append(refs, ref)
+		}
+	}
+
+	if block.BlockType == types.TransclusionBlockType && block.Props.SourceDocument != "" {
+		add(reference{
+			target: block.Props.SourceDocument,
+			link: types.Backlink{
+				SourceDocument: docID,
+				SourceBlock:    block.ID,
+				TargetBlock:    block.Props.SourceBlock,
+				Type:           string(types.TransclusionBlockType),
+			},
+		})
+		return refs
+	}
+
+	content := n.createBlockContentFromSnapshot(n.GetBlockSnapshot(docID, block.ID), n.GetBlockOps(docID, block.ID))
+	for _, inline := range content {
+		mention, ok := inline.(*types.PageMention)
+		if !ok {
+			continue
+		}
+		add(reference{
+			target: mention.DocumentID,
+			link: types.Backlink{
+				SourceDocument: docID,
+				SourceBlock:    block.ID,
+				TargetBlock:    mention.BlockID,
+				Type:           types.Mention,
+			},
+		})
+	}
+	return refs
+}
+
+// createTranscludedBlock compiles the block referenced by a transclusion
+// block. It returns an error message instead if the block cannot be found or
+// if it is already being compiled, i.e. the transclusions form a cycle.
+func (n *node) createTranscludedBlock(props types.DefaultBlockProps, path map[string]bool) (types.BlockType, string) {
+	if props.SourceDocument == "" || props.SourceBlock == "" {
+		return nil, "no source block"
+	}
+	if path[transclusionKey(props.SourceDocument, props.SourceBlock)] {
+		return nil, "transclusion cycle"
+	}
+
+	document, err := n.populateDocumentBlocks(props.SourceDocument)
+	if err != nil {
+		return nil, err.Error()
+	}
+	block, found := FindBlock(document, props.SourceBlock)
+	if !found || block.Deleted {
+		return nil, "source block not found"
+	}
+
+	blockOperations := n.GetBlockOps(props.SourceDocument, block.ID)
+	return n.createBlock(props.SourceDocument, block, blockOperations, path), ""
+}
+
+// transclusionKey identifies a block across documents.
+func transclusionKey(docID, blockID string) string {
+	return docID + "/" + blockID
+}
+
+// flattenBlocks returns the IDs of the blocks of a document and their
+// children.
+func flattenBlocks(document []types.BlockFactory) []string {
+	blockIDs := make([]string, 0, len(document))
+	for _, block := range document {
+		blockIDs = append(blockIDs, block.ID)
+		blockIDs = append(blockIDs, flattenBlocks(block.Children)...)
+	}
+	return blockIDs
+}
+
+// treeBlock is a block of a tree of blocks, such as the blocks of a document
+// or of its compiled form.
+type treeBlock[B any] interface {
+	BlockID() string
+	ChildBlocks() []B
+}
+
+// FindBlock looks for a block and its children in a tree of blocks.
+func FindBlock[B treeBlock[B]](blocks []B, blockID string) (B, bool) {
+	for _, block := range blocks {
+		if block.BlockID() == blockID {
+			return block, true
+		}
+		if child, found := FindBlock(block.ChildBlocks(), blockID); found {
+			return child, true
+		}
+	}
+	var none B
+	return none, false
+}
@@ -0,0 +1,78 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"sync"
+)
+
+// allBlocks marks dirty every block of a document.
+const allBlocks = ""
+
+// dirtyBlocks tells, for a given document, the blocks whose indexed data must
+// be recomputed.
+type dirtyBlocks map[string]map[string]struct{}
+
+func (d dirtyBlocks) mark(docID, blockID string) {
+	if _, exists := d[docID]; !exists {
+		d[docID] = make(map[string]struct{})
+	}
+	d[docID][blockID] = struct{}{}
+}
+
+// blockIndex holds the dirty blocks of an index over the blocks of the
+// documents, see refreshIndex. The index embeds it and guards its own data
+// with the same lock.
+type blockIndex struct {
+	mu    sync.Mutex
+	dirty dirtyBlocks
+}
+
+// MarkDirty marks a block to be indexed again, or the whole document for
+// allBlocks.
+func (b *blockIndex) MarkDirty(docID, blockID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dirty.mark(docID, blockID)
+}
+
+// TakeDirty returns the dirty blocks and clears them.
+func (b *blockIndex) TakeDirty() dirtyBlocks {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dirty := b.dirty
+	b.dirty = make(dirtyBlocks)
+	return dirty
+}
+
+// refreshIndex indexes again the dirty blocks of an index with reindex, which
+// gets the block if it is still in its document. A document marked dirty as a
+// whole indexes again its blocks along with the ones indexed so far, given by
+// indexed.
+func (n *node) refreshIndex(index *blockIndex, indexed func(docID string) []string,
+	reindex func(docID, blockID string, block types.BlockFactory, found bool)) {
+
+	for docID, blocks := range index.TakeDirty() {
+		document, err := n.populateDocumentBlocks(docID)
+		if err != nil {
+			n.logCRDT.Error().Err(err).Msgf("Failed to index document %s", docID)
+			continue
+		}
+
+		if _, all := blocks[allBlocks]; all {
+			delete(blocks, allBlocks)
+			for _, blockID := range indexed(docID) {
+				blocks[blockID] = struct{}{}
+			}
+			for _, blockID := range flattenBlocks(document) {
+				blocks[blockID] = struct{}{}
+			}
+		}
+
+		for blockID := range blocks {
+			block, found := FindBlock(document, blockID)
+			reindex(docID, blockID, block, found)
+		}
+	}
+}
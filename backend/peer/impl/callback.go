@@ -35,7 +35,17 @@ func (n *node) RumorsMessageCallback(msg types.Message, pkt transport.Packet) er
 
 	// flag for expected rumor
 	expectedRumor := false
+	// the rumors with a missing or invalid signature are neither processed
+	// nor forwarded
+	verified := make([]types.Rumor, 0, len(rumorsMsg.Rumors))
 	for _, rumor := range rumorsMsg.Rumors {
+		err := n.verifyRumor(rumor)
+		if err != nil {
+			n.rejectRumor(rumor, pkt.Header.Source, err)
+			continue
+		}
+		verified = append(verified, rumor)
+
 		if n.view.AddRumorView(rumor, rumor.Origin) {
 			expectedRumor = true // set to true if (at least one) rumor is expected
 
@@ -45,10 +55,26 @@ func (n *node) RumorsMessageCallback(msg types.Message, pkt transport.Packet) er
 				return xerrors.Errorf("Failed to process rumor: %v", err)
 			}
 
+			// our own rumors come back when a previous run used the same
+			// identity, they do not tell where we are
+			if rumor.Origin == n.GetIdentity() {
+				continue
+			}
+
+			n.setReadOnly(rumor.Origin, rumor.ReadOnly)
+
+			// the routing table is keyed by address, the identities are
+			// mapped to the address they announce
+			originAddr := rumor.Origin
+			if rumor.Address != "" {
+				originAddr = rumor.Address
+				n.setIdentityAddress(rumor.Origin, rumor.Address)
+			}
+
 			// if the message is from an indirect neighbor, update the routing table
-			if rumor.Origin != pkt.Header.RelayedBy || pkt.Header.Source == pkt.Header.RelayedBy {
-				n.log.Info().Msgf("Updating routing table with %s as origin and %s as relay", rumor.Origin, pkt.Header.RelayedBy)
-				n.SetRoutingEntry(rumor.Origin, pkt.Header.RelayedBy)
+			if originAddr != pkt.Header.RelayedBy || pkt.Header.Source == pkt.Header.RelayedBy {
+				n.log.Info().Msgf("Updating routing table with %s as origin and %s as relay", originAddr, pkt.Header.RelayedBy)
+				n.SetRoutingEntry(originAddr, pkt.Header.RelayedBy)
 			}
 		}
 	}
@@ -62,7 +88,7 @@ func (n *node) RumorsMessageCallback(msg types.Message, pkt transport.Packet) er
 	// if one of the rumors was expected, send a RumorsMessage to a random neighbor
 	if expectedRumor {
 		// marshal the RumorsMessage
-		payload, err := n.conf.MessageRegistry.MarshalMessage(rumorsMsg)
+		payload, err := n.conf.MessageRegistry.MarshalMessage(types.RumorsMessage{Rumors: verified})
 		if err != nil {
 			return xerrors.Errorf("Failed to marshal RumorsMessage: %v", err)
 		}
@@ -78,11 +104,16 @@ func (n *node) RumorsMessageCallback(msg types.Message, pkt transport.Packet) er
 
 // ProcessRumor processes the rumor
 func (n *node) ProcessRumor(rumor types.Rumor, pkt transport.Packet) error {
-	// process the message
+	// process the message with its own header, the callbacks can get the
+	// origin of the rumor from it
+	header := *pkt.Header
 	newPkt := transport.Packet{
-		Header: pkt.Header,
+		Header: &header,
 		Msg:    rumor.Msg,
 	}
+	n.rumorOrigins.Set(newPkt.Header, rumor.Origin)
+	defer n.rumorOrigins.Delete(newPkt.Header)
+
 	err := n.ProcessMsg(newPkt)
 	if err != nil {
 		return xerrors.Errorf("Failed to process message: %v", err)
@@ -637,7 +668,12 @@ func (n *node) TLCMessageCallback(msg types.Message, pkt transport.Packet) error
 	}
 
 	for { // catchup loop
-		tclMsg := n.tlcMessages.GetTLCMessages(uint(n.logicalClock.GetStep()))[0]
+		// the next step may have no message yet
+		tlcMsgs := n.tlcMessages.GetTLCMessages(uint(n.logicalClock.GetStep()))
+		if len(tlcMsgs) == 0 {
+			break
+		}
+		tclMsg := tlcMsgs[0]
 		// check tclMsg.Step == logicalClock.GetStep() to avoid processing messages from the future
 		if n.tlcMessages.LenTLCMessages(uint(n.logicalClock.GetStep())) >= n.conf.PaxosThreshold(n.conf.TotalPeers) &&
 			tclMsg.Step == uint(n.logicalClock.GetStep()) {
@@ -716,10 +752,25 @@ func (n *node) CRDTOperationsMessageCallback(msg types.Message, pkt transport.Pa
 
 	n.logCRDT.Info().Msgf("Received CRDTOperationsMessage from %s, I am %s", pkt.Header.Source, n.conf.Socket.GetAddress())
 
-	// Update our CRDTState with the operations
+	// The operations that do not come from the node itself are checked, the
+	// invalid ones are quarantined
+	if !n.localPackets.Contains(pkt.Header) {
+		rumorOrigin, _ := n.rumorOrigins.Get(pkt.Header)
+		crdtMsg.Operations = n.validateIncomingOperations(crdtMsg.Operations, pkt.Header.Source, rumorOrigin)
+	} else {
+		for i := range crdtMsg.Operations {
+			n.CastOperation(&crdtMsg.Operations[i])
+		}
+	}
+
+	// Drop the operations already applied, e.g. received with a snapshot
+	crdtMsg.Operations = n.filterKnownOperations(crdtMsg.Operations)
+
+	// Update our CRDTState with the operations, runs reserve one ID per character
 	for _, op := range crdtMsg.Operations {
-		if n.crdtState.GetState(op.DocumentID) < op.OperationID {
-			n.crdtState.SetState(op.DocumentID, op.OperationID)
+		lastID := op.OperationID + OpSpan(op) - 1
+		if n.crdtState.GetState(op.DocumentID) < lastID {
+			n.crdtState.SetState(op.DocumentID, lastID)
 		}
 	}
 
@@ -727,6 +778,206 @@ func (n *node) CRDTOperationsMessageCallback(msg types.Message, pkt transport.Pa
 	if err != nil {
 		return xerrors.Errorf("Failed to update editor: %v", err)
 	}
+
+	// the keys received before the access of their sender are checked again
+	for _, op := range crdtMsg.Operations {
+		if _, isAccess := op.Operation.(types.CRDTSetAccess); isAccess {
+			n.retryDocumentKeys(op.DocumentID)
+		}
+	}
+	return nil
+}
+
+// CRDTVersionMessageCallback handles the CRDTVersionMessage
+func (n *node) CRDTVersionMessageCallback(msg types.Message, pkt transport.Packet) error {
+	versionMsg, ok := msg.(*types.CRDTVersionMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a CRDTVersionMessage")
+	}
+
+	n.logCRDT.Info().Msgf("Received CRDTVersionMessage from %s, I am %s", pkt.Header.Source, n.conf.Socket.GetAddress())
+
+	n.compaction.SetPeerVersions(pkt.Header.Source, versionMsg.VersionVectors)
+	return nil
+}
+
+// CRDTSnapshotRequestMessageCallback handles the CRDTSnapshotRequestMessage
+func (n *node) CRDTSnapshotRequestMessageCallback(msg types.Message, pkt transport.Packet) error {
+	requestMsg, ok := msg.(*types.CRDTSnapshotRequestMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a CRDTSnapshotRequestMessage")
+	}
+
+	replyMsg := types.CRDTSnapshotReplyMessage{
+		RequestID:  requestMsg.RequestID,
+		DocumentID: requestMsg.DocumentID,
+	}
+
+	n.editor.mu.Lock()
+	_, exists := n.editor.ed[requestMsg.DocumentID]
+	n.editor.mu.Unlock()
+
+	// an empty metahash tells the requester we do not know the document. The
+	// snapshots are not encrypted, an encrypted document is synced with its
+	// operations instead, and a restricted one only with its members.
+	if exists && !n.IsDocumentEncrypted(requestMsg.DocumentID) &&
+		n.canSync(requestMsg.DocumentID, n.originOf(pkt.Header.Source)) {
+		metahash, vv, err := n.uploadDocumentSnapshot(requestMsg.DocumentID)
+		if err != nil {
+			return xerrors.Errorf("Failed to create snapshot: %v", err)
+		}
+		replyMsg.Metahash = metahash
+		replyMsg.VersionVector = vv
+	}
+
+	payload, err := n.conf.MessageRegistry.MarshalMessage(replyMsg)
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal CRDTSnapshotReplyMessage: %v", err)
+	}
+	err = n.Unicast(pkt.Header.Source, payload)
+	if err != nil {
+		return xerrors.Errorf("Failed to send CRDTSnapshotReplyMessage: %v", err)
+	}
+	return nil
+}
+
+// CRDTSnapshotReplyMessageCallback handles the CRDTSnapshotReplyMessage
+func (n *node) CRDTSnapshotReplyMessageCallback(msg types.Message, pkt transport.Packet) error {
+	replyMsg, ok := msg.(*types.CRDTSnapshotReplyMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a CRDTSnapshotReplyMessage")
+	}
+
+	n.snapshotReplyChanMap.mu.Lock()
+	replyChan, exists := n.snapshotReplyChanMap.repl[replyMsg.RequestID]
+	n.snapshotReplyChanMap.mu.Unlock()
+	if !exists {
+		return xerrors.Errorf("No reply channel found for request ID %s", replyMsg.RequestID)
+	}
+
+	select {
+	case replyChan <- *replyMsg:
+	default:
+		n.logCRDT.Warn().Msgf("Dropped duplicate snapshot reply %s", replyMsg.RequestID)
+	}
+	return nil
+}
+
+// CRDTSyncRequestMessageCallback handles the CRDTSyncRequestMessage
+func (n *node) CRDTSyncRequestMessageCallback(msg types.Message, pkt transport.Packet) error {
+	syncMsg, ok := msg.(*types.CRDTSyncRequestMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a CRDTSyncRequestMessage")
+	}
+
+	requester := n.originOf(pkt.Header.Source)
+	if !n.canSync(syncMsg.DocumentID, requester) {
+		n.logCRDT.Warn().Msgf("Refused to sync %s with %s, not a member", syncMsg.DocumentID, requester)
+		return nil
+	}
+
+	ops := n.operationsSince(syncMsg.DocumentID, syncMsg.VersionVector)
+	if len(ops) == 0 {
+		return nil
+	}
+
+	// the operations of an encrypted document go back encrypted, they can be
+	// relayed. They are sent reliably, the requester asks for operations it
+	// missed once already.
+	msgs, err := n.operationsMessages(types.CRDTOperationsMessage{Operations: ops}, false)
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal CRDTOperationsMessage: %v", err)
+	}
+	for _, payload := range msgs {
+		n.queueReliably(pkt.Header.Source, payload)
+	}
+	return nil
+}
+
+// ForwardDocumentSearchRequest forwards a DocumentSearchRequestMessage to the
+// neighbors, sharing the budget among them
+func (n *node) ForwardDocumentSearchRequest(budget uint, src string, req *types.DocumentSearchRequestMessage) {
+	neighbors := n.GetRandNeighsFromBudget(budget, src)
+
+	neighsLeft := len(neighbors)
+	for _, neigh := range neighbors {
+		b := budget / uint(neighsLeft)
+		if b == 0 {
+			continue
+		}
+
+		// only the budget changes
+		err := n.SendDocumentSearchRequestMessage(neigh, req.Query, b, req.RequestID, req.Origin)
+		if err != nil {
+			n.logCRDT.Error().Err(err).Msg("Error sending DocumentSearchRequestMessage")
+			return
+		}
+		neighsLeft--
+		budget -= b
+	}
+}
+
+// DocumentSearchRequestMessageCallback handles the DocumentSearchRequestMessage
+func (n *node) DocumentSearchRequestMessageCallback(msg types.Message, pkt transport.Packet) error {
+	searchReqMsg, ok := msg.(*types.DocumentSearchRequestMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a DocumentSearchRequestMessage")
+	}
+
+	// the request came back to its origin, which answered it locally
+	if searchReqMsg.Origin == n.conf.Socket.GetAddress() {
+		return nil
+	}
+
+	if searchReqMsg.Budget > 1 {
+		go n.ForwardDocumentSearchRequest(searchReqMsg.Budget-1, pkt.Header.Source, searchReqMsg)
+	}
+
+	searchReplyMsg := types.DocumentSearchReplyMessage{
+		RequestID: searchReqMsg.RequestID,
+		Peer:      n.conf.Socket.GetAddress(),
+		Responses: n.localDocumentSearchResults(searchReqMsg.Query, searchReqMsg.Origin),
+	}
+	searchReplyPayload, err := n.conf.MessageRegistry.MarshalMessage(searchReplyMsg)
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal DocumentSearchReplyMessage: %v", err)
+	}
+
+	// the reply follows the path of the request back to the origin
+	searchReplyHeader := transport.NewHeader(n.conf.Socket.GetAddress(), n.conf.Socket.GetAddress(), searchReqMsg.Origin)
+	searchReplyPkt := transport.Packet{
+		Header: &searchReplyHeader,
+		Msg:    &searchReplyPayload,
+	}
+	err = n.conf.Socket.Send(pkt.Header.Source, searchReplyPkt, time.Second*1)
+	if err != nil {
+		return xerrors.Errorf("Failed to send DocumentSearchReplyMessage: %v", err)
+	}
+	return nil
+}
+
+// DocumentSearchReplyMessageCallback handles the DocumentSearchReplyMessage
+func (n *node) DocumentSearchReplyMessageCallback(msg types.Message, pkt transport.Packet) error {
+	searchReplyMsg, ok := msg.(*types.DocumentSearchReplyMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a DocumentSearchReplyMessage")
+	}
+
+	n.documentSearchReplyChanMap.mu.Lock()
+	replyChan, exists := n.documentSearchReplyChanMap.repl[searchReplyMsg.RequestID]
+	n.documentSearchReplyChanMap.mu.Unlock()
+	if !exists {
+		// the search is over
+		n.logCRDT.Info().Msgf("Ignored late document search reply %s from %s",
+			searchReplyMsg.RequestID, searchReplyMsg.Peer)
+		return nil
+	}
+
+	select {
+	case replyChan <- *searchReplyMsg:
+	default:
+		n.logCRDT.Warn().Msgf("Dropped document search reply %s from %s", searchReplyMsg.RequestID, searchReplyMsg.Peer)
+	}
 	return nil
 }
 
@@ -871,3 +1122,86 @@ func (n *node) SendSearchRequestMessage(neigh string, r regexp.Regexp, b uint, r
 	}
 	return nil
 }
+
+// SendCRDTVersionMessages sends the version vectors of the documents to every
+// known peer. Nothing is sent as long as the peer has no document.
+func (n *node) SendCRDTVersionMessages() {
+	versionMsg := types.CRDTVersionMessage{
+		VersionVectors: n.crdtState.GetVersionVectors(),
+	}
+	if len(versionMsg.VersionVectors) == 0 {
+		return
+	}
+
+	payload, err := n.conf.MessageRegistry.MarshalMessage(versionMsg)
+	if err != nil {
+		n.log.Error().Err(err).Msg("Failed to marshal CRDTVersionMessage")
+		return
+	}
+
+	for dest := range n.GetRoutingTable() {
+		if dest == n.conf.Socket.GetAddress() {
+			continue
+		}
+		err = n.Unicast(dest, payload)
+		if err != nil {
+			n.log.Error().Err(err).Msgf("Failed to send CRDTVersionMessage to %s", dest)
+		}
+	}
+}
+
+// SendCRDTSnapshotRequestMessage asks a peer for the snapshot of a document.
+func (n *node) SendCRDTSnapshotRequestMessage(dest, docID, requestID string) error {
+	msg := types.CRDTSnapshotRequestMessage{
+		RequestID:  requestID,
+		DocumentID: docID,
+	}
+
+	payload, err := n.conf.MessageRegistry.MarshalMessage(msg)
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal CRDTSnapshotRequestMessage: %v", err)
+	}
+
+	err = n.Unicast(dest, payload)
+	if err != nil {
+		return xerrors.Errorf("Failed to send CRDTSnapshotRequestMessage: %v", err)
+	}
+	return nil
+}
+
+// SendCRDTSyncRequestMessage asks a peer for the operations of a document that
+// are not covered by the version vector.
+func (n *node) SendCRDTSyncRequestMessage(dest, docID string, vv types.VersionVector) error {
+	msg := types.CRDTSyncRequestMessage{
+		DocumentID:    docID,
+		VersionVector: vv,
+	}
+
+	payload, err := n.conf.MessageRegistry.MarshalMessage(msg)
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal CRDTSyncRequestMessage: %v", err)
+	}
+
+	err = n.Unicast(dest, payload)
+	if err != nil {
+		return xerrors.Errorf("Failed to send CRDTSyncRequestMessage: %v", err)
+	}
+	return nil
+}
+
+// SendDocumentSearchRequestMessage sends a DocumentSearchRequestMessage to the
+// neighbor.
+func (n *node) SendDocumentSearchRequestMessage(neigh, query string, b uint, requestID, origin string) error {
+	msg := types.DocumentSearchRequestMessage{
+		RequestID: requestID,
+		Origin:    origin,
+		Query:     query,
+		Budget:    b,
+	}
+
+	err := n.SendMsg(neigh, msg)
+	if err != nil {
+		return xerrors.Errorf("Failed to send DocumentSearchRequestMessage: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,508 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Compaction holds the version vectors reported by the other peers and the
+// statistics of the last compaction of each document.
+type Compaction struct {
+	mu           sync.Mutex
+	peerVersions map[string]map[string]types.VersionVector // peer -> docID -> version vector
+	stats        map[string]types.CompactionStats
+}
+
+// SetPeerVersions replaces the version vectors reported by a peer.
+func (c *Compaction) SetPeerVersions(peerAddr string, versions map[string]types.VersionVector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.peerVersions[peerAddr] = versions
+}
+
+// GetPeerVersion returns the version vector of a document reported by a peer.
+// The boolean is false if the peer never reported its versions.
+func (c *Compaction) GetPeerVersion(peerAddr, docID string) (types.VersionVector, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	versions, exists := c.peerVersions[peerAddr]
+	if !exists {
+		return nil, false
+	}
+	return versions[docID], true
+}
+
+// SetStats stores the statistics of the last compaction of a document.
+func (c *Compaction) SetStats(stats types.CompactionStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats[stats.DocumentID] = stats
+}
+
+// DeleteDocument forgets the versions and the statistics of a document.
+func (c *Compaction) DeleteDocument(docID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.stats, docID)
+	for _, versions := range c.peerVersions {
+		delete(versions, docID)
+	}
+}
+
+// GetStats returns the statistics of the last compaction of a document.
+func (c *Compaction) GetStats(docID string) types.CompactionStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats[docID]
+}
+
+// GetCompactionStats implements peer.CRDT
+func (n *node) GetCompactionStats(docID string) types.CompactionStats {
+	return n.compaction.GetStats(docID)
+}
+
+// GetVersionVector implements peer.CRDT
+func (n *node) GetVersionVector(docID string) types.VersionVector {
+	return n.crdtState.GetVersionVector(docID)
+}
+
+func (n *node) CompactionTicker() {
+	compactionTicker := time.NewTicker(n.conf.CompactionInterval)
+	defer compactionTicker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			n.log.Info().Msg("Stopping compaction")
+			return
+		case <-compactionTicker.C:
+			for docID := range n.GetEditor() {
+				stats, err := n.CompactDocument(docID)
+				if err != nil {
+					n.logCRDT.Error().Err(err).Msgf("Failed to compact document %s", docID)
+					continue
+				}
+				n.logCRDT.Debug().Msgf("Compacted document %s: %d -> %d operations",
+					docID, stats.OperationsBefore, stats.OperationsAfter)
+			}
+		}
+	}
+}
+
+// CompactDocument implements peer.CRDT
+func (n *node) CompactDocument(docID string) (types.CompactionStats, error) {
+	stats := types.CompactionStats{DocumentID: docID}
+
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	doc, exists := n.editor.ed[docID]
+	if !exists {
+		return stats, fmt.Errorf("document %s not found", docID)
+	}
+	if n.editor.snap[docID] == nil {
+		n.editor.snap[docID] = make(map[string]types.BlockSnapshot)
+	}
+	snapshots := n.editor.snap[docID]
+
+	var err error
+	stats.OperationsBefore, stats.BytesBefore, err = measureDocument(doc, snapshots)
+	if err != nil {
+		return stats, err
+	}
+
+	// only the winner of each metadata register is needed, whatever the
+	// stability of the operations
+	if metadataOps, exists := doc[types.MetadataBlockID]; exists {
+		doc[types.MetadataBlockID] = lwwWinners(metadataOps)
+	}
+
+	frontier, stable := n.stableFrontier(docID, documentACL(docID, doc[types.MetadataBlockID]))
+	if stable {
+		stats.BlocksDropped = n.dropRemovedBlocks(docID, doc, snapshots, frontier)
+
+		for blockID, ops := range doc {
+			if blockID == docID || blockID == types.MetadataBlockID {
+				continue
+			}
+			snapshot, remaining, dropped := n.compactBlock(snapshots[blockID], ops, frontier)
+			snapshots[blockID] = snapshot
+			doc[blockID] = remaining
+			stats.TombstonesDropped += dropped
+		}
+
+		// the batches folded into the snapshots are dropped from the audit
+		// log, the chains go on from the last one
+		err = n.audit.Prune(docID, frontier)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	stats.OperationsAfter, stats.BytesAfter, err = measureDocument(doc, snapshots)
+	if err != nil {
+		return stats, err
+	}
+
+	n.compaction.SetStats(stats)
+	return stats, nil
+}
+
+// stableFrontier returns, per origin, the highest operation ID applied by
+// every known peer, or every member of a restricted document. The boolean is false if some peer did not report its
+// version yet, or if it reported operations authored by itself that were not
+// received yet: future operations of that peer could then be ordered before
+// the ones considered stable.
+func (n *node) stableFrontier(docID string, acl types.DocumentACL) (types.VersionVector, bool) {
+	self := n.conf.Socket.GetAddress()
+	local := n.crdtState.GetVersionVector(docID)
+
+	frontier := make(types.VersionVector, len(local))
+	for origin, opID := range local {
+		frontier[origin] = opID
+	}
+
+	for peerAddr := range n.GetRoutingTable() {
+		if peerAddr == self {
+			continue
+		}
+		// the versions are reported per address and keyed by origin
+		peerOrigin := n.originOf(peerAddr)
+		// the peers outside of a restricted document never receive its
+		// operations, and the read-only ones never originate any
+		if len(acl.Members) > 0 && roleOf(acl, peerOrigin) == "" || n.isReadOnly(peerOrigin) {
+			continue
+		}
+		report, reported := n.compaction.GetPeerVersion(peerAddr, docID)
+		if !reported || local[peerOrigin] < report[peerOrigin] {
+			return nil, false
+		}
+		for origin, opID := range frontier {
+			if report[origin] < opID {
+				frontier[origin] = report[origin]
+			}
+		}
+	}
+
+	return frontier, true
+}
+
+// compactEntry is a single character operation of a block together with the
+// index of the operation it comes from.
+type compactEntry struct {
+	op  types.CRDTOperation
+	src int
+}
+
+// compactBlock folds the longest prefix of stable operations, in the order
+// used to compile the block, into its snapshot. It returns the new snapshot,
+// the remaining operations and the number of tombstones dropped.
+func (n *node) compactBlock(
+	snapshot types.BlockSnapshot,
+	ops []types.CRDTOperation,
+	frontier types.VersionVector,
+) (types.BlockSnapshot, []types.CRDTOperation, int) {
+	entries := make([]compactEntry, 0, len(ops))
+	expandedLen := make([]int, len(ops))
+	for i, op := range ops {
+		expanded := n.expandRunOperations([]types.CRDTOperation{op})
+		expandedLen[i] = len(expanded)
+		for _, charOp := range expanded {
+			entries = append(entries, compactEntry{op: charOp, src: i})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].op.OperationID == entries[j].op.OperationID {
+			return entries[i].op.Origin < entries[j].op.Origin
+		}
+		return entries[i].op.OperationID < entries[j].op.OperationID
+	})
+
+	prefix := 0
+	for prefix < len(entries) && entries[prefix].op.OperationID <= frontier[entries[prefix].op.Origin] {
+		prefix++
+	}
+	if prefix == 0 {
+		return snapshot, ops, 0
+	}
+
+	text, textStyles, charIDs, removedIDs := restoreSnapshot(snapshot)
+	folded := make(map[int]int)
+	for _, entry := range entries[:prefix] {
+		if err := n.processOperation(entry.op, &text, &textStyles, &charIDs, &removedIDs); err != nil {
+			n.logCRDT.Error().Msgf("Error processing operation: %v", err)
+		}
+		folded[entry.src]++
+	}
+
+	remaining := make([]types.CRDTOperation, 0, len(ops))
+	for i, op := range ops {
+		switch folded[i] {
+		case 0:
+			remaining = append(remaining, op)
+		case expandedLen[i]:
+			// fully folded into the snapshot
+		default:
+			remaining = append(remaining, trimInsertText(op, folded[i]))
+		}
+	}
+
+	referenced := n.referencedCharIDs(remaining)
+	removed := make(map[string]bool, len(removedIDs))
+	for _, removedID := range removedIDs {
+		removed[removedID] = true
+	}
+
+	newSnapshot := types.BlockSnapshot{
+		Anchors: make(map[string]string, len(snapshot.Anchors)),
+	}
+	for droppedID, anchorID := range snapshot.Anchors {
+		newSnapshot.Anchors[droppedID] = anchorID
+	}
+
+	dropped := 0
+	lastKept := ""
+	for i, charID := range charIDs {
+		if removed[charID] && !referenced[charID] {
+			newSnapshot.Anchors[charID] = lastKept
+			dropped++
+			continue
+		}
+		newSnapshot.Runs = appendSnapshotChar(newSnapshot.Runs, lastKept, charID, text[i:i+1],
+			textStyles[charID], removed[charID])
+		lastKept = charID
+	}
+
+	return newSnapshot, remaining, dropped
+}
+
+// trimInsertText returns the part of an insertText operation that follows its
+// first folded characters.
+func trimInsertText(op types.CRDTOperation, folded int) types.CRDTOperation {
+	insertOp, ok := op.Operation.(types.CRDTInsertText)
+	if !ok {
+		return op
+	}
+	chars := []rune(insertOp.Text)
+
+	trimmed := cutFrom(op)
+	trimmed.OperationID = op.OperationID + uint64(folded)
+	insertOp.AfterID = fmt.Sprintf("%d@%s", trimmed.OperationID-1, op.Origin)
+	insertOp.Text = string(chars[folded:])
+	trimmed.Operation = insertOp
+	return trimmed
+}
+
+// referencedCharIDs returns the character IDs the operations refer to.
+func (n *node) referencedCharIDs(ops []types.CRDTOperation) map[string]bool {
+	referenced := make(map[string]bool)
+	for _, op := range n.expandRunOperations(ops) {
+		switch charOp := op.Operation.(type) {
+		case types.CRDTInsertChar:
+			referenced[charOp.AfterID] = true
+		case types.CRDTDeleteChar:
+			referenced[charOp.RemovedID] = true
+		case types.CRDTAddMark:
+			referenced[charOp.Start.OpID] = true
+			referenced[charOp.End.OpID] = true
+		case types.CRDTRemoveMark:
+			referenced[charOp.Start.OpID] = true
+			referenced[charOp.End.OpID] = true
+		}
+	}
+	return referenced
+}
+
+// appendSnapshotChar adds a character to the runs of a snapshot, extending the
+// last run when the character directly follows it.
+func appendSnapshotChar(
+	runs []types.SnapshotRun,
+	previousID, charID, char string,
+	styles types.TextStyle,
+	deleted bool,
+) []types.SnapshotRun {
+	if len(runs) > 0 && previousID != "" {
+		last := &runs[len(runs)-1]
+		prevID, prevOrigin, errPrev := ParseID(previousID)
+		id, origin, err := ParseID(charID)
+		if errPrev == nil && err == nil && origin == prevOrigin && id == prevID+1 &&
+			last.Deleted == deleted && compareTextStyle(last.Styles, styles) {
+			last.Text += char
+			return runs
+		}
+	}
+	return append(runs, types.SnapshotRun{
+		StartID: charID,
+		Text:    char,
+		Styles:  styles,
+		Deleted: deleted,
+	})
+}
+
+// restoreSnapshot returns the replay state described by a snapshot.
+func restoreSnapshot(snapshot types.BlockSnapshot) (string, map[string]types.TextStyle, []string, []string) {
+	var text string
+	textStyles := make(map[string]types.TextStyle)
+	var charIDs []string
+	var removedIDs []string
+
+	for _, run := range snapshot.Runs {
+		startID, origin, err := ParseID(run.StartID)
+		if err != nil {
+			continue
+		}
+		for i := 0; i < len(run.Text); i++ {
+			charID := fmt.Sprintf("%d@%s", startID+uint64(i), origin)
+			text += run.Text[i : i+1]
+			textStyles[charID] = run.Styles
+			charIDs = append(charIDs, charID)
+			if run.Deleted {
+				removedIDs = append(removedIDs, charID)
+			}
+		}
+	}
+	return text, textStyles, charIDs, removedIDs
+}
+
+// resolveAnchors redirects the insertions placed after a character dropped by
+// a compaction to the closest preceding character still in the snapshot.
+func resolveAnchors(ops []types.CRDTOperation, anchors map[string]string) []types.CRDTOperation {
+	if len(anchors) == 0 {
+		return ops
+	}
+
+	resolve := func(id string) string {
+		for i := 0; i <= len(anchors); i++ {
+			anchorID, dropped := anchors[id]
+			if !dropped {
+				break
+			}
+			id = anchorID
+		}
+		return id
+	}
+
+	resolved := make([]types.CRDTOperation, len(ops))
+	for i, op := range ops {
+		switch insertOp := op.Operation.(type) {
+		case types.CRDTInsertChar:
+			insertOp.AfterID = resolve(insertOp.AfterID)
+			op.Operation = insertOp
+		case types.CRDTInsertText:
+			insertOp.AfterID = resolve(insertOp.AfterID)
+			op.Operation = insertOp
+		}
+		resolved[i] = op
+	}
+	return resolved
+}
+
+// dropRemovedBlocks deletes the blocks whose removal and other operations are
+// all stable and that no remaining operation uses as a parent or a position.
+// It returns the number of blocks dropped.
+func (n *node) dropRemovedBlocks(
+	docID string,
+	doc map[string][]types.CRDTOperation,
+	snapshots map[string]types.BlockSnapshot,
+	frontier types.VersionVector,
+) int {
+	isStable := func(op types.CRDTOperation) bool {
+		return op.OperationID+OpSpan(op)-1 <= frontier[op.Origin]
+	}
+
+	removed := make(map[string]bool)
+	unstable := make(map[string]bool)
+	referenced := make(map[string]bool)
+	for _, op := range doc[docID] {
+		subject := op.BlockID
+		switch blockOp := op.Operation.(type) {
+		case types.CRDTAddBlock:
+			subject = fmt.Sprintf("%d@%s", op.OperationID, op.Origin)
+			referenced[blockOp.AfterBlock] = referenced[blockOp.AfterBlock] || blockOp.AfterBlock != subject
+			referenced[blockOp.ParentBlock] = referenced[blockOp.ParentBlock] || blockOp.ParentBlock != subject
+		case types.CRDTUpdateBlock:
+			referenced[blockOp.AfterBlock] = referenced[blockOp.AfterBlock] || blockOp.AfterBlock != subject
+			referenced[blockOp.ParentBlock] = referenced[blockOp.ParentBlock] || blockOp.ParentBlock != subject
+		case types.CRDTRemoveBlock:
+			subject = blockOp.RemovedBlock
+			if isStable(op) {
+				removed[subject] = true
+			}
+		}
+		if !isStable(op) {
+			unstable[subject] = true
+		}
+	}
+
+	droppable := make(map[string]bool)
+	for blockID := range removed {
+		if unstable[blockID] || referenced[blockID] {
+			continue
+		}
+		contentStable := true
+		for _, op := range doc[blockID] {
+			if !isStable(op) {
+				contentStable = false
+				break
+			}
+		}
+		if contentStable {
+			droppable[blockID] = true
+		}
+	}
+	if len(droppable) == 0 {
+		return 0
+	}
+
+	remaining := make([]types.CRDTOperation, 0, len(doc[docID]))
+	for _, op := range doc[docID] {
+		subject := op.BlockID
+		switch blockOp := op.Operation.(type) {
+		case types.CRDTAddBlock:
+			subject = fmt.Sprintf("%d@%s", op.OperationID, op.Origin)
+		case types.CRDTRemoveBlock:
+			subject = blockOp.RemovedBlock
+		}
+		if !droppable[subject] {
+			remaining = append(remaining, op)
+		}
+	}
+	doc[docID] = remaining
+
+	for blockID := range droppable {
+		delete(doc, blockID)
+		delete(snapshots, blockID)
+	}
+	return len(droppable)
+}
+
+// measureDocument returns the number of operations of a document and the size
+// of its operations and snapshots once encoded.
+func measureDocument(
+	doc map[string][]types.CRDTOperation,
+	snapshots map[string]types.BlockSnapshot,
+) (int, int, error) {
+	count := 0
+	for _, ops := range doc {
+		count += len(ops)
+	}
+
+	opsBytes, err := json.Marshal(doc)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to marshal operations: %w", err)
+	}
+	snapBytes, err := json.Marshal(snapshots)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to marshal snapshots: %w", err)
+	}
+	return count, len(opsBytes) + len(snapBytes), nil
+}
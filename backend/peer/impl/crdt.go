@@ -7,15 +7,22 @@ import (
 	"path/filepath"
 	"sort"
 	"time"
+	"unicode/utf8"
 )
 
 func (n *node) createBlockContent(ops []types.CRDTOperation) []types.InlineContent {
-	ops = n.sortOps(ops)
+	return n.createBlockContentFromSnapshot(types.BlockSnapshot{}, ops)
+}
+
+// createBlockContentFromSnapshot replays the operations of a block on top of
+// the snapshot left by its last compaction.
+func (n *node) createBlockContentFromSnapshot(
+	snapshot types.BlockSnapshot,
+	ops []types.CRDTOperation,
+) []types.InlineContent {
+	ops = n.sortOps(n.expandRunOperations(resolveAnchors(ops, snapshot.Anchors)))
 
-	var text string
-	textStyles := make(map[string]types.TextStyle) // opID -> textStyle
-	var charIDs []string
-	var removedIDs []string
+	text, textStyles, charIDs, removedIDs := restoreSnapshot(snapshot) // textStyles: opID -> textStyle
 
 	n.logCRDT.Debug().Msgf("ops %v", ops)
 
@@ -56,6 +63,110 @@ func (n *node) processOperation(
 	}
 }
 
+// expandRunOperations splits the run-length operations (insertText and
+// deleteRange) into the single character operations they stand for. Edits that
+// later land inside a run address the implied character IDs, so splitting the
+// run before sorting keeps the result identical to the one obtained with
+// single character operations.
+func (n *node) expandRunOperations(ops []types.CRDTOperation) []types.CRDTOperation {
+	expanded := make([]types.CRDTOperation, 0, len(ops))
+	for _, op := range ops {
+		switch runOp := op.Operation.(type) {
+		case types.CRDTInsertText:
+			expanded = append(expanded, expandInsertText(op, runOp)...)
+		case types.CRDTDeleteRange:
+			chars, err := expandDeleteRange(op, runOp)
+			if err != nil {
+				n.logCRDT.Error().Msgf("Error expanding deleteRange: %v", err)
+				continue
+			}
+			expanded = append(expanded, chars...)
+		default:
+			expanded = append(expanded, op)
+		}
+	}
+	return expanded
+}
+
+func expandInsertText(op types.CRDTOperation, insertOp types.CRDTInsertText) []types.CRDTOperation {
+	chars := []rune(insertOp.Text)
+	expanded := make([]types.CRDTOperation, len(chars))
+
+	afterID := insertOp.AfterID
+	for i, char := range chars {
+		charOp := op
+		charOp.Type = types.CRDTInsertCharType
+		charOp.OperationID = op.OperationID + uint64(i)
+		charOp.Operation = types.CRDTInsertChar{
+			OpID:      fmt.Sprintf("%d@%s", charOp.OperationID, op.Origin),
+			AfterID:   afterID,
+			Character: string(char),
+		}
+		expanded[i] = charOp
+		afterID = fmt.Sprintf("%d@%s", charOp.OperationID, op.Origin)
+	}
+	return expanded
+}
+
+func expandDeleteRange(op types.CRDTOperation, deleteOp types.CRDTDeleteRange) ([]types.CRDTOperation, error) {
+	err := validateRun(deleteOp)
+	if err != nil {
+		return nil, err
+	}
+	startID, origin, err := ParseID(deleteOp.StartID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start ID %q: %w", deleteOp.StartID, err)
+	}
+
+	expanded := make([]types.CRDTOperation, deleteOp.Length)
+	for i := uint64(0); i < deleteOp.Length; i++ {
+		charOp := op
+		charOp.Type = types.CRDTDeleteCharType
+		charOp.Operation = types.CRDTDeleteChar{
+			RemovedID: fmt.Sprintf("%d@%s", startID+i, origin),
+		}
+		expanded[i] = charOp
+	}
+	return expanded, nil
+}
+
+// maxRunLength bounds the characters of a run-length operation. A deleteRange
+// is expanded into one operation per character, an unbounded Length sent by a
+// peer would exhaust the memory of every replica.
+const maxRunLength = 1 << 16
+
+// validateRun checks the length of a run-length operation, the other
+// operations are left unchecked.
+func validateRun(op types.CRDTOp) error {
+	switch runOp := op.(type) {
+	case types.CRDTInsertText:
+		if runOp.Text == "" {
+			return fmt.Errorf("empty text")
+		}
+		if length := utf8.RuneCountInString(runOp.Text); length > maxRunLength {
+			return fmt.Errorf("run of %d characters over the limit of %d", length, maxRunLength)
+		}
+	case types.CRDTDeleteRange:
+		if runOp.Length == 0 {
+			return fmt.Errorf("empty range")
+		}
+		if runOp.Length > maxRunLength {
+			return fmt.Errorf("range of %d characters over the limit of %d", runOp.Length, maxRunLength)
+		}
+	}
+	return nil
+}
+
+// OpSpan returns the number of operation IDs consumed by an operation.
+func OpSpan(op types.CRDTOperation) uint64 {
+	if insertOp, ok := op.Operation.(types.CRDTInsertText); ok {
+		if length := uint64(len([]rune(insertOp.Text))); length > 1 {
+			return length
+		}
+	}
+	return 1
+}
+
 func (n *node) processInsertChar(
 	op types.CRDTOperation,
 	opID string,
@@ -74,9 +185,13 @@ func (n *node) processInsertChar(
 	}
 
 	if afterID != lastAfterID {
-		pos := n.getIDIndex(afterID, *charIDs)
-		if pos == -1 {
-			return fmt.Errorf("failed to find afterID in charIDs")
+		// an empty afterID inserts the character at the start of the block
+		pos := -1
+		if afterID != "" {
+			pos = n.getIDIndex(afterID, *charIDs)
+			if pos == -1 {
+				return fmt.Errorf("failed to find afterID in charIDs")
+			}
 		}
 		*charIDs = append((*charIDs)[:pos+1], append([]string{opID}, (*charIDs)[pos+1:]...)...)
 		*text = (*text)[:pos+1] + insertOp.Character + (*text)[pos+1:]
@@ -186,6 +301,17 @@ func (n *node) generateInlineContent(text string,
 	for i, styledText := range styledTexts {
 		n.logCRDT.Debug().Msgf("styledText %v", styledText)
 		cp := styledText
+		if cp.Styles.MentionDocument != "" {
+			// the text covered by a mention mark is a page mention
+			inlineContents[i] = &types.PageMention{
+				CharIDs:    cp.CharIDs,
+				Text:       cp.Text,
+				Styles:     cp.Styles,
+				DocumentID: cp.Styles.MentionDocument,
+				BlockID:    cp.Styles.MentionBlock,
+			}
+			continue
+		}
 		inlineContents[i] = &cp
 	}
 
@@ -195,7 +321,8 @@ func (n *node) generateInlineContent(text string,
 func compareTextStyle(a types.TextStyle, b types.TextStyle) bool {
 	if a.Bold != b.Bold || a.Italic != b.Italic || a.Underline != b.Underline ||
 		a.Strikethrough != b.Strikethrough || a.TextColor != b.TextColor ||
-		a.BackgroundColor != b.BackgroundColor {
+		a.BackgroundColor != b.BackgroundColor || a.MentionDocument != b.MentionDocument ||
+		a.MentionBlock != b.MentionBlock {
 		return false
 	}
 
@@ -282,6 +409,44 @@ func (n *node) CompileDocument(docID string) (string, error) {
 	return n.serializeDocument(finalDocument), nil
 }
 
+// GetDocumentBlocks implements peer.CRDT
+func (n *node) GetDocumentBlocks(docID string) ([]types.DocumentBlock, error) {
+	document, err := n.populateDocumentBlocks(docID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to populate document blocks: %w", err)
+	}
+	return n.documentBlocks(docID, document), nil
+}
+
+// documentBlocks returns the visible blocks of a tree with their characters.
+func (n *node) documentBlocks(docID string, document []types.BlockFactory) []types.DocumentBlock {
+	blocks := make([]types.DocumentBlock, 0, len(document))
+	for _, block := range document {
+		if block.Deleted {
+			continue
+		}
+
+		chars := n.blockChars(docID, block)
+		runes := make([]rune, len(chars))
+		documentBlock := types.DocumentBlock{
+			ID:        block.ID,
+			BlockType: block.BlockType,
+			Props:     block.Props,
+			CharIDs:   make([]string, len(chars)),
+			Styles:    make([]types.TextStyle, len(chars)),
+			Children:  n.documentBlocks(docID, block.Children),
+		}
+		for i, c := range chars {
+			runes[i] = c.char
+			documentBlock.CharIDs[i] = c.id
+			documentBlock.Styles[i] = c.style
+		}
+		documentBlock.Text = string(runes)
+		blocks = append(blocks, documentBlock)
+	}
+	return blocks
+}
+
 func (n *node) populateDocumentBlocks(docID string) ([]types.BlockFactory, error) {
 	document := make([]types.BlockFactory, 0)
 	blockChangeOperations := n.GetDocumentOps(docID)[docID]
@@ -391,7 +556,7 @@ func (n *node) populateBlockContent(docID string, document []types.BlockFactory)
 			continue
 		}
 		blockOperations := n.GetBlockOps(docID, block.ID)
-		newBlock := n.createBlock(docID, block, blockOperations)
+		newBlock := n.createBlock(docID, block, blockOperations, make(map[string]bool))
 		finalDocument = append(finalDocument, newBlock)
 	}
 	return finalDocument
@@ -409,17 +574,24 @@ func (n *node) serializeDocument(finalDocument []types.BlockType) string {
 	return finalJSON
 }
 
+// createBlock compiles a block and its children. path holds the blocks being
+// compiled, from the root block through the transclusions, to detect cycles.
 func (n *node) createBlock(docID string,
 	block types.BlockFactory,
 	blockOperations []types.CRDTOperation,
+	path map[string]bool,
 ) types.BlockType {
+	path[transclusionKey(docID, block.ID)] = true
+	defer delete(path, transclusionKey(docID, block.ID))
+
 	// Create the children blocks if applicable
 	var childrenBlocks []types.BlockType
+	snapshot := n.GetBlockSnapshot(docID, block.ID)
 
 	if block.Children != nil {
 		for _, childBlock := range block.Children {
 			childBlockOperations := n.GetBlockOps(docID, childBlock.ID)
-			childrenBlocks = append(childrenBlocks, n.createBlock(docID, childBlock, childBlockOperations))
+			childrenBlocks = append(childrenBlocks, n.createBlock(docID, childBlock, childBlockOperations, path))
 		}
 	}
 
@@ -430,7 +602,7 @@ func (n *node) createBlock(docID string,
 			BlockType: nil,
 			Default:   block.Props,
 			ID:        block.ID,
-			Content:   n.createBlockContent(blockOperations),
+			Content:   n.createBlockContentFromSnapshot(snapshot, blockOperations),
 			Children:  childrenBlocks,
 		}
 		return newBlock
@@ -440,7 +612,7 @@ func (n *node) createBlock(docID string,
 			Default:   block.Props,
 			ID:        block.ID,
 			Level:     block.Props.Level,
-			Content:   n.createBlockContent(blockOperations),
+			Content:   n.createBlockContentFromSnapshot(snapshot, blockOperations),
 			Children:  childrenBlocks,
 		}
 		return newBlock
@@ -449,7 +621,7 @@ func (n *node) createBlock(docID string,
 			BlockType: nil,
 			Default:   block.Props,
 			ID:        block.ID,
-			Content:   n.createBlockContent(blockOperations),
+			Content:   n.createBlockContentFromSnapshot(snapshot, blockOperations),
 			Children:  childrenBlocks,
 		}
 		return newBlock
@@ -459,7 +631,7 @@ func (n *node) createBlock(docID string,
 			BlockType: nil,
 			Default:   block.Props,
 			ID:        block.ID,
-			Content:   n.createBlockContent(blockOperations),
+			Content:   n.createBlockContentFromSnapshot(snapshot, blockOperations),
 			Children:  childrenBlocks,
 		}
 		return newBlock
@@ -483,6 +655,15 @@ func (n *node) createBlock(docID string,
 			Children:  nil,
 		}
 		return newBlock
+	case types.TransclusionBlockType:
+		newBlock := &types.TransclusionBlock{
+			BlockType: nil,
+			Default:   block.Props,
+			ID:        block.ID,
+			Children:  childrenBlocks,
+		}
+		newBlock.Content, newBlock.Error = n.createTranscludedBlock(block.Props, path)
+		return newBlock
 	default:
 		return nil
 	}
@@ -766,6 +947,10 @@ func (n *node) updateBlockProps(blockProps types.DefaultBlockProps,
 	if updatedProps.TextAlignment != "" {
 		blockProps.TextAlignment = updatedProps.TextAlignment
 	}
+	if updatedProps.SourceDocument != "" {
+		blockProps.SourceDocument = updatedProps.SourceDocument
+		blockProps.SourceBlock = updatedProps.SourceBlock
+	}
 
 	return blockProps
 }
@@ -785,6 +970,9 @@ func (n *node) addMark2TextStyle(textStyle types.TextStyle, toAdd types.CRDTAddM
 		textStyle.TextColor = toAdd.Options.Color
 	case types.BackgroundColor:
 		textStyle.BackgroundColor = toAdd.Options.Color
+	case types.Mention:
+		textStyle.MentionDocument = toAdd.Options.DocumentID
+		textStyle.MentionBlock = toAdd.Options.BlockID
 	}
 
 	return textStyle
@@ -800,6 +988,9 @@ func (n *node) removeMark2TextStyle(textStyle types.TextStyle, toRemove string)
 		textStyle.Underline = false
 	case types.Strikethrough:
 		textStyle.Strikethrough = false
+	case types.Mention:
+		textStyle.MentionDocument = ""
+		textStyle.MentionBlock = ""
 	}
 
 	return textStyle
@@ -852,6 +1043,8 @@ func (n *node) StoreDocument(docID, doc string) error {
 
 // GetDocumentList returns a list of documents that are stored in the peer.
 // The documents are either in the document directory or in the Editor.
+// The documents of the Editor are listed in the depth-first order of the page
+// tree, archived and deleted documents and their subpages are not listed.
 func (n *node) GetDocumentList() ([]string, error) {
 	// Get the directory to store documents
 	// docDir := n.conf.DocumentDir
@@ -864,15 +1057,12 @@ func (n *node) GetDocumentList() ([]string, error) {
 
 	files := make([]os.DirEntry, 0)
 
-	// Get the editor
-	editor := n.GetEditor()
-
 	// Get the list of documents in the editor
-	editorDocs := make([]string, 0, len(editor))
-
-	for docID := range editor {
-		editorDocs = append(editorDocs, docID)
+	tree, err := n.GetPageTree()
+	if err != nil {
+		return nil, err
 	}
+	editorDocs := flattenPageTree(tree)
 
 	// Combine the list of documents in the directory and the editor
 	docList := make([]string, 0, len(files)+len(editorDocs))
@@ -902,202 +1092,32 @@ func (n *node) AddNewDocument(docID string) error {
 	return nil
 }
 
-func (n *node) SaveTransactions(transactions types.CRDTOperationsMessage) error {
-	operations := transactions.Operations
-	n.logCRDT.Debug().Msgf("SaveTransactions: %d operations", len(operations))
-
-	// Step 0: Cast all interfaces to the respective types
-	// Use an indexed loop so we can get a pointer to the actual slice element
-	for i := range operations {
-		n.CastOperation(&operations[i])
-	}
+// processAndBroadcast applies the operations of a transaction and sends them
+// to the peers. The operations are applied when it returns.
+func (n *node) processAndBroadcast(transactions types.CRDTOperationsMessage) error {
+	shared, private, privateOrder := n.splitPrivateOperations(transactions.Operations)
 
-	// Step 1: Update CRDT states and initialize operations
-	for i := range operations {
-		if err := n.updateCRDTState(&operations[i]); err != nil {
+	if len(shared) > 0 || len(privateOrder) == 0 {
+		msgs, err := n.operationsMessages(types.CRDTOperationsMessage{Operations: shared}, true)
+		if err != nil {
 			return err
 		}
-	}
-
-	// Step 2: Update operation attributes
-	for i := range operations {
-		if err := n.updateOperationAttributes(&operations[i]); err != nil {
-			return err
+		for _, msg := range msgs {
+			err = n.broadcast(msg, true)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
-	transactions.Operations = operations
-
-	// Reset temporary IDs
-	n.crdtState.ResetTmp()
-
-	// Step 3: Process and broadcast the operations
-	return n.processAndBroadcast(transactions)
-}
-
-func (n *node) updateCRDTState(operation *types.CRDTOperation) error {
-	opDocID := operation.DocumentID
-	operation.Origin = n.conf.Socket.GetAddress()
-
-	// Increment CRDT state for the document
-	newState := n.crdtState.GetState(opDocID) + 1
-	n.crdtState.SetState(opDocID, newState)
-
-	tmp := operation.OperationID
-	n.logCRDT.Debug().Msgf("updateCRDTState: %d -> %d", tmp, newState)
-	n.crdtState.SetTmpID(tmp, newState)
-
-	n.logCRDT.Debug().Msgf("tmp %d :", n.crdtState.GetTmpID(tmp))
-
-	// Assign the new operation ID
-	operation.OperationID = newState
-	return nil
-}
-
-func (n *node) updateOperationAttributes(operation *types.CRDTOperation) error {
-	// Update blockID reference
-	blockID, err := n.updateBlockReferences(&operation.BlockID)
-	if err != nil {
-		return fmt.Errorf("failed to update block references: %w", err)
-	}
-	operation.BlockID = blockID
-
-	// Update other block references
-	switch op := operation.Operation.(type) {
-	case types.CRDTAddBlock:
-		return n.handleCRDTAddBlock(operation, op)
-	case types.CRDTRemoveBlock:
-		return n.handleCRDTRemoveBlock(operation, op)
-	case types.CRDTUpdateBlock:
-		return n.handleCRDTUpdateBlock(operation, op)
-	case types.CRDTInsertChar:
-		return n.handleCRDTInsertChar(operation, op)
-	case types.CRDTDeleteChar:
-		return n.handleCRDTDeleteChar(operation, op)
-	case types.CRDTAddMark:
-		return n.handleCRDTAddMark(operation, op)
-	case types.CRDTRemoveMark:
-		return n.handleCRDTRemoveMark(operation, op)
-	default:
-		return fmt.Errorf("unknown CRDT operation type: %T", op)
-	}
-}
-
-func (n *node) handleCRDTAddBlock(operation *types.CRDTOperation, op types.CRDTAddBlock) error {
-	after, err1 := n.updateBlockReferences(&op.AfterBlock)
-	parent, err2 := n.updateBlockReferences(&op.ParentBlock)
-	if err1 != nil || err2 != nil {
-		return fmt.Errorf("failed to update block references: %w", err1)
-	}
-	op.AfterBlock = after
-	op.ParentBlock = parent
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) handleCRDTRemoveBlock(operation *types.CRDTOperation, op types.CRDTRemoveBlock) error {
-	removed, err := n.updateBlockReferences(&op.RemovedBlock)
-	if err != nil {
-		return fmt.Errorf("failed to update block references: %w", err)
-	}
-	op.RemovedBlock = removed
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) handleCRDTUpdateBlock(operation *types.CRDTOperation, op types.CRDTUpdateBlock) error {
-	updated, err1 := n.updateBlockReferences(&op.UpdatedBlock)
-	after, err2 := n.updateBlockReferences(&op.AfterBlock)
-	parent, err3 := n.updateBlockReferences(&op.ParentBlock)
-	if err1 != nil || err2 != nil || err3 != nil {
-		return fmt.Errorf("failed to update block references: %w", err1)
-	}
-	op.UpdatedBlock = updated
-	op.AfterBlock = after
-	op.ParentBlock = parent
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) handleCRDTInsertChar(operation *types.CRDTOperation, op types.CRDTInsertChar) error {
-	block, err := n.updateBlockReferences(&op.AfterID)
-	if err != nil {
-		return fmt.Errorf("failed to update block references: %w", err)
-	}
-	op.AfterID = block
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) handleCRDTDeleteChar(operation *types.CRDTOperation, op types.CRDTDeleteChar) error {
-	block, err := n.updateBlockReferences(&op.RemovedID)
-	if err != nil {
-		return fmt.Errorf("failed to update block references: %w", err)
-	}
-	op.RemovedID = block
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) handleCRDTAddMark(operation *types.CRDTOperation, op types.CRDTAddMark) error {
-	start, err1 := n.updateBlockReferences(&op.Start.OpID)
-	end, err2 := n.updateBlockReferences(&op.End.OpID)
-	if err1 != nil || err2 != nil {
-		return fmt.Errorf("failed to update block references: %w", err1)
-	}
-	op.Start.OpID = start
-	op.End.OpID = end
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) handleCRDTRemoveMark(operation *types.CRDTOperation, op types.CRDTRemoveMark) error {
-	start, err1 := n.updateBlockReferences(&op.Start.OpID)
-	end, err2 := n.updateBlockReferences(&op.End.OpID)
-	if err1 != nil || err2 != nil {
-		return fmt.Errorf("failed to update block references: %w", err1)
-	}
-	op.Start.OpID = start
-	op.End.OpID = end
-	operation.Operation = op
-	return nil
-}
-
-func (n *node) updateBlockReferences(ref *string) (string, error) {
-	if *ref == "" {
-		n.logCRDT.Warn().Msg("updateBlockReferences: empty reference")
-		return "", nil
-	}
-
-	// Parse the ID and username
-	id, username, err := ParseID(*ref)
-	if err != nil {
-		n.logCRDT.Error().Msgf("updateBlockReferences: %s", err)
-		return "", err
-	}
-	// Check if the ID is a temporary ID
-	if username == "temp" {
-		id = n.crdtState.GetTmpID(id)
-		username = n.conf.Socket.GetAddress()
-		res, err := ReconstructOpID(id, username)
+	// the operations of the private documents are never gossiped
+	for _, docID := range privateOrder {
+		err := n.sendPrivately(docID, private[docID])
 		if err != nil {
-			n.logCRDT.Error().Msgf("updateBlockReferences: %s", err)
-			return "", err
+			return err
 		}
-		n.logCRDT.Debug().Msgf("updateBlockReferences: %s -> %s", *ref, res)
-
-		return res, nil
 	}
-
-	return *ref, nil
-}
-
-func (n *node) processAndBroadcast(transactions types.CRDTOperationsMessage) error {
-	msg, err := n.conf.MessageRegistry.MarshalMessage(transactions)
-	if err != nil {
-		return err
-	}
-	return n.Broadcast(msg)
+	return nil
 }
 
 // -------------------------------------------------------------------
@@ -1133,3 +1153,11 @@ func (n *node) ExportCRDTAddMark(addMarkOp types.CRDTAddMark) error {
 func (n *node) ExportCRDTRemoveMark(removeMarkOp types.CRDTRemoveMark) error {
 	return nil
 }
+
+func (n *node) ExportCRDTInsertText(insertTextOp types.CRDTInsertText) error {
+	return nil
+}
+
+func (n *node) ExportCRDTDeleteRange(deleteRangeOp types.CRDTDeleteRange) error {
+	return nil
+}
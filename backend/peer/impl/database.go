@@ -0,0 +1,500 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateDatabase implements peer.CRDT
+func (n *node) CreateDatabase(title string) (string, error) {
+	dbID := n.newID()
+	err := n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: newDocumentOperations(dbID, title, types.MetadataKind, types.DocumentDatabase),
+	})
+	if err != nil {
+		return "", err
+	}
+	return dbID, nil
+}
+
+// AddDatabaseProperty implements peer.CRDT
+func (n *node) AddDatabaseProperty(dbID string, property types.DatabaseProperty) (string, error) {
+	property.ID = n.newID()
+	property.Deleted = false
+	err := n.SetDatabaseProperty(dbID, property)
+	if err != nil {
+		return "", err
+	}
+	return property.ID, nil
+}
+
+// SetDatabaseProperty implements peer.CRDT
+func (n *node) SetDatabaseProperty(dbID string, property types.DatabaseProperty) error {
+	if n.GetDocumentMetadata(dbID).Kind != types.DocumentDatabase {
+		return fmt.Errorf("document %s is not a database", dbID)
+	}
+	if property.ID == "" || property.ID == types.TitleProperty {
+		return fmt.Errorf("invalid property ID %q", property.ID)
+	}
+
+	switch property.Type {
+	case types.PropertyText, types.PropertyNumber, types.PropertyDate, types.PropertyCheckbox:
+	case types.PropertySelect:
+		if len(property.Options) == 0 {
+			return fmt.Errorf("select property %s has no option", property.ID)
+		}
+	case types.PropertyRelation:
+		if property.RelationDatabase == "" {
+			return fmt.Errorf("relation property %s has no database", property.ID)
+		}
+	default:
+		return fmt.Errorf("unknown property type %q", property.Type)
+	}
+
+	return n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			Type:        types.CRDTSetPropertyType,
+			OperationID: 1,
+			DocumentID:  dbID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   types.CRDTSetProperty{Property: property},
+		}},
+	})
+}
+
+// RemoveDatabaseProperty implements peer.CRDT
+func (n *node) RemoveDatabaseProperty(dbID, propertyID string) error {
+	property, err := n.getDatabaseProperty(dbID, propertyID)
+	if err != nil {
+		return err
+	}
+	property.Deleted = true
+	return n.SetDatabaseProperty(dbID, property)
+}
+
+// GetDatabaseSchema implements peer.CRDT
+func (n *node) GetDatabaseSchema(dbID string) ([]types.DatabaseProperty, error) {
+	if n.GetDocumentMetadata(dbID).Kind != types.DocumentDatabase {
+		return nil, fmt.Errorf("document %s is not a database", dbID)
+	}
+
+	schema := make([]types.DatabaseProperty, 0)
+	for _, op := range lwwRegisters(n.GetBlockOps(dbID, types.MetadataBlockID)) {
+		propertyOp, ok := op.Operation.(types.CRDTSetProperty)
+		if !ok || propertyOp.Property.Deleted {
+			continue
+		}
+		schema = append(schema, propertyOp.Property)
+	}
+
+	// properties are listed in the order they were created
+	sort.Slice(schema, func(i, j int) bool {
+		idI, originI, _ := ParseID(schema[i].ID)
+		idJ, originJ, _ := ParseID(schema[j].ID)
+		if idI == idJ {
+			return originI < originJ
+		}
+		return idI < idJ
+	})
+	return schema, nil
+}
+
+// AddDatabaseRow implements peer.CRDT
+func (n *node) AddDatabaseRow(dbID, title string) (string, error) {
+	if n.GetDocumentMetadata(dbID).Kind != types.DocumentDatabase {
+		return "", fmt.Errorf("document %s is not a database", dbID)
+	}
+
+	rowID := n.newID()
+	err := n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: newDocumentOperations(rowID, title, types.MetadataDatabase, dbID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return rowID, nil
+}
+
+// SetDatabaseCell implements peer.CRDT
+func (n *node) SetDatabaseCell(rowID, propertyID, value string) error {
+	dbID := n.GetDocumentMetadata(rowID).Database
+	if dbID == "" {
+		return fmt.Errorf("document %s is not a database row", rowID)
+	}
+
+	property, err := n.getDatabaseProperty(dbID, propertyID)
+	if err != nil {
+		return err
+	}
+	err = n.validateCell(property, value)
+	if err != nil {
+		return err
+	}
+
+	return n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			Type:        types.CRDTSetCellType,
+			OperationID: 1,
+			DocumentID:  rowID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   types.CRDTSetCell{PropertyID: propertyID, Value: value},
+		}},
+	})
+}
+
+// QueryDatabase implements peer.CRDT
+func (n *node) QueryDatabase(dbID string, query types.DatabaseQuery) ([]types.DatabaseRow, error) {
+	schema, err := n.GetDatabaseSchema(dbID)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]types.DatabaseProperty{
+		types.TitleProperty: {ID: types.TitleProperty, Name: types.TitleProperty, Type: types.PropertyText},
+	}
+	for _, property := range schema {
+		properties[property.ID] = property
+	}
+
+	for _, filter := range query.Filters {
+		property, exists := properties[filter.PropertyID]
+		if !exists {
+			return nil, fmt.Errorf("unknown property %s", filter.PropertyID)
+		}
+		err := validateFilter(property, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, order := range query.Sorts {
+		if _, exists := properties[order.PropertyID]; !exists {
+			return nil, fmt.Errorf("unknown property %s", order.PropertyID)
+		}
+	}
+
+	rows := make([]types.DatabaseRow, 0)
+	createdAt := make(map[string]int64)
+	for _, docID := range n.getDocumentIDs() {
+		summary := n.GetDocumentMetadata(docID)
+		if summary.Database != dbID || summary.Status != types.DocumentActive {
+			continue
+		}
+		row := n.getDatabaseRow(summary, properties)
+		if !matchFilters(properties, row, query.Filters) {
+			continue
+		}
+		rows = append(rows, row)
+		createdAt[docID] = summary.CreatedAt
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		for _, order := range query.Sorts {
+			property := properties[order.PropertyID]
+			c := compareCells(property, cellValue(rows[i], property.ID), cellValue(rows[j], property.ID))
+			if c != 0 {
+				return (c < 0) != order.Descending
+			}
+		}
+		if createdAt[rows[i].DocumentID] != createdAt[rows[j].DocumentID] {
+			return createdAt[rows[i].DocumentID] < createdAt[rows[j].DocumentID]
+		}
+		return rows[i].DocumentID < rows[j].DocumentID
+	})
+	return rows, nil
+}
+
+// newDocumentOperations returns the operations creating a document with a
+// title, its creation time and one more metadata field.
+func newDocumentOperations(docID, title, field, value string) []types.CRDTOperation {
+	metadata := []types.CRDTSetMetadata{
+		{Field: types.MetadataTitle, Value: title},
+		{Field: types.MetadataCreatedAt, Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		{Field: field, Value: value},
+	}
+
+	ops := make([]types.CRDTOperation, len(metadata))
+	for i, metadataOp := range metadata {
+		ops[i] = types.CRDTOperation{
+			Type:        types.CRDTSetMetadataType,
+			OperationID: uint64(i + 1),
+			DocumentID:  docID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   metadataOp,
+		}
+	}
+	return ops
+}
+
+// getDatabaseProperty returns a property of the schema of a database.
+func (n *node) getDatabaseProperty(dbID, propertyID string) (types.DatabaseProperty, error) {
+	schema, err := n.GetDatabaseSchema(dbID)
+	if err != nil {
+		return types.DatabaseProperty{}, err
+	}
+	for _, property := range schema {
+		if property.ID == propertyID {
+			return property, nil
+		}
+	}
+	return types.DatabaseProperty{}, fmt.Errorf("database %s has no property %s", dbID, propertyID)
+}
+
+// getDatabaseRow returns the cells of a row for the given properties. The
+// cells of removed properties are left out.
+func (n *node) getDatabaseRow(summary types.DocumentSummary, properties map[string]types.DatabaseProperty) types.DatabaseRow {
+	row := types.DatabaseRow{
+		DocumentID: summary.DocumentID,
+		Title:      summary.Title,
+		Cells:      make(map[string]string),
+	}
+	for _, op := range lwwRegisters(n.GetBlockOps(summary.DocumentID, types.MetadataBlockID)) {
+		cellOp, ok := op.Operation.(types.CRDTSetCell)
+		if !ok {
+			continue
+		}
+		if _, exists := properties[cellOp.PropertyID]; exists && cellOp.Value != "" {
+			row.Cells[cellOp.PropertyID] = cellOp.Value
+		}
+	}
+	return row
+}
+
+// validateCell checks that a value fits the type of a property. The empty
+// value clears a cell.
+func (n *node) validateCell(property types.DatabaseProperty, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch property.Type {
+	case types.PropertyRelation:
+		var rowIDs []string
+		if err := json.Unmarshal([]byte(value), &rowIDs); err != nil {
+			return fmt.Errorf("invalid relation %q: %w", value, err)
+		}
+		for _, rowID := range rowIDs {
+			if n.GetDocumentMetadata(rowID).Database != property.RelationDatabase {
+				return fmt.Errorf("document %s is not a row of database %s", rowID, property.RelationDatabase)
+			}
+		}
+		return nil
+	default:
+		return validateValue(property, value)
+	}
+}
+
+// validateValue checks that a value fits the type of a property, without
+// looking at the rows a relation references.
+func validateValue(property types.DatabaseProperty, value string) error {
+	switch property.Type {
+	case types.PropertyNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("invalid number %q: %w", value, err)
+		}
+	case types.PropertySelect:
+		for _, option := range property.Options {
+			if option == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not an option of property %s", value, property.ID)
+	case types.PropertyDate:
+		if _, ok := parseDate(value); !ok {
+			return fmt.Errorf("invalid date %q", value)
+		}
+	case types.PropertyCheckbox:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid checkbox %q: %w", value, err)
+		}
+	case types.PropertyRelation:
+		var rowIDs []string
+		if err := json.Unmarshal([]byte(value), &rowIDs); err != nil {
+			return fmt.Errorf("invalid relation %q: %w", value, err)
+		}
+	}
+	return nil
+}
+
+// validateFilter checks the operator of a filter and the value it compares
+// the cells with.
+func validateFilter(property types.DatabaseProperty, filter types.DatabaseFilter) error {
+	switch filter.Operator {
+	case types.FilterIsEmpty, types.FilterIsNotEmpty:
+		return nil
+	case types.FilterContains:
+		// a relation contains a row ID, a text contains any text
+		return nil
+	case types.FilterEquals, types.FilterNotEquals, types.FilterGreaterThan, types.FilterLessThan:
+		if property.Type == types.PropertyRelation && filter.Operator != types.FilterEquals &&
+			filter.Operator != types.FilterNotEquals {
+			return fmt.Errorf("relation property %s cannot be compared", property.ID)
+		}
+		return validateValue(property, filter.Value)
+	default:
+		return fmt.Errorf("unknown filter operator %q", filter.Operator)
+	}
+}
+
+// matchFilters tells if a row matches all the filters.
+func matchFilters(properties map[string]types.DatabaseProperty, row types.DatabaseRow, filters []types.DatabaseFilter) bool {
+	for _, filter := range filters {
+		property := properties[filter.PropertyID]
+		value := cellValue(row, property.ID)
+
+		var match bool
+		switch filter.Operator {
+		case types.FilterIsEmpty:
+			match = isEmptyCell(property, value)
+		case types.FilterIsNotEmpty:
+			match = !isEmptyCell(property, value)
+		case types.FilterEquals:
+			match = compareCells(property, value, filter.Value) == 0
+		case types.FilterNotEquals:
+			match = compareCells(property, value, filter.Value) != 0
+		case types.FilterGreaterThan:
+			match = !isEmptyCell(property, value) && compareCells(property, value, filter.Value) > 0
+		case types.FilterLessThan:
+			match = !isEmptyCell(property, value) && compareCells(property, value, filter.Value) < 0
+		case types.FilterContains:
+			match = cellContains(property, value, filter.Value)
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// cellValue returns the value of a cell, the title for types.TitleProperty.
+func cellValue(row types.DatabaseRow, propertyID string) string {
+	if propertyID == types.TitleProperty {
+		return row.Title
+	}
+	return row.Cells[propertyID]
+}
+
+// isEmptyCell tells if a cell has no value. An unchecked checkbox and an
+// empty relation are empty.
+func isEmptyCell(property types.DatabaseProperty, value string) bool {
+	switch property.Type {
+	case types.PropertyCheckbox:
+		checked, _ := strconv.ParseBool(value)
+		return !checked
+	case types.PropertyRelation:
+		return len(relationRows(value)) == 0
+	default:
+		return value == ""
+	}
+}
+
+// cellContains tells if a cell contains a value: a relation contains a row
+// ID, other cells contain a text regardless of the case.
+func cellContains(property types.DatabaseProperty, value, contained string) bool {
+	if property.Type == types.PropertyRelation {
+		for _, rowID := range relationRows(value) {
+			if rowID == contained {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(contained))
+}
+
+// compareCells compares two values of a property according to its type.
+// Values that do not fit the type, e.g. after the type changed, are compared
+// as empty values, which come first.
+func compareCells(property types.DatabaseProperty, a, b string) int {
+	switch property.Type {
+	case types.PropertyNumber:
+		numA, errA := strconv.ParseFloat(a, 64)
+		numB, errB := strconv.ParseFloat(b, 64)
+		switch {
+		case errA != nil || errB != nil:
+			return compareValidity(errA == nil, errB == nil)
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		default:
+			return 0
+		}
+	case types.PropertyDate:
+		dateA, okA := parseDate(a)
+		dateB, okB := parseDate(b)
+		if !okA || !okB {
+			return compareValidity(okA, okB)
+		}
+		return dateA.Compare(dateB)
+	case types.PropertyCheckbox:
+		checkedA, _ := strconv.ParseBool(a)
+		checkedB, _ := strconv.ParseBool(b)
+		return compareValidity(checkedA, checkedB)
+	case types.PropertySelect:
+		// options are ordered as in the schema
+		return compareIndex(optionIndex(property.Options, a), optionIndex(property.Options, b))
+	case types.PropertyRelation:
+		return strings.Compare(strings.Join(relationRows(a), ","), strings.Join(relationRows(b), ","))
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// compareValidity orders an invalid or false value before a valid or true
+// one.
+func compareValidity(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func compareIndex(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// optionIndex returns the index of a select option, -1 if the value is not an
+// option.
+func optionIndex(options []string, value string) int {
+	for i, option := range options {
+		if option == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// relationRows returns the row IDs of a relation cell.
+func relationRows(value string) []string {
+	var rowIDs []string
+	if value == "" || json.Unmarshal([]byte(value), &rowIDs) != nil {
+		return nil
+	}
+	return rowIDs
+}
+
+// parseDate parses a date cell, either a day or a RFC 3339 time.
+func parseDate(value string) (time.Time, bool) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, true
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,460 @@
+package impl
+
+import (
+	"Node-tion/backend/identity"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/types"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// documentKeySize is the size of the key of a document, in bytes.
+const documentKeySize = 32
+
+// maxPendingKeys is the number of keys of a document kept until their sender
+// is known as an owner, the oldest ones are dropped first.
+const maxPendingKeys = 8
+
+// errNotOwner rejects a key whose sender is not known as an owner of its
+// document, which may only mean its access did not arrive yet.
+var errNotOwner = errors.New("not an owner")
+
+// DocumentKeys is a map of document ID to the keys encrypting its operations,
+// one per epoch. The key of the latest epoch encrypts the new operations.
+type DocumentKeys struct {
+	mu      sync.Mutex
+	keys    map[string]map[uint][]byte
+	epochs  map[string]uint
+	pending map[string][]types.DocumentKeyMessage // keys received before the access of their sender
+	awaited map[string]uint                       // epochs of the keys missing to read operations received
+}
+
+// Await records that operations of a document were dropped for lack of the
+// key of an epoch.
+func (d *DocumentKeys) Await(docID string, epoch uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if epoch > d.awaited[docID] {
+		d.awaited[docID] = epoch
+	}
+}
+
+// Awaited returns the epoch of the key awaited for a document, if operations
+// were dropped for lack of it.
+func (d *DocumentKeys) Awaited(docID string) (uint, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	epoch, awaited := d.awaited[docID]
+	return epoch, awaited
+}
+
+// AddPending keeps a key until the access of its sender arrives
+func (d *DocumentKeys) AddPending(keyMsg types.DocumentKeyMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pending := append(d.pending[keyMsg.DocumentID], keyMsg)
+	if len(pending) > maxPendingKeys {
+		pending = pending[len(pending)-maxPendingKeys:]
+	}
+	d.pending[keyMsg.DocumentID] = pending
+}
+
+// TakePending returns and forgets the pending keys of a document
+func (d *DocumentKeys) TakePending(docID string) []types.DocumentKeyMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pending := d.pending[docID]
+	delete(d.pending, docID)
+	return pending
+}
+
+// Get returns the current key of a document and its epoch, if it is encrypted
+func (d *DocumentKeys) Get(docID string) ([]byte, uint, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	epoch := d.epochs[docID]
+	key, exists := d.keys[docID][epoch]
+	return key, epoch, exists
+}
+
+// GetEpoch returns the key of a document for an epoch
+func (d *DocumentKeys) GetEpoch(docID string, epoch uint) ([]byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, exists := d.keys[docID][epoch]
+	return key, exists
+}
+
+// Set sets the key of a document for an epoch. It becomes the current key
+// unless a later epoch is known.
+func (d *DocumentKeys) Set(docID string, epoch uint, key []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.keys[docID] == nil {
+		d.keys[docID] = make(map[uint][]byte)
+	}
+	d.keys[docID][epoch] = key
+	if current, known := d.epochs[docID]; !known || epoch > current {
+		d.epochs[docID] = epoch
+	}
+	if awaited, exists := d.awaited[docID]; exists && epoch >= awaited {
+		delete(d.awaited, docID)
+	}
+}
+
+// EncryptDocument implements peer.CRDT
+func (n *node) EncryptDocument(docID string) error {
+	err := n.checkWritable()
+	if err != nil {
+		return err
+	}
+	if _, _, exists := n.documentKeys.Get(docID); exists {
+		return fmt.Errorf("document %s is already encrypted", docID)
+	}
+
+	key, err := newDocumentKey()
+	if err != nil {
+		return err
+	}
+	n.documentKeys.Set(docID, 0, key)
+	return nil
+}
+
+// newDocumentKey returns a random document key.
+func newDocumentKey() ([]byte, error) {
+	key := make([]byte, documentKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// IsDocumentEncrypted implements peer.CRDT
+func (n *node) IsDocumentEncrypted(docID string) bool {
+	_, _, exists := n.documentKeys.Get(docID)
+	return exists
+}
+
+// ShareDocumentKey implements peer.CRDT
+func (n *node) ShareDocumentKey(docID string, collaborators ...string) error {
+	err := n.checkWritable()
+	if err != nil {
+		return err
+	}
+	if n.conf.Identity == nil {
+		return fmt.Errorf("sharing a key requires an identity")
+	}
+	key, epoch, exists := n.documentKeys.Get(docID)
+	if !exists {
+		return fmt.Errorf("document %s is not encrypted", docID)
+	}
+
+	for _, collaborator := range collaborators {
+		addr, known := n.ResolveIdentity(collaborator)
+		if !known {
+			return fmt.Errorf("unknown collaborator %s", collaborator)
+		}
+		keyMsg, err := n.documentKeyMessage(docID, collaborator, epoch, key)
+		if err != nil {
+			return err
+		}
+		n.queueReliably(addr, keyMsg)
+	}
+	return nil
+}
+
+// documentKeyMessage returns the message giving the key of a document for an
+// epoch to a collaborator, sealed for it and signed by us.
+func (n *node) documentKeyMessage(docID, collaborator string, epoch uint, key []byte) (transport.Message, error) {
+	sealed, err := identity.Seal(collaborator, key)
+	if err != nil {
+		return transport.Message{}, fmt.Errorf("failed to seal key for %s: %w", collaborator, err)
+	}
+
+	keyMsg := types.DocumentKeyMessage{
+		DocumentID: docID,
+		Recipient:  collaborator,
+		Epoch:      epoch,
+		SealedKey:  sealed,
+		Sender:     n.conf.Identity.ID,
+	}
+	buf, err := documentKeyBytes(keyMsg)
+	if err != nil {
+		return transport.Message{}, fmt.Errorf("failed to encode key message: %w", err)
+	}
+	keyMsg.Signature = n.conf.Identity.Sign(buf)
+
+	return n.conf.MessageRegistry.MarshalMessage(keyMsg)
+}
+
+// operationsMessages returns the messages carrying the operations of a
+// transaction: one plaintext message for the documents that are neither
+// encrypted nor restricted, and one message per other document. The
+// operations of an encrypted document are encrypted, and the ones of a
+// restricted document are sent privately to its members if toMembers is set.
+func (n *node) operationsMessages(transactions types.CRDTOperationsMessage, toMembers bool) ([]transport.Message, error) {
+	docOps := make(map[string][]types.CRDTOperation)
+	docOrder := make([]string, 0)
+	for _, op := range transactions.Operations {
+		if _, exists := docOps[op.DocumentID]; !exists {
+			docOrder = append(docOrder, op.DocumentID)
+		}
+		docOps[op.DocumentID] = append(docOps[op.DocumentID], op)
+	}
+
+	plain := make([]types.CRDTOperation, 0, len(transactions.Operations))
+	msgs := make([]transport.Message, 0, len(docOrder)+1)
+	for _, docID := range docOrder {
+		ops := docOps[docID]
+		_, _, encrypted := n.documentKeys.Get(docID)
+		var recipients map[string]struct{}
+		restricted := false
+		if toMembers {
+			recipients, restricted = n.memberAddresses(docID, ops)
+		}
+		if !encrypted && !restricted {
+			plain = append(plain, ops...)
+			continue
+		}
+
+		var msg transport.Message
+		var err error
+		if encrypted {
+			msg, err = n.encryptOperations(docID, ops)
+		} else {
+			msg, err = n.conf.MessageRegistry.MarshalMessage(types.CRDTOperationsMessage{Operations: ops})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if restricted {
+			msg, err = n.conf.MessageRegistry.MarshalMessage(types.PrivateMessage{
+				Recipients: recipients,
+				Msg:        &msg,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if len(plain) > 0 || len(msgs) == 0 {
+		msg, err := n.conf.MessageRegistry.MarshalMessage(types.CRDTOperationsMessage{Operations: plain})
+		if err != nil {
+			return nil, err
+		}
+		msgs = append([]transport.Message{msg}, msgs...)
+	}
+	return msgs, nil
+}
+
+// encryptOperations returns the message carrying the operations of an
+// encrypted document, encrypted with its current key.
+func (n *node) encryptOperations(docID string, ops []types.CRDTOperation) (transport.Message, error) {
+	key, epoch, _ := n.documentKeys.Get(docID)
+	aead, err := documentAEAD(key)
+	if err != nil {
+		return transport.Message{}, err
+	}
+
+	plaintext, err := json.Marshal(types.CRDTOperationsMessage{Operations: ops})
+	if err != nil {
+		return transport.Message{}, fmt.Errorf("failed to encode operations: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return transport.Message{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return n.conf.MessageRegistry.MarshalMessage(types.EncryptedCRDTOperationsMessage{
+		DocumentID: docID,
+		Epoch:      epoch,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(docID)),
+	})
+}
+
+// decryptOperations returns the operations of an encrypted message, which
+// must all belong to its document.
+func decryptOperations(key []byte, msg types.EncryptedCRDTOperationsMessage) (types.CRDTOperationsMessage, error) {
+	aead, err := documentAEAD(key)
+	if err != nil {
+		return types.CRDTOperationsMessage{}, err
+	}
+	plaintext, err := aead.Open(nil, msg.Nonce, msg.Ciphertext, []byte(msg.DocumentID))
+	if err != nil {
+		return types.CRDTOperationsMessage{}, fmt.Errorf("failed to decrypt operations: %w", err)
+	}
+
+	var crdtMsg types.CRDTOperationsMessage
+	err = json.Unmarshal(plaintext, &crdtMsg)
+	if err != nil {
+		return types.CRDTOperationsMessage{}, fmt.Errorf("failed to decode operations: %w", err)
+	}
+	for _, op := range crdtMsg.Operations {
+		if op.DocumentID != msg.DocumentID {
+			return types.CRDTOperationsMessage{}, fmt.Errorf("operation of document %s encrypted for %s",
+				op.DocumentID, msg.DocumentID)
+		}
+	}
+	return crdtMsg, nil
+}
+
+// documentAEAD returns the cipher of a document key.
+func documentAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptedCRDTOperationsMessageCallback handles the
+// EncryptedCRDTOperationsMessage
+func (n *node) EncryptedCRDTOperationsMessageCallback(msg types.Message, pkt transport.Packet) error {
+	encryptedMsg, ok := msg.(*types.EncryptedCRDTOperationsMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not an EncryptedCRDTOperationsMessage")
+	}
+
+	// once operations were dropped for lack of a key, the next ones are
+	// dropped too until the key arrives: applying them would move the version
+	// vector past the dropped ones, which would never be sent again. The
+	// operations are then requested with the version vector before the gap.
+	docID := encryptedMsg.DocumentID
+	if epoch, awaited := n.documentKeys.Awaited(docID); awaited {
+		n.logCRDT.Info().Msgf("Ignored operations of %s until its key of epoch %d", docID, epoch)
+		return nil
+	}
+	key, exists := n.documentKeys.GetEpoch(docID, encryptedMsg.Epoch)
+	if !exists {
+		// a node holding no key of the document is not a member, it has
+		// no key to wait for
+		if _, current, member := n.documentKeys.Get(docID); member && encryptedMsg.Epoch > current {
+			n.documentKeys.Await(docID, encryptedMsg.Epoch)
+		}
+		n.logCRDT.Info().Msgf("Ignored operations of %s without its key of epoch %d", docID, encryptedMsg.Epoch)
+		return nil
+	}
+
+	crdtMsg, err := decryptOperations(key, *encryptedMsg)
+	if err != nil {
+		return xerrors.Errorf("Failed to read EncryptedCRDTOperationsMessage: %v", err)
+	}
+	payload, err := n.conf.MessageRegistry.MarshalMessage(crdtMsg)
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal CRDTOperationsMessage: %v", err)
+	}
+
+	// the header is kept, it tells the rumor origin of the operations
+	newPkt := transport.Packet{
+		Header: pkt.Header,
+		Msg:    &payload,
+	}
+	return n.ProcessMsg(newPkt)
+}
+
+// verifyDocumentKey checks that a key message is signed by an owner of the
+// document and that its epoch is after the one of the current key.
+func (n *node) verifyDocumentKey(keyMsg types.DocumentKeyMessage) error {
+	buf, err := documentKeyBytes(keyMsg)
+	if err != nil {
+		return fmt.Errorf("failed to encode key message: %w", err)
+	}
+	err = identity.Verify(keyMsg.Sender, buf, keyMsg.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature of %q: %w", keyMsg.Sender, err)
+	}
+	if roleOf(n.GetDocumentACL(keyMsg.DocumentID), keyMsg.Sender) != types.RoleOwner {
+		return fmt.Errorf("%s is %w", keyMsg.Sender, errNotOwner)
+	}
+	if _, epoch, exists := n.documentKeys.Get(keyMsg.DocumentID); exists && keyMsg.Epoch <= epoch {
+		return fmt.Errorf("epoch %d is not after the current epoch %d", keyMsg.Epoch, epoch)
+	}
+	return nil
+}
+
+// DocumentKeyMessageCallback handles the DocumentKeyMessage
+func (n *node) DocumentKeyMessageCallback(msg types.Message, pkt transport.Packet) error {
+	keyMsg, ok := msg.(*types.DocumentKeyMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a DocumentKeyMessage")
+	}
+
+	if n.conf.Identity == nil || keyMsg.Recipient != n.conf.Identity.ID {
+		return nil
+	}
+	return n.acceptDocumentKey(*keyMsg)
+}
+
+// acceptDocumentKey checks and opens a key sent to us. A key whose sender is
+// not known as an owner yet is kept until the access of the document changes.
+func (n *node) acceptDocumentKey(keyMsg types.DocumentKeyMessage) error {
+	err := n.verifyDocumentKey(keyMsg)
+	if errors.Is(err, errNotOwner) {
+		n.logCRDT.Info().Msgf("Kept key of %s until %s is known as an owner", keyMsg.DocumentID, keyMsg.Sender)
+		n.documentKeys.AddPending(keyMsg)
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("Rejected key of %s: %v", keyMsg.DocumentID, err)
+	}
+	key, err := n.conf.Identity.Open(keyMsg.SealedKey)
+	if err != nil {
+		return xerrors.Errorf("Failed to open key of %s: %v", keyMsg.DocumentID, err)
+	}
+	if len(key) != documentKeySize {
+		return xerrors.Errorf("Invalid key of %s", keyMsg.DocumentID)
+	}
+	n.documentKeys.Set(keyMsg.DocumentID, keyMsg.Epoch, key)
+
+	// the operations sent before we had the key are requested again, from
+	// the neighbors and reliably from the owner who sent it, which has them
+	vv := n.GetVersionVector(keyMsg.DocumentID)
+	for _, neighbor := range n.GetNeighbors() {
+		err := n.SendCRDTSyncRequestMessage(neighbor, keyMsg.DocumentID, vv)
+		if err != nil {
+			n.logCRDT.Error().Err(err).Msgf("Failed to request the operations of %s from %s",
+				keyMsg.DocumentID, neighbor)
+		}
+	}
+	if addr, known := n.ResolveIdentity(keyMsg.Sender); known {
+		payload, err := n.conf.MessageRegistry.MarshalMessage(types.CRDTSyncRequestMessage{
+			DocumentID:    keyMsg.DocumentID,
+			VersionVector: vv,
+		})
+		if err != nil {
+			return xerrors.Errorf("Failed to marshal CRDTSyncRequestMessage: %v", err)
+		}
+		n.queueReliably(addr, payload)
+	}
+	return nil
+}
+
+// retryDocumentKeys checks again the pending keys of a document once its
+// access changed.
+func (n *node) retryDocumentKeys(docID string) {
+	for _, keyMsg := range n.documentKeys.TakePending(docID) {
+		err := n.acceptDocumentKey(keyMsg)
+		if err != nil {
+			n.logCRDT.Warn().Err(err).Msgf("Failed to accept a pending key of %s", docID)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// snippetLength is the number of characters of a block shown in a hit.
+const snippetLength = 80
+
+// indexedBlock identifies a block of the full-text index. The title of a
+// document is indexed under types.MetadataBlockID.
+type indexedBlock struct {
+	docID   string
+	blockID string
+}
+
+// indexedText is the text of an indexed block and its terms in order.
+type indexedText struct {
+	text  string
+	terms []string
+}
+
+// searchClause is a part of a full-text query: a single term or a quoted
+// phrase, whose last term matches as a prefix if the clause ends with "*".
+type searchClause struct {
+	terms  []string
+	prefix bool
+}
+
+// FullTextIndex is an inverted index of the text of the blocks. Like the
+// backlinks, the blocks whose text may change are marked dirty as the
+// operations arrive and are indexed again before a query.
+type FullTextIndex struct {
+	blockIndex
+	postings map[string]map[indexedBlock][]int // term -> block -> positions
+	blocks   map[indexedBlock]indexedText
+}
+
+// Track marks dirty the block of an operation if the operation may change its
+// text.
+func (f *FullTextIndex) Track(op types.CRDTOperation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch crdtOp := op.Operation.(type) {
+	case types.CRDTInsertChar, types.CRDTDeleteChar, types.CRDTInsertText, types.CRDTDeleteRange,
+		types.CRDTUpdateBlock:
+		f.dirty.mark(op.DocumentID, op.BlockID)
+	case types.CRDTAddBlock:
+		f.dirty.mark(op.DocumentID, fmt.Sprintf("%d@%s", op.OperationID, op.Origin))
+	case types.CRDTRemoveBlock:
+		// the children of the removed block are removed as well
+		for block := range f.blocks {
+			if block.docID == op.DocumentID {
+				f.dirty.mark(op.DocumentID, block.blockID)
+			}
+		}
+	case types.CRDTSetMetadata:
+		if crdtOp.Field == types.MetadataTitle {
+			f.dirty.mark(op.DocumentID, types.MetadataBlockID)
+		}
+	}
+}
+
+// GetBlockIDs returns the indexed blocks of a document.
+func (f *FullTextIndex) GetBlockIDs(docID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	blockIDs := make([]string, 0)
+	for block := range f.blocks {
+		if block.docID == docID {
+			blockIDs = append(blockIDs, block.blockID)
+		}
+	}
+	return blockIDs
+}
+
+// SetText replaces the indexed text of a block. The empty text removes the
+// block from the index.
+func (f *FullTextIndex) SetText(docID, blockID, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	block := indexedBlock{docID: docID, blockID: blockID}
+	f.removeBlock(block)
+
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return
+	}
+	f.blocks[block] = indexedText{text: text, terms: terms}
+	for position, term := range terms {
+		if _, exists := f.postings[term]; !exists {
+			f.postings[term] = make(map[indexedBlock][]int)
+		}
+		f.postings[term][block] = append(f.postings[term][block], position)
+	}
+}
+
+// DeleteDocument removes the blocks of a document from the index.
+func (f *FullTextIndex) DeleteDocument(docID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for block := range f.blocks {
+		if block.docID == docID {
+			f.removeBlock(block)
+		}
+	}
+	delete(f.dirty, docID)
+}
+
+func (f *FullTextIndex) removeBlock(block indexedBlock) {
+	indexed, exists := f.blocks[block]
+	if !exists {
+		return
+	}
+	for _, term := range indexed.terms {
+		delete(f.postings[term], block)
+		if len(f.postings[term]) == 0 {
+			delete(f.postings, term)
+		}
+	}
+	delete(f.blocks, block)
+}
+
+// Search returns the blocks matching all the clauses, scored with TF-IDF.
+func (f *FullTextIndex) Search(clauses []searchClause) []types.SearchHit {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	scores := make(map[indexedBlock]float64)
+	for i, clause := range clauses {
+		frequencies := f.matchClause(clause)
+		if len(frequencies) == 0 {
+			return []types.SearchHit{}
+		}
+
+		idf := math.Log(1 + float64(len(f.blocks))/float64(len(frequencies)))
+		clauseScores := make(map[indexedBlock]float64, len(frequencies))
+		for block, frequency := range frequencies {
+			// only the blocks matching the previous clauses are kept
+			if _, matched := scores[block]; i > 0 && !matched {
+				continue
+			}
+			clauseScores[block] = scores[block] + (1+math.Log(float64(frequency)))*idf
+		}
+		scores = clauseScores
+	}
+
+	hits := make([]types.SearchHit, 0, len(scores))
+	for block, score := range scores {
+		hit := types.SearchHit{
+			DocumentID: block.docID,
+			BlockID:    block.blockID,
+			Score:      score,
+			Snippet:    snippet(f.blocks[block].text, clauses[0].terms[0]),
+		}
+		if block.blockID == types.MetadataBlockID {
+			hit.BlockID = ""
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// matchClause returns, for each block, the number of times a clause occurs.
+func (f *FullTextIndex) matchClause(clause searchClause) map[indexedBlock]int {
+	// the first term is looked up in the postings, the next ones are checked
+	// against the terms of the block
+	firstTerms := []string{clause.terms[0]}
+	if clause.prefix && len(clause.terms) == 1 {
+		firstTerms = firstTerms[:0]
+		for term := range f.postings {
+			if strings.HasPrefix(term, clause.terms[0]) {
+				firstTerms = append(firstTerms, term)
+			}
+		}
+	}
+
+	frequencies := make(map[indexedBlock]int)
+	for _, firstTerm := range firstTerms {
+		for block, positions := range f.postings[firstTerm] {
+			terms := f.blocks[block].terms
+			for _, position := range positions {
+				if matchTerms(terms[position:], clause) {
+					frequencies[block]++
+				}
+			}
+		}
+	}
+	return frequencies
+}
+
+// matchTerms tells if a clause matches the beginning of a list of terms.
+func matchTerms(terms []string, clause searchClause) bool {
+	if len(terms) < len(clause.terms) {
+		return false
+	}
+	last := len(clause.terms) - 1
+	for i, term := range clause.terms {
+		if i == last && clause.prefix {
+			return strings.HasPrefix(terms[i], term)
+		}
+		if terms[i] != term {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchDocuments implements peer.CRDT
+func (n *node) SearchDocuments(query string, limit int) ([]types.SearchHit, error) {
+	clauses := parseSearchQuery(query)
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("empty search query %q", query)
+	}
+
+	n.refreshFullTextIndex()
+
+	hits := make([]types.SearchHit, 0)
+	for _, hit := range n.fullText.Search(clauses) {
+		// archived and deleted documents are hidden
+		if n.GetDocumentMetadata(hit.DocumentID).Status != types.DocumentActive {
+			continue
+		}
+		hits = append(hits, hit)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].DocumentID != hits[j].DocumentID {
+			return hits[i].DocumentID < hits[j].DocumentID
+		}
+		return hits[i].BlockID < hits[j].BlockID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// refreshFullTextIndex indexes again the text of the dirty blocks. The title
+// of a document is indexed as the metadata block.
+func (n *node) refreshFullTextIndex() {
+	indexed := func(docID string) []string {
+		return append(n.fullText.GetBlockIDs(docID), types.MetadataBlockID)
+	}
+	n.refreshIndex(&n.fullText.blockIndex, indexed,
+		func(docID, blockID string, block types.BlockFactory, found bool) {
+			switch {
+			case blockID == types.MetadataBlockID:
+				n.fullText.SetText(docID, blockID, n.GetDocumentMetadata(docID).Title)
+			case !found || block.Deleted:
+				n.fullText.SetText(docID, blockID, "")
+			default:
+				n.fullText.SetText(docID, blockID, n.blockPlainText(docID, block))
+			}
+		})
+}
+
+// blockPlainText returns the text of a block, without its children.
+func (n *node) blockPlainText(docID string, block types.BlockFactory) string {
+	chars := n.blockChars(docID, block)
+	runes := make([]rune, len(chars))
+	for i, c := range chars {
+		runes[i] = c.char
+	}
+	return string(runes)
+}
+
+// parseSearchQuery splits a query into clauses: words, "quoted phrases" and
+// prefixes ending with "*".
+func parseSearchQuery(query string) []searchClause {
+	clauses := make([]searchClause, 0)
+	addClause := func(text string) {
+		prefix := strings.HasSuffix(text, "*")
+		terms := tokenize(strings.TrimSuffix(text, "*"))
+		if len(terms) > 0 {
+			clauses = append(clauses, searchClause{terms: terms, prefix: prefix})
+		}
+	}
+
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		if strings.HasPrefix(query, `"`) {
+			end := strings.Index(query[1:], `"`)
+			if end == -1 {
+				addClause(query[1:])
+				break
+			}
+			phrase := query[1 : end+1]
+			query = query[end+2:]
+			// a "*" right after the closing quote makes the last term a prefix
+			if strings.HasPrefix(query, "*") {
+				phrase += "*"
+				query = query[1:]
+			}
+			addClause(phrase)
+			continue
+		}
+
+		end := strings.IndexFunc(query, unicode.IsSpace)
+		if end == -1 {
+			end = len(query)
+		}
+		addClause(query[:end])
+		query = query[end:]
+	}
+	return clauses
+}
+
+// tokenize splits a text into lower case terms made of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippet returns the part of a text around the first occurrence of a term.
+func snippet(text, term string) string {
+	runes := []rune(text)
+	start := 0
+	lower := []rune(strings.ToLower(text))
+	if len(lower) == len(runes) {
+		if index := strings.Index(string(lower), term); index != -1 {
+			start = len([]rune(string(lower)[:index])) - snippetLength/4
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+	return string(runes[start:end])
+}
@@ -4,6 +4,8 @@ import (
 	"Node-tion/backend/peer"
 	"Node-tion/backend/transport"
 	"Node-tion/backend/types"
+	"Node-tion/backend/workspace"
+	"container/list"
 	"context"
 	"errors"
 	"io"
@@ -29,10 +31,16 @@ func NewPeer(conf peer.Configuration) peer.Peer {
 
 	routingTable := newRoutingTable()
 	view := newView()
+	// a restarted node goes on from the last rumor of its identity
+	if conf.Counters != nil {
+		view.rumorSeq = conf.Counters.GetRumorSeq()
+	}
 	ackTickers := newAckMap()
 	catalog := newCatalog()
 	dataReplyChanMap := newDataReplyChanMap()
 	searchReplyChanMap := newSearchReplyChanMap()
+	snapshotReplyChanMap := newSnapshotReplyChanMap()
+	documentSearchReplyChanMap := newDocumentSearchReplyChanMap()
 	requests := newRequests()
 	logicalClock := newLogicalClock()
 	acceptor := newAcceptor()
@@ -41,30 +49,72 @@ func NewPeer(conf peer.Configuration) peer.Peer {
 	editor := newEditor()
 	docTimestampMap := newDocTimestampMap()
 	crdtState := newCRDTState()
+	compaction := newCompaction()
+	backlinks := newBacklinks()
+	fullText := newFullTextIndex()
+	rumorOrigins := newRumorOrigins()
+	localPackets := newLocalPackets()
+	localQueue := newLocalQueue()
+	packetRemotes := newPacketRemotes()
+	reliableQueues := newReliableQueues()
+	quarantine := newQuarantine()
+	operationIDs := newOperationIDs()
+	repairs := newRepairs()
+	documentKeys := newDocumentKeys()
+	membership := newMembershipLog()
+	rateLimiter := newRateLimiter()
+	audit := newAuditLog()
+	// a restarted node goes on from the last batch of its audit chains
+	if conf.Counters != nil {
+		audit.heads = conf.Counters.GetAuditHeads()
+	}
+
+	// every packet goes through the workspace socket, which authenticates it
+	// once the node is in a workspace
+	workspaceSocket := workspace.NewSocket(conf.Socket, conf.WorkspaceKey)
+	conf.Socket = workspaceSocket
 
 	maxUploadSize := 2 * 1024 * 1024 // 2 MiB
 
 	node := node{
-		conf:               conf,
-		mu:                 sync.Mutex{},
-		log:                logger,
-		logPAXOS:           loggerPAXOS,
-		logCRDT:            loggerCRDT,
-		routingTable:       routingTable,
-		view:               view,
-		ackTickers:         ackTickers,
-		catalog:            catalog,
-		dataReplyChanMap:   dataReplyChanMap,
-		searchReplyChanMap: searchReplyChanMap,
-		requests:           requests,
-		maxUploadSize:      int64(maxUploadSize),
-		logicalClock:       logicalClock,
-		acceptor:           acceptor,
-		proposer:           proposer,
-		tlcMessages:        tlcMessages,
-		editor:             editor,
-		docTimestampMap:    docTimestampMap,
-		crdtState:          crdtState,
+		conf:                       conf,
+		mu:                         sync.Mutex{},
+		log:                        logger,
+		logPAXOS:                   loggerPAXOS,
+		logCRDT:                    loggerCRDT,
+		routingTable:               routingTable,
+		view:                       view,
+		ackTickers:                 ackTickers,
+		catalog:                    catalog,
+		dataReplyChanMap:           dataReplyChanMap,
+		searchReplyChanMap:         searchReplyChanMap,
+		snapshotReplyChanMap:       snapshotReplyChanMap,
+		documentSearchReplyChanMap: documentSearchReplyChanMap,
+		requests:                   requests,
+		maxUploadSize:              int64(maxUploadSize),
+		logicalClock:               logicalClock,
+		acceptor:                   acceptor,
+		proposer:                   proposer,
+		tlcMessages:                tlcMessages,
+		editor:                     editor,
+		docTimestampMap:            docTimestampMap,
+		crdtState:                  crdtState,
+		compaction:                 compaction,
+		backlinks:                  backlinks,
+		fullText:                   fullText,
+		rumorOrigins:               rumorOrigins,
+		localPackets:               localPackets,
+		localQueue:                 localQueue,
+		packetRemotes:              packetRemotes,
+		reliableQueues:             reliableQueues,
+		quarantine:                 quarantine,
+		operationIDs:               operationIDs,
+		repairs:                    repairs,
+		workspace:                  workspaceSocket,
+		documentKeys:               documentKeys,
+		membership:                 membership,
+		rateLimiter:                rateLimiter,
+		audit:                      audit,
 	}
 
 	return &node
@@ -79,8 +129,10 @@ func newLogger(io io.Writer, level zerolog.Level) zerolog.Logger {
 
 func newRoutingTable() *RoutingTable {
 	return &RoutingTable{
-		mu: sync.Mutex{},
-		rt: make(peer.RoutingTable),
+		mu:         sync.Mutex{},
+		rt:         make(peer.RoutingTable),
+		identities: make(map[string]string),
+		readOnly:   make(map[string]bool),
 	}
 }
 
@@ -121,6 +173,20 @@ func newSearchReplyChanMap() *SearchReplyChanMap {
 	}
 }
 
+func newSnapshotReplyChanMap() *SnapshotReplyChanMap {
+	return &SnapshotReplyChanMap{
+		mu:   sync.Mutex{},
+		repl: make(map[string]chan types.CRDTSnapshotReplyMessage),
+	}
+}
+
+func newDocumentSearchReplyChanMap() *DocumentSearchReplyChanMap {
+	return &DocumentSearchReplyChanMap{
+		mu:   sync.Mutex{},
+		repl: make(map[string]chan types.DocumentSearchReplyMessage),
+	}
+}
+
 func newRequests() *Requests {
 	return &Requests{
 		mu:  sync.Mutex{},
@@ -167,8 +233,11 @@ func newTLC() *TLC {
 
 func newEditor() *Editor {
 	return &Editor{
-		mu: sync.Mutex{},
-		ed: make(peer.Editor),
+		mu:   sync.Mutex{},
+		ed:   make(peer.Editor),
+		snap: make(map[string]map[string]types.BlockSnapshot),
+
+		purged: make(map[string]struct{}),
 	}
 }
 
@@ -182,8 +251,132 @@ func newDocTimestampMap() *DocTimestampMap {
 
 func newCRDTState() *CRDTState {
 	return &CRDTState{
-		state: make(map[string]uint64),
-		tmp:   make(map[uint64]uint64),
+		state:    make(map[string]uint64),
+		versions: make(map[string]types.VersionVector),
+	}
+}
+
+func newCompaction() *Compaction {
+	return &Compaction{
+		mu:           sync.Mutex{},
+		peerVersions: make(map[string]map[string]types.VersionVector),
+		stats:        make(map[string]types.CompactionStats),
+	}
+}
+
+func newBacklinks() *Backlinks {
+	return &Backlinks{
+		blockIndex: newBlockIndex(),
+		sources:    make(map[string]map[string][]reference),
+	}
+}
+
+func newFullTextIndex() *FullTextIndex {
+	return &FullTextIndex{
+		blockIndex: newBlockIndex(),
+		postings:   make(map[string]map[indexedBlock][]int),
+		blocks:     make(map[indexedBlock]indexedText),
+	}
+}
+
+func newBlockIndex() blockIndex {
+	return blockIndex{
+		mu:    sync.Mutex{},
+		dirty: make(dirtyBlocks),
+	}
+}
+
+func newRumorOrigins() *RumorOrigins {
+	return &RumorOrigins{
+		mu:      sync.Mutex{},
+		origins: make(map[*transport.Header]string),
+	}
+}
+
+func newReliableQueues() *ReliableQueues {
+	return &ReliableQueues{
+		mu:     sync.Mutex{},
+		queues: make(map[string][]queuedMessage),
+	}
+}
+
+func newLocalPackets() *LocalPackets {
+	return &LocalPackets{
+		mu:      sync.Mutex{},
+		headers: make(map[*transport.Header]struct{}),
+	}
+}
+
+func newLocalQueue() *LocalQueue {
+	return &LocalQueue{
+		mu:   sync.Mutex{},
+		msgs: make([]transport.Message, 0),
+	}
+}
+
+func newPacketRemotes() *PacketRemotes {
+	return &PacketRemotes{
+		mu:      sync.Mutex{},
+		remotes: make(map[*transport.Header]string),
+	}
+}
+
+func newOperationIDs() *OperationIDs {
+	return &OperationIDs{
+		mu:    sync.Mutex{},
+		spans: make(map[string]map[string][]idSpan),
+	}
+}
+
+func newQuarantine() *Quarantine {
+	return &Quarantine{
+		mu:     sync.Mutex{},
+		ops:    make([]types.QuarantinedOperation, 0),
+		rumors: make([]types.RejectedRumor, 0),
+	}
+}
+
+func newRepairs() *Repairs {
+	return &Repairs{
+		mu:   sync.Mutex{},
+		docs: make(map[string]int),
+	}
+}
+
+func newDocumentKeys() *DocumentKeys {
+	return &DocumentKeys{
+		mu:      sync.Mutex{},
+		keys:    make(map[string]map[uint][]byte),
+		epochs:  make(map[string]uint),
+		pending: make(map[string][]types.DocumentKeyMessage),
+		awaited: make(map[string]uint),
+	}
+}
+
+func newMembershipLog() *MembershipLog {
+	return &MembershipLog{
+		mu:      sync.Mutex{},
+		entries: make(map[string][]types.MembershipEntry),
+	}
+}
+
+func newRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		mu:      sync.Mutex{},
+		sources: make(map[string]*list.Element),
+		active:  list.New(),
+		banned:  list.New(),
+	}
+}
+
+func newAuditLog() *AuditLog {
+	return &AuditLog{
+		mu:        sync.Mutex{},
+		batches:   make(map[string]map[string]map[string]*types.AuditBatch),
+		unchained: make(map[string][]string),
+		anchors:   make(map[string][]types.AuditAnchor),
+		heads:     make(map[string][]byte),
+		pruned:    make(map[string]map[string]types.AuditBatch),
 	}
 }
 
@@ -192,28 +385,47 @@ func newCRDTState() *CRDTState {
 // - implements peer.Peer
 type node struct {
 	peer.Peer
-	conf               peer.Configuration
-	mu                 sync.Mutex
-	ctx                context.Context    // for managing the start/stop
-	cancel             context.CancelFunc // to cancel the listening goroutine
-	log                zerolog.Logger
-	logPAXOS           zerolog.Logger
-	logCRDT            zerolog.Logger
-	routingTable       *RoutingTable
-	view               *View
-	ackTickers         *AckMap
-	catalog            *Catalog
-	dataReplyChanMap   *DataReplyChanMap
-	searchReplyChanMap *SearchReplyChanMap
-	requests           *Requests
-	maxUploadSize      int64
-	logicalClock       *LogicalClock
-	acceptor           *Acceptor
-	proposer           *Proposer
-	tlcMessages        *TLC
-	editor             *Editor
-	docTimestampMap    *DocTimestampMap
-	crdtState          *CRDTState
+	conf                       peer.Configuration
+	mu                         sync.Mutex
+	ctx                        context.Context    // for managing the start/stop
+	cancel                     context.CancelFunc // to cancel the listening goroutine
+	log                        zerolog.Logger
+	logPAXOS                   zerolog.Logger
+	logCRDT                    zerolog.Logger
+	routingTable               *RoutingTable
+	view                       *View
+	ackTickers                 *AckMap
+	catalog                    *Catalog
+	dataReplyChanMap           *DataReplyChanMap
+	searchReplyChanMap         *SearchReplyChanMap
+	snapshotReplyChanMap       *SnapshotReplyChanMap
+	documentSearchReplyChanMap *DocumentSearchReplyChanMap
+	requests                   *Requests
+	maxUploadSize              int64
+	logicalClock               *LogicalClock
+	acceptor                   *Acceptor
+	proposer                   *Proposer
+	tlcMessages                *TLC
+	editor                     *Editor
+	docTimestampMap            *DocTimestampMap
+	crdtState                  *CRDTState
+	compaction                 *Compaction
+	backlinks                  *Backlinks
+	fullText                   *FullTextIndex
+	rumorOrigins               *RumorOrigins
+	localPackets               *LocalPackets
+	localQueue                 *LocalQueue
+	packetRemotes              *PacketRemotes
+	reliableQueues             *ReliableQueues
+	quarantine                 *Quarantine
+	operationIDs               *OperationIDs
+	repairs                    *Repairs
+	workspace                  *workspace.Socket
+	documentKeys               *DocumentKeys
+	membership                 *MembershipLog
+	rateLimiter                *RateLimiter
+	audit                      *AuditLog
+	commitMu                   sync.Mutex // serializes the transactions of the node
 }
 
 // Start implements peer.Service
@@ -241,6 +453,16 @@ func (n *node) Start() error {
 	n.conf.MessageRegistry.RegisterMessageCallback(&types.TLCMessage{}, n.TLCMessageCallback)
 
 	n.conf.MessageRegistry.RegisterMessageCallback(&types.CRDTOperationsMessage{}, n.CRDTOperationsMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.CRDTVersionMessage{}, n.CRDTVersionMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.CRDTSnapshotRequestMessage{}, n.CRDTSnapshotRequestMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.CRDTSnapshotReplyMessage{}, n.CRDTSnapshotReplyMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.CRDTSyncRequestMessage{}, n.CRDTSyncRequestMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.DocumentSearchRequestMessage{}, n.DocumentSearchRequestMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.DocumentSearchReplyMessage{}, n.DocumentSearchReplyMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.EncryptedCRDTOperationsMessage{}, n.EncryptedCRDTOperationsMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.DocumentKeyMessage{}, n.DocumentKeyMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.ReliableMessage{}, n.ReliableMessageCallback)
+	n.conf.MessageRegistry.RegisterMessageCallback(&types.ReliableAckMessage{}, n.ReliableAckMessageCallback)
 
 	n.SetRoutingEntry(n.conf.Socket.GetAddress(), n.conf.Socket.GetAddress())
 
@@ -263,6 +485,21 @@ func (n *node) Start() error {
 		go n.AntiEntropyTicker()
 	}
 
+	// compaction go routine
+	if n.conf.CompactionInterval > 0 {
+		go n.CompactionTicker()
+	}
+
+	// purge of the deleted documents go routine
+	if n.conf.DocumentRetention > 0 {
+		go n.PurgeTicker()
+	}
+
+	// anchoring of the audit logs go routine
+	if n.conf.AuditAnchorInterval > 0 {
+		go n.AuditAnchorTicker()
+	}
+
 	return nil
 }
 
@@ -283,7 +520,7 @@ func (n *node) Listen() {
 			return
 		default:
 			// listen for incoming messages
-			pkt, err := n.conf.Socket.Recv(time.Second * 1)
+			pkt, remote, err := n.receive(time.Second * 1)
 			if errors.Is(err, transport.TimeoutError(0)) {
 				// No message, just continue
 				continue
@@ -293,10 +530,20 @@ func (n *node) Listen() {
 				continue
 			}
 
+			// drop the packets of the sources over their rate limits
+			if !n.allowPacket(pkt, remote) {
+				n.log.Warn().Msgf("Dropped %s packet from %s over the rate limit", pkt.Msg.Type, remote)
+				continue
+			}
+
 			// determine if the message is for this node
 			if pkt.Header.Destination == n.conf.Socket.GetAddress() {
 				// if for this node, process it using the message registry
+				if remote != "" {
+					n.packetRemotes.Set(pkt.Header, remote)
+				}
 				err = n.ProcessMsg(pkt)
+				n.packetRemotes.Delete(pkt.Header)
 				if err != nil {
 					n.log.Error().Err(err).Msg("Failed to process message")
 				}
@@ -308,11 +555,27 @@ func (n *node) Listen() {
 	}
 }
 
+// receive receives the next packet of the socket, with the address it was
+// received from if the socket can tell it.
+func (n *node) receive(timeout time.Duration) (transport.Packet, string, error) {
+	if sock, ok := n.conf.Socket.(transport.RemoteSocket); ok {
+		return sock.RecvFrom(timeout)
+	}
+	pkt, err := n.conf.Socket.Recv(timeout)
+	return pkt, "", err
+}
+
 // Unicast implements peer.Messaging
 func (n *node) Unicast(dest string, msg transport.Message) error {
 	// If I send unicast to an unknown node, then I should get an error and the
 	// other node should not receive the packet.
 	rt := n.GetRoutingTable()
+	// the destination can be the identity of a node
+	if _, exists := rt[dest]; !exists {
+		if addr, known := n.ResolveIdentity(dest); known {
+			dest = addr
+		}
+	}
 	// check if the destination is in the routing table
 	relay, exists := rt[dest]
 	if !exists {
@@ -332,20 +595,43 @@ func (n *node) Unicast(dest string, msg transport.Message) error {
 
 // Broadcast implements peer.Messaging
 func (n *node) Broadcast(msg transport.Message) error {
+	return n.broadcast(msg, false)
+}
+
+// broadcast gossips a message and processes it locally, in the background
+// unless processed is set, in which case it returns once the message is
+// processed.
+func (n *node) broadcast(msg transport.Message, processed bool) error {
 	// Create a RumorsMessage containing one Rumor
-	n.view.IncrementRumorSeq()
 	rumor := types.Rumor{
-		Origin:   n.conf.Socket.GetAddress(),
-		Sequence: n.view.GetRumorSeq(),
+		Origin:   n.GetIdentity(),
+		ReadOnly: n.conf.ReadOnly,
+		Sequence: n.view.NextRumorSeq(n.GetIdentity()),
 		Msg:      &msg,
 	}
+	// the sequence is saved before the rumor is sent, it is used anyway: the
+	// peers wait for the sequences in order
+	if n.conf.Counters != nil {
+		err := n.conf.Counters.SetRumorSeq(rumor.Sequence)
+		if err != nil {
+			n.log.Error().Err(err).Msg("Failed to save the rumor sequence")
+		}
+	}
+	// the peers learn the current address of an identity from its rumors
+	if rumor.Origin != n.conf.Socket.GetAddress() {
+		rumor.Address = n.conf.Socket.GetAddress()
+	}
+	err := n.signRumor(&rumor)
+	if err != nil {
+		return xerrors.Errorf("failed to sign rumor: %v", err)
+	}
 
 	rumorsMsg := types.RumorsMessage{
 		Rumors: []types.Rumor{rumor},
 	}
 
 	// save the rumor in the view
-	n.view.AddRumorView(rumor, n.conf.Socket.GetAddress())
+	n.view.AddRumorView(rumor, rumor.Origin)
 
 	// cast to transport.Message
 	// marshal the RumorsMessage
@@ -365,21 +651,33 @@ func (n *node) Broadcast(msg transport.Message) error {
 		go n.AckTicker(&packetHeader, payload)
 	}
 
+	// Process the message locally
+	if processed {
+		return n.processLocally(msg)
+	}
+	n.queueLocally(msg)
+	return nil
+}
+
+// queueLocally processes a broadcast message locally without waiting for it,
+// after the previous ones. A single goroutine processes the queue.
+func (n *node) queueLocally(msg transport.Message) {
+	if !n.localQueue.Push(msg) {
+		return
+	}
 	go func() {
-		// Process the message locally
-		header := transport.NewHeader(n.conf.Socket.GetAddress(), n.conf.Socket.GetAddress(), n.conf.Socket.GetAddress())
-		pkt := transport.Packet{
-			Header: &header,
-			Msg:    &msg,
-		}
-		err = n.ProcessMsg(pkt)
-		if err != nil {
-			n.log.Error().Err(err).Msg("Failed to process message")
+		for {
+			msg, ok := n.localQueue.Pop()
+			if !ok {
+				return
+			}
+			err := n.processLocally(msg)
+			if err != nil {
+				n.log.Error().Err(err).Msg("Failed to process message")
+			}
+			n.log.Info().Msg("Processed message locally")
 		}
-		n.log.Info().Msg("Processed message locally")
 	}()
-
-	return nil
 }
 
 func (n *node) HeartbeatTicker() {
@@ -425,7 +723,8 @@ func (n *node) AntiEntropyTicker() {
 				n.log.Error().Err(err).Msg("Failed to send anti-entropy StatusMessage")
 			}
 
-			// send CRDTMessage
+			// send the version vectors of the documents to the known peers
+			n.SendCRDTVersionMessages()
 		}
 	}
 }
@@ -467,6 +766,17 @@ func (n *node) ProcessMsg(pkt transport.Packet) error {
 	return nil
 }
 
+// processLocally processes a message created by the node itself. Its packet
+// is marked local, the callbacks trust its content.
+func (n *node) processLocally(msg transport.Message) error {
+	self := n.conf.Socket.GetAddress()
+	header := transport.NewHeader(self, self, self)
+	n.localPackets.Add(&header)
+	defer n.localPackets.Delete(&header)
+
+	return n.ProcessMsg(transport.Packet{Header: &header, Msg: &msg})
+}
+
 // RelayMsg relays the message to its next hop.
 func (n *node) RelayMsg(pkt transport.Packet) {
 	// update the relayed by field
@@ -0,0 +1,244 @@
+package impl
+
+import (
+	"Node-tion/backend/oplog"
+	"Node-tion/backend/types"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// repairPollInterval is how often a repair checks whether the missing
+// operations arrived.
+const repairPollInterval = 50 * time.Millisecond
+
+// Repairs counts, per document, the repairs waiting for missing operations.
+// The operations of these documents are deduplicated against the log rather
+// than the version vector, see filterKnownOperations.
+type Repairs struct {
+	mu   sync.Mutex
+	docs map[string]int
+}
+
+// Start marks a document as being repaired.
+func (r *Repairs) Start(docID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.docs[docID]++
+}
+
+// Done ends a repair of a document.
+func (r *Repairs) Done(docID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.docs[docID]--
+	if r.docs[docID] <= 0 {
+		delete(r.docs, docID)
+	}
+}
+
+// Active tells whether a document is being repaired.
+func (r *Repairs) Active(docID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.docs[docID] > 0
+}
+
+// ExportDocumentLog implements peer.CRDT
+func (n *node) ExportDocumentLog(docID string) (types.DocumentSnapshot, error) {
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	if _, exists := n.editor.ed[docID]; !exists {
+		return types.DocumentSnapshot{}, fmt.Errorf("document %s not found", docID)
+	}
+	return n.documentLog(docID), nil
+}
+
+// CheckDocument implements peer.CRDT
+func (n *node) CheckDocument(docID string) (types.IntegrityReport, error) {
+	log, err := n.ExportDocumentLog(docID)
+	if err != nil {
+		return types.IntegrityReport{}, err
+	}
+	return oplog.Check(log), nil
+}
+
+// RepairDocument implements peer.CRDT
+func (n *node) RepairDocument(docID string, opts types.RepairOptions, timeout time.Duration) (types.IntegrityReport, error) {
+	log, err := n.ExportDocumentLog(docID)
+	if err != nil {
+		return types.IntegrityReport{}, err
+	}
+	report := oplog.Check(log)
+
+	if opts.RequestMissing && hasMissingOperations(report) {
+		n.repairs.Start(docID)
+		report, err = n.requestMissingOperations(log, report, timeout)
+		n.repairs.Done(docID)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if opts.DropOrphans {
+		n.dropOrphans(docID)
+	}
+	return n.CheckDocument(docID)
+}
+
+// requestMissingOperations asks the neighbors for the operations missing from
+// a log until nothing is missing anymore or until the timeout, and returns
+// the last report. The operations received can reveal older missing ones,
+// they are requested in turn.
+func (n *node) requestMissingOperations(log types.DocumentSnapshot, report types.IntegrityReport,
+	timeout time.Duration) (types.IntegrityReport, error) {
+
+	ticker := time.NewTicker(repairPollInterval)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+
+	requested := make(map[string]struct{})
+	for {
+		if hasUnrequestedOperations(report, requested) {
+			vv := oplog.RequestVersion(log, report)
+			for _, neighbor := range n.GetNeighbors() {
+				err := n.SendCRDTSyncRequestMessage(neighbor, log.DocumentID, vv)
+				if err != nil {
+					n.logCRDT.Error().Err(err).Msgf("Failed to request the missing operations of %s from %s",
+						log.DocumentID, neighbor)
+				}
+			}
+		}
+
+		select {
+		case <-n.ctx.Done():
+			return report, nil
+		case <-deadline:
+			return report, nil
+		case <-ticker.C:
+		}
+
+		var err error
+		log, err = n.ExportDocumentLog(log.DocumentID)
+		if err != nil {
+			return report, err
+		}
+		report = oplog.Check(log)
+		if !hasMissingOperations(report) {
+			return report, nil
+		}
+	}
+}
+
+// dropOrphans removes the duplicated and the orphaned operations of a
+// document, the indexes of the document are computed again.
+func (n *node) dropOrphans(docID string) {
+	n.editor.mu.Lock()
+	repaired, dropped := oplog.DropOrphans(n.documentLog(docID))
+	if len(dropped) > 0 {
+		n.editor.ed[docID] = repaired.Operations
+	}
+	n.editor.mu.Unlock()
+
+	if len(dropped) == 0 {
+		return
+	}
+	for _, issue := range dropped {
+		n.logCRDT.Warn().Msgf("Dropped operation %s of %s: %s %s", issue.OperationID, docID, issue.Kind, issue.Detail)
+	}
+	n.backlinks.MarkDirty(docID, allBlocks)
+	n.fullText.MarkDirty(docID, allBlocks)
+}
+
+// knownOperations returns the IDs of the operations stored for a document.
+func (n *node) knownOperations(docID string) map[string]struct{} {
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	known := make(map[string]struct{})
+	for _, ops := range n.editor.ed[docID] {
+		for _, op := range ops {
+			for k := uint64(0); k < OpSpan(op); k++ {
+				known[fmt.Sprintf("%d@%s", op.OperationID+k, op.Origin)] = struct{}{}
+			}
+		}
+	}
+	return known
+}
+
+// unknownParts returns the parts of an operation whose IDs are not known yet
+// and marks them known. A run the log already has in part, e.g. trimmed by an
+// earlier sync, is split into the runs of its unknown characters.
+func unknownParts(op types.CRDTOperation, known map[string]struct{}) []types.CRDTOperation {
+	insertOp, isRun := op.Operation.(types.CRDTInsertText)
+	if !isRun {
+		id := fmt.Sprintf("%d@%s", op.OperationID, op.Origin)
+		if _, exists := known[id]; exists {
+			return nil
+		}
+		known[id] = struct{}{}
+		return []types.CRDTOperation{op}
+	}
+
+	chars := []rune(insertOp.Text)
+	parts := make([]types.CRDTOperation, 0, 1)
+	for i := 0; i < len(chars); {
+		id := fmt.Sprintf("%d@%s", op.OperationID+uint64(i), op.Origin)
+		if _, exists := known[id]; exists {
+			i++
+			continue
+		}
+
+		end := i
+		for ; end < len(chars); end++ {
+			id := fmt.Sprintf("%d@%s", op.OperationID+uint64(end), op.Origin)
+			if _, exists := known[id]; exists {
+				break
+			}
+			known[id] = struct{}{}
+		}
+
+		part := cutFrom(op)
+		part.OperationID = op.OperationID + uint64(i)
+		partOp := insertOp
+		if i > 0 {
+			partOp.AfterID = fmt.Sprintf("%d@%s", part.OperationID-1, op.Origin)
+		}
+		partOp.Text = string(chars[i:end])
+		part.Operation = partOp
+		parts = append(parts, part)
+		i = end
+	}
+	return parts
+}
+
+// hasMissingOperations tells whether a report found references to unknown
+// operations or gaps in the sequences.
+func hasMissingOperations(report types.IntegrityReport) bool {
+	for _, issue := range report.Issues {
+		if issue.Kind == types.DanglingReferenceIssue || issue.Kind == types.SequenceGapIssue {
+			return true
+		}
+	}
+	return false
+}
+
+// hasUnrequestedOperations tells whether a report found missing operations
+// that were not requested yet, and marks them requested.
+func hasUnrequestedOperations(report types.IntegrityReport, requested map[string]struct{}) bool {
+	found := false
+	for _, issue := range report.Issues {
+		if issue.Kind != types.DanglingReferenceIssue && issue.Kind != types.SequenceGapIssue {
+			continue
+		}
+		if _, done := requested[issue.Kind+issue.Reference]; !done {
+			requested[issue.Kind+issue.Reference] = struct{}{}
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,191 @@
+package impl
+
+import (
+	"Node-tion/backend/identity"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/types"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MembershipLog is a map of document ID to the hash chain of the access
+// operations applied to the document.
+type MembershipLog struct {
+	mu      sync.Mutex
+	entries map[string][]types.MembershipEntry
+}
+
+// Append chains an access operation to the log of its document.
+func (m *MembershipLog) Append(op types.CRDTOperation, keyEpoch uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := m.entries[op.DocumentID]
+	entry := types.MembershipEntry{
+		Index:     uint(len(entries)),
+		Operation: op,
+		KeyEpoch:  keyEpoch,
+	}
+	if len(entries) > 0 {
+		entry.PrevHash = entries[len(entries)-1].Hash
+	}
+
+	hash, err := membershipHash(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	m.entries[op.DocumentID] = append(entries, entry)
+	return nil
+}
+
+// Get returns the log of a document
+func (m *MembershipLog) Get(docID string) []types.MembershipEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]types.MembershipEntry, len(m.entries[docID]))
+	copy(entries, m.entries[docID])
+	return entries
+}
+
+// membershipHash returns the hash of an entry, which covers the hash of the
+// previous entry.
+func membershipHash(entry types.MembershipEntry) ([]byte, error) {
+	buf, err := json.Marshal(struct {
+		Index     uint
+		Operation types.CRDTOperation
+		KeyEpoch  uint
+		PrevHash  []byte
+	}{entry.Index, entry.Operation, entry.KeyEpoch, entry.PrevHash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry %d: %w", entry.Index, err)
+	}
+	sum := sha256.Sum256(buf)
+	return sum[:], nil
+}
+
+// GetMembershipLog implements peer.CRDT
+func (n *node) GetMembershipLog(docID string) []types.MembershipEntry {
+	return n.membership.Get(docID)
+}
+
+// VerifyMembershipLog implements peer.CRDT
+func (n *node) VerifyMembershipLog(entries []types.MembershipEntry) error {
+	var prevHash []byte
+	for i, entry := range entries {
+		if entry.Index != uint(i) {
+			return fmt.Errorf("entry %d has index %d", i, entry.Index)
+		}
+		if !bytes.Equal(entry.PrevHash, prevHash) {
+			return fmt.Errorf("entry %d does not follow entry %d", i, i-1)
+		}
+
+		// the operation may come from another peer, it is cast before being
+		// checked and hashed again
+		if entry.Operation.Type != "" {
+			err := n.castOperation(&entry.Operation)
+			if err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+		}
+		if _, ok := entry.Operation.Operation.(types.CRDTSetAccess); !ok {
+			return fmt.Errorf("entry %d is not an access operation", i)
+		}
+		err := n.verifyOperation(entry.Operation)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+
+		hash, err := membershipHash(entry)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash, entry.Hash) {
+			return fmt.Errorf("entry %d was altered", i)
+		}
+		prevHash = entry.Hash
+	}
+	return nil
+}
+
+// RemoveDocumentMember implements peer.CRDT
+func (n *node) RemoveDocumentMember(docID, member string) error {
+	err := n.checkWritable()
+	if err != nil {
+		return err
+	}
+	revoke := types.CRDTSetAccess{Member: member}
+	err = n.checkAccess(docID, n.GetIdentity(), revoke)
+	if err != nil {
+		return err
+	}
+	err = validateAccess(docID, revoke)
+	if err != nil {
+		return err
+	}
+	if _, exists := n.GetDocumentACL(docID).Members[member]; !exists {
+		return fmt.Errorf("%s is not a member of document %s", member, docID)
+	}
+
+	// the key is rotated first: the revocation and the operations after it
+	// are encrypted with a key the removed member never gets, and that the
+	// remaining members hold before they receive them
+	if n.IsDocumentEncrypted(docID) {
+		err = n.rotateDocumentKey(docID, member)
+		if err != nil {
+			return err
+		}
+	}
+	return n.RevokeDocumentAccess(docID, member)
+}
+
+// rotateDocumentKey gives a new key to an encrypted document, for the next
+// epoch, and sends it reliably to its members but the removed one. The key is
+// only rotated once the messages of all the members are ready: a member we
+// cannot send the key to would miss every operation sent after. It returns
+// once every member acknowledged the key, or fails if one did not.
+func (n *node) rotateDocumentKey(docID, removed string) error {
+	if n.conf.Identity == nil {
+		return fmt.Errorf("rotating a key requires an identity")
+	}
+	_, epoch, _ := n.documentKeys.Get(docID)
+	key, err := newDocumentKey()
+	if err != nil {
+		return err
+	}
+
+	self := n.GetIdentity()
+	keyMsgs := make(map[string]transport.Message)
+	for member := range n.GetDocumentACL(docID).Members {
+		if member == removed || member == self || !identity.IsIdentity(member) {
+			continue
+		}
+		addr, known := n.ResolveIdentity(member)
+		if !known {
+			return fmt.Errorf("cannot send the new key of %s to %s: unknown address", docID, member)
+		}
+		keyMsg, err := n.documentKeyMessage(docID, member, epoch+1, key)
+		if err != nil {
+			return err
+		}
+		keyMsgs[addr] = keyMsg
+	}
+
+	// the members acknowledging the key use it at once, we hold it even if
+	// another one does not
+	n.documentKeys.Set(docID, epoch+1, key)
+	acks := make(map[string]<-chan bool, len(keyMsgs))
+	for addr, keyMsg := range keyMsgs {
+		acks[addr] = n.queueReliably(addr, keyMsg)
+	}
+	for addr, acked := range acks {
+		if !<-acked {
+			return fmt.Errorf("the new key of %s was not acknowledged by %s", docID, addr)
+		}
+	}
+	return nil
+}
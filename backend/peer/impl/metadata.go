@@ -0,0 +1,191 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// SetDocumentMetadata implements peer.CRDT
+func (n *node) SetDocumentMetadata(docID, field, value string) error {
+	err := validateMetadataField(field, value)
+	if err != nil {
+		return err
+	}
+
+	return n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			Type:        types.CRDTSetMetadataType,
+			OperationID: 1,
+			DocumentID:  docID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   types.CRDTSetMetadata{Field: field, Value: value},
+		}},
+	})
+}
+
+// validateMetadataField checks the value of a metadata field.
+func validateMetadataField(field, value string) error {
+	switch field {
+	case types.MetadataTitle, types.MetadataIcon, types.MetadataCover, types.MetadataCreatedBy,
+		types.MetadataDatabase:
+	case types.MetadataKind:
+		if value != types.DocumentPage && value != types.DocumentDatabase {
+			return fmt.Errorf("unknown document kind %q", value)
+		}
+	case types.MetadataCreatedAt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid creation time %q: %w", value, err)
+		}
+	case types.MetadataTags:
+		var tags []string
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			return fmt.Errorf("invalid tags %q: %w", value, err)
+		}
+	case types.MetadataPrivate:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("invalid private flag %q", value)
+		}
+	default:
+		return fmt.Errorf("unknown metadata field %q", field)
+	}
+	return nil
+}
+
+// GetDocumentMetadata implements peer.CRDT
+func (n *node) GetDocumentMetadata(docID string) types.DocumentSummary {
+	summary := types.DocumentSummary{DocumentID: docID}
+
+	// the document ID is the ID of the operation of its creator
+	if _, origin, err := ParseID(docID); err == nil {
+		summary.CreatedBy = origin
+	}
+
+	summary.Status = types.DocumentActive
+	summary.Kind = types.DocumentPage
+
+	for field, op := range lwwRegisters(n.GetBlockOps(docID, types.MetadataBlockID)) {
+		if statusOp, ok := op.Operation.(types.CRDTSetStatus); ok {
+			summary.Status = statusOp.Status
+			summary.StatusTime = statusOp.Timestamp
+			continue
+		}
+		metadataOp, ok := op.Operation.(types.CRDTSetMetadata)
+		if !ok {
+			continue
+		}
+		value := metadataOp.Value
+
+		switch field {
+		case types.MetadataTitle:
+			summary.Title = value
+		case types.MetadataIcon:
+			summary.Icon = value
+		case types.MetadataCover:
+			summary.Cover = value
+		case types.MetadataCreatedBy:
+			summary.CreatedBy = value
+		case types.MetadataCreatedAt:
+			createdAt, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				n.logCRDT.Error().Err(err).Msgf("Invalid creation time of document %s", docID)
+				continue
+			}
+			summary.CreatedAt = createdAt
+		case types.MetadataTags:
+			var tags []string
+			if err := json.Unmarshal([]byte(value), &tags); err != nil {
+				n.logCRDT.Error().Err(err).Msgf("Invalid tags of document %s", docID)
+				continue
+			}
+			summary.Tags = tags
+		case types.MetadataKind:
+			summary.Kind = value
+		case types.MetadataDatabase:
+			summary.Database = value
+		case types.MetadataPrivate:
+			summary.Private = value == "true"
+		}
+	}
+	return summary
+}
+
+// GetDocumentSummaries implements peer.CRDT
+func (n *node) GetDocumentSummaries() ([]types.DocumentSummary, error) {
+	docList, err := n.GetDocumentList()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]types.DocumentSummary, 0, len(docList))
+	for _, docID := range docList {
+		summaries = append(summaries, n.GetDocumentMetadata(docID))
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].CreatedAt == summaries[j].CreatedAt {
+			return summaries[i].DocumentID < summaries[j].DocumentID
+		}
+		return summaries[i].CreatedAt < summaries[j].CreatedAt
+	})
+	return summaries, nil
+}
+
+// lwwWins tells if the operation a overwrites the operation b written to the
+// same last-writer-wins register.
+func lwwWins(a, b types.CRDTOperation) bool {
+	if a.OperationID == b.OperationID {
+		return a.Origin > b.Origin
+	}
+	return a.OperationID > b.OperationID
+}
+
+// lwwRegisterKey returns the register a last-writer-wins operation writes to.
+func lwwRegisterKey(op types.CRDTOperation) (string, bool) {
+	switch registerOp := op.Operation.(type) {
+	case types.CRDTSetMetadata:
+		return registerOp.Field, true
+	case types.CRDTSetStatus:
+		return "status", true
+	case types.CRDTSetProperty:
+		return "property/" + registerOp.Property.ID, true
+	case types.CRDTSetCell:
+		return "cell/" + registerOp.PropertyID, true
+	case types.CRDTSetAccess:
+		return "access/" + registerOp.Member, true
+	default:
+		return "", false
+	}
+}
+
+// lwwRegisters returns the winning operation of each register.
+func lwwRegisters(ops []types.CRDTOperation) map[string]types.CRDTOperation {
+	registers := make(map[string]types.CRDTOperation)
+	for _, op := range ops {
+		key, ok := lwwRegisterKey(op)
+		if !ok {
+			continue
+		}
+		if current, exists := registers[key]; !exists || lwwWins(op, current) {
+			registers[key] = op
+		}
+	}
+	return registers
+}
+
+// lwwWinners drops the register operations that are overwritten. Other
+// operations are kept.
+func lwwWinners(ops []types.CRDTOperation) []types.CRDTOperation {
+	registers := lwwRegisters(ops)
+
+	winners := make([]types.CRDTOperation, 0, len(registers))
+	for _, op := range ops {
+		key, ok := lwwRegisterKey(op)
+		if !ok || (registers[key].OperationID == op.OperationID && registers[key].Origin == op.Origin) {
+			winners = append(winners, op)
+		}
+	}
+	return winners
+}
@@ -0,0 +1,159 @@
+package impl
+
+import (
+	"Node-tion/backend/peer"
+	"Node-tion/backend/types"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const (
+	// maxDocumentSearchResults is the number of documents a peer answers a
+	// document search request with.
+	maxDocumentSearchResults = 20
+
+	// documentSearchReplyBuffer is the number of document search replies
+	// waiting to be merged.
+	documentSearchReplyBuffer = 32
+)
+
+// SearchNetwork implements peer.CRDT
+func (n *node) SearchNetwork(query string, conf peer.ExpandingRing) ([]types.DocumentSearchResult, error) {
+	if len(parseSearchQuery(query)) == 0 {
+		return nil, fmt.Errorf("empty search query %q", query)
+	}
+
+	results := make(map[string]*types.DocumentSearchResult)
+	mergeDocumentSearchResults(results, n.conf.Socket.GetAddress(), n.localDocumentSearchResults(query, ""))
+
+	// expanding-ring search, stopped as soon as a peer has a matching
+	// document
+	budget := conf.Initial
+	for i := uint(0); i < conf.Retry; i++ {
+		neighbors := n.GetRandNeighsFromBudget(budget)
+		if len(neighbors) == 0 {
+			break
+		}
+
+		if n.searchNetworkRound(query, budget, neighbors, conf.Timeout, results) {
+			break
+		}
+		budget *= conf.Factor
+	}
+
+	sorted := make([]types.DocumentSearchResult, 0, len(results))
+	for _, result := range results {
+		sort.Strings(result.Peers)
+		sorted = append(sorted, *result)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Score != sorted[j].Score {
+			return sorted[i].Score > sorted[j].Score
+		}
+		return sorted[i].DocumentID < sorted[j].DocumentID
+	})
+	return sorted, nil
+}
+
+// searchNetworkRound sends a document search request to the neighbors and
+// merges the replies received before the timeout. It tells if a peer answered
+// with a matching document.
+func (n *node) searchNetworkRound(query string, budget uint, neighbors []string, timeout time.Duration,
+	results map[string]*types.DocumentSearchResult) bool {
+
+	requestID := xid.New().String()
+	replyChan := make(chan types.DocumentSearchReplyMessage, documentSearchReplyBuffer)
+	n.SetDocumentSearchReplyChan(requestID, replyChan)
+	defer n.DeleteDocumentSearchReplyChan(requestID)
+
+	budgetMap := n.CreateBudgetMap(budget, len(neighbors))
+	for i, neigh := range neighbors {
+		if budgetMap[i] == 0 {
+			continue
+		}
+		err := n.SendDocumentSearchRequestMessage(neigh, query, budgetMap[i], requestID, n.conf.Socket.GetAddress())
+		if err != nil {
+			n.logCRDT.Error().Err(err).Msg("Error sending DocumentSearchRequestMessage")
+		}
+	}
+
+	found := false
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case reply := <-replyChan:
+			found = found || len(reply.Responses) > 0
+			mergeDocumentSearchResults(results, reply.Peer, reply.Responses)
+		case <-timer.C:
+			return found
+		}
+	}
+}
+
+// localDocumentSearchResults returns the local documents matching a query,
+// with the snippet of their best hit, for the peer at the requester address.
+// An empty requester is ourselves. The encrypted documents are only returned
+// to ourselves, their snippets would reach peers without their key, and the
+// restricted ones to their members.
+func (n *node) localDocumentSearchResults(query, requester string) []types.DocumentSearchResult {
+	results := make([]types.DocumentSearchResult, 0)
+
+	hits, err := n.SearchDocuments(query, 0)
+	if err != nil {
+		return results
+	}
+
+	seen := make(map[string]struct{})
+	for _, hit := range hits {
+		if _, exists := seen[hit.DocumentID]; exists {
+			continue
+		}
+		seen[hit.DocumentID] = struct{}{}
+		if requester != "" && (n.IsDocumentEncrypted(hit.DocumentID) ||
+			!n.canRead(hit.DocumentID, n.originOf(requester))) {
+			continue
+		}
+
+		results = append(results, types.DocumentSearchResult{
+			DocumentID: hit.DocumentID,
+			Title:      n.GetDocumentMetadata(hit.DocumentID).Title,
+			Snippet:    hit.Snippet,
+			Score:      hit.Score,
+		})
+		if len(results) == maxDocumentSearchResults {
+			break
+		}
+	}
+	return results
+}
+
+// mergeDocumentSearchResults adds the documents hosted by a peer to the
+// results. The snippet and the title of the best score are kept.
+func mergeDocumentSearchResults(results map[string]*types.DocumentSearchResult, peerAddr string,
+	responses []types.DocumentSearchResult) {
+
+	for _, response := range responses {
+		result, exists := results[response.DocumentID]
+		if !exists {
+			result = &types.DocumentSearchResult{DocumentID: response.DocumentID}
+			results[response.DocumentID] = result
+		}
+		if !exists || response.Score > result.Score {
+			result.Title = response.Title
+			result.Snippet = response.Snippet
+			result.Score = response.Score
+		}
+
+		hosted := false
+		for _, p := range result.Peers {
+			hosted = hosted || p == peerAddr
+		}
+		if !hosted {
+			result.Peers = append(result.Peers, peerAddr)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// pageLayout is the workspace tree obtained by replaying the page moves.
+type pageLayout struct {
+	docs      map[string]struct{}
+	purged    map[string]struct{}
+	parents   map[string]string
+	positions map[string]float64
+}
+
+// CreateSubpage implements peer.CRDT
+func (n *node) CreateSubpage(parentID, title string) (string, error) {
+	layout := n.getPageLayout()
+	if parentID != "" {
+		if _, exists := layout.docs[parentID]; !exists {
+			return "", fmt.Errorf("page %s not found", parentID)
+		}
+	}
+
+	children, err := n.pageChildren(layout, parentID)
+	if err != nil {
+		return "", err
+	}
+
+	docID := n.newID()
+	err = n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{
+			{
+				Type:        types.CRDTSetMetadataType,
+				OperationID: 1,
+				DocumentID:  docID,
+				BlockID:     types.MetadataBlockID,
+				Operation:   types.CRDTSetMetadata{Field: types.MetadataTitle, Value: title},
+			},
+			{
+				Type:        types.CRDTSetMetadataType,
+				OperationID: 2,
+				DocumentID:  docID,
+				BlockID:     types.MetadataBlockID,
+				Operation: types.CRDTSetMetadata{
+					Field: types.MetadataCreatedAt,
+					Value: strconv.FormatInt(time.Now().Unix(), 10),
+				},
+			},
+			{
+				Type:        types.CRDTMovePageType,
+				OperationID: 3,
+				DocumentID:  docID,
+				BlockID:     types.MetadataBlockID,
+				Operation: types.CRDTMovePage{
+					ParentID: parentID,
+					Position: pagePosition(layout, children, len(children)),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return docID, nil
+}
+
+// MovePage implements peer.CRDT
+func (n *node) MovePage(docID, parentID string, index int) error {
+	layout := n.getPageLayout()
+	if _, exists := layout.docs[docID]; !exists {
+		return fmt.Errorf("page %s not found", docID)
+	}
+	if parentID != "" {
+		if _, exists := layout.docs[parentID]; !exists {
+			return fmt.Errorf("page %s not found", parentID)
+		}
+	}
+	if layout.isAncestor(docID, parentID) {
+		return fmt.Errorf("cannot move page %s under its own subpage %s", docID, parentID)
+	}
+
+	children, err := n.pageChildren(layout, parentID)
+	if err != nil {
+		return err
+	}
+	siblings := make([]string, 0, len(children))
+	for _, child := range children {
+		if child != docID {
+			siblings = append(siblings, child)
+		}
+	}
+	if index < 0 || index > len(siblings) {
+		return fmt.Errorf("invalid index %d among %d pages", index, len(siblings))
+	}
+
+	return n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			Type:        types.CRDTMovePageType,
+			OperationID: 1,
+			DocumentID:  docID,
+			BlockID:     types.MetadataBlockID,
+			Operation: types.CRDTMovePage{
+				ParentID: parentID,
+				Position: pagePosition(layout, siblings, index),
+			},
+		}},
+	})
+}
+
+// GetPageTree implements peer.CRDT
+func (n *node) GetPageTree() ([]types.PageNode, error) {
+	return n.buildPageNodes(n.getPageLayout(), "")
+}
+
+// getPageLayout replays the page moves of every document in the order of
+// their IDs. Since every peer replays the same moves in the same order, the
+// skipped moves are the same and the trees converge.
+func (n *node) getPageLayout() pageLayout {
+	layout := pageLayout{
+		docs:      make(map[string]struct{}),
+		purged:    make(map[string]struct{}),
+		parents:   make(map[string]string),
+		positions: make(map[string]float64),
+	}
+
+	moves := make([]types.CRDTOperation, 0)
+
+	n.editor.mu.Lock()
+	for docID, doc := range n.editor.ed {
+		layout.docs[docID] = struct{}{}
+		for _, op := range doc[types.MetadataBlockID] {
+			if op.Type == types.CRDTMovePageType {
+				moves = append(moves, op)
+			}
+		}
+	}
+	for docID := range n.editor.purged {
+		layout.purged[docID] = struct{}{}
+	}
+	n.editor.mu.Unlock()
+
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].OperationID != moves[j].OperationID {
+			return moves[i].OperationID < moves[j].OperationID
+		}
+		if moves[i].Origin != moves[j].Origin {
+			return moves[i].Origin < moves[j].Origin
+		}
+		return moves[i].DocumentID < moves[j].DocumentID
+	})
+
+	for _, op := range moves {
+		move, ok := op.Operation.(types.CRDTMovePage)
+		if !ok || layout.isAncestor(op.DocumentID, move.ParentID) {
+			continue
+		}
+		layout.parents[op.DocumentID] = move.ParentID
+		layout.positions[op.DocumentID] = move.Position
+	}
+	return layout
+}
+
+// isAncestor tells if a page is the page itself or one of the ancestors of
+// another page.
+func (l pageLayout) isAncestor(ancestor, page string) bool {
+	for page != "" {
+		if page == ancestor {
+			return true
+		}
+		page = l.parents[page]
+	}
+	return false
+}
+
+// visibleParent returns the parent under which a page is shown, the root if
+// the parent is not known yet. The page is hidden if it is not shown itself or
+// if its parent is hidden or purged.
+func (n *node) visibleParent(l pageLayout, docID string, visible map[string]bool) (string, bool) {
+	parentID := l.parents[docID]
+	if _, purged := l.purged[parentID]; purged {
+		return "", false
+	}
+	if _, exists := l.docs[parentID]; !exists {
+		parentID = ""
+	}
+
+	if parentID == "" {
+		return "", n.isPageShown(docID)
+	}
+	if _, done := visible[parentID]; !done {
+		_, visible[parentID] = n.visibleParent(l, parentID, visible)
+	}
+	if !visible[parentID] {
+		return "", false
+	}
+	return parentID, n.isPageShown(docID)
+}
+
+// isPageShown tells if a document is shown in the tree: it must be active and
+// not be the row of a database, rows are listed by their database.
+func (n *node) isPageShown(docID string) bool {
+	summary := n.GetDocumentMetadata(docID)
+	return summary.Status == types.DocumentActive && summary.Database == ""
+}
+
+// pageChildren returns the visible subpages of a page sorted by position.
+func (n *node) pageChildren(l pageLayout, parentID string) ([]string, error) {
+	nodes, err := n.buildPageNodes(l, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		children = append(children, node.DocumentID)
+	}
+	return children, nil
+}
+
+// buildPageNodes returns the visible subtrees of the subpages of a page, the
+// root pages if parentID is empty.
+func (n *node) buildPageNodes(l pageLayout, parentID string) ([]types.PageNode, error) {
+	visible := make(map[string]bool, len(l.docs))
+	children := make(map[string][]string)
+	for docID := range l.docs {
+		parent, ok := n.visibleParent(l, docID, visible)
+		visible[docID] = ok
+		if ok {
+			children[parent] = append(children[parent], docID)
+		}
+	}
+	if parentID != "" && !visible[parentID] {
+		return nil, fmt.Errorf("page %s is not visible", parentID)
+	}
+
+	for _, pages := range children {
+		sort.Slice(pages, func(i, j int) bool {
+			if l.positions[pages[i]] != l.positions[pages[j]] {
+				return l.positions[pages[i]] < l.positions[pages[j]]
+			}
+			return pages[i] < pages[j]
+		})
+	}
+
+	var build func(string) []types.PageNode
+	build = func(id string) []types.PageNode {
+		nodes := make([]types.PageNode, 0, len(children[id]))
+		for _, docID := range children[id] {
+			summary := n.GetDocumentMetadata(docID)
+			nodes = append(nodes, types.PageNode{
+				DocumentID: docID,
+				Title:      summary.Title,
+				Icon:       summary.Icon,
+				Children:   build(docID),
+			})
+		}
+		return nodes
+	}
+	return build(parentID), nil
+}
+
+// pagePosition returns the position of a page inserted at index among
+// siblings sorted by position.
+func pagePosition(l pageLayout, siblings []string, index int) float64 {
+	switch {
+	case len(siblings) == 0:
+		return 0
+	case index == 0:
+		return l.positions[siblings[0]] - 1
+	case index == len(siblings):
+		return l.positions[siblings[len(siblings)-1]] + 1
+	default:
+		return (l.positions[siblings[index-1]] + l.positions[siblings[index]]) / 2
+	}
+}
+
+// flattenPageTree lists the pages of a tree in depth-first order.
+func flattenPageTree(nodes []types.PageNode) []string {
+	docIDs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		docIDs = append(docIDs, node.DocumentID)
+		docIDs = append(docIDs, flattenPageTree(node.Children)...)
+	}
+	return docIDs
+}
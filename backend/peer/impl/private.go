@@ -0,0 +1,254 @@
+package impl
+
+import (
+	"Node-tion/backend/transport"
+	"Node-tion/backend/types"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	"golang.org/x/xerrors"
+)
+
+// SetDocumentPrivate implements peer.CRDT
+func (n *node) SetDocumentPrivate(docID string, private bool) error {
+	return n.SetDocumentMetadata(docID, types.MetadataPrivate, strconv.FormatBool(private))
+}
+
+// isPrivate tells whether the operations of a document must only be sent to
+// its members. The operations about to be sent can change it.
+func (n *node) isPrivate(docID string, ops []types.CRDTOperation) bool {
+	private := n.GetDocumentMetadata(docID).Private
+	for _, op := range ops {
+		if metadataOp, ok := op.Operation.(types.CRDTSetMetadata); ok && metadataOp.Field == types.MetadataPrivate {
+			private = metadataOp.Value == "true"
+		}
+	}
+	return private
+}
+
+// splitPrivateOperations separates the operations of the private documents,
+// per document, from the others.
+func (n *node) splitPrivateOperations(ops []types.CRDTOperation) ([]types.CRDTOperation, map[string][]types.CRDTOperation, []string) {
+	docOps := make(map[string][]types.CRDTOperation)
+	docOrder := make([]string, 0)
+	for _, op := range ops {
+		if _, exists := docOps[op.DocumentID]; !exists {
+			docOrder = append(docOrder, op.DocumentID)
+		}
+		docOps[op.DocumentID] = append(docOps[op.DocumentID], op)
+	}
+
+	shared := make([]types.CRDTOperation, 0, len(ops))
+	private := make(map[string][]types.CRDTOperation)
+	privateOrder := make([]string, 0)
+	for _, docID := range docOrder {
+		if n.isPrivate(docID, docOps[docID]) {
+			private[docID] = docOps[docID]
+			privateOrder = append(privateOrder, docID)
+		} else {
+			shared = append(shared, docOps[docID]...)
+		}
+	}
+	return shared, private, privateOrder
+}
+
+// sendPrivately processes the operations of a private document and sends them
+// to each of its members with a ReliableMessage. Nothing is gossiped, the
+// other peers at most relay them without processing them.
+func (n *node) sendPrivately(docID string, ops []types.CRDTOperation) error {
+	recipients, _ := n.memberAddresses(docID, ops)
+
+	msgs, err := n.operationsMessages(types.CRDTOperationsMessage{Operations: ops}, false)
+	if err != nil {
+		return err
+	}
+
+	self := n.conf.Socket.GetAddress()
+	for _, msg := range msgs {
+		msg := msg
+		err = n.processLocally(msg)
+		if err != nil {
+			return err
+		}
+
+		for addr := range recipients {
+			if addr != self {
+				n.queueReliably(addr, msg)
+			}
+		}
+	}
+	return nil
+}
+
+// ReliableQueues holds the reliable messages not sent yet of each
+// destination. The messages of a destination are sent one at a time, in
+// order, each once the previous one was acknowledged or given up.
+type ReliableQueues struct {
+	mu     sync.Mutex
+	queues map[string][]queuedMessage
+}
+
+// queuedMessage is a reliable message waiting to be sent, with the channel
+// telling whether it was acknowledged.
+type queuedMessage struct {
+	msg   transport.Message
+	acked chan bool
+}
+
+// Push queues a message for a destination. It returns true if the
+// destination had no queue, the caller then sends its messages.
+func (r *ReliableQueues) Push(dest string, queued queuedMessage) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queue, active := r.queues[dest]
+	r.queues[dest] = append(queue, queued)
+	return !active
+}
+
+// Pop returns the next message of a destination. Once its queue is empty, it
+// is dropped and Pop returns false.
+func (r *ReliableQueues) Pop(dest string) (queuedMessage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queue := r.queues[dest]
+	if len(queue) == 0 {
+		delete(r.queues, dest)
+		return queuedMessage{}, false
+	}
+	r.queues[dest] = queue[1:]
+	return queue[0], true
+}
+
+// queueReliably sends a message reliably after the previous reliable
+// messages of its destination, which receives them in order. The channel
+// returned tells whether the destination acknowledged it.
+func (n *node) queueReliably(dest string, msg transport.Message) <-chan bool {
+	acked := make(chan bool, 1)
+	if !n.reliableQueues.Push(dest, queuedMessage{msg: msg, acked: acked}) {
+		return acked
+	}
+	go func() {
+		for {
+			queued, ok := n.reliableQueues.Pop(dest)
+			if !ok {
+				return
+			}
+			queued.acked <- n.sendReliably(dest, queued.msg)
+		}
+	}()
+	return acked
+}
+
+// sendReliably unicasts a message until the destination acknowledges it. It
+// is retried following the data request backoff, and returns false if it was
+// never acknowledged.
+func (n *node) sendReliably(dest string, msg transport.Message) bool {
+	reliableMsg := types.ReliableMessage{
+		MessageID: xid.New().String(),
+		Msg:       &msg,
+	}
+	payload, err := n.conf.MessageRegistry.MarshalMessage(reliableMsg)
+	if err != nil {
+		n.log.Error().Err(err).Msg("Failed to marshal ReliableMessage")
+		return false
+	}
+
+	ack := make(chan bool, 1)
+	n.SetAck(reliableMsg.MessageID, ack)
+	defer n.DeleteAck(reliableMsg.MessageID)
+
+	backoff := n.conf.BackoffDataRequest.Initial
+	for i := uint(0); i <= n.conf.BackoffDataRequest.Retry; i++ {
+		err = n.Unicast(dest, payload)
+		if err != nil {
+			n.log.Warn().Err(err).Msgf("Failed to send ReliableMessage to %s", dest)
+		}
+
+		select {
+		case <-ack:
+			return true
+		case <-n.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= time.Duration(n.conf.BackoffDataRequest.Factor)
+	}
+	n.log.Error().Msgf("ReliableMessage %s to %s was never acknowledged", reliableMsg.MessageID, dest)
+	return false
+}
+
+// ReliableMessageCallback handles the ReliableMessage
+func (n *node) ReliableMessageCallback(msg types.Message, pkt transport.Packet) error {
+	reliableMsg, ok := msg.(*types.ReliableMessage)
+	if !ok || reliableMsg.Msg == nil {
+		return xerrors.Errorf("Message is not a ReliableMessage")
+	}
+
+	ackPayload, err := n.conf.MessageRegistry.MarshalMessage(types.ReliableAckMessage{
+		MessageID: reliableMsg.MessageID,
+	})
+	if err != nil {
+		return xerrors.Errorf("Failed to marshal ReliableAckMessage: %v", err)
+	}
+	err = n.sendBack(pkt, ackPayload)
+	if err != nil {
+		return xerrors.Errorf("Failed to send ReliableAckMessage: %v", err)
+	}
+
+	// only operations, their requests and document keys are delivered this
+	// way
+	switch reliableMsg.Msg.Type {
+	case types.CRDTOperationsMessage{}.Name(), types.EncryptedCRDTOperationsMessage{}.Name(),
+		types.DocumentKeyMessage{}.Name(), types.CRDTSyncRequestMessage{}.Name():
+	default:
+		return xerrors.Errorf("Unexpected %s in ReliableMessage", reliableMsg.Msg.Type)
+	}
+
+	// a retried message is processed again, its operations are already known.
+	// The inner message gets its own header, it is checked as any packet of
+	// the socket.
+	header := *pkt.Header
+	newPkt := transport.Packet{
+		Header: &header,
+		Msg:    reliableMsg.Msg,
+	}
+	if remote, known := n.packetRemotes.Get(pkt.Header); known {
+		n.packetRemotes.Set(newPkt.Header, remote)
+		defer n.packetRemotes.Delete(newPkt.Header)
+	}
+	return n.ProcessMsg(newPkt)
+}
+
+// sendBack replies to the source of a packet through the node the packet was
+// received from, which relays it if needed. The reply cannot be sent to a
+// node the header names but that did not send the packet.
+func (n *node) sendBack(pkt transport.Packet, msg transport.Message) error {
+	remote, known := n.packetRemotes.Get(pkt.Header)
+	if !known {
+		return xerrors.Errorf("unknown sender of the packet from %s", pkt.Header.Source)
+	}
+	self := n.conf.Socket.GetAddress()
+	header := transport.NewHeader(self, self, pkt.Header.Source)
+	return n.conf.Socket.Send(remote, transport.Packet{Header: &header, Msg: &msg}, time.Second*1)
+}
+
+// ReliableAckMessageCallback handles the ReliableAckMessage
+func (n *node) ReliableAckMessageCallback(msg types.Message, pkt transport.Packet) error {
+	ackMsg, ok := msg.(*types.ReliableAckMessage)
+	if !ok {
+		return xerrors.Errorf("Message is not a ReliableAckMessage")
+	}
+
+	ack, exists := n.GetAck(ackMsg.MessageID)
+	if exists {
+		select {
+		case ack <- true:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package impl
+
+import (
+	"Node-tion/backend/peer"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/types"
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// anyMessageType is the key of the rate limit of the message types without
+// their own, see peer.Configuration.RateLimits.
+const anyMessageType = "*"
+
+// RateLimiter keeps a token bucket per source and per type of message, and
+// bans the sources dropping too many packets. The number of sources tracked
+// is bounded, the least recently seen ones are forgotten first, the banned
+// ones last.
+type RateLimiter struct {
+	mu      sync.Mutex
+	sources map[string]*list.Element // of *sourceState, in active or banned
+	active  *list.List               // the sources not banned, the most recently seen first
+	banned  *list.List               // the banned sources, the most recently seen first
+}
+
+// tokenBucket holds the tokens left to a source for a type of message, as of
+// last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// sourceState holds the counters of a source, its buckets by type of message,
+// its strikes towards a ban, the end of its current ban and the list of the
+// rate limiter it is in.
+type sourceState struct {
+	stats       types.RateLimitStats
+	buckets     map[string]*tokenBucket
+	strikes     uint
+	lastStrike  time.Time
+	bannedUntil time.Time
+	inBanned    bool
+}
+
+// Allow tells whether a packet of a source with a type of message is within
+// the limits, and counts it. A source is banned once it drops threshold
+// packets, each within banDuration of the previous one. At most maxSources
+// sources are tracked, 0 means no bound.
+func (r *RateLimiter) Allow(source, msgType string, limits map[string]peer.RateLimit,
+	threshold uint, banDuration time.Duration, maxSources uint, now time.Time) bool {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.source(source, maxSources, now)
+	if now.Before(state.bannedUntil) {
+		state.stats.Dropped[msgType]++
+		return false
+	}
+
+	limit, limited := limits[msgType]
+	if !limited {
+		limit, limited = limits[anyMessageType]
+	}
+	if !limited || state.take(msgType, limit, now) {
+		state.stats.Accepted++
+		return true
+	}
+
+	state.stats.Dropped[msgType]++
+	if threshold == 0 {
+		return false
+	}
+	if now.Sub(state.lastStrike) > banDuration {
+		state.strikes = 0
+	}
+	state.strikes++
+	state.lastStrike = now
+	if state.strikes >= threshold {
+		state.strikes = 0
+		state.bannedUntil = now.Add(banDuration)
+		state.stats.Bans++
+		state.stats.BannedUntil = state.bannedUntil.Unix()
+		r.move(source, state, true)
+	}
+	return false
+}
+
+// Stats returns the counters of the sources, sorted by source.
+func (r *RateLimiter) Stats() []types.RateLimitStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]types.RateLimitStats, 0, len(r.sources))
+	for _, elem := range r.sources {
+		state := elem.Value.(*sourceState)
+		sourceStats := state.stats
+		sourceStats.Dropped = make(map[string]uint, len(state.stats.Dropped))
+		for msgType, dropped := range state.stats.Dropped {
+			sourceStats.Dropped[msgType] = dropped
+		}
+		stats = append(stats, sourceStats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+// source returns the state of a source, created if missing, and marks it as
+// the most recently seen. The least recently seen source is forgotten to make
+// room for a new one, preferably one that is not banned. The caller must hold
+// the lock.
+func (r *RateLimiter) source(source string, maxSources uint, now time.Time) *sourceState {
+	elem, exists := r.sources[source]
+	if exists {
+		state := elem.Value.(*sourceState)
+		// a source whose ban ended is not banned anymore
+		r.move(source, state, now.Before(state.bannedUntil))
+		return state
+	}
+
+	if maxSources > 0 && uint(len(r.sources)) >= maxSources {
+		r.forget(now)
+	}
+
+	state := &sourceState{
+		stats: types.RateLimitStats{
+			Source:  source,
+			Dropped: make(map[string]uint),
+		},
+		buckets: make(map[string]*tokenBucket),
+	}
+	r.sources[source] = r.active.PushFront(state)
+	return state
+}
+
+// move puts a source first in the list of the banned sources or in the list
+// of the others. The caller must hold the lock.
+func (r *RateLimiter) move(source string, state *sourceState, banned bool) {
+	elem := r.sources[source]
+	if state.inBanned == banned {
+		if banned {
+			r.banned.MoveToFront(elem)
+		} else {
+			r.active.MoveToFront(elem)
+		}
+		return
+	}
+
+	if state.inBanned {
+		r.banned.Remove(elem)
+	} else {
+		r.active.Remove(elem)
+	}
+	state.inBanned = banned
+	if banned {
+		r.sources[source] = r.banned.PushFront(state)
+	} else {
+		r.sources[source] = r.active.PushFront(state)
+	}
+}
+
+// forget drops the least recently seen source, a banned one only if all are
+// banned, or if its ban is over. The caller must hold the lock.
+func (r *RateLimiter) forget(now time.Time) {
+	elem := r.banned.Back()
+	if elem == nil || now.Before(elem.Value.(*sourceState).bannedUntil) {
+		if oldest := r.active.Back(); oldest != nil {
+			elem = oldest
+		}
+	}
+	if elem == nil {
+		return
+	}
+
+	state := elem.Value.(*sourceState)
+	if state.inBanned {
+		r.banned.Remove(elem)
+	} else {
+		r.active.Remove(elem)
+	}
+	delete(r.sources, state.stats.Source)
+}
+
+// take refills the bucket of a type of message and takes a token from it if
+// there is one. A new bucket starts full. The caller must hold the lock of
+// the rate limiter.
+func (s *sourceState) take(msgType string, limit peer.RateLimit, now time.Time) bool {
+	bucket, exists := s.buckets[msgType]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[msgType] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Seconds() * limit.Rate
+	if bucket.tokens > float64(limit.Burst) {
+		bucket.tokens = float64(limit.Burst)
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// allowPacket tells whether a received packet is within the rate limits of the
+// node that sent it, known by the address the socket received it from rather
+// than by a header field its sender chooses: a node relaying a flood is
+// limited, not the one it claims to relay. The packets of sockets that cannot
+// tell the address share one budget.
+func (n *node) allowPacket(pkt transport.Packet, remote string) bool {
+	if len(n.conf.RateLimits) == 0 {
+		return true
+	}
+	return n.rateLimiter.Allow(remote, pkt.Msg.Type, n.conf.RateLimits,
+		n.conf.BanThreshold, n.conf.BanDuration, n.conf.RateLimitSources, time.Now())
+}
+
+// GetRateLimitStats implements peer.Messaging
+func (n *node) GetRateLimitStats() []types.RateLimitStats {
+	return n.rateLimiter.Stats()
+}
@@ -0,0 +1,324 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// blockChar is a visible character of a block with its style.
+type blockChar struct {
+	id    string
+	char  rune
+	style types.TextStyle
+}
+
+// FindInDocuments implements peer.CRDT
+func (n *node) FindInDocuments(opts types.FindOptions) ([]types.TextMatch, error) {
+	re, err := compileFindQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	docIDs, err := n.findDocumentIDs(opts.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]types.TextMatch, 0)
+	for _, docID := range docIDs {
+		err = n.forEachBlockMatch(docID, re, func(blockID string, chars []blockChar, text string, loc []int) {
+			start, end := runeOffset(text, loc[0]), runeOffset(text, loc[1])
+			charIDs := make([]string, 0, end-start)
+			for _, c := range chars[start:end] {
+				charIDs = append(charIDs, c.id)
+			}
+			matches = append(matches, types.TextMatch{
+				DocumentID: docID,
+				BlockID:    blockID,
+				Start:      start,
+				End:        end,
+				Text:       text[loc[0]:loc[1]],
+				CharIDs:    charIDs,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// ReplaceInDocuments implements peer.CRDT
+func (n *node) ReplaceInDocuments(opts types.FindOptions, replacement string) (int, error) {
+	re, err := compileFindQuery(opts)
+	if err != nil {
+		return 0, err
+	}
+	docIDs, err := n.findDocumentIDs(opts.DocumentID)
+	if err != nil {
+		return 0, err
+	}
+
+	replaced := 0
+	for _, docID := range docIDs {
+		ops := make([]types.CRDTOperation, 0)
+		tmpID := uint64(1)
+		count := 0
+
+		err = n.forEachBlockMatch(docID, re, func(blockID string, chars []blockChar, text string, loc []int) {
+			with := replacement
+			if opts.Regex {
+				with = string(re.ExpandString(nil, replacement, text, loc))
+			}
+			matched := chars[runeOffset(text, loc[0]):runeOffset(text, loc[1])]
+			ops = append(ops, replaceOperations(docID, blockID, matched, with, &tmpID)...)
+			count++
+		})
+		if err != nil {
+			return replaced, err
+		}
+		if len(ops) == 0 {
+			continue
+		}
+
+		err = n.SaveTransactions(types.CRDTOperationsMessage{Operations: ops})
+		if err != nil {
+			return replaced, fmt.Errorf("failed to replace in document %s: %w", docID, err)
+		}
+		replaced += count
+	}
+	return replaced, nil
+}
+
+// compileFindQuery returns the regular expression looking for the query.
+func compileFindQuery(opts types.FindOptions) (*regexp.Regexp, error) {
+	if opts.Query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	expr := opts.Query
+	if !opts.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", opts.Query, err)
+	}
+	return re, nil
+}
+
+// findDocumentIDs returns the document to search, or every active document if
+// docID is empty.
+func (n *node) findDocumentIDs(docID string) ([]string, error) {
+	docIDs := n.getDocumentIDs()
+	if docID != "" {
+		for _, id := range docIDs {
+			if id == docID {
+				return []string{docID}, nil
+			}
+		}
+		return nil, fmt.Errorf("document %s not found", docID)
+	}
+
+	active := make([]string, 0, len(docIDs))
+	for _, id := range docIDs {
+		if n.GetDocumentMetadata(id).Status == types.DocumentActive {
+			active = append(active, id)
+		}
+	}
+	sort.Strings(active)
+	return active, nil
+}
+
+// forEachBlockMatch calls f for every non-empty match of a regular expression
+// in the text of the blocks of a document, in document order. loc holds the
+// byte offsets of the match and of its groups in text.
+func (n *node) forEachBlockMatch(docID string, re *regexp.Regexp,
+	f func(blockID string, chars []blockChar, text string, loc []int)) error {
+
+	document, err := n.populateDocumentBlocks(docID)
+	if err != nil {
+		return fmt.Errorf("failed to compile document %s: %w", docID, err)
+	}
+
+	for _, blockID := range flattenBlocks(document) {
+		block, _ := FindBlock(document, blockID)
+		if block.Deleted {
+			continue
+		}
+		chars := n.blockChars(docID, block)
+		if len(chars) == 0 {
+			continue
+		}
+
+		runes := make([]rune, len(chars))
+		for i, c := range chars {
+			runes[i] = c.char
+		}
+		text := string(runes)
+
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			f(blockID, chars, text, loc)
+		}
+	}
+	return nil
+}
+
+// blockChars returns the visible characters of a text block, without its
+// children.
+func (n *node) blockChars(docID string, block types.BlockFactory) []blockChar {
+	switch block.BlockType {
+	case types.ParagraphBlockType, types.HeadingBlockType, types.BulletedListBlockType,
+		types.NumberedListBlockType:
+	default:
+		return nil
+	}
+
+	chars := make([]blockChar, 0)
+	add := func(charIDs []string, text string, style types.TextStyle) {
+		runes := []rune(text)
+		for i := 0; i < len(charIDs) && i < len(runes); i++ {
+			chars = append(chars, blockChar{id: charIDs[i], char: runes[i], style: style})
+		}
+	}
+
+	content := n.createBlockContentFromSnapshot(n.GetBlockSnapshot(docID, block.ID), n.GetBlockOps(docID, block.ID))
+	for _, inline := range content {
+		switch c := inline.(type) {
+		case *types.StyledText:
+			add(c.CharIDs, c.Text, c.Styles)
+		case *types.PageMention:
+			add(c.CharIDs, c.Text, c.Styles)
+		}
+	}
+	return chars
+}
+
+// replaceOperations returns the operations replacing the matched characters
+// of a block with a text. The text is inserted after the last matched
+// character and every inserted character gets the marks of the matched
+// character at the same offset, or of the last one. tmpID is the next
+// temporary operation ID.
+func replaceOperations(docID, blockID string, matched []blockChar, text string,
+	tmpID *uint64) []types.CRDTOperation {
+
+	ops := make([]types.CRDTOperation, 0)
+	tempID := func(id uint64) string {
+		return fmt.Sprintf("%d@temp", id)
+	}
+
+	runes := []rune(text)
+	if len(runes) > 0 {
+		first := *tmpID
+		ops = append(ops, types.CRDTOperation{
+			Type:        types.CRDTInsertTextType,
+			OperationID: first,
+			DocumentID:  docID,
+			BlockID:     blockID,
+			Operation:   types.CRDTInsertText{AfterID: matched[len(matched)-1].id, Text: text},
+		})
+		*tmpID += uint64(len(runes))
+
+		styleAt := func(i int) types.TextStyle {
+			if i >= len(matched) {
+				i = len(matched) - 1
+			}
+			return matched[i].style
+		}
+		for start := 0; start < len(runes); {
+			style := styleAt(start)
+			end := start
+			for end+1 < len(runes) && styleAt(end+1) == style {
+				end++
+			}
+			for _, mark := range StyleMarks(style) {
+				mark.Start = types.MarkStart{Type: "before", OpID: tempID(first + uint64(start))}
+				mark.End = types.MarkEnd{Type: "after", OpID: tempID(first + uint64(end))}
+				ops = append(ops, types.CRDTOperation{
+					Type:        types.CRDTAddMarkType,
+					OperationID: *tmpID,
+					DocumentID:  docID,
+					BlockID:     blockID,
+					Operation:   mark,
+				})
+				*tmpID++
+			}
+			start = end + 1
+		}
+	}
+
+	// the matched characters are deleted by runs of consecutive IDs
+	for i := 0; i < len(matched); {
+		length := 1
+		id, origin, err := ParseID(matched[i].id)
+		for err == nil && i+length < len(matched) {
+			next, nextOrigin, nextErr := ParseID(matched[i+length].id)
+			if nextErr != nil || nextOrigin != origin || next != id+uint64(length) {
+				break
+			}
+			length++
+		}
+		ops = append(ops, types.CRDTOperation{
+			Type:        types.CRDTDeleteRangeType,
+			OperationID: *tmpID,
+			DocumentID:  docID,
+			BlockID:     blockID,
+			Operation:   types.CRDTDeleteRange{StartID: matched[i].id, Length: uint64(length)},
+		})
+		*tmpID++
+		i += length
+	}
+	return ops
+}
+
+// StyleMarks returns the marks giving a style to a text, without their range.
+func StyleMarks(style types.TextStyle) []types.CRDTAddMark {
+	marks := make([]types.CRDTAddMark, 0)
+	flags := []struct {
+		markType string
+		set      bool
+	}{
+		{types.Bold, style.Bold},
+		{types.Italic, style.Italic},
+		{types.Underline, style.Underline},
+		{types.Strikethrough, style.Strikethrough},
+	}
+	for _, flag := range flags {
+		if flag.set {
+			marks = append(marks, types.CRDTAddMark{MarkType: flag.markType})
+		}
+	}
+	if style.TextColor != "" {
+		marks = append(marks, types.CRDTAddMark{
+			MarkType: types.TextColor,
+			Options:  types.MarkOptions{Color: style.TextColor},
+		})
+	}
+	if style.BackgroundColor != "" {
+		marks = append(marks, types.CRDTAddMark{
+			MarkType: types.BackgroundColor,
+			Options:  types.MarkOptions{Color: style.BackgroundColor},
+		})
+	}
+	if style.MentionDocument != "" {
+		marks = append(marks, types.CRDTAddMark{
+			MarkType: types.Mention,
+			Options:  types.MarkOptions{DocumentID: style.MentionDocument, BlockID: style.MentionBlock},
+		})
+	}
+	return marks
+}
+
+// runeOffset converts a byte offset of a text into a character offset.
+func runeOffset(text string, offset int) int {
+	return utf8.RuneCountInString(text[:offset])
+}
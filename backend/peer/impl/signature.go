@@ -0,0 +1,227 @@
+package impl
+
+import (
+	"Node-tion/backend/identity"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/types"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// signedOperation is the content of an operation covered by its signature.
+type signedOperation struct {
+	Type        string
+	Origin      string
+	OperationID uint64
+	DocumentID  string
+	BlockID     string
+	Operation   types.CRDTOp
+	Audit       *types.AuditLink `json:",omitempty"`
+}
+
+// signedRumor is the content of a rumor covered by its signature.
+type signedRumor struct {
+	Origin   string
+	Address  string
+	ReadOnly bool
+	Sequence uint
+	Msg      *transport.Message
+}
+
+// signedDocumentKey is the content of a key message covered by its signature.
+type signedDocumentKey struct {
+	DocumentID string
+	Recipient  string
+	Epoch      uint
+	SealedKey  []byte
+	Sender     string
+}
+
+// operationBytes returns the bytes an operation is signed on. The operation
+// must be cast, so that its content is encoded the same by every peer.
+func operationBytes(op types.CRDTOperation) ([]byte, error) {
+	return json.Marshal(signedOperation{
+		Type:        op.Type,
+		Origin:      op.Origin,
+		OperationID: op.OperationID,
+		DocumentID:  op.DocumentID,
+		BlockID:     op.BlockID,
+		Operation:   op.Operation,
+		Audit:       op.Audit,
+	})
+}
+
+// rumorBytes returns the bytes a rumor is signed on.
+func rumorBytes(rumor types.Rumor) ([]byte, error) {
+	return json.Marshal(signedRumor{
+		Origin:   rumor.Origin,
+		Address:  rumor.Address,
+		ReadOnly: rumor.ReadOnly,
+		Sequence: rumor.Sequence,
+		Msg:      rumor.Msg,
+	})
+}
+
+// documentKeyBytes returns the bytes a key message is signed on.
+func documentKeyBytes(keyMsg types.DocumentKeyMessage) ([]byte, error) {
+	return json.Marshal(signedDocumentKey{
+		DocumentID: keyMsg.DocumentID,
+		Recipient:  keyMsg.Recipient,
+		Epoch:      keyMsg.Epoch,
+		SealedKey:  keyMsg.SealedKey,
+		Sender:     keyMsg.Sender,
+	})
+}
+
+// signOperations signs our operations, if we have an identity.
+func (n *node) signOperations(ops []types.CRDTOperation) error {
+	if n.conf.Identity == nil {
+		return nil
+	}
+	for i := range ops {
+		buf, err := operationBytes(ops[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode operation %d: %w", ops[i].OperationID, err)
+		}
+		ops[i].Signature = n.conf.Identity.Sign(buf)
+	}
+	return nil
+}
+
+// signRumor signs our rumor, if we have an identity.
+func (n *node) signRumor(rumor *types.Rumor) error {
+	if n.conf.Identity == nil {
+		return nil
+	}
+	buf, err := rumorBytes(*rumor)
+	if err != nil {
+		return fmt.Errorf("failed to encode rumor: %w", err)
+	}
+	rumor.Signature = n.conf.Identity.Sign(buf)
+	return nil
+}
+
+// signatureRequired tells whether the content of an origin must be signed. A
+// node with an identity signs its content, it accepts no unsigned content in
+// return.
+func (n *node) signatureRequired(origin string) bool {
+	return n.conf.RequireSignatures || n.conf.Identity != nil || identity.IsIdentity(origin)
+}
+
+// verifyOperation checks the signature of a cast operation. A run cut from a
+// signed run is checked against the original run.
+func (n *node) verifyOperation(op types.CRDTOperation) error {
+	signed := op
+	if op.Original != nil {
+		signed = *op.Original
+		err := n.castOperation(&signed)
+		if err != nil {
+			return fmt.Errorf("original operation: %w", err)
+		}
+		err = checkCutRun(op, signed)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(signed.Signature) == 0 {
+		if n.signatureRequired(signed.Origin) {
+			return fmt.Errorf("unsigned operation")
+		}
+		return nil
+	}
+	buf, err := operationBytes(signed)
+	if err != nil {
+		return err
+	}
+	err = identity.Verify(signed.Origin, buf, signed.Signature)
+	if err != nil {
+		return fmt.Errorf("signature of %s: %w", signed.Origin, err)
+	}
+	return nil
+}
+
+// checkCutRun checks that a run holds consecutive characters of an original
+// run, placed like in the original run.
+func checkCutRun(op, original types.CRDTOperation) error {
+	run, isRun := op.Operation.(types.CRDTInsertText)
+	originalRun, isOriginalRun := original.Operation.(types.CRDTInsertText)
+	switch {
+	case !isRun || !isOriginalRun:
+		return fmt.Errorf("only runs can be cut")
+	case op.Type != original.Type || op.Origin != original.Origin ||
+		op.DocumentID != original.DocumentID || op.BlockID != original.BlockID:
+		return fmt.Errorf("run does not match its original run")
+	case op.OperationID < original.OperationID:
+		return fmt.Errorf("run starts before its original run")
+	}
+
+	chars := []rune(run.Text)
+	originalChars := []rune(originalRun.Text)
+	offset := op.OperationID - original.OperationID
+	if len(chars) == 0 || offset+uint64(len(chars)) > uint64(len(originalChars)) ||
+		string(originalChars[offset:offset+uint64(len(chars))]) != run.Text {
+		return fmt.Errorf("text is not part of the original run")
+	}
+
+	afterID := originalRun.AfterID
+	if offset > 0 {
+		afterID = fmt.Sprintf("%d@%s", op.OperationID-1, op.Origin)
+	}
+	if run.AfterID != afterID {
+		return fmt.Errorf("run is not placed like in its original run")
+	}
+	return nil
+}
+
+// cutFrom returns a copy of a run to cut it, the signature of the run is kept
+// with the original run. So is the run of a batch, the audit log hashes it
+// whole.
+func cutFrom(run types.CRDTOperation) types.CRDTOperation {
+	cut := run
+	if run.Original != nil {
+		cut.Original = run.Original
+	} else if len(run.Signature) > 0 || run.Audit != nil {
+		original := run
+		cut.Original = &original
+	}
+	cut.Signature = nil
+	return cut
+}
+
+// verifyRumor checks the signature of a rumor.
+func (n *node) verifyRumor(rumor types.Rumor) error {
+	if len(rumor.Signature) == 0 {
+		if n.signatureRequired(rumor.Origin) {
+			return fmt.Errorf("unsigned rumor")
+		}
+		return nil
+	}
+	buf, err := rumorBytes(rumor)
+	if err != nil {
+		return err
+	}
+	err = identity.Verify(rumor.Origin, buf, rumor.Signature)
+	if err != nil {
+		return fmt.Errorf("signature of %s: %w", rumor.Origin, err)
+	}
+	return nil
+}
+
+// rejectRumor reports a rumor dropped because of its signature.
+func (n *node) rejectRumor(rumor types.Rumor, source string, err error) {
+	n.log.Warn().Err(err).Msgf("Rejected rumor %d of %s from %s", rumor.Sequence, rumor.Origin, source)
+	n.quarantine.AddRumor(types.RejectedRumor{
+		Origin:    rumor.Origin,
+		Sequence:  rumor.Sequence,
+		Source:    source,
+		Reason:    err.Error(),
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// GetRejectedRumors implements peer.Messaging
+func (n *node) GetRejectedRumors() []types.RejectedRumor {
+	return n.quarantine.GetRumors()
+}
@@ -0,0 +1,224 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// BootstrapDocument implements peer.CRDT
+func (n *node) BootstrapDocument(docID string, peerAddr string, timeout time.Duration) error {
+	requestID := xid.New().String()
+	// set up the reply channel BEFORE sending the request
+	replyChan := make(chan types.CRDTSnapshotReplyMessage, 1)
+	n.SetSnapshotReplyChan(requestID, replyChan)
+	defer n.DeleteSnapshotReplyChan(requestID)
+
+	err := n.SendCRDTSnapshotRequestMessage(peerAddr, docID, requestID)
+	if err != nil {
+		return err
+	}
+
+	var reply types.CRDTSnapshotReplyMessage
+	select {
+	case reply = <-replyChan:
+	case <-time.After(timeout):
+		return fmt.Errorf("no snapshot of document %s received from %s", docID, peerAddr)
+	}
+	if reply.Metahash == "" {
+		return fmt.Errorf("peer %s does not know document %s", peerAddr, docID)
+	}
+
+	// the snapshot is downloaded chunk by chunk from the replying peer
+	n.UpdateCatalog(reply.Metahash, peerAddr)
+	metafile, err := n.DownloadElement(reply.Metahash)
+	if err != nil {
+		return fmt.Errorf("failed to download snapshot metafile: %w", err)
+	}
+	for _, chunkHash := range n.SplitMetafile(metafile) {
+		n.UpdateCatalog(chunkHash, peerAddr)
+	}
+	data, err := n.Download(reply.Metahash)
+	if err != nil {
+		return fmt.Errorf("failed to download snapshot: %w", err)
+	}
+
+	var snapshot types.DocumentSnapshot
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	if snapshot.DocumentID != docID {
+		return fmt.Errorf("received a snapshot of document %s instead of %s", snapshot.DocumentID, docID)
+	}
+
+	err = n.installSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// stream the operations applied by the peer since the snapshot was taken
+	return n.SendCRDTSyncRequestMessage(peerAddr, docID, n.crdtState.GetVersionVector(docID))
+}
+
+// createDocumentSnapshot compacts a document and returns its state.
+func (n *node) createDocumentSnapshot(docID string) (types.DocumentSnapshot, error) {
+	_, err := n.CompactDocument(docID)
+	if err != nil {
+		return types.DocumentSnapshot{}, err
+	}
+
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	return n.documentLog(docID), nil
+}
+
+// documentLog copies the operations and the block snapshots of a document.
+// The version vector is updated with the editor locked, the caller must hold
+// the editor lock so that it matches the operations copied.
+func (n *node) documentLog(docID string) types.DocumentSnapshot {
+	log := types.DocumentSnapshot{
+		DocumentID:    docID,
+		VersionVector: n.crdtState.GetVersionVector(docID),
+		Operations:    make(map[string][]types.CRDTOperation, len(n.editor.ed[docID])),
+		Blocks:        make(map[string]types.BlockSnapshot, len(n.editor.snap[docID])),
+		Compacted:     n.editor.snap[docID] != nil,
+	}
+	for blockID, ops := range n.editor.ed[docID] {
+		log.Operations[blockID] = make([]types.CRDTOperation, len(ops))
+		copy(log.Operations[blockID], ops)
+	}
+	for blockID, blockSnapshot := range n.editor.snap[docID] {
+		log.Blocks[blockID] = blockSnapshot
+	}
+	return log
+}
+
+// installSnapshot replaces the state of a document by a snapshot. Operations
+// received in the meantime, e.g. through the rumors, are kept if the snapshot
+// does not cover them.
+func (n *node) installSnapshot(snapshot types.DocumentSnapshot) error {
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	docID := snapshot.DocumentID
+	if len(n.editor.snap[docID]) > 0 {
+		return fmt.Errorf("document %s is already compacted locally", docID)
+	}
+
+	doc := make(map[string][]types.CRDTOperation, len(snapshot.Operations))
+	for blockID, ops := range snapshot.Operations {
+		doc[blockID] = make([]types.CRDTOperation, len(ops))
+		for i := range ops {
+			n.CastOperation(&ops[i])
+			doc[blockID][i] = ops[i]
+		}
+	}
+	for blockID, ops := range n.editor.ed[docID] {
+		doc[blockID] = append(doc[blockID], filterCoveredOperations(ops, snapshot.VersionVector)...)
+	}
+	n.editor.ed[docID] = doc
+
+	n.editor.snap[docID] = make(map[string]types.BlockSnapshot, len(snapshot.Blocks))
+	for blockID, blockSnapshot := range snapshot.Blocks {
+		n.editor.snap[docID][blockID] = blockSnapshot
+	}
+
+	n.backlinks.MarkDirty(docID, allBlocks)
+	n.fullText.MarkDirty(docID, allBlocks)
+
+	for origin, opID := range snapshot.VersionVector {
+		n.crdtState.UpdateVersion(docID, origin, opID)
+		if n.crdtState.GetState(docID) < opID {
+			n.crdtState.SetState(docID, opID)
+		}
+	}
+	return nil
+}
+
+// uploadDocumentSnapshot stores the snapshot of a document in the blob store
+// and returns its metahash and version vector.
+func (n *node) uploadDocumentSnapshot(docID string) (string, types.VersionVector, error) {
+	snapshot, err := n.createDocumentSnapshot(docID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	metahash, err := n.Upload(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	return metahash, snapshot.VersionVector, nil
+}
+
+// operationsSince returns the operations of a document that are not covered
+// by a version vector. A run is cut to the characters that are not covered.
+func (n *node) operationsSince(docID string, vv types.VersionVector) []types.CRDTOperation {
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	var ops []types.CRDTOperation
+	for _, blockOps := range n.editor.ed[docID] {
+		ops = append(ops, filterCoveredOperations(blockOps, vv)...)
+	}
+	return n.sortOps(ops)
+}
+
+// filterCoveredOperations drops the operations covered by a version vector.
+func filterCoveredOperations(ops []types.CRDTOperation, vv types.VersionVector) []types.CRDTOperation {
+	filtered := make([]types.CRDTOperation, 0, len(ops))
+	for _, op := range ops {
+		covered := vv[op.Origin]
+		switch {
+		case op.OperationID+OpSpan(op)-1 <= covered:
+			continue
+		case op.OperationID <= covered:
+			filtered = append(filtered, trimInsertText(op, int(covered-op.OperationID+1)))
+		default:
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// filterKnownOperations drops the operations of other origins that are already
+// covered by the version vector of their document. Operations of a given
+// origin are delivered in order, so an ID below the version is a duplicate.
+// Our own operations are processed concurrently and are never filtered.
+func (n *node) filterKnownOperations(ops []types.CRDTOperation) []types.CRDTOperation {
+	self := n.GetIdentity()
+	versions := make(map[string]types.VersionVector)
+	known := make(map[string]map[string]struct{})
+
+	filtered := make([]types.CRDTOperation, 0, len(ops))
+	for _, op := range ops {
+		// the operations requested by a repair are below the version vector,
+		// they are checked against the log instead
+		if n.repairs.Active(op.DocumentID) {
+			if _, exists := known[op.DocumentID]; !exists {
+				known[op.DocumentID] = n.knownOperations(op.DocumentID)
+			}
+			filtered = append(filtered, unknownParts(op, known[op.DocumentID])...)
+			continue
+		}
+		if op.Origin == self {
+			filtered = append(filtered, op)
+			continue
+		}
+		if _, exists := versions[op.DocumentID]; !exists {
+			versions[op.DocumentID] = n.crdtState.GetVersionVector(op.DocumentID)
+		}
+		filtered = append(filtered, filterCoveredOperations([]types.CRDTOperation{op}, versions[op.DocumentID])...)
+	}
+	return filtered
+}
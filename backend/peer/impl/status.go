@@ -0,0 +1,148 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ArchiveDocument implements peer.CRDT
+func (n *node) ArchiveDocument(docID string) error {
+	return n.setDocumentStatus(docID, types.DocumentArchived)
+}
+
+// DeleteDocument implements peer.CRDT
+func (n *node) DeleteDocument(docID string) error {
+	return n.setDocumentStatus(docID, types.DocumentDeleted)
+}
+
+// RestoreDocument implements peer.CRDT
+func (n *node) RestoreDocument(docID string) error {
+	summary := n.GetDocumentMetadata(docID)
+	switch summary.Status {
+	case types.DocumentActive:
+		return fmt.Errorf("document %s is not archived nor deleted", docID)
+	case types.DocumentDeleted:
+		if n.retentionExpired(summary, time.Now()) {
+			return fmt.Errorf("retention of document %s expired", docID)
+		}
+	}
+	return n.setDocumentStatus(docID, types.DocumentActive)
+}
+
+func (n *node) setDocumentStatus(docID, status string) error {
+	n.editor.mu.Lock()
+	_, purged := n.editor.purged[docID]
+	n.editor.mu.Unlock()
+	if purged {
+		return fmt.Errorf("document %s is purged", docID)
+	}
+
+	return n.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			Type:        types.CRDTSetStatusType,
+			OperationID: 1,
+			DocumentID:  docID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   types.CRDTSetStatus{Status: status, Timestamp: time.Now().Unix()},
+		}},
+	})
+}
+
+// GetDocumentSummariesByStatus implements peer.CRDT
+func (n *node) GetDocumentSummariesByStatus(status string) ([]types.DocumentSummary, error) {
+	summaries := make([]types.DocumentSummary, 0)
+	for docID := range n.GetEditor() {
+		summary := n.GetDocumentMetadata(docID)
+		if summary.Status == status {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].StatusTime == summaries[j].StatusTime {
+			return summaries[i].DocumentID < summaries[j].DocumentID
+		}
+		return summaries[i].StatusTime < summaries[j].StatusTime
+	})
+	return summaries, nil
+}
+
+// PurgeExpiredDocuments implements peer.CRDT
+func (n *node) PurgeExpiredDocuments() ([]string, error) {
+	now := time.Now()
+
+	purged := make([]string, 0)
+	for docID := range n.GetEditor() {
+		if !n.retentionExpired(n.GetDocumentMetadata(docID), now) {
+			continue
+		}
+		err := n.purgeDocument(docID)
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, docID)
+	}
+	return purged, nil
+}
+
+// retentionExpired tells if a document is deleted for longer than the
+// retention. Nothing expires if there is no retention.
+func (n *node) retentionExpired(summary types.DocumentSummary, now time.Time) bool {
+	if summary.Status != types.DocumentDeleted || n.conf.DocumentRetention <= 0 {
+		return false
+	}
+	return !now.Before(time.Unix(summary.StatusTime, 0).Add(n.conf.DocumentRetention))
+}
+
+// purgeDocument drops the operations, snapshots and stored files of a
+// document. The operations received later for it are ignored.
+func (n *node) purgeDocument(docID string) error {
+	n.editor.mu.Lock()
+	delete(n.editor.ed, docID)
+	delete(n.editor.snap, docID)
+	n.editor.purged[docID] = struct{}{}
+	n.editor.mu.Unlock()
+
+	n.crdtState.DeleteDocument(docID)
+	n.compaction.DeleteDocument(docID)
+	n.backlinks.DeleteDocument(docID)
+	n.fullText.DeleteDocument(docID)
+	n.operationIDs.DeleteDocument(docID)
+	n.audit.DeleteDocument(docID)
+
+	for _, path := range n.docTimestampMap.RemoveDocs(docID) {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stored document: %w", err)
+		}
+	}
+
+	n.logCRDT.Info().Msgf("purged document %s", docID)
+	return nil
+}
+
+func (n *node) PurgeTicker() {
+	// deleted documents are purged at most a minute after their retention
+	interval := n.conf.DocumentRetention
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	purgeTicker := time.NewTicker(interval)
+	defer purgeTicker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			n.log.Info().Msg("Stopping purge of the deleted documents")
+			return
+		case <-purgeTicker.C:
+			_, err := n.PurgeExpiredDocuments()
+			if err != nil {
+				n.logCRDT.Error().Err(err).Msg("Failed to purge the deleted documents")
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+)
+
+// tempOrigin is the origin of the temporary IDs a transaction gives to its
+// operations before they are saved.
+const tempOrigin = "temp"
+
+// SaveTransactions implements peer.CRDT
+func (n *node) SaveTransactions(transactions types.CRDTOperationsMessage) error {
+	_, err := n.CommitTransaction(transactions)
+	return err
+}
+
+// CommitTransaction implements peer.CRDT
+func (n *node) CommitTransaction(transactions types.CRDTOperationsMessage) (types.TransactionResult, error) {
+	n.logCRDT.Debug().Msgf("CommitTransaction: %d operations", len(transactions.Operations))
+
+	err := n.checkWritable()
+	if err != nil {
+		return types.TransactionResult{}, err
+	}
+
+	// the operations of the caller are left untouched
+	operations := make([]types.CRDTOperation, len(transactions.Operations))
+	copy(operations, transactions.Operations)
+
+	err = n.validateTransaction(operations)
+	if err != nil {
+		return types.TransactionResult{}, err
+	}
+
+	// the transactions are committed one at a time, so that the IDs of a
+	// transaction failing before it is sent are released for the next one
+	n.commitMu.Lock()
+	defer n.commitMu.Unlock()
+
+	// the IDs are reserved per document, the temporary IDs of a run get
+	// consecutive IDs
+	origin := n.GetIdentity()
+	realIDs := make(map[uint64]uint64)
+	reserved := make([]types.CRDTOperation, 0, len(operations))
+	sent := false
+	defer func() {
+		for i := len(reserved) - 1; i >= 0 && !sent; i-- {
+			n.crdtState.Release(reserved[i].DocumentID, reserved[i].OperationID, OpSpan(reserved[i]))
+		}
+	}()
+	for i := range operations {
+		op := &operations[i]
+		span := OpSpan(*op)
+		if n.conf.Counters != nil {
+			n.crdtState.Raise(op.DocumentID, n.conf.Counters.GetDocument(op.DocumentID))
+		}
+		first := n.crdtState.Reserve(op.DocumentID, span)
+		for k := uint64(0); k < span; k++ {
+			realIDs[op.OperationID+k] = first + k
+		}
+		op.OperationID = first
+		op.Origin = origin
+		reserved = append(reserved, *op)
+	}
+
+	resolve := func(ref string) (string, error) {
+		id, username, err := ParseID(ref)
+		if err != nil || username != tempOrigin {
+			return ref, err
+		}
+		return ReconstructOpID(realIDs[id], origin)
+	}
+	for i := range operations {
+		if isMetadataOp(operations[i].Operation) {
+			operations[i].BlockID = types.MetadataBlockID
+		}
+		err = mapReferences(&operations[i], resolve)
+		if err != nil {
+			return types.TransactionResult{}, transactionError(i, transactions.Operations[i], err)
+		}
+	}
+
+	// the operations of each document are chained to the previous ones of the
+	// node in the document, the link is signed with them
+	heads, err := n.audit.Link(origin, operations)
+	if err != nil {
+		return types.TransactionResult{}, err
+	}
+
+	err = n.signOperations(operations)
+	if err != nil {
+		return types.TransactionResult{}, err
+	}
+
+	result := types.TransactionResult{
+		Operations: operations,
+		IDs:        make(map[string]string, len(realIDs)),
+	}
+	for tmpID, id := range realIDs {
+		result.IDs[fmt.Sprintf("%d@%s", tmpID, tempOrigin)] = fmt.Sprintf("%d@%s", id, origin)
+	}
+
+	// the IDs are saved before they are sent, a restarted node does not
+	// reissue them
+	if n.conf.Counters != nil {
+		lastIDs := make(map[string]uint64)
+		for _, op := range operations {
+			lastIDs[op.DocumentID] = max(lastIDs[op.DocumentID], op.OperationID+OpSpan(op)-1)
+		}
+		err = n.conf.Counters.SetDocuments(lastIDs)
+		if err != nil {
+			return types.TransactionResult{}, err
+		}
+	}
+
+	// once sent, even partly, the IDs are used
+	sent = true
+	err = n.processAndBroadcast(types.CRDTOperationsMessage{Operations: operations})
+	if err != nil {
+		return types.TransactionResult{}, err
+	}
+
+	// the next batches follow these ones only once they are sent, a restarted
+	// node goes on from them
+	n.audit.SetHeads(heads)
+	if n.conf.Counters != nil {
+		err = n.conf.Counters.SetAuditHeads(heads)
+		if err != nil {
+			return types.TransactionResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// validateTransaction checks the operations of a transaction before any ID is
+// reserved: their types, their temporary IDs, which must not overlap, and
+// their references, whose temporary IDs must be given in the transaction.
+func (n *node) validateTransaction(operations []types.CRDTOperation) error {
+	tmpIDs := make(map[uint64]int)
+	for i := range operations {
+		op := &operations[i]
+		// an operation without type must already have the right Go type, it
+		// is checked with its references. A metadata operation is stored
+		// under its type, it is given the type of its Go type.
+		if op.Type == "" && isMetadataOp(op.Operation) {
+			op.Type = operationType(op.Operation)
+		} else if op.Type != "" {
+			err := n.castOperation(op)
+			if err != nil {
+				return transactionError(i, *op, err)
+			}
+		}
+		if op.DocumentID == "" {
+			return transactionError(i, *op, fmt.Errorf("missing document ID"))
+		}
+		if op.OperationID == 0 {
+			return transactionError(i, *op, fmt.Errorf("missing temporary ID"))
+		}
+		err := n.checkAccess(op.DocumentID, n.GetIdentity(), op.Operation)
+		if err != nil {
+			return transactionError(i, *op, err)
+		}
+
+		err = validateRun(op.Operation)
+		if err != nil {
+			return transactionError(i, *op, err)
+		}
+		if setAccess, ok := op.Operation.(types.CRDTSetAccess); ok {
+			err = validateAccess(op.DocumentID, setAccess)
+			if err != nil {
+				return transactionError(i, *op, err)
+			}
+		}
+
+		for k := uint64(0); k < OpSpan(*op); k++ {
+			if other, used := tmpIDs[op.OperationID+k]; used {
+				return transactionError(i, *op,
+					fmt.Errorf("temporary ID %d already used by operation %d", op.OperationID+k, other))
+			}
+			tmpIDs[op.OperationID+k] = i
+		}
+	}
+
+	check := func(ref string) (string, error) {
+		id, username, err := ParseID(ref)
+		if err != nil {
+			return ref, err
+		}
+		if _, given := tmpIDs[id]; username == tempOrigin && !given {
+			return ref, fmt.Errorf("unknown temporary ID %s", ref)
+		}
+		return ref, nil
+	}
+	for i := range operations {
+		op := operations[i]
+		err := mapReferences(&op, check)
+		if err != nil {
+			return transactionError(i, operations[i], err)
+		}
+	}
+	return nil
+}
+
+// mapReferences replaces the non-empty block and character IDs an operation
+// refers to with f.
+func mapReferences(op *types.CRDTOperation, f func(ref string) (string, error)) error {
+	var err error
+	mapRef := func(field string, ref *string) {
+		if err != nil || *ref == "" {
+			return
+		}
+		mapped, refErr := f(*ref)
+		if refErr != nil {
+			err = fmt.Errorf("%s: %w", field, refErr)
+			return
+		}
+		*ref = mapped
+	}
+
+	if isMetadataOp(op.Operation) {
+		return nil
+	}
+	mapRef("BlockID", &op.BlockID)
+
+	switch crdtOp := op.Operation.(type) {
+	case types.CRDTAddBlock:
+		mapRef("AfterBlock", &crdtOp.AfterBlock)
+		mapRef("ParentBlock", &crdtOp.ParentBlock)
+		op.Operation = crdtOp
+	case types.CRDTRemoveBlock:
+		mapRef("RemovedBlock", &crdtOp.RemovedBlock)
+		op.Operation = crdtOp
+	case types.CRDTUpdateBlock:
+		mapRef("UpdatedBlock", &crdtOp.UpdatedBlock)
+		mapRef("AfterBlock", &crdtOp.AfterBlock)
+		mapRef("ParentBlock", &crdtOp.ParentBlock)
+		op.Operation = crdtOp
+	case types.CRDTInsertChar:
+		mapRef("AfterID", &crdtOp.AfterID)
+		op.Operation = crdtOp
+	case types.CRDTDeleteChar:
+		mapRef("RemovedID", &crdtOp.RemovedID)
+		op.Operation = crdtOp
+	case types.CRDTAddMark:
+		mapRef("Start", &crdtOp.Start.OpID)
+		mapRef("End", &crdtOp.End.OpID)
+		op.Operation = crdtOp
+	case types.CRDTRemoveMark:
+		mapRef("Start", &crdtOp.Start.OpID)
+		mapRef("End", &crdtOp.End.OpID)
+		op.Operation = crdtOp
+	case types.CRDTInsertText:
+		mapRef("AfterID", &crdtOp.AfterID)
+		op.Operation = crdtOp
+	case types.CRDTDeleteRange:
+		mapRef("StartID", &crdtOp.StartID)
+		op.Operation = crdtOp
+	default:
+		return fmt.Errorf("unknown CRDT operation type: %T", crdtOp)
+	}
+	return err
+}
+
+func transactionError(index int, op types.CRDTOperation, err error) error {
+	return types.TransactionError{Index: index, OperationID: op.OperationID, Type: op.Type, Err: err}
+}
@@ -2,6 +2,7 @@ package impl
 
 import (
 	"Node-tion/backend/peer"
+	"Node-tion/backend/transport"
 	"Node-tion/backend/types"
 	"crypto"
 	"encoding/hex"
@@ -60,6 +61,12 @@ func (s *Set[T]) Values() []T {
 type RoutingTable struct {
 	mu sync.Mutex
 	rt peer.RoutingTable
+	// identities maps the ID of the identity of a node to its current
+	// address, the entries of rt are keyed by address
+	identities map[string]string
+	// readOnly holds the origins of the read-only nodes, as told by their
+	// rumors
+	readOnly map[string]bool
 }
 
 // GetRoutingTable implements peer.Messaging
@@ -88,6 +95,69 @@ func (n *node) SetRoutingEntry(origin, relayAddr string) {
 	}
 }
 
+// GetIdentity implements peer.Messaging
+func (n *node) GetIdentity() string {
+	if n.conf.Identity != nil {
+		return n.conf.Identity.ID
+	}
+	return n.conf.Socket.GetAddress()
+}
+
+// ResolveIdentity implements peer.Messaging
+func (n *node) ResolveIdentity(id string) (string, bool) {
+	n.routingTable.mu.Lock()
+	defer n.routingTable.mu.Unlock()
+
+	addr, exists := n.routingTable.identities[id]
+	return addr, exists
+}
+
+// setIdentityAddress records the current address of the node with the given
+// identity.
+func (n *node) setIdentityAddress(id, addr string) {
+	n.routingTable.mu.Lock()
+	defer n.routingTable.mu.Unlock()
+
+	if n.routingTable.identities[id] != addr {
+		n.log.Info().Msgf("Identity %s is now at %s", id, addr)
+		n.routingTable.identities[id] = addr
+	}
+}
+
+// setReadOnly records whether the node of an origin is read-only.
+func (n *node) setReadOnly(origin string, readOnly bool) {
+	n.routingTable.mu.Lock()
+	defer n.routingTable.mu.Unlock()
+
+	if readOnly {
+		n.routingTable.readOnly[origin] = true
+	} else {
+		delete(n.routingTable.readOnly, origin)
+	}
+}
+
+// isReadOnly tells whether the node of an origin is read-only.
+func (n *node) isReadOnly(origin string) bool {
+	n.routingTable.mu.Lock()
+	defer n.routingTable.mu.Unlock()
+
+	return n.routingTable.readOnly[origin]
+}
+
+// originOf returns the origin of the node at the given address: the ID of its
+// identity if it announced one, the address otherwise.
+func (n *node) originOf(addr string) string {
+	n.routingTable.mu.Lock()
+	defer n.routingTable.mu.Unlock()
+
+	for id, idAddr := range n.routingTable.identities {
+		if idAddr == addr {
+			return id
+		}
+	}
+	return addr
+}
+
 // AddPeer implements peer.Messaging
 func (n *node) AddSinglePeer(addr string) {
 	n.AddPeer(addr)
@@ -186,12 +256,18 @@ func (v *View) GetPeerSeq() map[string]uint {
 	return peerSeq
 }
 
-// IncrementRumorSeq increments the sequence number of the last rumor sent by this node
-func (v *View) IncrementRumorSeq() {
+// NextRumorSeq increments and returns the sequence number of the last rumor
+// sent by this node. The rumors of the node received back from its peers,
+// e.g. sent by a previous run with the same identity, are skipped.
+func (v *View) NextRumorSeq(self string) uint {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
+	if v.peerSeq[self] > v.rumorSeq {
+		v.rumorSeq = v.peerSeq[self]
+	}
 	v.rumorSeq++
+	return v.rumorSeq
 }
 
 // GetRumorSeq returns the sequence number of the last rumor sent by this node
@@ -289,6 +365,187 @@ func (n *node) DeleteDataReplyChan(requestID string) {
 	delete(n.dataReplyChanMap.repl, requestID)
 }
 
+// SnapshotReplyChanMap is a map of RequestID to snapshot reply channel
+type SnapshotReplyChanMap struct {
+	mu   sync.Mutex
+	repl map[string]chan types.CRDTSnapshotReplyMessage // map of RequestID to reply channel
+}
+
+// SetSnapshotReplyChan sets the reply channel for a snapshot request
+func (n *node) SetSnapshotReplyChan(requestID string, replyChan chan types.CRDTSnapshotReplyMessage) {
+	n.snapshotReplyChanMap.mu.Lock()
+	defer n.snapshotReplyChanMap.mu.Unlock()
+
+	n.snapshotReplyChanMap.repl[requestID] = replyChan
+}
+
+// DeleteSnapshotReplyChan deletes the reply channel for a snapshot request
+func (n *node) DeleteSnapshotReplyChan(requestID string) {
+	n.snapshotReplyChanMap.mu.Lock()
+	defer n.snapshotReplyChanMap.mu.Unlock()
+
+	delete(n.snapshotReplyChanMap.repl, requestID)
+}
+
+// DocumentSearchReplyChanMap is a map of RequestID to document search reply
+// channel
+type DocumentSearchReplyChanMap struct {
+	mu   sync.Mutex
+	repl map[string]chan types.DocumentSearchReplyMessage // map of RequestID to reply channel
+}
+
+// SetDocumentSearchReplyChan sets the reply channel for a document search
+// request
+func (n *node) SetDocumentSearchReplyChan(requestID string, replyChan chan types.DocumentSearchReplyMessage) {
+	n.documentSearchReplyChanMap.mu.Lock()
+	defer n.documentSearchReplyChanMap.mu.Unlock()
+
+	n.documentSearchReplyChanMap.repl[requestID] = replyChan
+}
+
+// DeleteDocumentSearchReplyChan deletes the reply channel for a document
+// search request
+func (n *node) DeleteDocumentSearchReplyChan(requestID string) {
+	n.documentSearchReplyChanMap.mu.Lock()
+	defer n.documentSearchReplyChanMap.mu.Unlock()
+
+	delete(n.documentSearchReplyChanMap.repl, requestID)
+}
+
+// LocalQueue holds the messages broadcast by the node and not processed
+// locally yet. They are processed one at a time, in order.
+type LocalQueue struct {
+	mu     sync.Mutex
+	msgs   []transport.Message
+	active bool
+}
+
+// Push queues a message. It returns true if no message was being processed,
+// the caller then processes the messages.
+func (l *LocalQueue) Push(msg transport.Message) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.msgs = append(l.msgs, msg)
+	if l.active {
+		return false
+	}
+	l.active = true
+	return true
+}
+
+// Pop returns the next message, once the previous one was processed. Once the
+// queue is empty, it returns false and the next Push returns true.
+func (l *LocalQueue) Pop() (transport.Message, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.msgs) == 0 {
+		l.active = false
+		return transport.Message{}, false
+	}
+	msg := l.msgs[0]
+	l.msgs = l.msgs[1:]
+	return msg, true
+}
+
+// LocalPackets is the set of the headers of the packets being processed that
+// the node created itself. No header field can tell them apart, the sender of
+// a packet chooses its header.
+type LocalPackets struct {
+	mu      sync.Mutex
+	headers map[*transport.Header]struct{}
+}
+
+// Add marks a packet being processed as local
+func (l *LocalPackets) Add(header *transport.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.headers[header] = struct{}{}
+}
+
+// Contains tells whether a packet was created by the node itself
+func (l *LocalPackets) Contains(header *transport.Header) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, local := l.headers[header]
+	return local
+}
+
+// Delete forgets a packet once processed
+func (l *LocalPackets) Delete(header *transport.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.headers, header)
+}
+
+// RumorOrigins is a map of the header of the packets being processed from a
+// rumor to the origin of the rumor
+type RumorOrigins struct {
+	mu      sync.Mutex
+	origins map[*transport.Header]string // map of packet header to rumor origin
+}
+
+// Set sets the rumor origin of a packet being processed
+func (r *RumorOrigins) Set(header *transport.Header, origin string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.origins[header] = origin
+}
+
+// Get returns the rumor origin of a packet, if it comes from a rumor
+func (r *RumorOrigins) Get(header *transport.Header) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	origin, exists := r.origins[header]
+	return origin, exists
+}
+
+// Delete deletes the rumor origin of a processed packet
+func (r *RumorOrigins) Delete(header *transport.Header) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.origins, header)
+}
+
+// PacketRemotes is a map of the header of the packets being processed to the
+// address they were received from, when the socket can tell it
+type PacketRemotes struct {
+	mu      sync.Mutex
+	remotes map[*transport.Header]string // map of packet header to remote address
+}
+
+// Set sets the remote address of a packet being processed
+func (r *PacketRemotes) Set(header *transport.Header, remote string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remotes[header] = remote
+}
+
+// Get returns the address a packet was received from, if known
+func (r *PacketRemotes) Get(header *transport.Header) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remote, exists := r.remotes[header]
+	return remote, exists
+}
+
+// Delete deletes the remote address of a processed packet
+func (r *PacketRemotes) Delete(header *transport.Header) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.remotes, header)
+}
+
 // SearchReplyChanMap is a map of RequestID to reply channel
 type SearchReplyChanMap struct {
 	mu   sync.Mutex
@@ -691,8 +948,23 @@ func (t *TLC) LenTLCMessages(step uint) int {
 
 // Editor is a map of documents to blocks
 type Editor struct {
-	mu sync.Mutex
-	ed peer.Editor
+	mu   sync.Mutex
+	ed   peer.Editor
+	snap map[string]map[string]types.BlockSnapshot // docID -> blockID -> snapshot of the compacted operations
+
+	purged map[string]struct{} // documents purged after their deletion, their operations are ignored
+}
+
+// getDocumentIDs returns the IDs of the documents of the editor.
+func (n *node) getDocumentIDs() []string {
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	docIDs := make([]string, 0, len(n.editor.ed))
+	for docID := range n.editor.ed {
+		docIDs = append(docIDs, docID)
+	}
+	return docIDs
 }
 
 // GetEditor returns the editor of the CRDT
@@ -719,6 +991,10 @@ func (n *node) UpdateEditor(ops []types.CRDTOperation) error {
 
 	// apply the operation to the editor
 	for _, op := range ops {
+		if _, purged := n.editor.purged[op.DocumentID]; purged {
+			continue
+		}
+
 		if _, exists := n.editor.ed[op.DocumentID]; !exists {
 			n.editor.ed[op.DocumentID] = make(map[string][]types.CRDTOperation)
 		}
@@ -727,16 +1003,26 @@ func (n *node) UpdateEditor(ops []types.CRDTOperation) error {
 			n.editor.ed[op.DocumentID][op.BlockID] = make([]types.CRDTOperation, 0)
 		}
 
-		if _, exists := n.editor.ed[op.DocumentID][op.DocumentID]; !exists {
-			n.editor.ed[op.DocumentID][op.BlockID] = make([]types.CRDTOperation, 0)
-		}
-
 		// cast the operation to the correct type
 		n.CastOperation(&op)
+		n.crdtState.UpdateVersion(op.DocumentID, op.Origin, op.OperationID+OpSpan(op)-1)
+		n.backlinks.Track(op)
+		n.fullText.Track(op)
+		n.operationIDs.Track(op)
+		n.recordAudit(op)
+		if _, isAccess := op.Operation.(types.CRDTSetAccess); isAccess {
+			_, keyEpoch, _ := n.documentKeys.Get(op.DocumentID)
+			err := n.membership.Append(op, keyEpoch)
+			if err != nil {
+				n.logCRDT.Error().Err(err).Msgf("Failed to log the access operation %d@%s", op.OperationID, op.Origin)
+			}
+		}
 
 		// check if the operation is a Block operation
 		if op.Type == types.CRDTAddBlockType || op.Type == types.CRDTRemoveBlockType || op.Type == types.CRDTUpdateBlockType {
 			n.editor.ed[op.DocumentID][op.DocumentID] = append(n.editor.ed[op.DocumentID][op.DocumentID], op)
+		} else if isMetadataOperation(op.Type) {
+			n.editor.ed[op.DocumentID][types.MetadataBlockID] = append(n.editor.ed[op.DocumentID][types.MetadataBlockID], op)
 		} else {
 			n.editor.ed[op.DocumentID][op.BlockID] = append(n.editor.ed[op.DocumentID][op.BlockID], op)
 		}
@@ -745,6 +1031,14 @@ func (n *node) UpdateEditor(ops []types.CRDTOperation) error {
 	return nil
 }
 
+// GetBlockSnapshot returns the snapshot left by the last compaction of a block
+func (n *node) GetBlockSnapshot(docID, blockID string) types.BlockSnapshot {
+	n.editor.mu.Lock()
+	defer n.editor.mu.Unlock()
+
+	return n.editor.snap[docID][blockID]
+}
+
 // GetDocumentOps returns the document of the CRDT
 func (n *node) GetDocumentOps(docID string) map[string][]types.CRDTOperation {
 	n.editor.mu.Lock()
@@ -772,6 +1066,14 @@ func (n *node) GetBlockOps(docID, blockID string) []types.CRDTOperation {
 // CastOperation casts the operation to the correct type
 // necessary after having marshalled the struct and sent as a CRDTOperationsMessage
 func (n *node) CastOperation(op *types.CRDTOperation) {
+	err := n.castOperation(op)
+	if err != nil {
+		n.logCRDT.Error().Err(err).Msg("Failed to cast operation")
+	}
+}
+
+// castOperation casts the operation to the type given by op.Type.
+func (n *node) castOperation(op *types.CRDTOperation) error {
 	var err error
 
 	switch op.Type {
@@ -796,14 +1098,34 @@ func (n *node) CastOperation(op *types.CRDTOperation) {
 	case types.CRDTRemoveMarkType:
 		crdtOp := &types.CRDTRemoveMark{}
 		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTInsertTextType:
+		crdtOp := &types.CRDTInsertText{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTDeleteRangeType:
+		crdtOp := &types.CRDTDeleteRange{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTSetMetadataType:
+		crdtOp := &types.CRDTSetMetadata{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTSetStatusType:
+		crdtOp := &types.CRDTSetStatus{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTMovePageType:
+		crdtOp := &types.CRDTMovePage{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTSetPropertyType:
+		crdtOp := &types.CRDTSetProperty{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTSetCellType:
+		crdtOp := &types.CRDTSetCell{}
+		err = n.CastAndSetOperation(op, crdtOp)
+	case types.CRDTSetAccessType:
+		crdtOp := &types.CRDTSetAccess{}
+		err = n.CastAndSetOperation(op, crdtOp)
 	default:
-		n.logCRDT.Error().Msg("Unknown operation type")
-		return
-	}
-
-	if err != nil {
-		n.logCRDT.Error().Err(err).Msg("Failed to cast operation")
+		return fmt.Errorf("unknown operation type %q", op.Type)
 	}
+	return err
 }
 
 // CastAndSetOperation casts the operation to the correct type and sets it
@@ -870,6 +1192,22 @@ func dereferenceIfOpPointer(op types.CRDTOp) types.CRDTOp {
 		return *v
 	case *types.CRDTRemoveMark:
 		return *v
+	case *types.CRDTInsertText:
+		return *v
+	case *types.CRDTDeleteRange:
+		return *v
+	case *types.CRDTSetMetadata:
+		return *v
+	case *types.CRDTSetStatus:
+		return *v
+	case *types.CRDTMovePage:
+		return *v
+	case *types.CRDTSetProperty:
+		return *v
+	case *types.CRDTSetCell:
+		return *v
+	case *types.CRDTSetAccess:
+		return *v
 	default:
 		return op
 	}
@@ -926,6 +1264,18 @@ func (dtm *DocTimestampMap) DequeueDoc(docID string) {
 	dtm.docSaved[docID] = dtm.docSaved[docID][1:]
 }
 
+// RemoveDocs forgets the documents saved in the directory with the
+// corresponding document ID and returns their paths.
+func (dtm *DocTimestampMap) RemoveDocs(docID string) []string {
+	dtm.mu.Lock()
+	defer dtm.mu.Unlock()
+
+	docs := dtm.docSaved[docID]
+	delete(dtm.docSaved, docID)
+	delete(dtm.newestTimestamp, docID)
+	return docs
+}
+
 // DocSavedLen returns the number of documents saved in the directory
 // with the corresponding document ID.
 func (dtm *DocTimestampMap) DocSavedLen(docID string) int {
@@ -936,8 +1286,8 @@ func (dtm *DocTimestampMap) DocSavedLen(docID string) int {
 
 type CRDTState struct {
 	sync.Mutex
-	state map[string]uint64 // map of documentIDs latest OperationID
-	tmp   map[uint64]uint64 // map of tmpIDs to OperationIDs
+	state    map[string]uint64              // map of documentIDs latest OperationID
+	versions map[string]types.VersionVector // map of documentIDs to the latest OperationID applied per origin
 }
 
 func (c *CRDTState) GetState(docID string) uint64 {
@@ -959,43 +1309,153 @@ func (c *CRDTState) SetState(docID string, state uint64) {
 	c.state[docID] = state
 }
 
-func (c *CRDTState) SetTmpID(tmpID, opID uint64) {
+// Raise raises the last operation ID of a document to at least lastID.
+func (c *CRDTState) Raise(docID string, lastID uint64) {
 	c.Lock()
 	defer c.Unlock()
 
-	c.tmp[tmpID] = opID
+	if c.state[docID] < lastID {
+		c.state[docID] = lastID
+	}
 }
 
-func (c *CRDTState) GetTmpID(tmpID uint64) uint64 {
+// Release gives back count operation IDs of a document reserved from first,
+// unless a later ID was reserved or seen since.
+func (c *CRDTState) Release(docID string, first, count uint64) {
 	c.Lock()
 	defer c.Unlock()
 
-	opID, exists := c.tmp[tmpID]
-	if !exists {
-		return 0
+	if c.state[docID] == first+count-1 {
+		c.state[docID] = first - 1
 	}
-	return opID
 }
 
-func (c *CRDTState) ResetTmp() {
+// Reserve reserves count consecutive operation IDs of a document and returns
+// the first one.
+func (c *CRDTState) Reserve(docID string, count uint64) uint64 {
 	c.Lock()
 	defer c.Unlock()
 
-	c.tmp = make(map[uint64]uint64)
+	first := c.state[docID] + 1
+	c.state[docID] += count
+	return first
 }
 
-func (n *node) GetCRDTState(docID string) uint64 {
-	return n.crdtState.GetState(docID)
+func (c *CRDTState) UpdateVersion(docID, origin string, opID uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, exists := c.versions[docID]; !exists {
+		c.versions[docID] = make(types.VersionVector)
+	}
+	if opID > c.versions[docID][origin] {
+		c.versions[docID][origin] = opID
+	}
+}
+
+func (c *CRDTState) GetVersionVector(docID string) types.VersionVector {
+	c.Lock()
+	defer c.Unlock()
+
+	vv := make(types.VersionVector, len(c.versions[docID]))
+	for origin, opID := range c.versions[docID] {
+		vv[origin] = opID
+	}
+	return vv
+}
+
+func (c *CRDTState) GetVersionVectors() map[string]types.VersionVector {
+	c.Lock()
+	defer c.Unlock()
+
+	versions := make(map[string]types.VersionVector, len(c.versions))
+	for docID, vv := range c.versions {
+		versions[docID] = make(types.VersionVector, len(vv))
+		for origin, opID := range vv {
+			versions[docID][origin] = opID
+		}
+	}
+	return versions
+}
+
+func (c *CRDTState) DeleteDocument(docID string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.state, docID)
+	delete(c.versions, docID)
 }
 
-func (n *node) GetTmpID(id uint64) uint64 {
-	return n.crdtState.GetTmpID(id)
+func (n *node) GetCRDTState(docID string) uint64 {
+	return n.crdtState.GetState(docID)
 }
 
 func (n *node) GetAddress() string {
 	return n.conf.Socket.GetAddress()
 }
 
+// newID returns a new ID@Origin identifier for a document or a database
+// property created by this peer.
+func (n *node) newID() string {
+	return fmt.Sprintf("%d@%s", time.Now().UnixNano(), n.GetIdentity())
+}
+
+// isMetadataOperation tells if an operation applies to the document itself
+// rather than to one of its blocks. Such operations are stored under
+// types.MetadataBlockID.
+func isMetadataOperation(opType string) bool {
+	switch opType {
+	case types.CRDTSetMetadataType, types.CRDTSetStatusType, types.CRDTMovePageType,
+		types.CRDTSetPropertyType, types.CRDTSetCellType, types.CRDTSetAccessType:
+		return true
+	default:
+		return false
+	}
+}
+
+// isMetadataOp tells whether a cast operation is a metadata operation.
+func isMetadataOp(crdtOp types.CRDTOp) bool {
+	return isMetadataOperation(operationType(crdtOp))
+}
+
+// operationType returns the type of a cast operation, empty if unknown.
+func operationType(crdtOp types.CRDTOp) string {
+	switch crdtOp.(type) {
+	case types.CRDTAddBlock:
+		return types.CRDTAddBlockType
+	case types.CRDTRemoveBlock:
+		return types.CRDTRemoveBlockType
+	case types.CRDTUpdateBlock:
+		return types.CRDTUpdateBlockType
+	case types.CRDTInsertChar:
+		return types.CRDTInsertCharType
+	case types.CRDTDeleteChar:
+		return types.CRDTDeleteCharType
+	case types.CRDTAddMark:
+		return types.CRDTAddMarkType
+	case types.CRDTRemoveMark:
+		return types.CRDTRemoveMarkType
+	case types.CRDTInsertText:
+		return types.CRDTInsertTextType
+	case types.CRDTDeleteRange:
+		return types.CRDTDeleteRangeType
+	case types.CRDTSetMetadata:
+		return types.CRDTSetMetadataType
+	case types.CRDTSetStatus:
+		return types.CRDTSetStatusType
+	case types.CRDTMovePage:
+		return types.CRDTMovePageType
+	case types.CRDTSetProperty:
+		return types.CRDTSetPropertyType
+	case types.CRDTSetCell:
+		return types.CRDTSetCellType
+	case types.CRDTSetAccess:
+		return types.CRDTSetAccessType
+	default:
+		return ""
+	}
+}
+
 // ParseID extracts the ID before the "@" symbol and the username after it.
 func ParseID(input string) (uint64, string, error) {
 	// Split the input string on the "@" character.
@@ -0,0 +1,370 @@
+package impl
+
+import (
+	"Node-tion/backend/types"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxQuarantined is the number of rejected operations kept for inspection,
+// the oldest ones are dropped first.
+const maxQuarantined = 1000
+
+// Quarantine keeps the last operations rejected by the validation of the
+// incoming operations, and the last rumors rejected because of their
+// signature.
+type Quarantine struct {
+	mu     sync.Mutex
+	ops    []types.QuarantinedOperation
+	rumors []types.RejectedRumor
+}
+
+// Add adds a rejected operation.
+func (q *Quarantine) Add(op types.QuarantinedOperation) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.ops = append(q.ops, op)
+	if len(q.ops) > maxQuarantined {
+		q.ops = q.ops[len(q.ops)-maxQuarantined:]
+	}
+}
+
+// Get returns the rejected operations, oldest first.
+func (q *Quarantine) Get() []types.QuarantinedOperation {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ops := make([]types.QuarantinedOperation, len(q.ops))
+	copy(ops, q.ops)
+	return ops
+}
+
+// AddRumor adds a rejected rumor.
+func (q *Quarantine) AddRumor(rumor types.RejectedRumor) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rumors = append(q.rumors, rumor)
+	if len(q.rumors) > maxQuarantined {
+		q.rumors = q.rumors[len(q.rumors)-maxQuarantined:]
+	}
+}
+
+// GetRumors returns the rejected rumors, oldest first.
+func (q *Quarantine) GetRumors() []types.RejectedRumor {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	rumors := make([]types.RejectedRumor, len(q.rumors))
+	copy(rumors, q.rumors)
+	return rumors
+}
+
+// Clear drops the rejected operations and rumors.
+func (q *Quarantine) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.ops = make([]types.QuarantinedOperation, 0)
+	q.rumors = make([]types.RejectedRumor, 0)
+}
+
+// GetQuarantinedOperations implements peer.CRDT
+func (n *node) GetQuarantinedOperations() []types.QuarantinedOperation {
+	return n.quarantine.Get()
+}
+
+// ClearQuarantine implements peer.CRDT
+func (n *node) ClearQuarantine() {
+	n.quarantine.Clear()
+}
+
+// idSpan is a run of consecutive operation IDs.
+type idSpan struct {
+	first uint64
+	last  uint64
+}
+
+// OperationIDs indexes the IDs of the stored operations, to catch the
+// references into another document. The IDs are counted per document, so the
+// same ID may exist in several documents.
+type OperationIDs struct {
+	mu    sync.Mutex
+	spans map[string]map[string][]idSpan // origin -> docID -> sorted spans
+}
+
+// Track records the IDs of an operation.
+func (o *OperationIDs) Track(op types.CRDTOperation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	docs, exists := o.spans[op.Origin]
+	if !exists {
+		docs = make(map[string][]idSpan)
+		o.spans[op.Origin] = docs
+	}
+
+	span := idSpan{first: op.OperationID, last: op.OperationID + OpSpan(op) - 1}
+	spans := docs[op.DocumentID]
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].first > span.first })
+	spans = append(spans, idSpan{})
+	copy(spans[i+1:], spans[i:])
+	spans[i] = span
+
+	// the touching spans are merged, the spans of an origin are mostly
+	// consecutive
+	merged := spans[:1]
+	for _, next := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if next.first <= last.last+1 {
+			last.last = max(last.last, next.last)
+			continue
+		}
+		merged = append(merged, next)
+	}
+	docs[op.DocumentID] = merged
+}
+
+// Stored tells whether an ID of an origin is stored in a document.
+func (o *OperationIDs) Stored(docID string, id uint64, origin string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	spans := o.spans[origin][docID]
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].first > id })
+	return i > 0 && spans[i-1].last >= id
+}
+
+// StoredElsewhere tells whether an ID of an origin is stored in a document
+// other than the given one.
+func (o *OperationIDs) StoredElsewhere(docID string, id uint64, origin string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for otherDocID, spans := range o.spans[origin] {
+		if otherDocID == docID {
+			continue
+		}
+		i := sort.Search(len(spans), func(i int) bool { return spans[i].first > id })
+		if i > 0 && spans[i-1].last >= id {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteDocument forgets the IDs of a document.
+func (o *OperationIDs) DeleteDocument(docID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, docs := range o.spans {
+		delete(docs, docID)
+	}
+}
+
+// validateIncomingOperations casts and checks the operations received from
+// another peer. The invalid ones are quarantined and the valid ones returned.
+// rumorOrigin is the origin of the rumor the operations came with, or empty
+// if they were sent directly, e.g. in reply to a sync request.
+func (n *node) validateIncomingOperations(ops []types.CRDTOperation, source, rumorOrigin string) []types.CRDTOperation {
+	// the documents of the IDs given in the message, to catch the references
+	// into another document
+	idDocs := make(map[string]map[string]struct{})
+	for _, op := range ops {
+		for k := uint64(0); k < OpSpan(op); k++ {
+			id := fmt.Sprintf("%d@%s", op.OperationID+k, op.Origin)
+			if _, exists := idDocs[id]; !exists {
+				idDocs[id] = make(map[string]struct{})
+			}
+			idDocs[id][op.DocumentID] = struct{}{}
+		}
+	}
+
+	valid := make([]types.CRDTOperation, 0, len(ops))
+	for i := range ops {
+		op := ops[i]
+		err := n.validateIncomingOperation(&op, rumorOrigin, idDocs)
+		if err != nil {
+			n.logCRDT.Warn().Err(err).Msgf("Quarantined operation %d@%s from %s", op.OperationID, op.Origin, source)
+			n.quarantine.Add(types.QuarantinedOperation{
+				Operation: ops[i],
+				Source:    source,
+				Reason:    err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+			continue
+		}
+		valid = append(valid, op)
+	}
+	return valid
+}
+
+// validateIncomingOperation casts an operation and checks its IDs, its
+// references and its content.
+func (n *node) validateIncomingOperation(op *types.CRDTOperation, rumorOrigin string,
+	idDocs map[string]map[string]struct{}) error {
+
+	err := n.castOperation(op)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case op.DocumentID == "":
+		return fmt.Errorf("missing document ID")
+	case op.OperationID == 0:
+		return fmt.Errorf("missing operation ID")
+	case op.Origin == "" || op.Origin == tempOrigin:
+		return fmt.Errorf("invalid origin %q", op.Origin)
+	case rumorOrigin != "" && op.Origin != rumorOrigin:
+		return fmt.Errorf("origin %s does not match the rumor origin %s", op.Origin, rumorOrigin)
+	case n.isReadOnly(op.Origin):
+		return fmt.Errorf("origin %s is read-only", op.Origin)
+	}
+
+	err = n.verifyOperation(*op)
+	if err != nil {
+		return err
+	}
+
+	err = n.checkAccess(op.DocumentID, op.Origin, op.Operation)
+	if err != nil {
+		return err
+	}
+
+	if isMetadataOperation(op.Type) {
+		if op.BlockID != types.MetadataBlockID {
+			return fmt.Errorf("metadata operation on block %q", op.BlockID)
+		}
+		return validateMetadataOperation(*op)
+	}
+
+	// the IDs are opaque, but the ones made of an operation ID and an origin
+	// must be well-formed and cannot be temporary
+	versions := n.crdtState.GetVersionVector(op.DocumentID)
+	check := func(ref string) (string, error) {
+		if !strings.Contains(ref, "@") {
+			return ref, nil
+		}
+		id, origin, err := ParseID(ref)
+		if err != nil {
+			return ref, err
+		}
+		if origin == tempOrigin {
+			return ref, fmt.Errorf("temporary ID %s", ref)
+		}
+		if docs, given := idDocs[ref]; given {
+			if _, sameDocument := docs[op.DocumentID]; !sameDocument {
+				return ref, fmt.Errorf("reference %s into another document", ref)
+			}
+			return ref, nil
+		}
+		// the operations of an origin arrive in order, an ID the document
+		// should already have but that is only stored in another document
+		// belongs to the other document
+		if versions[origin] >= id && !n.operationIDs.Stored(op.DocumentID, id, origin) &&
+			n.operationIDs.StoredElsewhere(op.DocumentID, id, origin) {
+			return ref, fmt.Errorf("reference %s into another document", ref)
+		}
+		return ref, nil
+	}
+	if op.BlockID == "" {
+		return fmt.Errorf("missing block ID")
+	}
+	checked := *op
+	err = mapReferences(&checked, check)
+	if err != nil {
+		return err
+	}
+
+	switch crdtOp := op.Operation.(type) {
+	case types.CRDTAddBlock:
+		return validateBlockProps(crdtOp.BlockType, crdtOp.Props)
+	case types.CRDTUpdateBlock:
+		return validateBlockProps(crdtOp.BlockType, crdtOp.Props)
+	case types.CRDTInsertChar:
+		if len([]rune(crdtOp.Character)) != 1 {
+			return fmt.Errorf("invalid character %q", crdtOp.Character)
+		}
+	case types.CRDTInsertText, types.CRDTDeleteRange:
+		return validateRun(crdtOp)
+	case types.CRDTAddMark:
+		if crdtOp.MarkType == types.Mention && crdtOp.Options.DocumentID == "" {
+			return fmt.Errorf("mention without document")
+		}
+		return validateMarkType(crdtOp.MarkType)
+	case types.CRDTRemoveMark:
+		return validateMarkType(crdtOp.MarkType)
+	}
+	return nil
+}
+
+// validateMetadataOperation checks the content of a metadata operation.
+func validateMetadataOperation(op types.CRDTOperation) error {
+	switch crdtOp := op.Operation.(type) {
+	case types.CRDTSetMetadata:
+		return validateMetadataField(crdtOp.Field, crdtOp.Value)
+	case types.CRDTSetStatus:
+		switch crdtOp.Status {
+		case types.DocumentActive, types.DocumentArchived, types.DocumentDeleted:
+			return nil
+		default:
+			return fmt.Errorf("unknown document status %q", crdtOp.Status)
+		}
+	case types.CRDTSetProperty:
+		if crdtOp.Property.ID == "" || crdtOp.Property.ID == types.TitleProperty {
+			return fmt.Errorf("invalid property ID %q", crdtOp.Property.ID)
+		}
+	case types.CRDTSetCell:
+		if crdtOp.PropertyID == "" {
+			return fmt.Errorf("missing property ID")
+		}
+	case types.CRDTSetAccess:
+		return validateAccess(op.DocumentID, crdtOp)
+	}
+	return nil
+}
+
+// validateBlockProps checks that the properties of a block are within the
+// schema of its type.
+func validateBlockProps(blockType types.BlockTypeName, props types.DefaultBlockProps) error {
+	switch blockType {
+	case types.ParagraphBlockType, types.HeadingBlockType, types.BulletedListBlockType,
+		types.NumberedListBlockType, types.ImageBlockType, types.TableBlockType:
+		if props.SourceDocument != "" || props.SourceBlock != "" {
+			return fmt.Errorf("%s block with a source", blockType)
+		}
+	case types.TransclusionBlockType:
+		if props.SourceDocument == "" || props.SourceBlock == "" {
+			return fmt.Errorf("transclusion block without source")
+		}
+	default:
+		return fmt.Errorf("unknown block type %q", blockType)
+	}
+
+	switch props.TextAlignment {
+	case "", types.Left, types.Center, types.Right, types.Justify:
+	default:
+		return fmt.Errorf("unknown text alignment %q", props.TextAlignment)
+	}
+	if props.Level < 0 || props.Level > types.H4 {
+		return fmt.Errorf("invalid heading level %d", props.Level)
+	}
+	return nil
+}
+
+// validateMarkType checks that a mark type is known.
+func validateMarkType(markType string) error {
+	switch markType {
+	case types.Bold, types.Italic, types.Underline, types.Strikethrough, types.TextColor,
+		types.BackgroundColor, types.Mention, types.LinkType:
+		return nil
+	default:
+		return fmt.Errorf("unknown mark type %q", markType)
+	}
+}
@@ -0,0 +1,16 @@
+package impl
+
+import "fmt"
+
+// IsReadOnly implements peer.CRDT
+func (n *node) IsReadOnly() bool {
+	return n.conf.ReadOnly
+}
+
+// checkWritable refuses the changes of a read-only node.
+func (n *node) checkWritable() error {
+	if n.conf.ReadOnly {
+		return fmt.Errorf("the node is read-only")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package impl
+
+import (
+	"Node-tion/backend/workspace"
+	"fmt"
+)
+
+// GetInvite implements peer.Messaging
+func (n *node) GetInvite() (string, error) {
+	key := n.workspace.Key()
+	if key == nil {
+		return "", fmt.Errorf("not in a workspace")
+	}
+	invite := workspace.Invite{
+		Address: n.conf.Socket.GetAddress(),
+		Secret:  key,
+	}
+	return invite.Token(), nil
+}
+
+// JoinWorkspace implements peer.Messaging
+func (n *node) JoinWorkspace(token string) error {
+	invite, err := workspace.ParseInvite(token)
+	if err != nil {
+		return err
+	}
+	n.workspace.SetKey(invite.Secret)
+	n.AddPeer(invite.Address)
+	return nil
+}
@@ -2,6 +2,7 @@ package peer
 
 import (
 	"Node-tion/backend/transport"
+	"Node-tion/backend/types"
 	"fmt"
 	"io"
 	"strings"
@@ -45,6 +46,32 @@ type Messaging interface {
 	//
 	// - implemented in HW0
 	SetRoutingEntry(origin, relayAddr string)
+
+	// GetIdentity returns the origin of the node: the ID of its identity if it
+	// has one, its socket address otherwise.
+	GetIdentity() string
+
+	// ResolveIdentity returns the last known address of the node with the
+	// given identity, as announced in its rumors.
+	ResolveIdentity(id string) (string, bool)
+
+	// GetRejectedRumors returns the last rumors dropped because of a missing
+	// or invalid signature.
+	GetRejectedRumors() []types.RejectedRumor
+
+	// GetInvite returns an invite token to the workspace of the node, holding
+	// the address of the node and the key of the workspace.
+	GetInvite() (string, error)
+
+	// JoinWorkspace joins the workspace of an invite token: its key
+	// authenticates the packets from now on, and the node it names becomes a
+	// peer.
+	JoinWorkspace(token string) error
+
+	// GetRateLimitStats returns the counters of the sources of the packets
+	// received, see Configuration.RateLimits. They are only kept when rate
+	// limits are set.
+	GetRateLimitStats() []types.RateLimitStats
 }
 
 // RoutingTable defines a simple next-hop routing table. The key is the origin
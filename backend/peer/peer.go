@@ -1,9 +1,11 @@
 package peer
 
 import (
+	"Node-tion/backend/identity"
 	"Node-tion/backend/registry"
 	"Node-tion/backend/storage"
 	"Node-tion/backend/transport"
+	"Node-tion/backend/workspace"
 	"time"
 )
 
@@ -95,6 +97,94 @@ type Configuration struct {
 	// DocumentDir defines the directory where the documents are stored.
 	// Default: "documents".
 	DocumentDir string
+
+	// CompactionInterval is the interval at which the peer compacts the
+	// causally stable operations of its documents. Version vectors are shared
+	// with the anti-entropy, so AntiEntropyInterval must also be set. 0 means
+	// documents are only compacted on demand.
+	// Default: 0
+	CompactionInterval time.Duration
+
+	// DocumentRetention is how long a deleted document can be restored. Once
+	// it expires, the operations and the stored files of the document are
+	// purged. 0 means deleted documents are never purged.
+	// Default: 0
+	DocumentRetention time.Duration
+
+	// Identity is the persistent identity of the peer. Its ID is the origin of
+	// the CRDT operations and of the rumors of the peer instead of the socket
+	// address, see identity.LoadOrCreate. nil means the socket address is
+	// used.
+	// Default: nil
+	Identity *identity.Identity
+
+	// Counters are the rumor sequence and the operation IDs last used by the
+	// identity, see identity.LoadCounters. The peer goes on from them and
+	// saves them before sending new rumors or operations, so that it does not
+	// reissue IDs its peers already have after a restart. nil means they are
+	// kept in memory only.
+	// Default: nil
+	Counters *identity.Counters
+
+	// RequireSignatures rejects the unsigned operations and rumors whatever
+	// their origin. Otherwise only the ones whose origin is an identity must
+	// be signed, the origins without identity cannot sign. A peer with an
+	// Identity requires them anyway.
+	// Default: false
+	RequireSignatures bool
+
+	// WorkspaceKey is the shared secret of the workspace of the peer. The
+	// packets are authenticated with it, and the ones of the nodes outside of
+	// the workspace are dropped before being processed, see
+	// workspace.Socket. nil means the peer is in no workspace and accepts
+	// every packet, until it joins one with an invite.
+	// Default: nil
+	WorkspaceKey workspace.Key
+
+	// ReadOnly makes the peer a viewer: it syncs the documents and relays the
+	// rumors, but refuses to originate CRDT operations. Its rumors tell the
+	// other peers, which do not wait for its version to compact documents and
+	// reject the operations claiming it as origin.
+	// Default: false
+	ReadOnly bool
+
+	// RateLimits bounds the packets accepted from each source, the node that
+	// sent them whatever node created them, per type of message, e.g. "rumor"
+	// or "searchrequest". The limit of the key "*"
+	// applies to the types without their own. The packets over the limit are
+	// dropped before being processed or relayed. nil means no limit.
+	// Default: nil
+	RateLimits map[string]RateLimit
+
+	// BanThreshold is the number of packets of a source dropped by the rate
+	// limits, each within BanDuration of the previous one, after which every
+	// packet of the source is dropped for BanDuration. 0 means sources are
+	// never banned.
+	// Default: 0
+	BanThreshold uint
+
+	// BanDuration is how long an abusive source is banned, see BanThreshold.
+	// Default: 0
+	BanDuration time.Duration
+
+	// RateLimitSources is the number of sources tracked by the rate limits.
+	// Past it, the least recently seen source is forgotten, the ones not
+	// banned first. 0 means no bound.
+	// Default: 1024
+	RateLimitSources uint
+
+	// AuditAnchorInterval is the interval at which the peer anchors the state
+	// hash of the documents that changed since their last anchor, see
+	// CRDT.AnchorDocument. 0 means documents are only anchored on demand.
+	// Default: 0
+	AuditAnchorInterval time.Duration
+}
+
+// RateLimit describes a token bucket: it holds up to Burst packets and refills
+// at Rate packets per second.
+type RateLimit struct {
+	Rate  float64
+	Burst uint
 }
 
 // Backoff describes parameters for a backoff algorithm. The initial time must
@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"Node-tion/backend/identity"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Once a document has members, its operations only reach them, and the
+// operations of the members without the needed role are refused.
+func Test_Access_Document(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 3)
+	nodes := make([]z.TestNode, 3)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id),
+			z.WithAntiEntropy(time.Millisecond*50))
+		defer nodes[i].Stop()
+	}
+	owner, editor, outsider := nodes[0], nodes[1], nodes[2]
+
+	for _, node := range nodes {
+		for _, other := range nodes {
+			node.AddPeer(other.GetAddr())
+		}
+	}
+	for _, node := range nodes {
+		empty, err := node.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+		require.NoError(t, err)
+		require.NoError(t, node.Broadcast(empty))
+	}
+	time.Sleep(time.Millisecond * 300)
+
+	// the creator of the document is its owner
+	docID := "100@" + ids[0].ID
+	commit := func(node z.TestNode, text string) error {
+		_, err := node.CommitTransaction(types.CRDTOperationsMessage{
+			Operations: paragraphOps(docID, "1@temp", text),
+		})
+		return err
+	}
+
+	require.NoError(t, commit(owner, "public"))
+	require.Error(t, editor.ShareDocument(docID, ids[2].ID, types.RoleViewer))
+	require.Error(t, owner.ShareDocument(docID, ids[1].ID, "admin"))
+	require.Error(t, owner.RevokeDocumentAccess(docID, ids[0].ID))
+	require.NoError(t, owner.ShareDocument(docID, ids[1].ID, types.RoleEditor))
+
+	time.Sleep(time.Millisecond * 300)
+
+	acl := editor.GetDocumentACL(docID)
+	require.Equal(t, ids[0].ID, acl.Creator)
+	require.Equal(t, map[string]string{ids[0].ID: types.RoleOwner, ids[1].ID: types.RoleEditor}, acl.Members)
+	require.Empty(t, outsider.GetDocumentACL(docID).Members)
+
+	// > the new operations only reach the members
+	require.NoError(t, commit(owner, "private"))
+	require.NoError(t, commit(editor, "edit"))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, owner.GetDocumentOps(docID)[docID], 3)
+	require.Len(t, editor.GetDocumentOps(docID)[docID], 3)
+	require.Len(t, outsider.GetDocumentOps(docID)[docID], 1)
+
+	// > the operations of a non-member are rejected by the members
+	require.NoError(t, commit(outsider, "intrusion"))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, owner.GetDocumentOps(docID)[docID], 3)
+	quarantined := owner.GetQuarantinedOperations()
+	require.NotEmpty(t, quarantined)
+	require.Contains(t, quarantined[0].Reason, "no editor access")
+
+	// > a viewer cannot edit, a revoked editor neither
+	require.NoError(t, owner.ShareDocument(docID, ids[2].ID, types.RoleViewer))
+	require.NoError(t, owner.RevokeDocumentAccess(docID, ids[1].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Equal(t, types.RoleViewer, outsider.GetDocumentACL(docID).Members[ids[2].ID])
+	require.Error(t, commit(outsider, "viewer"))
+	require.NotContains(t, editor.GetDocumentACL(docID).Members, ids[1].ID)
+	require.Error(t, commit(editor, "revoked"))
+}
@@ -0,0 +1,354 @@
+package unit
+
+import (
+	"Node-tion/backend/identity"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/storage"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The batches of each author chain the previous one, an altered or dropped
+// batch is detected.
+func Test_Audit_Chain(t *testing.T) {
+	transp := channel.NewTransport()
+
+	id1, err := identity.Generate()
+	require.NoError(t, err)
+	id2, err := identity.Generate()
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id1))
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id2))
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	result, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Hello"),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.Operations[0].Audit)
+	require.Empty(t, result.Operations[0].Audit.Prev)
+	require.Equal(t, 2, result.Operations[0].Audit.Size)
+
+	time.Sleep(time.Millisecond * 200)
+
+	_, err = node2.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "World"),
+	})
+	require.NoError(t, err)
+	_, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Again"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > both nodes hold the same chains
+	log1, err := node1.GetAuditLog("doc")
+	require.NoError(t, err)
+	log2, err := node2.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, log2, 3)
+	for i := range log1 {
+		require.Equal(t, log1[i].Hash, log2[i].Hash)
+	}
+	require.NoError(t, node2.VerifyAuditLog(log1))
+
+	var first, second types.AuditBatch
+	for _, batch := range log2 {
+		if batch.Author == id1.ID && batch.Index == 0 {
+			first = batch
+		}
+		if batch.Author == id1.ID && batch.Index == 1 {
+			second = batch
+		}
+	}
+	require.Equal(t, first.Hash, second.PrevHash)
+
+	report, err := node2.ExportAuditReport("doc")
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+	require.Len(t, report.Authors, 2)
+	require.NotEmpty(t, report.StateHash)
+
+	// > an altered operation breaks the hash of its batch
+	altered := copyAuditLog(log2)
+	for i, batch := range altered {
+		if batch.Author == id1.ID && batch.Index == 1 {
+			altered[i].Operations[1].Operation = types.CRDTInsertText{Text: "Forged"}
+		}
+	}
+	require.Error(t, node2.VerifyAuditLog(altered))
+
+	// > so does a dropped batch
+	dropped := make([]types.AuditBatch, 0)
+	for _, batch := range log2 {
+		if batch.Author == id1.ID && batch.Index == 0 {
+			continue
+		}
+		dropped = append(dropped, batch)
+	}
+	require.Error(t, node2.VerifyAuditLog(dropped))
+
+	// > and a batch cut short, even with its size fixed, the operations are
+	// linked to the batch as signed
+	shortened := copyAuditLog(log2)
+	for i, batch := range shortened {
+		if batch.Author == id2.ID {
+			shortened[i].Operations = shortened[i].Operations[:1]
+			shortened[i].Size = 1
+		}
+	}
+	require.Error(t, node2.VerifyAuditLog(shortened))
+}
+
+// The state hash of a document is anchored in the naming store, again on
+// every tick once the document changed.
+func Test_Audit_Anchor_Alone(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAuditAnchorInterval(time.Millisecond*100))
+	defer node.Stop()
+
+	_, err := node.AnchorDocument("doc")
+	require.Error(t, err)
+
+	_, err = node.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Hello"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 250)
+
+	report, err := node.ExportAuditReport("doc")
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+	require.Len(t, report.Anchors, 1)
+
+	anchor := report.Anchors[0]
+	require.Equal(t, report.StateHash, anchor.StateHash)
+	tagged := node.GetStorage().GetNamingStore().Get(anchor.Name)
+	require.Equal(t, hex.EncodeToString(anchor.StateHash), string(tagged))
+
+	// > the document changes, it is anchored again
+	_, err = node.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "World"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 250)
+
+	report, err = node.ExportAuditReport("doc")
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+	require.Len(t, report.Anchors, 2)
+	require.Equal(t, report.StateHash, report.Anchors[1].StateHash)
+}
+
+// With several peers, the anchor goes through Paxos/TLC and ends up in the
+// blockchain of every peer.
+func Test_Audit_Anchor_Blockchain(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(3), z.WithPaxosID(1))
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(3), z.WithPaxosID(2))
+	defer node2.Stop()
+
+	node3 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(3), z.WithPaxosID(3))
+	defer node3.Stop()
+
+	node1.AddPeer(node2.GetAddr(), node3.GetAddr())
+	node2.AddPeer(node1.GetAddr(), node3.GetAddr())
+	node3.AddPeer(node1.GetAddr(), node2.GetAddr())
+
+	_, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Hello"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	anchor, err := node1.AnchorDocument("doc")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	for _, node := range []z.TestNode{node1, node2, node3} {
+		tagged := node.GetStorage().GetNamingStore().Get(anchor.Name)
+		require.Equal(t, hex.EncodeToString(anchor.StateHash), string(tagged))
+		require.NotNil(t, node.GetStorage().GetBlockchainStore().Get(storage.LastBlockKey))
+	}
+}
+
+// A restarted node goes on with its chain from its saved head. Without it, the
+// new chain is kept apart from the first one and reported.
+func Test_Audit_Restart(t *testing.T) {
+	transp := channel.NewTransport()
+	path := filepath.Join(t.TempDir(), "identity.key.counters")
+
+	id, err := identity.Generate()
+	require.NoError(t, err)
+	counters, err := identity.LoadCounters(path)
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id), z.WithCounters(counters))
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	_, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Hello"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > the same identity runs again with its counters
+	node1.Stop()
+	counters, err = identity.LoadCounters(path)
+	require.NoError(t, err)
+	head := counters.GetAuditHeads()["doc"]
+	require.NotEmpty(t, head)
+
+	node1b := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id), z.WithCounters(counters))
+
+	node1b.AddPeer(node2.GetAddr())
+
+	result, err := node1b.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "World"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, head, result.Operations[0].Audit.Prev)
+
+	time.Sleep(time.Millisecond * 200)
+
+	log, err := node2.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.Equal(t, log[0].Hash, log[1].PrevHash)
+	require.NoError(t, node2.VerifyAuditLog(log))
+
+	// > without its saved head, e.g. with counters saved before the heads
+	// were, the identity starts a chain again
+	node1b.Stop()
+	counters, err = identity.LoadCounters(path)
+	require.NoError(t, err)
+	delete(counters.AuditHeads, "doc")
+
+	node1c := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id), z.WithCounters(counters))
+	defer node1c.Stop()
+
+	node1c.AddPeer(node2.GetAddr())
+
+	_, err = node1c.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Again"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	log, err = node2.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+	for _, batch := range log {
+		require.Len(t, batch.Operations, 2)
+	}
+	require.Empty(t, log[2].PrevHash)
+	require.Error(t, node2.VerifyAuditLog(log))
+}
+
+// The batches folded into the snapshots by the compaction are dropped, the
+// chain goes on from the last one dropped.
+func Test_Audit_Compaction(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*100))
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*100))
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	_, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Hello"),
+	})
+	require.NoError(t, err)
+	_, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "World"),
+	})
+	require.NoError(t, err)
+
+	// > wait for the operations and the version vectors to be exchanged
+	time.Sleep(time.Second)
+
+	full, err := node1.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, full, 2)
+
+	_, err = node1.CompactDocument("doc")
+	require.NoError(t, err)
+
+	log, err := node1.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	require.True(t, log[0].Pruned)
+	require.Equal(t, uint(1), log[0].Index)
+	require.Equal(t, full[1].Hash, log[0].Hash)
+	require.Empty(t, log[0].Operations)
+
+	// > the chain goes on from the pruned batch
+	_, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "Again"),
+	})
+	require.NoError(t, err)
+
+	log, err = node1.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.Equal(t, uint(2), log[1].Index)
+	require.Equal(t, full[1].Hash, log[1].PrevHash)
+	require.NoError(t, node2.VerifyAuditLog(log))
+
+	report, err := node1.ExportAuditReport("doc")
+	require.NoError(t, err)
+	require.Empty(t, report.Problems)
+	require.Equal(t, 3, report.Authors[0].Batches)
+
+	// > a pruned batch cannot stand in the middle of the chain
+	time.Sleep(time.Millisecond * 200)
+	log2, err := node2.GetAuditLog("doc")
+	require.NoError(t, err)
+	require.Len(t, log2, 3)
+	forged := copyAuditLog(log2)
+	forged[1].Pruned = true
+	forged[1].Operations = nil
+	require.Error(t, node2.VerifyAuditLog(forged))
+}
+
+// copyAuditLog returns a copy of an audit log whose operations can be altered.
+func copyAuditLog(log []types.AuditBatch) []types.AuditBatch {
+	copied := make([]types.AuditBatch, len(log))
+	for i, batch := range log {
+		copied[i] = batch
+		copied[i].Operations = make([]types.CRDTOperation, len(batch.Operations))
+		copy(copied[i].Operations, batch.Operations)
+	}
+	return copied
+}
@@ -0,0 +1,191 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A page mention and a transclusion of another document are listed as its
+// backlinks on every peer, and the transcluded block follows the edits of its
+// source.
+func Test_Backlinks_Mention_Transclusion(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	addr := node1.GetAddr()
+
+	// > docB has a paragraph 1@addr with "Hello"
+	err := node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 1,
+			DocumentID:  "docB",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 2,
+			DocumentID:  "docB",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{Text: "Hello"},
+		},
+	}})
+	require.NoError(t, err)
+
+	// > docA mentions docB in "See B" and transcludes its paragraph
+	err = node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 1,
+			DocumentID:  "docA",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 2,
+			DocumentID:  "docA",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{Text: "See B"},
+		},
+		{
+			Type:        types.CRDTAddMarkType,
+			OperationID: 7,
+			DocumentID:  "docA",
+			BlockID:     "1@temp",
+			Operation: types.CRDTAddMark{
+				Start:    types.MarkStart{OpID: "6@temp"},
+				End:      types.MarkEnd{OpID: "6@temp"},
+				MarkType: types.Mention,
+				Options:  types.MarkOptions{DocumentID: "docB"},
+			},
+		},
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 8,
+			DocumentID:  "docA",
+			BlockID:     "8@temp",
+			Operation: types.CRDTAddBlock{
+				AfterBlock: "1@temp",
+				BlockType:  types.TransclusionBlockType,
+				Props:      types.DefaultBlockProps{SourceDocument: "docB", SourceBlock: "1@" + addr},
+			},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	expected := []types.Backlink{
+		{SourceDocument: "docA", SourceBlock: "1@" + addr, Type: types.Mention},
+		{SourceDocument: "docA", SourceBlock: "8@" + addr, TargetBlock: "1@" + addr, Type: "transclusion"},
+	}
+
+	for _, node := range []z.TestNode{node1, node2} {
+		backlinks, err := node.GetBacklinks("docB")
+		require.NoError(t, err)
+		require.Equal(t, expected, backlinks)
+
+		doc, err := node.CompileDocument("docA")
+		require.NoError(t, err)
+		require.Contains(t, doc, `"type": "pageMention"`)
+		require.Contains(t, doc, `"documentId": "docB"`)
+		require.Contains(t, doc, `"text": "Hello"`)
+	}
+
+	// > the transcluded paragraph is edited on node2
+	err = node2.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTInsertCharType,
+			OperationID: 1,
+			DocumentID:  "docB",
+			BlockID:     "1@" + addr,
+			Operation:   types.CRDTInsertChar{AfterID: "6@" + addr, Character: "!"},
+		},
+	}})
+	require.NoError(t, err)
+
+	// > the mention is removed on node2
+	err = node2.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTRemoveMarkType,
+			OperationID: 1,
+			DocumentID:  "docA",
+			BlockID:     "1@" + addr,
+			Operation: types.CRDTRemoveMark{
+				Start:    types.MarkStart{OpID: "6@" + addr},
+				End:      types.MarkEnd{OpID: "6@" + addr},
+				MarkType: types.Mention,
+			},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		backlinks, err := node.GetBacklinks("docB")
+		require.NoError(t, err)
+		require.Equal(t, expected[1:], backlinks)
+
+		doc, err := node.CompileDocument("docA")
+		require.NoError(t, err)
+		require.NotContains(t, doc, "pageMention")
+		require.Contains(t, doc, `"text": "Hello!"`)
+	}
+}
+
+// Transclusions that include each other are compiled with an error instead of
+// looping forever.
+func Test_Backlinks_Transclusion_Cycle(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	addr := node1.GetAddr()
+
+	// > 1@addr of docA includes 1@addr of docB, which includes 1@addr of docA
+	for _, ids := range [][2]string{{"docA", "docB"}, {"docB", "docA"}} {
+		err := node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+			{
+				Type:        types.CRDTAddBlockType,
+				OperationID: 1,
+				DocumentID:  ids[0],
+				BlockID:     "1@temp",
+				Operation: types.CRDTAddBlock{
+					BlockType: types.TransclusionBlockType,
+					Props:     types.DefaultBlockProps{SourceDocument: ids[1], SourceBlock: "1@" + addr},
+				},
+			},
+		}})
+		require.NoError(t, err)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	doc, err := node1.CompileDocument("docA")
+	require.NoError(t, err)
+	require.Contains(t, doc, "transclusion cycle")
+	require.Equal(t, 2, strings.Count(doc, `"type": "transclusion"`))
+
+	backlinks, err := node1.GetBacklinks("docA")
+	require.NoError(t, err)
+	require.Len(t, backlinks, 1)
+	require.Equal(t, "docB", backlinks[0].SourceDocument)
+}
@@ -0,0 +1,163 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Without the version vector of every known peer, nothing is causally stable
+// and the compaction must leave the document untouched.
+func Test_Compaction_Unknown_Peer_Version(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node1.AddPeer("127.0.0.1:9999")
+
+	ops := []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 2,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{Text: "Hello"},
+		},
+	}
+	err := node1.SaveTransactions(types.CRDTOperationsMessage{Operations: ops})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	stats, err := node1.CompactDocument("doc1")
+	require.NoError(t, err)
+
+	require.Equal(t, 2, stats.OperationsBefore)
+	require.Equal(t, 2, stats.OperationsAfter)
+	require.Equal(t, 0, stats.TombstonesDropped)
+	require.Equal(t, stats, node1.GetCompactionStats("doc1"))
+}
+
+// Once both peers reported their version, the stable operations are folded
+// into snapshots, the tombstones and the removed blocks are dropped, and the
+// document still accepts concurrent edits.
+func Test_Compaction_Two_Peers(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*100))
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*100))
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	addr := node1.GetAddr()
+
+	// > block "Hello world" (IDs 1 to 12) and a second block (ID 13)
+	err := node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 2,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{Text: "Hello world"},
+		},
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 13,
+			DocumentID:  "doc1",
+			BlockID:     "13@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType, AfterBlock: "1@temp"},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > delete " world" and the second block
+	err = node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTDeleteRangeType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@" + addr,
+			Operation:   types.CRDTDeleteRange{StartID: "7@" + addr, Length: 6},
+		},
+		{
+			Type:        types.CRDTRemoveBlockType,
+			OperationID: 2,
+			DocumentID:  "doc1",
+			BlockID:     "13@" + addr,
+			Operation:   types.CRDTRemoveBlock{RemovedBlock: "13@" + addr},
+		},
+	}})
+	require.NoError(t, err)
+
+	// > wait for the operations and the version vectors to be exchanged
+	time.Sleep(time.Second)
+
+	require.Equal(t, types.VersionVector{addr: 15}, node1.GetVersionVector("doc1"))
+	require.Equal(t, types.VersionVector{addr: 15}, node2.GetVersionVector("doc1"))
+
+	before, err := node1.CompileDocument("doc1")
+	require.NoError(t, err)
+
+	stats, err := node1.CompactDocument("doc1")
+	require.NoError(t, err)
+
+	require.Equal(t, 5, stats.OperationsBefore)
+	require.Equal(t, 1, stats.OperationsAfter) // the first addBlock
+	require.Equal(t, 6, stats.TombstonesDropped)
+	require.Equal(t, 1, stats.BlocksDropped)
+	require.Less(t, stats.BytesAfter, stats.BytesBefore)
+
+	after, err := node1.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+	require.Contains(t, after, "Hello")
+	require.NotContains(t, after, "world")
+
+	// > node2 did not compact: it appends after the last character, node1 must
+	// still place it although the tombstones are gone
+	err = node2.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTInsertCharType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@" + addr,
+			Operation:   types.CRDTInsertChar{AfterID: "6@" + addr, Character: "!"},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	doc1, err := node1.CompileDocument("doc1")
+	require.NoError(t, err)
+	doc2, err := node2.CompileDocument("doc1")
+	require.NoError(t, err)
+
+	require.Contains(t, doc1, "Hello!")
+	require.Equal(t, doc2, doc1)
+}
@@ -0,0 +1,192 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A database edited by two peers has the same schema and rows on both, and
+// its rows can be filtered and sorted by their typed cells.
+func Test_Database_Schema_Rows_Query(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	dbID, err := node1.CreateDatabase("Tasks")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	status, err := node1.AddDatabaseProperty(dbID, types.DatabaseProperty{
+		Name:    "Status",
+		Type:    types.PropertySelect,
+		Options: []string{"todo", "doing", "done"},
+	})
+	require.NoError(t, err)
+	points, err := node1.AddDatabaseProperty(dbID, types.DatabaseProperty{Name: "Points", Type: types.PropertyNumber})
+	require.NoError(t, err)
+	due, err := node1.AddDatabaseProperty(dbID, types.DatabaseProperty{Name: "Due", Type: types.PropertyDate})
+	require.NoError(t, err)
+	blocked, err := node1.AddDatabaseProperty(dbID, types.DatabaseProperty{
+		Name:             "Blocked by",
+		Type:             types.PropertyRelation,
+		RelationDatabase: dbID,
+	})
+	require.NoError(t, err)
+
+	_, err = node1.AddDatabaseProperty(dbID, types.DatabaseProperty{Name: "Owner", Type: "person"})
+	require.Error(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	schema, err := node2.GetDatabaseSchema(dbID)
+	require.NoError(t, err)
+	require.Len(t, schema, 4)
+	require.Equal(t, []string{status, points, due, blocked},
+		[]string{schema[0].ID, schema[1].ID, schema[2].ID, schema[3].ID})
+
+	// > node2 adds the rows, node1 fills them
+	rowA, err := node2.AddDatabaseRow(dbID, "Write spec")
+	require.NoError(t, err)
+	rowB, err := node2.AddDatabaseRow(dbID, "Review spec")
+	require.NoError(t, err)
+	rowC, err := node2.AddDatabaseRow(dbID, "Ship")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	cells := []struct{ row, property, value string }{
+		{rowA, status, "done"}, {rowA, points, "3"}, {rowA, due, "2024-05-01"},
+		{rowB, status, "doing"}, {rowB, points, "5"}, {rowB, blocked, `["` + rowA + `"]`},
+		{rowC, status, "todo"}, {rowC, points, "1.5"}, {rowC, due, "2024-06-01"},
+	}
+	for _, cell := range cells {
+		require.NoError(t, node1.SetDatabaseCell(cell.row, cell.property, cell.value))
+	}
+
+	require.Error(t, node1.SetDatabaseCell(rowA, points, "three"))
+	require.Error(t, node1.SetDatabaseCell(rowA, status, "blocked"))
+	require.Error(t, node1.SetDatabaseCell(rowA, due, "tomorrow"))
+	require.Error(t, node1.SetDatabaseCell(rowA, blocked, `["unknown"]`))
+	require.Error(t, node1.SetDatabaseCell(dbID, points, "1"))
+
+	time.Sleep(time.Millisecond * 300)
+
+	rowIDs := func(rows []types.DatabaseRow) []string {
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row.DocumentID
+		}
+		return ids
+	}
+
+	for _, node := range []z.TestNode{node1, node2} {
+		rows, err := node.QueryDatabase(dbID, types.DatabaseQuery{
+			Sorts: []types.DatabaseSort{{PropertyID: points, Descending: true}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{rowB, rowA, rowC}, rowIDs(rows))
+		require.Equal(t, "Review spec", rows[0].Title)
+		require.Equal(t, "doing", rows[0].Cells[status])
+
+		rows, err = node.QueryDatabase(dbID, types.DatabaseQuery{
+			Filters: []types.DatabaseFilter{
+				{PropertyID: points, Operator: types.FilterGreaterThan, Value: "2"},
+				{PropertyID: types.TitleProperty, Operator: types.FilterContains, Value: "SPEC"},
+			},
+			Sorts: []types.DatabaseSort{{PropertyID: status}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{rowB, rowA}, rowIDs(rows))
+
+		rows, err = node.QueryDatabase(dbID, types.DatabaseQuery{
+			Filters: []types.DatabaseFilter{{PropertyID: due, Operator: types.FilterIsNotEmpty}},
+			Sorts:   []types.DatabaseSort{{PropertyID: due, Descending: true}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{rowC, rowA}, rowIDs(rows))
+
+		rows, err = node.QueryDatabase(dbID, types.DatabaseQuery{
+			Filters: []types.DatabaseFilter{{PropertyID: blocked, Operator: types.FilterContains, Value: rowA}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{rowB}, rowIDs(rows))
+
+		// > the rows are listed by the database, not in the page tree
+		docList, err := node.GetDocumentList()
+		require.NoError(t, err)
+		require.Equal(t, []string{dbID}, docList)
+	}
+
+	_, err = node1.QueryDatabase(dbID, types.DatabaseQuery{
+		Filters: []types.DatabaseFilter{{PropertyID: points, Operator: types.FilterLessThan, Value: "many"}},
+	})
+	require.Error(t, err)
+
+	// > a removed property is no longer part of the rows
+	err = node2.RemoveDatabaseProperty(dbID, points)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	schema, err = node1.GetDatabaseSchema(dbID)
+	require.NoError(t, err)
+	require.Len(t, schema, 3)
+
+	rows, err := node1.QueryDatabase(dbID, types.DatabaseQuery{})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	for _, row := range rows {
+		require.NotContains(t, row.Cells, points)
+	}
+}
+
+// Concurrent writes to the same cell converge to the same value.
+func Test_Database_Concurrent_Cell(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	dbID, err := node1.CreateDatabase("Decisions")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	done, err := node1.AddDatabaseProperty(dbID, types.DatabaseProperty{Name: "Done", Type: types.PropertyCheckbox})
+	require.NoError(t, err)
+	row, err := node1.AddDatabaseRow(dbID, "Use CRDTs")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.NoError(t, node1.SetDatabaseCell(row, done, "true"))
+	require.NoError(t, node2.SetDatabaseCell(row, done, "false"))
+
+	time.Sleep(time.Millisecond * 300)
+
+	rows1, err := node1.QueryDatabase(dbID, types.DatabaseQuery{})
+	require.NoError(t, err)
+	rows2, err := node2.QueryDatabase(dbID, types.DatabaseQuery{})
+	require.NoError(t, err)
+	require.Equal(t, rows1, rows2)
+	require.Len(t, rows1, 1)
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// An archived document is hidden from the document summaries of every peer
+// until it is restored.
+func Test_DocumentStatus_Archive_Restore(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	docID := "1@" + node1.GetAddr()
+
+	err := node1.SetDocumentMetadata(docID, types.MetadataTitle, "Notes")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	summaries, err := node2.GetDocumentSummaries()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+
+	// > restoring an active document fails
+	require.Error(t, node2.RestoreDocument(docID))
+
+	err = node2.ArchiveDocument(docID)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		summaries, err = node.GetDocumentSummaries()
+		require.NoError(t, err)
+		require.Len(t, summaries, 0)
+
+		archived, err := node.GetDocumentSummariesByStatus(types.DocumentArchived)
+		require.NoError(t, err)
+		require.Len(t, archived, 1)
+		require.Equal(t, docID, archived[0].DocumentID)
+		require.Equal(t, "Notes", archived[0].Title)
+	}
+
+	err = node1.RestoreDocument(docID)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		summaries, err = node.GetDocumentSummaries()
+		require.NoError(t, err)
+		require.Len(t, summaries, 1)
+		require.Equal(t, docID, summaries[0].DocumentID)
+		require.Equal(t, types.DocumentActive, node.GetDocumentMetadata(docID).Status)
+	}
+}
+
+// A deleted document can be restored during the retention period, after
+// which it is purged on every peer and cannot come back.
+func Test_DocumentStatus_Delete_Purge(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDocumentRetention(time.Second*2))
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDocumentRetention(time.Second*2))
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	docID := "1@" + node1.GetAddr()
+
+	err := node1.SetDocumentMetadata(docID, types.MetadataTitle, "Draft")
+	require.NoError(t, err)
+	err = node1.DeleteDocument(docID)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	deleted, err := node2.GetDocumentSummariesByStatus(types.DocumentDeleted)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+
+	// > the retention is not over yet
+	err = node2.RestoreDocument(docID)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Equal(t, types.DocumentActive, node1.GetDocumentMetadata(docID).Status)
+
+	err = node1.DeleteDocument(docID)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 4500)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		require.Len(t, node.GetEditor(), 0)
+
+		deleted, err = node.GetDocumentSummariesByStatus(types.DocumentDeleted)
+		require.NoError(t, err)
+		require.Len(t, deleted, 0)
+
+		require.Error(t, node.RestoreDocument(docID))
+	}
+
+	// > late operations of the purged document are ignored
+	err = node2.SetDocumentMetadata(docID, types.MetadataTitle, "Back")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, node1.GetEditor(), 0)
+	require.Len(t, node2.GetEditor(), 0)
+}
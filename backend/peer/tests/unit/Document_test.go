@@ -732,6 +732,106 @@ func Test_Document_Compilation_2Peers_SameBlock(t *testing.T) {
 
 }
 
+// Check that an insertText run compiles like the equivalent single character
+// inserts, and that edits landing inside the run split it.
+func Test_Document_Compilation_1Peer_InsertTextRun(t *testing.T) {
+	transp := channel.NewTransport()
+	peerRun := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(1))
+	defer peerRun.Stop()
+	peerChars := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(1))
+	defer peerChars.Stop()
+
+	docID := "doc1"
+	block1ID := "1" + "@temp"
+	addBlock := tests.CreateNewBlockOp("temp", docID, block1ID)
+
+	// > "Hello" as one run (IDs 2..6) and as 5 single character inserts
+	err := peerRun.UpdateEditor(addBlock)
+	require.NoError(t, err)
+	err = peerRun.UpdateEditor([]types.CRDTOperation{
+		tests.CreateInsertTextOp("temp", docID, block1ID, "", "Hello", 2),
+	})
+	require.NoError(t, err)
+
+	err = peerChars.UpdateEditor(addBlock)
+	require.NoError(t, err)
+	err = peerChars.UpdateEditor(tests.CreateInsertsFromString("Hello", "temp", docID, block1ID, 2))
+	require.NoError(t, err)
+
+	docRun, err := peerRun.CompileDocument(docID)
+	require.NoError(t, err)
+	docChars, err := peerChars.CompileDocument(docID)
+	require.NoError(t, err)
+	require.JSONEq(t, docChars, docRun)
+
+	// > insert "X" after the first "l" (4@temp) and delete "lo" (5@temp..6@temp)
+	edits := []types.CRDTOperation{
+		{
+			Type:        types.CRDTInsertCharType,
+			Origin:      "temp",
+			OperationID: 7,
+			DocumentID:  docID,
+			BlockID:     block1ID,
+			Operation:   tests.CreateInsertOp("4@temp", "X"),
+		},
+		{
+			Type:        types.CRDTDeleteRangeType,
+			Origin:      "temp",
+			OperationID: 8,
+			DocumentID:  docID,
+			BlockID:     block1ID,
+			Operation: types.CRDTDeleteRange{
+				StartID: "5@temp",
+				Length:  2,
+			},
+		},
+	}
+	err = peerRun.UpdateEditor(edits)
+	require.NoError(t, err)
+
+	doc, err := peerRun.CompileDocument(docID)
+	require.NoError(t, err)
+
+	expected := "[{\"id\":\"1@temp\",\"type\":\"paragraph\",\"props\":{\"textColor\":\"\",\"backgroundColor\":\"\",\"textAlignment\":\"\"},\"content\":[{\"type\":\"text\",\"charIds\":[\"2@temp\",\"3@temp\",\"4@temp\",\"7@temp\"],\"text\":\"HelX\",\"styles\":{}}],\"children\":[]}]"
+	require.JSONEq(t, expected, doc)
+
+	// > the run is still stored as a single operation
+	require.Len(t, peerRun.GetBlockOps(docID, block1ID), 3)
+}
+
+// Check that a deleteRange longer than the run limit is skipped when compiling
+// instead of being expanded.
+func Test_Document_Compilation_1Peer_OversizedDeleteRange(t *testing.T) {
+	transp := channel.NewTransport()
+	node := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(1))
+	defer node.Stop()
+
+	docID := "doc1"
+	block1ID := "1" + "@temp"
+
+	err := node.UpdateEditor(tests.CreateNewBlockOp("temp", docID, block1ID))
+	require.NoError(t, err)
+	err = node.UpdateEditor([]types.CRDTOperation{
+		tests.CreateInsertTextOp("temp", docID, block1ID, "", "Hello", 2),
+		{
+			Type:        types.CRDTDeleteRangeType,
+			Origin:      "temp",
+			OperationID: 7,
+			DocumentID:  docID,
+			BlockID:     block1ID,
+			Operation: types.CRDTDeleteRange{
+				StartID: "2@temp",
+				Length:  1 << 62,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, err := node.CompileDocument(docID)
+	require.NoError(t, err)
+	require.Contains(t, doc, "Hello")
+}
+
 // Check that a document is stored in the correct directory.
 func Test_Document_Directory_Store(t *testing.T) {
 	transp := channel.NewTransport()
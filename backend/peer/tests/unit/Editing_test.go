@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"Node-tion/backend/editing"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Edits made by position reach the other peers as CRDT operations.
+func Test_Editing_By_Position(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	client := editing.NewClient(node1)
+
+	para, err := client.AddBlock("doc", types.ParagraphBlockType, "", 0)
+	require.NoError(t, err)
+	blocks, err := node1.GetDocumentBlocks("doc")
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	require.Equal(t, para, blocks[0].ID)
+
+	require.NoError(t, client.InsertText("doc", para, 0, "world"))
+	require.NoError(t, client.InsertText("doc", para, 0, "Hello "))
+	require.NoError(t, client.InsertText("doc", para, 11, "!"))
+	require.Error(t, client.InsertText("doc", para, 13, "?"))
+	require.Error(t, client.InsertText("doc", "1@unknown", 0, "?"))
+
+	require.NoError(t, client.SetStyle("doc", para, 0, 5, types.TextStyle{Bold: true, TextColor: "red"}))
+	require.NoError(t, client.Delete("doc", para, 5, 6))
+	require.Error(t, client.Delete("doc", para, 5, 2))
+	require.NoError(t, client.SetStyle("doc", para, 0, 2, types.TextStyle{TextColor: "red"}))
+
+	// > a heading at the root gets the paragraph and a new list item as
+	// children
+	heading, err := client.AddBlock("doc", types.HeadingBlockType, "", 1)
+	require.NoError(t, err)
+	blocks, err = node1.GetDocumentBlocks("doc")
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	require.Equal(t, heading, blocks[1].ID)
+
+	_, err = client.AddBlock("doc", types.BulletedListBlockType, heading, 0)
+	require.NoError(t, err)
+	require.NoError(t, client.MoveBlock("doc", para, heading, 1))
+	require.Error(t, client.MoveBlock("doc", heading, para, 0))
+	_, err = client.AddBlock("doc", types.ParagraphBlockType, heading, 3)
+	require.Error(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		blocks, err := node.GetDocumentBlocks("doc")
+		require.NoError(t, err)
+		require.Len(t, blocks, 1)
+		require.Equal(t, heading, blocks[0].ID)
+		require.Equal(t, types.HeadingBlockType, blocks[0].BlockType)
+
+		children := blocks[0].Children
+		require.Len(t, children, 2)
+		require.Equal(t, types.BulletedListBlockType, children[0].BlockType)
+		require.Equal(t, para, children[1].ID)
+		require.Equal(t, "Hello!", children[1].Text)
+
+		styles := children[1].Styles
+		require.Equal(t, types.TextStyle{TextColor: "red"}, styles[0])
+		require.Equal(t, types.TextStyle{TextColor: "red"}, styles[1])
+		require.Equal(t, types.TextStyle{Bold: true, TextColor: "red"}, styles[2])
+		require.Equal(t, types.TextStyle{Bold: true, TextColor: "red"}, styles[4])
+		require.Equal(t, types.TextStyle{}, styles[5])
+	}
+}
@@ -0,0 +1,404 @@
+package unit
+
+import (
+	"Node-tion/backend/identity"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/peer"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Only the identity a key is sealed for can open it.
+func Test_Encryption_Seal(t *testing.T) {
+	id, err := identity.Generate()
+	require.NoError(t, err)
+	other, err := identity.Generate()
+	require.NoError(t, err)
+
+	sealed, err := identity.Seal(id.ID, []byte("secret"))
+	require.NoError(t, err)
+
+	data, err := id.Open(sealed)
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(data))
+
+	_, err = other.Open(sealed)
+	require.Error(t, err)
+
+	_, err = identity.Seal("127.0.0.1:1", []byte("secret"))
+	require.Error(t, err)
+}
+
+// The operations of an encrypted document are relayed as ciphertext, the
+// collaborator gets the key and then the operations it missed.
+func Test_Encryption_Document(t *testing.T) {
+	transp := channel.NewTransport()
+
+	id1, err := identity.Generate()
+	require.NoError(t, err)
+	id2, err := identity.Generate()
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id1))
+	defer node1.Stop()
+
+	relay := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer relay.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id2))
+	defer node2.Stop()
+
+	// node1 <-> relay <-> node2
+	node1.AddPeer(relay.GetAddr())
+	relay.AddPeer(node1.GetAddr(), node2.GetAddr())
+	node2.AddPeer(relay.GetAddr())
+
+	empty, err := node2.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+	require.NoError(t, err)
+	require.NoError(t, node2.Broadcast(empty))
+
+	require.NoError(t, node1.EncryptDocument("doc"))
+	require.Error(t, node1.EncryptDocument("doc"))
+	require.True(t, node1.IsDocumentEncrypted("doc"))
+
+	result, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "secret"),
+	})
+	require.NoError(t, err)
+	block := result.IDs["1@temp"]
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the relay and node2 cannot read the operations
+	require.Empty(t, relay.GetDocumentOps("doc"))
+	require.Empty(t, node2.GetDocumentOps("doc"))
+	require.Len(t, node1.GetBlockOps("doc", block), 1)
+
+	require.Error(t, node1.ShareDocumentKey("doc", "unknown"))
+	require.NoError(t, node1.ShareDocumentKey("doc", id2.ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > node2 opened the key and requested the operations it missed
+	require.True(t, node2.IsDocumentEncrypted("doc"))
+	require.False(t, relay.IsDocumentEncrypted("doc"))
+	require.Len(t, node2.GetBlockOps("doc", block), 1)
+
+	_, err = node2.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "reply"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, node1.GetDocumentOps("doc")["doc"], 2)
+	require.Empty(t, relay.GetDocumentOps("doc"))
+
+	// > node1 only finds the encrypted document locally, it does not return
+	// it to the searches of others
+	results, err := node1.SearchNetwork("secret", peer.ExpandingRing{Initial: 2, Factor: 2, Retry: 1,
+		Timeout: time.Millisecond * 200})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	results, err = relay.SearchNetwork("secret", peer.ExpandingRing{Initial: 2, Factor: 2, Retry: 1,
+		Timeout: time.Millisecond * 200})
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+// Removing a member rotates the key: the remaining members get the new key
+// and keep reading the document, the removed one cannot read the operations
+// sent after. The changes of members are kept in a verifiable log.
+func Test_Encryption_Key_Rotation(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 3)
+	nodes := make([]z.TestNode, 3)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id),
+			z.WithAntiEntropy(time.Millisecond*50))
+		defer nodes[i].Stop()
+	}
+	owner, member, removed := nodes[0], nodes[1], nodes[2]
+
+	announceIdentities(t, nodes)
+
+	docID := "100@" + ids[0].ID
+	commit := func(node z.TestNode, text string) {
+		_, err := node.CommitTransaction(types.CRDTOperationsMessage{
+			Operations: paragraphOps(docID, "1@temp", text),
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, owner.EncryptDocument(docID))
+	require.NoError(t, owner.ShareDocument(docID, ids[1].ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocument(docID, ids[2].ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocumentKey(docID, ids[1].ID, ids[2].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	commit(owner, "before")
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, member.GetDocumentOps(docID)[docID], 1)
+	require.Len(t, removed.GetDocumentOps(docID)[docID], 1)
+
+	require.Error(t, member.RemoveDocumentMember(docID, ids[2].ID))
+	require.Error(t, owner.RemoveDocumentMember(docID, "unknown"))
+	require.NoError(t, owner.RemoveDocumentMember(docID, ids[2].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	commit(owner, "after")
+	commit(member, "reply")
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the remaining members read the operations sent after the rotation
+	require.Len(t, owner.GetDocumentOps(docID)[docID], 3)
+	require.Len(t, member.GetDocumentOps(docID)[docID], 3)
+	require.NotContains(t, member.GetDocumentACL(docID).Members, ids[2].ID)
+
+	// > the removed member holds the previous key only
+	require.True(t, removed.IsDocumentEncrypted(docID))
+	require.Len(t, removed.GetDocumentOps(docID)[docID], 1)
+	require.Contains(t, removed.GetDocumentACL(docID).Members, ids[2].ID)
+
+	// > the log records the changes and the key epoch of the removal
+	entries := owner.GetMembershipLog(docID)
+	require.Len(t, entries, 3)
+	require.Equal(t, types.CRDTSetAccess{Member: ids[2].ID}, entries[2].Operation.Operation)
+	require.Equal(t, uint(0), entries[1].KeyEpoch)
+	require.Equal(t, uint(1), entries[2].KeyEpoch)
+	require.NoError(t, owner.VerifyMembershipLog(entries))
+	require.NoError(t, owner.VerifyMembershipLog(member.GetMembershipLog(docID)))
+
+	// > altering, dropping or forging an entry is detected
+	altered := owner.GetMembershipLog(docID)
+	altered[1].Operation.Operation = types.CRDTSetAccess{Member: ids[2].ID, Role: types.RoleOwner}
+	require.Error(t, owner.VerifyMembershipLog(altered))
+
+	require.Error(t, owner.VerifyMembershipLog(entries[1:]))
+
+	forged := owner.GetMembershipLog(docID)
+	forged[2].KeyEpoch = 0
+	require.Error(t, owner.VerifyMembershipLog(forged))
+}
+
+// Only the keys shared by an owner of the document are accepted, with an
+// epoch after the one of the current key.
+func Test_Encryption_Key_Owner(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 3)
+	nodes := make([]z.TestNode, 3)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+		defer nodes[i].Stop()
+	}
+	owner, editor, other := nodes[0], nodes[1], nodes[2]
+
+	announceIdentities(t, nodes)
+
+	docID := "100@" + ids[0].ID
+	require.NoError(t, owner.EncryptDocument(docID))
+	require.NoError(t, owner.ShareDocument(docID, ids[1].ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocument(docID, ids[2].ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocumentKey(docID, ids[1].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.True(t, editor.IsDocumentEncrypted(docID))
+
+	// > the key shared by an editor is rejected
+	require.NoError(t, editor.ShareDocumentKey(docID, ids[2].ID))
+
+	// > so is a key without signature, even claiming to be of the owner
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+	sealed, err := identity.Seal(ids[2].ID, make([]byte, 32))
+	require.NoError(t, err)
+	sendMessage(t, sender, other, &types.DocumentKeyMessage{
+		DocumentID: docID,
+		Recipient:  ids[2].ID,
+		Epoch:      5,
+		SealedKey:  sealed,
+		Sender:     ids[0].ID,
+	})
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.False(t, other.IsDocumentEncrypted(docID))
+
+	// > the key of the owner is accepted
+	require.NoError(t, owner.ShareDocumentKey(docID, ids[2].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.True(t, other.IsDocumentEncrypted(docID))
+}
+
+// A key whose sender is not known as an owner yet is kept, and accepted once
+// the access giving it the owner role arrives.
+func Test_Encryption_Key_Pending(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 3)
+	nodes := make([]z.TestNode, 3)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id),
+			z.WithAntiEntropy(time.Millisecond*50))
+		defer nodes[i].Stop()
+	}
+	creator, owner, member := nodes[0], nodes[1], nodes[2]
+
+	announceIdentities(t, nodes)
+
+	docID := "100@" + ids[0].ID
+	require.NoError(t, creator.ShareDocument(docID, ids[2].ID, types.RoleEditor))
+	require.NoError(t, owner.EncryptDocument(docID))
+	require.NoError(t, owner.ShareDocumentKey(docID, ids[2].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.False(t, member.IsDocumentEncrypted(docID))
+
+	// > the creator makes the sender an owner
+	require.NoError(t, creator.ShareDocument(docID, ids[1].ID, types.RoleOwner))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.True(t, member.IsDocumentEncrypted(docID))
+}
+
+// The operations received without their key are dropped with the ones after
+// them, and requested again once the key arrives.
+func Test_Encryption_Key_Awaited(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 2)
+	nodes := make([]z.TestNode, 2)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+		defer nodes[i].Stop()
+	}
+	owner, member := nodes[0], nodes[1]
+
+	announceIdentities(t, nodes)
+
+	removed, err := identity.Generate()
+	require.NoError(t, err)
+
+	docID := "100@" + ids[0].ID
+	require.NoError(t, owner.EncryptDocument(docID))
+	require.NoError(t, owner.ShareDocument(docID, ids[1].ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocument(docID, removed.ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocumentKey(docID, ids[1].ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the member misses the key of the next epoch
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+	sendMessage(t, sender, member, &types.EncryptedCRDTOperationsMessage{
+		DocumentID: docID,
+		Epoch:      1,
+		Nonce:      make([]byte, 12),
+		Ciphertext: []byte("unreadable"),
+	})
+
+	time.Sleep(time.Millisecond * 100)
+
+	vv := member.GetVersionVector(docID)
+
+	_, err = owner.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps(docID, "1@temp", "held"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the operations after are dropped too, the version vector stays
+	require.Empty(t, member.GetDocumentOps(docID)[docID])
+	require.Equal(t, vv, member.GetVersionVector(docID))
+
+	// > the key of the next epoch brings them
+	require.NoError(t, owner.RemoveDocumentMember(docID, removed.ID))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, member.GetDocumentOps(docID)[docID], 1)
+	require.NotContains(t, member.GetDocumentACL(docID).Members, removed.ID)
+}
+
+// The key is not rotated when a remaining member cannot be sent the new key,
+// the member is not removed.
+func Test_Encryption_Key_Rotation_Unknown_Member(t *testing.T) {
+	transp := channel.NewTransport()
+
+	id, err := identity.Generate()
+	require.NoError(t, err)
+	unknown, err := identity.Generate()
+	require.NoError(t, err)
+	removed, err := identity.Generate()
+	require.NoError(t, err)
+
+	owner := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+	defer owner.Stop()
+
+	docID := "100@" + id.ID
+	require.NoError(t, owner.EncryptDocument(docID))
+	require.NoError(t, owner.ShareDocument(docID, unknown.ID, types.RoleEditor))
+	require.NoError(t, owner.ShareDocument(docID, removed.ID, types.RoleEditor))
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Error(t, owner.RemoveDocumentMember(docID, removed.ID))
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Contains(t, owner.GetDocumentACL(docID).Members, removed.ID)
+	require.Len(t, owner.GetMembershipLog(docID), 2)
+}
+
+// announceIdentities connects the nodes with each other and waits until they
+// know the addresses of the identities. A rumor relayed by a peer routes its
+// origin through it, the nodes are made neighbors again afterwards.
+func announceIdentities(t *testing.T, nodes []z.TestNode) {
+	for _, node := range nodes {
+		for _, peer := range nodes {
+			node.AddPeer(peer.GetAddr())
+		}
+	}
+	for _, node := range nodes {
+		empty, err := node.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+		require.NoError(t, err)
+		require.NoError(t, node.Broadcast(empty))
+	}
+	time.Sleep(time.Millisecond * 300)
+
+	for _, node := range nodes {
+		for _, peer := range nodes {
+			node.AddPeer(peer.GetAddr())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A replacement is found in the compiled text, keeps the marks of the replaced
+// text and reaches the other peers.
+func Test_FindReplace_Marks(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	// > "gossip" (10@temp to 15@temp) is bold
+	ops := paragraphOps("doc", "1@temp", "Use the gossip layer. Gossip is fast.")
+	ops = append(ops, types.CRDTOperation{
+		Type:        types.CRDTAddMarkType,
+		OperationID: 40,
+		DocumentID:  "doc",
+		BlockID:     "1@temp",
+		Operation: types.CRDTAddMark{
+			Start:    types.MarkStart{Type: "before", OpID: "10@temp"},
+			End:      types.MarkEnd{Type: "after", OpID: "15@temp"},
+			MarkType: types.Bold,
+		},
+	})
+	require.NoError(t, node1.SaveTransactions(types.CRDTOperationsMessage{Operations: ops}))
+	require.NoError(t, node1.SaveTransactions(types.CRDTOperationsMessage{
+		Operations: paragraphOps("other", "1@temp", "More gossip"),
+	}))
+
+	time.Sleep(time.Millisecond * 300)
+
+	matches, err := node2.FindInDocuments(types.FindOptions{Query: "gossip", IgnoreCase: true, DocumentID: "doc"})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	require.Equal(t, 8, matches[0].Start)
+	require.Equal(t, 14, matches[0].End)
+	require.Equal(t, "Gossip", matches[1].Text)
+	require.Len(t, matches[1].CharIDs, 6)
+
+	matches, err = node2.FindInDocuments(types.FindOptions{Query: "gossip"})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	require.ElementsMatch(t, []string{"doc", "other"}, []string{matches[0].DocumentID, matches[1].DocumentID})
+
+	_, err = node2.FindInDocuments(types.FindOptions{Query: "(", Regex: true})
+	require.Error(t, err)
+	_, err = node2.FindInDocuments(types.FindOptions{Query: "gossip", DocumentID: "unknown"})
+	require.Error(t, err)
+
+	// > node2 replaces both occurrences in doc
+	count, err := node2.ReplaceInDocuments(types.FindOptions{
+		Query:      "gossip",
+		IgnoreCase: true,
+		DocumentID: "doc",
+	}, "rumor")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	time.Sleep(time.Millisecond * 300)
+
+	boldRumor := regexp.MustCompile(`"text": "rumor",\s*"styles": \{\s*"bold": true`)
+	for _, node := range []z.TestNode{node1, node2} {
+		doc, err := node.CompileDocument("doc")
+		require.NoError(t, err)
+		require.True(t, boldRumor.MatchString(doc), doc)
+		require.Contains(t, doc, `"text": " layer. rumor is fast."`)
+		require.NotContains(t, doc, "ossip")
+
+		doc, err = node.CompileDocument("other")
+		require.NoError(t, err)
+		require.Contains(t, doc, `"text": "More gossip"`)
+	}
+
+	// > regular expression groups are expanded
+	count, err = node1.ReplaceInDocuments(types.FindOptions{Query: `(\w+) is fast`, Regex: true}, "$1 was fast")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	time.Sleep(time.Millisecond * 300)
+
+	matches, err = node2.FindInDocuments(types.FindOptions{Query: "rumor was fast", DocumentID: "doc"})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+}
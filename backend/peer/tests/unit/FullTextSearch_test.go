@@ -0,0 +1,139 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// paragraphOps returns the operations adding a paragraph with a text at the
+// end of a document.
+func paragraphOps(docID, tempID, text string) []types.CRDTOperation {
+	return []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 1,
+			DocumentID:  docID,
+			BlockID:     tempID,
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 2,
+			DocumentID:  docID,
+			BlockID:     tempID,
+			Operation:   types.CRDTInsertText{Text: text},
+		},
+	}
+}
+
+// Words, phrases and prefixes find the matching blocks of every peer, ranked
+// by relevance, and the results follow the edits of the documents.
+func Test_FullTextSearch_Queries(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	addr := node1.GetAddr()
+
+	docA, err := node1.CreateSubpage("", "Gossip notes")
+	require.NoError(t, err)
+	docB, err := node1.CreateSubpage("", "Recipes")
+	require.NoError(t, err)
+
+	for _, op := range [][]types.CRDTOperation{
+		paragraphOps(docA, "1@temp", "Rumor mongering spreads a rumor to a random peer."),
+		paragraphOps(docA, "1@temp", "Anti-entropy repairs missed rumors."),
+		paragraphOps(docB, "1@temp", "Bake the bread with a random amount of salt."),
+	} {
+		err = node1.SaveTransactions(types.CRDTOperationsMessage{Operations: op})
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	time.Sleep(time.Millisecond * 300)
+
+	blocks := func(hits []types.SearchHit) [][2]string {
+		ids := make([][2]string, len(hits))
+		for i, hit := range hits {
+			ids[i] = [2]string{hit.DocumentID, hit.BlockID}
+		}
+		return ids
+	}
+
+	for _, node := range []z.TestNode{node1, node2} {
+		// > "rumor" occurs twice in the first paragraph
+		hits := mustSearch(t, node, "rumor", 0)
+		require.Len(t, hits, 1)
+		require.Equal(t, docA, hits[0].DocumentID)
+		require.Contains(t, hits[0].Snippet, "Rumor mongering")
+		first := hits[0].BlockID
+
+		hits = mustSearch(t, node, "RUMOR*", 0)
+		require.Len(t, hits, 2)
+		require.Equal(t, first, hits[0].BlockID)
+		require.Greater(t, hits[0].Score, hits[1].Score)
+
+		hits = mustSearch(t, node, "random", 0)
+		require.Len(t, hits, 2)
+		require.ElementsMatch(t, []string{docA, docB}, []string{hits[0].DocumentID, hits[1].DocumentID})
+
+		hits = mustSearch(t, node, `"random peer"`, 0)
+		require.Equal(t, [][2]string{{docA, first}}, blocks(hits))
+		require.Empty(t, mustSearch(t, node, `"peer random"`, 0))
+
+		hits = mustSearch(t, node, `random sal*`, 0)
+		require.Len(t, hits, 1)
+		require.Equal(t, docB, hits[0].DocumentID)
+
+		// > titles match with an empty block
+		hits = mustSearch(t, node, "gossip", 0)
+		require.Equal(t, [][2]string{{docA, ""}}, blocks(hits))
+
+		require.Len(t, mustSearch(t, node, "rumor*", 1), 1)
+	}
+
+	_, err = node1.SearchDocuments(` " * `, 0)
+	require.Error(t, err)
+
+	// > node2 deletes "peer." (49@addr to 53@addr) from the first paragraph
+	// and archives docB
+	hits := mustSearch(t, node2, `"random peer"`, 0)
+	require.Len(t, hits, 1)
+	err = node2.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTDeleteRangeType,
+			OperationID: 1,
+			DocumentID:  docA,
+			BlockID:     hits[0].BlockID,
+			Operation:   types.CRDTDeleteRange{StartID: "49@" + addr, Length: 5},
+		},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, node2.ArchiveDocument(docB))
+
+	time.Sleep(time.Millisecond * 300)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		require.Empty(t, mustSearch(t, node, `"random peer"`, 0))
+		require.Len(t, mustSearch(t, node, `"random"`, 0), 1)
+		require.Empty(t, mustSearch(t, node, "salt", 0))
+	}
+}
+
+func mustSearch(t *testing.T, node z.TestNode, query string, limit int) []types.SearchHit {
+	hits, err := node.SearchDocuments(query, limit)
+	require.NoError(t, err)
+	return hits
+}
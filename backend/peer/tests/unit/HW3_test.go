@@ -708,6 +708,79 @@ func Test_HW3_TLC_Move_Step_Not_Enough(t *testing.T) {
 	require.Equal(t, 0, store.Len())
 }
 
+// A TLC message that moves the peer to a step it has no message for yet is
+// processed without error, the rumors after it in the same message are
+// processed too.
+func Test_HW3_TLC_Move_Step_Empty_Next(t *testing.T) {
+	transp := channel.NewTransport()
+
+	// Threshold = 1
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAckTimeout(0), z.WithTotalPeers(1))
+	defer node1.Stop()
+
+	socketX, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	node1.AddPeer(socketX.GetAddress())
+
+	// computed by hand
+	blockHash := "14eeef32b9ede3fe4effd3b0f54c0010dcac56d0b1321ee314a8ae6043e65a18"
+	previousHash := [32]byte{}
+
+	tlc := types.TLCMessage{
+		Step: 0,
+		Block: types.BlockchainBlock{
+			Index: 0,
+			Hash:  z.MustDecode(blockHash),
+			Value: types.PaxosValue{
+				Filename: "a",
+				Metahash: "b",
+			},
+			PrevHash: previousHash[:],
+		},
+	}
+	tlcMsg, err := node1.GetRegistry().MarshalMessage(&tlc)
+	require.NoError(t, err)
+
+	chat := types.ChatMessage{Message: "after"}
+	chatMsg, err := node1.GetRegistry().MarshalMessage(&chat)
+	require.NoError(t, err)
+
+	rumors := types.RumorsMessage{Rumors: []types.Rumor{
+		{Origin: socketX.GetAddress(), Sequence: 1, Msg: &tlcMsg},
+		{Origin: socketX.GetAddress(), Sequence: 2, Msg: &chatMsg},
+	}}
+	transpMsg, err := node1.GetRegistry().MarshalMessage(&rumors)
+	require.NoError(t, err)
+
+	header := transport.NewHeader(socketX.GetAddress(), socketX.GetAddress(), node1.GetAddr())
+
+	packet := transport.Packet{
+		Header: &header,
+		Msg:    &transpMsg,
+	}
+
+	err = socketX.Send(node1.GetAddr(), packet, 0)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second * 1)
+
+	// > node1 added the block and processed the chat message after it
+
+	store := node1.GetStorage().GetBlockchainStore()
+	require.Equal(t, 2, store.Len())
+
+	chats := node1.GetRegistry().GetMessages()
+	found := false
+	for _, msg := range chats {
+		chatMsg, ok := msg.(*types.ChatMessage)
+		if ok && chatMsg.Message == "after" {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
 // 3-12
 //
 // If a peer receives enough TLC message it must then add a new block, and
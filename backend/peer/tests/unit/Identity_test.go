@@ -0,0 +1,161 @@
+package unit
+
+import (
+	"Node-tion/backend/identity"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// An identity is generated on the first run and loaded on the next ones.
+func Test_Identity_LoadOrCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node", "identity.key")
+
+	id, err := identity.LoadOrCreate(path)
+	require.NoError(t, err)
+	require.NotContains(t, id.ID, "@")
+	require.True(t, identity.IsIdentity(id.ID))
+	require.False(t, identity.IsIdentity("127.0.0.1:1"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := identity.LoadOrCreate(path)
+	require.NoError(t, err)
+	require.Equal(t, id.ID, loaded.ID)
+
+	key, err := identity.PublicKeyOf(id.ID)
+	require.NoError(t, err)
+	require.Equal(t, id.PublicKey(), key)
+
+	err = os.WriteFile(path, []byte("garbage"), 0o600)
+	require.NoError(t, err)
+	_, err = identity.LoadOrCreate(path)
+	require.Error(t, err)
+}
+
+// The identity is the origin of the operations and of the rumors, and the
+// peers map it to the current address of the node, also after it restarted
+// at another address.
+func Test_Identity_Origin(t *testing.T) {
+	transp := channel.NewTransport()
+
+	id, err := identity.Generate()
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	require.Equal(t, id.ID, node1.GetIdentity())
+	require.Equal(t, node2.GetAddr(), node2.GetIdentity())
+
+	result, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "abc"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "1@"+id.ID, result.IDs["1@temp"])
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > node2 received the operations of the identity and knows its address
+	ops := node2.GetBlockOps("doc", "1@"+id.ID)
+	require.Len(t, ops, 1)
+	require.Equal(t, id.ID, ops[0].Origin)
+
+	addr, known := node2.ResolveIdentity(id.ID)
+	require.True(t, known)
+	require.Equal(t, node1.GetAddr(), addr)
+	require.Equal(t, node1.GetAddr(), node2.GetRoutingTable()[addr])
+
+	// > the same identity runs again at another address
+	node1.Stop()
+	node1b := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id),
+		z.WithAntiEntropy(time.Millisecond*100))
+	defer node1b.Stop()
+
+	node1b.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1b.GetAddr())
+
+	// > it gets back its previous rumors, and its operations, from node2
+	time.Sleep(time.Millisecond * 500)
+	require.Len(t, node1b.GetBlockOps("doc", "1@"+id.ID), 1)
+
+	result, err = node1b.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "def"),
+	})
+	require.NoError(t, err)
+	newBlock := result.IDs["1@temp"]
+	require.True(t, strings.HasSuffix(newBlock, "@"+id.ID))
+	require.NotEqual(t, "1@"+id.ID, newBlock)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, node2.GetBlockOps("doc", newBlock), 1)
+	addr, known = node2.ResolveIdentity(id.ID)
+	require.True(t, known)
+	require.Equal(t, node1b.GetAddr(), addr)
+}
+
+// The counters of the identity are saved, the same identity restarted goes on
+// from its last rumor and its last operation IDs before it syncs with its
+// peers.
+func Test_Identity_Counters(t *testing.T) {
+	transp := channel.NewTransport()
+	path := filepath.Join(t.TempDir(), "identity.key.counters")
+
+	id, err := identity.Generate()
+	require.NoError(t, err)
+	counters, err := identity.LoadCounters(path)
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id), z.WithCounters(counters))
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	_, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "abc"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+	require.Len(t, node2.GetBlockOps("doc", "1@"+id.ID), 1)
+
+	// > the same identity runs again, its counters are loaded from the file
+	node1.Stop()
+	counters, err = identity.LoadCounters(path)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), counters.GetRumorSeq())
+	require.Equal(t, uint64(4), counters.GetDocument("doc"))
+
+	node1b := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id), z.WithCounters(counters))
+	defer node1b.Stop()
+
+	node1b.AddPeer(node2.GetAddr())
+
+	result, err := node1b.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "def"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "5@"+id.ID, result.IDs["1@temp"])
+
+	// > node2 takes the next rumor of the identity and its new operations
+	time.Sleep(time.Millisecond * 300)
+	require.Len(t, node2.GetBlockOps("doc", "5@"+id.ID), 1)
+	require.Equal(t, uint(2), counters.GetRumorSeq())
+}
@@ -0,0 +1,93 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Concurrent writes to a metadata field converge to the same value on every
+// peer, and a later write overwrites it.
+func Test_Metadata_LWW_Two_Peers(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	docID := "1@" + node1.GetAddr()
+
+	// > both peers write the title concurrently, they agree on the winner
+	err := node1.SetDocumentMetadata(docID, types.MetadataTitle, "Title 1")
+	require.NoError(t, err)
+	err = node2.SetDocumentMetadata(docID, types.MetadataTitle, "Title 2")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	title := node1.GetDocumentMetadata(docID).Title
+	require.Contains(t, []string{"Title 1", "Title 2"}, title)
+	require.Equal(t, title, node2.GetDocumentMetadata(docID).Title)
+
+	// > a write made after seeing the others wins
+
+	err = node1.SetDocumentMetadata(docID, types.MetadataTitle, "Title 3")
+	require.NoError(t, err)
+	err = node1.SetDocumentMetadata(docID, types.MetadataIcon, "📄")
+	require.NoError(t, err)
+	err = node2.SetDocumentMetadata(docID, types.MetadataTags, `["work","draft"]`)
+	require.NoError(t, err)
+	err = node2.SetDocumentMetadata(docID, types.MetadataCreatedAt, "1700000000")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	expected := types.DocumentSummary{
+		DocumentID: docID,
+		Title:      "Title 3",
+		Icon:       "📄",
+		CreatedBy:  node1.GetAddr(),
+		CreatedAt:  1700000000,
+		Tags:       []string{"work", "draft"},
+		Status:     types.DocumentActive,
+		Kind:       types.DocumentPage,
+	}
+	require.Equal(t, expected, node1.GetDocumentMetadata(docID))
+	require.Equal(t, expected, node2.GetDocumentMetadata(docID))
+
+	summaries, err := node2.GetDocumentSummaries()
+	require.NoError(t, err)
+	require.Equal(t, []types.DocumentSummary{expected}, summaries)
+
+	// > the compaction only keeps the winner of each field
+	_, err = node1.CompactDocument(docID)
+	require.NoError(t, err)
+	require.Len(t, node1.GetBlockOps(docID, types.MetadataBlockID), 4)
+	require.Equal(t, expected, node1.GetDocumentMetadata(docID))
+}
+
+// Invalid metadata values are rejected before any operation is sent.
+func Test_Metadata_Invalid_Field(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	err := node1.SetDocumentMetadata("doc1", "owner", "me")
+	require.Error(t, err)
+	err = node1.SetDocumentMetadata("doc1", types.MetadataCreatedAt, "yesterday")
+	require.Error(t, err)
+	err = node1.SetDocumentMetadata("doc1", types.MetadataTags, "work")
+	require.Error(t, err)
+
+	require.Equal(t, uint64(0), node1.GetCRDTState("doc1"))
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/peer"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// node1 <-> node2 <-> node3, each peer has documents the others do not know.
+// A network search finds the documents of the peers reached by the budget,
+// and the budget grows until a peer has a matching document.
+func Test_NetworkSearch_Expanding_Ring(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node3 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node3.Stop()
+
+	// > the documents are created before the peers know each other
+	save := func(node z.TestNode, docID, text string) {
+		err := node.SaveTransactions(types.CRDTOperationsMessage{Operations: paragraphOps(docID, "1@temp", text)})
+		require.NoError(t, err)
+	}
+	save(node1, "notes", "Gossip protocols in practice")
+	save(node2, "spec", "The gossip protocol spreads rumors")
+	save(node3, "spec", "Gossip protocol, second draft")
+	save(node3, "dht", "Kademlia routing tables")
+
+	time.Sleep(time.Millisecond * 100)
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr(), node3.GetAddr())
+	node3.AddPeer(node2.GetAddr())
+
+	_, err := node1.SearchNetwork("  ", peer.ExpandingRing{Initial: 1, Factor: 2, Retry: 1, Timeout: time.Second})
+	require.Error(t, err)
+
+	// > a budget of 2 reaches node2 and node3
+	results, err := node1.SearchNetwork(`"gossip protocol"`, peer.ExpandingRing{
+		Initial: 2,
+		Factor:  2,
+		Retry:   1,
+		Timeout: time.Millisecond * 500,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "spec", results[0].DocumentID)
+	require.ElementsMatch(t, []string{node2.GetAddr(), node3.GetAddr()}, results[0].Peers)
+	require.Contains(t, results[0].Snippet, "ossip protocol")
+
+	// > the local documents are part of the results
+	results, err = node1.SearchNetwork("gossip*", peer.ExpandingRing{
+		Initial: 2,
+		Factor:  2,
+		Retry:   1,
+		Timeout: time.Millisecond * 500,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	hosts := make(map[string][]string)
+	for _, result := range results {
+		hosts[result.DocumentID] = result.Peers
+	}
+	require.Equal(t, []string{node1.GetAddr()}, hosts["notes"])
+	require.Len(t, hosts["spec"], 2)
+
+	// > a budget of 1 only reaches node2, which does not know the DHT, so the
+	// budget is doubled
+	results, err = node1.SearchNetwork("kademlia", peer.ExpandingRing{
+		Initial: 1,
+		Factor:  2,
+		Retry:   2,
+		Timeout: time.Millisecond * 500,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []types.DocumentSearchResult{{
+		DocumentID: "dht",
+		Title:      "",
+		Snippet:    "Kademlia routing tables",
+		Score:      results[0].Score,
+		Peers:      []string{node3.GetAddr()},
+	}}, results)
+
+	results, err = node1.SearchNetwork("kademlia", peer.ExpandingRing{
+		Initial: 1,
+		Factor:  2,
+		Retry:   1,
+		Timeout: time.Millisecond * 500,
+	})
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	// > the documents stay on their peers
+	require.Empty(t, mustSearch(t, node1, "kademlia", 0))
+}
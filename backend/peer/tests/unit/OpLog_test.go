@@ -0,0 +1,151 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/oplog"
+	"Node-tion/backend/peer/tests"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The checker reports the dangling references, the duplicated IDs and the
+// gaps of a log, and the repair drops the orphans.
+func Test_OpLog_Check_DropOrphans(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := sender.GetAddress()
+	blockID := "1@" + addr
+	sendOperations(t, sender, node1,
+		tests.CreateNewBlockOp(addr, "doc1", blockID)[0],
+		tests.CreateInsertTextOp(addr, "doc1", blockID, "", "ab", 2),
+		// > 3@addr is already given by the run
+		tests.CreateInsertTextOp(addr, "doc1", blockID, "2@"+addr, "x", 3),
+		// > 9@addr is unknown and no operation has the ID 4
+		tests.CreateInsertTextOp(addr, "doc1", blockID, "9@"+addr, "y", 5),
+		tests.CreateInsertTextOp(addr, "doc1", blockID, "5@"+addr, "z", 6),
+	)
+
+	time.Sleep(time.Millisecond * 300)
+
+	report, err := node1.CheckDocument("doc1")
+	require.NoError(t, err)
+	require.Equal(t, 5, report.Operations)
+	require.Equal(t, []types.IntegrityIssue{
+		{Kind: types.DuplicateIDIssue, BlockID: blockID, OperationID: "3@" + addr, Reference: "3@" + addr,
+			Detail: report.Issues[0].Detail},
+		{Kind: types.DanglingReferenceIssue, BlockID: blockID, OperationID: "5@" + addr, Reference: "9@" + addr,
+			Detail: "AfterID: unknown character"},
+		{Kind: types.SequenceGapIssue, BlockID: blockID, OperationID: "5@" + addr, Reference: "4",
+			Detail: report.Issues[2].Detail},
+	}, report.Issues)
+
+	// > the log exported by the node is checked the same offline
+	exported, err := node1.ExportDocumentLog("doc1")
+	require.NoError(t, err)
+	buf, err := json.Marshal(exported)
+	require.NoError(t, err)
+	loaded, err := oplog.Load(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, report, oplog.Check(loaded))
+
+	// > 6@addr is orphaned once 5@addr is dropped
+	report, err = node1.RepairDocument("doc1", types.RepairOptions{DropOrphans: true}, 0)
+	require.NoError(t, err)
+	for _, issue := range report.Issues {
+		require.Equal(t, types.SequenceGapIssue, issue.Kind)
+	}
+
+	blockOps := node1.GetBlockOps("doc1", blockID)
+	require.Len(t, blockOps, 1)
+	require.Equal(t, uint64(2), blockOps[0].OperationID)
+
+	doc, err := node1.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Contains(t, doc, "ab")
+	require.NotContains(t, doc, "5@"+addr)
+}
+
+// A node re-requests from its neighbors the operations missing from its log.
+func Test_OpLog_Repair_RequestMissing(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := sender.GetAddress()
+	blockID := "1@" + addr
+	block := tests.CreateNewBlockOp(addr, "doc1", blockID)[0]
+	run := tests.CreateInsertTextOp(addr, "doc1", blockID, "", "ab", 2)
+	last := tests.CreateInsertTextOp(addr, "doc1", blockID, "3@"+addr, "c", 4)
+
+	sendOperations(t, sender, node1, block, run, last)
+	// > node2 lost the run
+	sendOperations(t, sender, node2, block, last)
+	// > 3@addr is requested first, the run it belongs to reveals 2@addr
+	time.Sleep(time.Millisecond * 300)
+
+	report, err := node2.CheckDocument("doc1")
+	require.NoError(t, err)
+	require.NotEmpty(t, report.Issues)
+
+	report, err = node2.RepairDocument("doc1", types.RepairOptions{RequestMissing: true}, time.Second*2)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+
+	// > the run came back trimmed to 3@addr first, then whole: only its
+	// first character is added the second time
+	blockOps := node2.GetBlockOps("doc1", blockID)
+	require.Len(t, blockOps, 3)
+	require.Equal(t, uint64(2), blockOps[2].OperationID)
+	require.Equal(t, "a", blockOps[2].Operation.(types.CRDTInsertText).Text)
+
+	doc, err := node2.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Contains(t, doc, "abc")
+}
+
+// The parent chain of a block must not loop.
+func Test_OpLog_Parent_Loop(t *testing.T) {
+	addBlock := func(opID uint64, parent string) types.CRDTOperation {
+		op := tests.CreateNewBlockOp("A", "doc1", fmt.Sprintf("%d@A", opID))[0]
+		op.OperationID = opID
+		op.Operation = types.CRDTAddBlock{BlockType: types.ParagraphBlockType, ParentBlock: parent}
+		return op
+	}
+
+	log := types.DocumentSnapshot{
+		DocumentID: "doc1",
+		Operations: map[string][]types.CRDTOperation{
+			"doc1": {addBlock(1, ""), addBlock(2, "3@A"), addBlock(3, "2@A")},
+		},
+	}
+
+	report := oplog.Check(log)
+	require.Len(t, report.Issues, 2)
+	require.Equal(t, types.ParentLoopIssue, report.Issues[0].Kind)
+	require.Equal(t, "2@A", report.Issues[0].OperationID)
+	require.Equal(t, "3@A", report.Issues[0].Reference)
+	require.Equal(t, "3@A", report.Issues[1].OperationID)
+}
@@ -0,0 +1,145 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Subpages created and moved on different peers end up in the same tree.
+func Test_PageTree_Create_Move(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	root, err := node1.CreateSubpage("", "Root")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	pageA, err := node2.CreateSubpage(root, "A")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	pageB, err := node1.CreateSubpage(root, "B")
+	require.NoError(t, err)
+	pageC, err := node1.CreateSubpage(pageA, "C")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	expected := []types.PageNode{{
+		DocumentID: root,
+		Title:      "Root",
+		Children: []types.PageNode{
+			{
+				DocumentID: pageA,
+				Title:      "A",
+				Children: []types.PageNode{
+					{DocumentID: pageC, Title: "C", Children: []types.PageNode{}},
+				},
+			},
+			{DocumentID: pageB, Title: "B", Children: []types.PageNode{}},
+		},
+	}}
+
+	for _, node := range []z.TestNode{node1, node2} {
+		tree, err := node.GetPageTree()
+		require.NoError(t, err)
+		require.Equal(t, expected, tree)
+
+		docList, err := node.GetDocumentList()
+		require.NoError(t, err)
+		require.Equal(t, []string{root, pageA, pageC, pageB}, docList)
+	}
+
+	// > B is moved before A, and C to the root
+	err = node2.MovePage(pageB, root, 0)
+	require.NoError(t, err)
+	err = node2.MovePage(pageC, "", 1)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		docList, err := node.GetDocumentList()
+		require.NoError(t, err)
+		require.Equal(t, []string{root, pageB, pageA, pageC}, docList)
+	}
+
+	// > a page cannot be moved under itself or its subpages
+	require.Error(t, node1.MovePage(root, root, 0))
+	require.Error(t, node1.MovePage(root, pageA, 0))
+
+	// > archiving a page hides its subpages
+	err = node1.ArchiveDocument(root)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	for _, node := range []z.TestNode{node1, node2} {
+		docList, err := node.GetDocumentList()
+		require.NoError(t, err)
+		require.Equal(t, []string{pageC}, docList)
+	}
+}
+
+// Concurrent moves that together would create a cycle converge to the same
+// tree without a cycle on every peer.
+func Test_PageTree_Concurrent_Cycle(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	pageA, err := node1.CreateSubpage("", "A")
+	require.NoError(t, err)
+	pageB, err := node1.CreateSubpage("", "B")
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > node1 moves A under B while node2 moves B under A, the moves are sent
+	// as raw operations to skip the local cycle check
+	move := func(node z.TestNode, docID, parentID string) {
+		err := node.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{{
+			Type:        types.CRDTMovePageType,
+			OperationID: 1,
+			DocumentID:  docID,
+			BlockID:     types.MetadataBlockID,
+			Operation:   types.CRDTMovePage{ParentID: parentID},
+		}}})
+		require.NoError(t, err)
+	}
+	move(node1, pageA, pageB)
+	move(node2, pageB, pageA)
+
+	time.Sleep(time.Millisecond * 500)
+
+	tree1, err := node1.GetPageTree()
+	require.NoError(t, err)
+	tree2, err := node2.GetPageTree()
+	require.NoError(t, err)
+
+	require.Equal(t, tree1, tree2)
+	require.Len(t, tree1, 1)
+	require.Len(t, tree1[0].Children, 1)
+}
@@ -0,0 +1,204 @@
+package unit
+
+import (
+	"Node-tion/backend/identity"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The operations of a private document are only sent to its members, the
+// other peers at most relay them without processing them.
+func Test_Private_Document(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 3)
+	nodes := make([]z.TestNode, 3)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id),
+			z.WithAntiEntropy(time.Millisecond*50))
+		defer nodes[i].Stop()
+	}
+	owner, member, outsider := nodes[0], nodes[1], nodes[2]
+
+	for _, node := range nodes {
+		for _, other := range nodes {
+			node.AddPeer(other.GetAddr())
+		}
+	}
+	for _, node := range nodes {
+		empty, err := node.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+		require.NoError(t, err)
+		require.NoError(t, node.Broadcast(empty))
+	}
+	time.Sleep(time.Millisecond * 300)
+
+	docID := "100@" + ids[0].ID
+	commit := func(node z.TestNode, text string) error {
+		_, err := node.CommitTransaction(types.CRDTOperationsMessage{
+			Operations: paragraphOps(docID, "1@temp", text),
+		})
+		return err
+	}
+
+	// > only the owner can make a document private
+	require.NoError(t, owner.ShareDocument(docID, ids[1].ID, types.RoleEditor))
+	time.Sleep(time.Millisecond * 300)
+	require.Error(t, member.SetDocumentPrivate(docID, true))
+	require.NoError(t, owner.SetDocumentPrivate(docID, true))
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.True(t, owner.GetDocumentMetadata(docID).Private)
+	require.True(t, member.GetDocumentMetadata(docID).Private)
+	require.False(t, outsider.GetDocumentMetadata(docID).Private)
+
+	require.NoError(t, commit(owner, "secret"))
+	require.NoError(t, commit(member, "reply"))
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the members have the operations of each other
+	require.Len(t, owner.GetDocumentOps(docID)[docID], 2)
+	require.Len(t, member.GetDocumentOps(docID)[docID], 2)
+
+	// > the outsider received none of them, it may only relay them
+	require.Empty(t, outsider.GetDocumentOps(docID)[docID])
+	for _, pkt := range outsider.GetIns() {
+		if pkt.Header.Destination != outsider.GetAddr() {
+			continue
+		}
+		require.NotContains(t, string(pkt.Msg.Payload), "secret")
+		require.NotContains(t, string(pkt.Msg.Payload), "reply")
+	}
+}
+
+// The batches of a private document reach each member in the order they were
+// committed, none of them is dropped as already known.
+func Test_Private_Document_Order(t *testing.T) {
+	transp := channel.NewTransport()
+
+	ids := make([]identity.Identity, 2)
+	nodes := make([]z.TestNode, 2)
+	for i := range nodes {
+		id, err := identity.Generate()
+		require.NoError(t, err)
+		ids[i] = id
+		nodes[i] = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+		defer nodes[i].Stop()
+	}
+	owner, member := nodes[0], nodes[1]
+
+	owner.AddPeer(member.GetAddr())
+	member.AddPeer(owner.GetAddr())
+	for _, node := range nodes {
+		empty, err := node.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+		require.NoError(t, err)
+		require.NoError(t, node.Broadcast(empty))
+	}
+	time.Sleep(time.Millisecond * 300)
+
+	docID := "100@" + ids[0].ID
+	require.NoError(t, owner.ShareDocument(docID, ids[1].ID, types.RoleEditor))
+	require.NoError(t, owner.SetDocumentPrivate(docID, true))
+
+	const n = 30
+	for i := 0; i < n; i++ {
+		_, err := owner.CommitTransaction(types.CRDTOperationsMessage{
+			Operations: paragraphOps(docID, "1@temp", "x"),
+		})
+		require.NoError(t, err)
+	}
+
+	time.Sleep(time.Second)
+
+	require.Len(t, member.GetDocumentOps(docID)[docID], n)
+}
+
+// A private document is neither synced nor snapshotted for a peer that is not
+// one of its members, even when it has no member yet.
+func Test_Private_Document_Sync_Refused(t *testing.T) {
+	transp := channel.NewTransport()
+
+	owner := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer owner.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	owner.AddPeer(sender.GetAddress())
+
+	docID := "1@" + owner.GetAddr()
+	_, err = owner.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps(docID, "1@temp", "secret"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, owner.SetDocumentPrivate(docID, true))
+
+	sendMessage(t, sender, owner, &types.CRDTSyncRequestMessage{
+		DocumentID:    docID,
+		VersionVector: types.VersionVector{},
+	})
+	sendMessage(t, sender, owner, &types.CRDTSnapshotRequestMessage{
+		RequestID:  "request",
+		DocumentID: docID,
+	})
+
+	// > the only reply tells the document is unknown, the rumor of the commit
+	// aside
+	replies := make([]transport.Packet, 0)
+	for {
+		pkt, err := sender.Recv(time.Millisecond * 300)
+		if err != nil {
+			break
+		}
+		if pkt.Msg.Type != (types.RumorsMessage{}).Name() {
+			replies = append(replies, pkt)
+		}
+	}
+	require.Len(t, replies, 1)
+	require.Equal(t, types.CRDTSnapshotReplyMessage{}.Name(), replies[0].Msg.Type)
+
+	var reply types.CRDTSnapshotReplyMessage
+	require.NoError(t, owner.GetRegistry().UnmarshalMessage(replies[0].Msg, &reply))
+	require.Empty(t, reply.Metahash)
+}
+
+// A reliable message is acknowledged to the node it was received from,
+// whatever source its header claims.
+func Test_Private_Reliable_Ack(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+	victim, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	receiver.AddPeer(sender.GetAddress())
+	receiver.AddPeer(victim.GetAddress())
+
+	crdtMsg, err := receiver.GetRegistry().MarshalMessage(&types.CRDTOperationsMessage{})
+	require.NoError(t, err)
+	sendRelayed(t, sender, receiver, victim.GetAddress(), &types.ReliableMessage{
+		MessageID: "message",
+		Msg:       &crdtMsg,
+	})
+
+	pkt, err := sender.Recv(time.Millisecond * 300)
+	require.NoError(t, err)
+	require.Equal(t, types.ReliableAckMessage{}.Name(), pkt.Msg.Type)
+
+	_, err = victim.Recv(time.Millisecond * 300)
+	require.Error(t, err)
+}
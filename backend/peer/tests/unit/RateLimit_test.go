@@ -0,0 +1,212 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/peer"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The packets of a source over the limit of their type are dropped, the other
+// types have their own bucket or none.
+func Test_RateLimit_Per_Type(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithRateLimits(map[string]peer.RateLimit{
+		"chat": {Rate: 0, Burst: 3},
+		"*":    {Rate: 0, Burst: 2},
+	}))
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		sendMessage(t, sender, receiver, &types.ChatMessage{Message: "flood"})
+	}
+	for i := 0; i < 4; i++ {
+		sendMessage(t, sender, receiver, &types.EmptyMessage{})
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Len(t, receiver.GetChatMsgs(), 3)
+
+	stats := receiver.GetRateLimitStats()
+	require.Len(t, stats, 1)
+	require.Equal(t, sender.GetAddress(), stats[0].Source)
+	require.Equal(t, uint(5), stats[0].Accepted)
+	require.Equal(t, map[string]uint{"chat": 2, "empty": 2}, stats[0].Dropped)
+	require.Equal(t, uint(0), stats[0].Bans)
+}
+
+// A source dropping too many packets is banned: all its packets are dropped
+// until the ban ends, the packets of the other sources are not.
+func Test_RateLimit_Ban(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0",
+		z.WithRateLimits(map[string]peer.RateLimit{"chat": {Rate: 0, Burst: 1}}),
+		z.WithBan(3, time.Millisecond*500))
+	defer receiver.Stop()
+
+	flooder, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+	other, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		sendMessage(t, flooder, receiver, &types.ChatMessage{Message: "flood"})
+	}
+	sendMessage(t, flooder, receiver, &types.EmptyMessage{})
+	sendMessage(t, other, receiver, &types.ChatMessage{Message: "other"})
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Len(t, receiver.GetChatMsgs(), 2)
+
+	stats := receiver.GetRateLimitStats()
+	require.Len(t, stats, 2)
+
+	flooderStats := stats[0]
+	if flooderStats.Source != flooder.GetAddress() {
+		flooderStats = stats[1]
+	}
+	require.Equal(t, uint(1), flooderStats.Accepted)
+	require.Equal(t, map[string]uint{"chat": 3, "empty": 1}, flooderStats.Dropped)
+	require.Equal(t, uint(1), flooderStats.Bans)
+	require.NotZero(t, flooderStats.BannedUntil)
+
+	// > once the ban ends, the packets without limit go through again
+	time.Sleep(time.Millisecond * 500)
+
+	sendMessage(t, flooder, receiver, &types.EmptyMessage{})
+
+	time.Sleep(time.Millisecond * 200)
+
+	for _, sourceStats := range receiver.GetRateLimitStats() {
+		if sourceStats.Source == flooder.GetAddress() {
+			require.Equal(t, uint(2), sourceStats.Accepted)
+			require.Equal(t, uint(1), sourceStats.Dropped["empty"])
+		}
+	}
+}
+
+// The packets are limited by the node relaying them, whatever their source.
+func Test_RateLimit_Relayed(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithRateLimits(map[string]peer.RateLimit{
+		"chat": {Rate: 0, Burst: 3},
+	}))
+	defer receiver.Stop()
+
+	relay, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		sendRelayed(t, relay, receiver, fmt.Sprintf("127.0.0.1:%d", 1000+i), &types.ChatMessage{Message: "flood"})
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Len(t, receiver.GetChatMsgs(), 3)
+
+	stats := receiver.GetRateLimitStats()
+	require.Len(t, stats, 1)
+	require.Equal(t, relay.GetAddress(), stats[0].Source)
+	require.Equal(t, uint(3), stats[0].Accepted)
+	require.Equal(t, uint(2), stats[0].Dropped["chat"])
+}
+
+// The relay a packet claims is not trusted, not even when it is the receiver:
+// the packets are limited by the node the receiver got them from.
+func Test_RateLimit_Spoofed_Relay(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithRateLimits(map[string]peer.RateLimit{
+		"chat": {Rate: 0, Burst: 3},
+	}))
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	relays := []string{receiver.GetAddr(), receiver.GetAddr(), "127.0.0.1:1000", "127.0.0.1:1001", "127.0.0.1:1002"}
+	for _, relay := range relays {
+		chat, err := receiver.GetRegistry().MarshalMessage(&types.ChatMessage{Message: "flood"})
+		require.NoError(t, err)
+		header := transport.NewHeader(sender.GetAddress(), relay, receiver.GetAddr())
+		err = sender.Send(receiver.GetAddr(), transport.Packet{Header: &header, Msg: &chat}, 0)
+		require.NoError(t, err)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Len(t, receiver.GetChatMsgs(), 3)
+
+	stats := receiver.GetRateLimitStats()
+	require.Len(t, stats, 1)
+	require.Equal(t, sender.GetAddress(), stats[0].Source)
+	require.Equal(t, uint(2), stats[0].Dropped["chat"])
+}
+
+// Past the bound of sources, the least recently seen one is forgotten.
+func Test_RateLimit_Sources_Bound(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0",
+		z.WithRateLimits(map[string]peer.RateLimit{"chat": {Rate: 0, Burst: 1}}),
+		z.WithRateLimitSources(2))
+	defer receiver.Stop()
+
+	senders := make([]transport.ClosableSocket, 3)
+	for i := range senders {
+		sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+		require.NoError(t, err)
+		senders[i] = sender
+	}
+
+	for _, sender := range senders {
+		sendMessage(t, sender, receiver, &types.ChatMessage{Message: "hello"})
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+
+	sources := make([]string, 0)
+	for _, sourceStats := range receiver.GetRateLimitStats() {
+		sources = append(sources, sourceStats.Source)
+	}
+	require.ElementsMatch(t, []string{senders[1].GetAddress(), senders[2].GetAddress()}, sources)
+
+	// > the forgotten source starts over with a full bucket
+	sendMessage(t, senders[0], receiver, &types.ChatMessage{Message: "again"})
+
+	time.Sleep(time.Millisecond * 200)
+
+	require.Len(t, receiver.GetChatMsgs(), 4)
+	require.Len(t, receiver.GetRateLimitStats(), 2)
+}
+
+// sendMessage sends a message to the receiver from the sender socket.
+func sendMessage(t *testing.T, sender transport.ClosableSocket, receiver z.TestNode, msg types.Message) {
+	sendRelayed(t, sender, receiver, sender.GetAddress(), msg)
+}
+
+// sendRelayed sends a message created by source to the receiver, relayed by
+// the sender socket.
+func sendRelayed(t *testing.T, sender transport.ClosableSocket, receiver z.TestNode, source string, msg types.Message) {
+	transpMsg, err := receiver.GetRegistry().MarshalMessage(msg)
+	require.NoError(t, err)
+
+	header := transport.NewHeader(source, sender.GetAddress(), receiver.GetAddr())
+	err = sender.Send(receiver.GetAddr(), transport.Packet{Header: &header, Msg: &transpMsg}, 0)
+	require.NoError(t, err)
+}
@@ -0,0 +1,189 @@
+package unit
+
+import (
+	"Node-tion/backend/identity"
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The operations of an identity must carry its signature, runs cut from a
+// signed run are checked against it.
+func Test_Signature_Operations(t *testing.T) {
+	transp := channel.NewTransport()
+
+	id, err := identity.Generate()
+	require.NoError(t, err)
+
+	author := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+	defer author.Stop()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	result, err := author.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "abc"),
+	})
+	require.NoError(t, err)
+	block, run := result.Operations[0], result.Operations[1]
+	require.NotEmpty(t, block.Signature)
+	require.NotEmpty(t, run.Signature)
+
+	unsigned := run
+	unsigned.Signature = nil
+
+	tampered := run
+	tampered.Operation = types.CRDTInsertText{Text: "xyz"}
+
+	// > the last two characters of the run, as sent after a compaction
+	cut := run
+	cut.OperationID++
+	cut.Signature = nil
+	cut.Original = &run
+	cut.Operation = types.CRDTInsertText{AfterID: fmt.Sprintf("%d@%s", run.OperationID, id.ID), Text: "bc"}
+
+	badCut := cut
+	badCut.Operation = types.CRDTInsertText{AfterID: cut.Operation.(types.CRDTInsertText).AfterID, Text: "xc"}
+
+	sendOperations(t, sender, receiver, block, unsigned, tampered, badCut, cut)
+
+	time.Sleep(time.Millisecond * 300)
+
+	quarantined := receiver.GetQuarantinedOperations()
+	require.Len(t, quarantined, 3)
+	require.Contains(t, quarantined[0].Reason, "unsigned")
+	require.Contains(t, quarantined[1].Reason, "invalid signature")
+	require.Contains(t, quarantined[2].Reason, "original run")
+
+	ops := receiver.GetBlockOps("doc", block.BlockID)
+	require.Len(t, ops, 1)
+	require.Equal(t, cut.OperationID, ops[0].OperationID)
+}
+
+// The rumors of an identity must carry its signature, the others are rejected
+// and reported.
+func Test_Signature_Rumors(t *testing.T) {
+	transp := channel.NewTransport()
+
+	id, err := identity.Generate()
+	require.NoError(t, err)
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	receiver.AddPeer(sender.GetAddress())
+
+	payload, err := receiver.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+	require.NoError(t, err)
+
+	sendRumor := func(rumor types.Rumor) {
+		rumors := types.RumorsMessage{Rumors: []types.Rumor{rumor}}
+		transpMsg, err := receiver.GetRegistry().MarshalMessage(&rumors)
+		require.NoError(t, err)
+
+		header := transport.NewHeader(sender.GetAddress(), sender.GetAddress(), receiver.GetAddr())
+		err = sender.Send(receiver.GetAddr(), transport.Packet{Header: &header, Msg: &transpMsg}, 0)
+		require.NoError(t, err)
+	}
+
+	// > forged rumors of the identity
+	sendRumor(types.Rumor{Origin: id.ID, Address: sender.GetAddress(), Sequence: 1, Msg: &payload})
+	sendRumor(types.Rumor{Origin: id.ID, Address: sender.GetAddress(), Sequence: 1, Msg: &payload,
+		Signature: id.Sign([]byte("something else"))})
+	// > the rumors of an address need no signature
+	sendRumor(types.Rumor{Origin: sender.GetAddress(), Sequence: 1, Msg: &payload})
+
+	time.Sleep(time.Millisecond * 300)
+
+	rejected := receiver.GetRejectedRumors()
+	require.Len(t, rejected, 2)
+	require.Equal(t, id.ID, rejected[0].Origin)
+	require.Equal(t, sender.GetAddress(), rejected[0].Source)
+	require.Contains(t, rejected[0].Reason, "unsigned")
+	require.Contains(t, rejected[1].Reason, "invalid signature")
+
+	_, known := receiver.ResolveIdentity(id.ID)
+	require.False(t, known)
+
+	// > a signed rumor is accepted by another node
+	node := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithIdentity(id))
+	defer node.Stop()
+
+	node.AddPeer(receiver.GetAddr())
+	err = node.Broadcast(payload)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Len(t, receiver.GetRejectedRumors(), 2)
+	addr, known := receiver.ResolveIdentity(id.ID)
+	require.True(t, known)
+	require.Equal(t, node.GetAddr(), addr)
+}
+
+// With RequireSignatures, or with an identity, the unsigned operations of an
+// address are rejected too.
+func Test_Signature_Required(t *testing.T) {
+	id, err := identity.Generate()
+	require.NoError(t, err)
+
+	for _, option := range []z.Option{z.WithRequireSignatures(), z.WithIdentity(id)} {
+		transp := channel.NewTransport()
+
+		receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", option)
+
+		sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+		require.NoError(t, err)
+
+		addr := sender.GetAddress()
+		op := paragraphOps("doc", "1@"+addr, "a")[0]
+		op.Origin = addr
+		sendOperations(t, sender, receiver, op)
+
+		time.Sleep(time.Millisecond * 300)
+
+		require.Empty(t, receiver.GetDocumentOps("doc"))
+		quarantined := receiver.GetQuarantinedOperations()
+		require.Len(t, quarantined, 1)
+		require.Contains(t, quarantined[0].Reason, "unsigned")
+
+		receiver.Stop()
+	}
+}
+
+// A packet claiming to come from the receiver itself is checked like any
+// other packet of the socket.
+func Test_Signature_Spoofed_Source(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithRequireSignatures())
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	op := paragraphOps("doc", "1@"+receiver.GetAddr(), "a")[0]
+	op.Origin = receiver.GetAddr()
+	sendRelayed(t, sender, receiver, receiver.GetAddr(), &types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{op},
+	})
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Empty(t, receiver.GetDocumentOps("doc"))
+	quarantined := receiver.GetQuarantinedOperations()
+	require.Len(t, quarantined, 1)
+	require.Contains(t, quarantined[0].Reason, "unsigned")
+}
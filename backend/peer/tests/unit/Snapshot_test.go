@@ -0,0 +1,130 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A peer joining late installs the snapshot of a document, then only applies
+// the operations that are newer than it, even when the historical rumors are
+// caught up with the anti-entropy.
+func Test_Snapshot_Bootstrap(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*100))
+	defer node1.Stop()
+
+	addr := node1.GetAddr()
+
+	err := node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 2,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{Text: "Hello world"},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	err = node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTDeleteRangeType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@" + addr,
+			Operation:   types.CRDTDeleteRange{StartID: "7@" + addr, Length: 6},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > node1 is alone, the whole history is stable
+	stats, err := node1.CompactDocument("doc1")
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.OperationsAfter)
+
+	// > a new peer joins and bootstraps the document from node1
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*100))
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	err = node2.BootstrapDocument("doc1", node1.GetAddr(), time.Second*2)
+	require.NoError(t, err)
+
+	require.Equal(t, node1.GetVersionVector("doc1"), node2.GetVersionVector("doc1"))
+	require.Equal(t, uint64(13), node2.GetCRDTState("doc1"))
+
+	doc1, err := node1.CompileDocument("doc1")
+	require.NoError(t, err)
+	doc2, err := node2.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Equal(t, doc1, doc2)
+
+	// > the text only travels as a block snapshot
+	require.Len(t, node2.GetBlockOps("doc1", "1@"+addr), 0)
+
+	// > a new edit reaches node2 together with the old rumors
+	err = node1.SaveTransactions(types.CRDTOperationsMessage{Operations: []types.CRDTOperation{
+		{
+			Type:        types.CRDTInsertCharType,
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@" + addr,
+			Operation:   types.CRDTInsertChar{AfterID: "6@" + addr, Character: "!"},
+		},
+	}})
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	doc1, err = node1.CompileDocument("doc1")
+	require.NoError(t, err)
+	doc2, err = node2.CompileDocument("doc1")
+	require.NoError(t, err)
+
+	require.Equal(t, doc1, doc2)
+	require.Contains(t, doc2, "Hello!")
+	require.Equal(t, 1, strings.Count(doc2, "Hello"))
+	require.Len(t, node2.GetBlockOps("doc1", "1@"+addr), 1)
+}
+
+// Bootstrapping a document the peer does not know must fail.
+func Test_Snapshot_Bootstrap_Unknown_Document(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	err := node2.BootstrapDocument("doc1", node1.GetAddr(), time.Second)
+	require.Error(t, err)
+
+	_, err = node2.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Len(t, node2.GetDocumentOps("doc1"), 0)
+}
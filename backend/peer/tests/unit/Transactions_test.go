@@ -5,6 +5,10 @@ import (
 	"Node-tion/backend/transport/channel"
 	"Node-tion/backend/types"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -161,3 +165,233 @@ func Test_SaveTransactions_TempIDMappingWithEditor(t *testing.T) {
 	require.Equal(t, addOp.AfterBlock, "2@"+node.GetAddr())
 
 }
+
+// Test_SaveTransactions_InsertTextReservesIDs verifies that an insertText run
+// reserves one operation ID per character.
+func Test_SaveTransactions_InsertTextReservesIDs(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node.Stop()
+
+	ops := []types.CRDTOperation{
+		{
+			Type:        types.CRDTAddBlockType,
+			Origin:      node.GetAddr(),
+			OperationID: 1,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTAddBlock{BlockType: types.ParagraphBlockType},
+		},
+		{
+			Type:        types.CRDTInsertTextType,
+			Origin:      node.GetAddr(),
+			OperationID: 2,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{Text: "Hello"},
+		},
+		{
+			Type:        types.CRDTInsertCharType,
+			Origin:      node.GetAddr(),
+			OperationID: 7,
+			DocumentID:  "doc1",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertChar{AfterID: "6@temp", Character: "!"},
+		},
+	}
+
+	err := node.SaveTransactions(types.CRDTOperationsMessage{Operations: ops})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > 1 ID for the block, 5 for the run and 1 for the single character
+	require.Equal(t, uint64(7), node.GetCRDTState("doc1"))
+
+	blockOps := node.GetBlockOps("doc1", "1@"+node.GetAddr())
+	require.Len(t, blockOps, 2)
+	require.Equal(t, uint64(2), blockOps[0].OperationID)
+	require.Equal(t, uint64(7), blockOps[1].OperationID)
+
+	// > the character typed after the run references its last implied ID
+	insertOp, ok := blockOps[1].Operation.(types.CRDTInsertChar)
+	require.True(t, ok)
+	require.Equal(t, "6@"+node.GetAddr(), insertOp.AfterID)
+
+	doc, err := node.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Contains(t, doc, "Hello!")
+}
+
+// A transaction returns the real ID of each of its temporary IDs and leaves
+// the operations of the caller untouched.
+func Test_Transaction_IDs(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	ops := paragraphOps("doc", "1@temp", "abc")
+	result, err := node1.CommitTransaction(types.CRDTOperationsMessage{Operations: ops})
+	require.NoError(t, err)
+
+	addr := node1.GetAddr()
+	require.Equal(t, map[string]string{
+		"1@temp": "1@" + addr,
+		"2@temp": "2@" + addr,
+		"3@temp": "3@" + addr,
+		"4@temp": "4@" + addr,
+	}, result.IDs)
+	require.Len(t, result.Operations, 2)
+	require.Equal(t, "1@"+addr, result.Operations[1].BlockID)
+	require.Equal(t, uint64(2), result.Operations[1].OperationID)
+
+	require.Equal(t, "1@temp", ops[1].BlockID)
+	require.Equal(t, uint64(2), ops[1].OperationID)
+	require.Equal(t, "", ops[1].Origin)
+
+	// > the temporary IDs of the next transaction start over
+	result, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "d"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "5@"+addr, result.IDs["1@temp"])
+	require.Equal(t, "5@"+addr, result.Operations[1].BlockID)
+}
+
+// Concurrent transactions do not see the temporary IDs of each other.
+func Test_Transaction_Concurrent(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	const n = 20
+	results := make([]types.TransactionResult, n)
+	wait := sync.WaitGroup{}
+	wait.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wait.Done()
+			result, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+				Operations: paragraphOps("doc", "1@temp", fmt.Sprintf("text %d", i)),
+			})
+			require.NoError(t, err)
+			results[i] = result
+		}(i)
+	}
+	wait.Wait()
+
+	blockIDs := make(map[string]struct{})
+	for _, result := range results {
+		blockID := result.IDs["1@temp"]
+		require.Equal(t, blockID, result.Operations[0].BlockID)
+		require.Equal(t, blockID, result.Operations[1].BlockID)
+		blockIDs[blockID] = struct{}{}
+	}
+	require.Len(t, blockIDs, n)
+}
+
+// An invalid transaction names the offending operation and saves nothing.
+func Test_Transaction_Validation(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	insert := func(opID uint64, blockID, afterID string) types.CRDTOperation {
+		return types.CRDTOperation{
+			Type:        types.CRDTInsertTextType,
+			OperationID: opID,
+			DocumentID:  "doc",
+			BlockID:     blockID,
+			Operation:   types.CRDTInsertText{AfterID: afterID, Text: "xy"},
+		}
+	}
+
+	cases := []struct {
+		ops   []types.CRDTOperation
+		index int
+	}{
+		// > unknown temporary block
+		{append(paragraphOps("doc", "1@temp", "a"), insert(3, "9@temp", "")), 2},
+		// > the run of 2@temp reserves 2@temp and 3@temp
+		{append(paragraphOps("doc", "1@temp", "ab"), insert(3, "1@temp", "")), 2},
+		// > invalid reference
+		{append(paragraphOps("doc", "1@temp", "a"), insert(3, "1@temp", "bad")), 2},
+		// > unknown type
+		{[]types.CRDTOperation{{Type: "unknown", OperationID: 1, DocumentID: "doc"}}, 0},
+		// > missing document
+		{[]types.CRDTOperation{paragraphOps("", "1@temp", "a")[0]}, 0},
+		// > range over the limit of a run
+		{append(paragraphOps("doc", "1@temp", "a"), types.CRDTOperation{
+			Type:        types.CRDTDeleteRangeType,
+			OperationID: 3,
+			DocumentID:  "doc",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTDeleteRange{StartID: "2@temp", Length: 1 << 62},
+		}), 2},
+		// > text over the limit of a run
+		{append(paragraphOps("doc", "1@temp", "a"), types.CRDTOperation{
+			Type:        types.CRDTInsertTextType,
+			OperationID: 3,
+			DocumentID:  "doc",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{AfterID: "2@temp", Text: strings.Repeat("x", 1<<16+1)},
+		}), 2},
+	}
+
+	for _, c := range cases {
+		_, err := node1.CommitTransaction(types.CRDTOperationsMessage{Operations: c.ops})
+		require.Error(t, err)
+
+		var txErr types.TransactionError
+		require.True(t, errors.As(err, &txErr), err)
+		require.Equal(t, c.index, txErr.Index, err)
+		require.Equal(t, c.ops[c.index].OperationID, txErr.OperationID)
+	}
+
+	require.Equal(t, uint64(0), node1.GetCRDTState("doc"))
+	require.Empty(t, node1.GetDocumentOps("doc"))
+}
+
+// An operation built in Go without its type is committed as its Go type, and
+// a failed transaction leaves no gap in the IDs.
+func Test_Transaction_Typed(t *testing.T) {
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	result, err := node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: []types.CRDTOperation{{
+			OperationID: 1,
+			DocumentID:  "doc",
+			Operation:   types.CRDTSetMetadata{Field: "title", Value: "Notes"},
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Operations, 1)
+	require.Equal(t, types.CRDTSetMetadataType, result.Operations[0].Type)
+	require.Equal(t, types.MetadataBlockID, result.Operations[0].BlockID)
+	require.Equal(t, "Notes", node1.GetDocumentMetadata("doc").Title)
+
+	// > an invalid reference is found once the IDs are reserved
+	_, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: append(paragraphOps("doc", "1@temp", "a"), types.CRDTOperation{
+			OperationID: 3,
+			DocumentID:  "doc",
+			BlockID:     "1@temp",
+			Operation:   types.CRDTInsertText{AfterID: "9@temp", Text: "b"},
+		}),
+	})
+	require.Error(t, err)
+	require.Equal(t, uint64(1), node1.GetCRDTState("doc"))
+
+	result, err = node1.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc", "1@temp", "a"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "2@"+node1.GetAddr(), result.IDs["1@temp"])
+}
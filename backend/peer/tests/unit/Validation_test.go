@@ -0,0 +1,176 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/peer/tests"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The malformed operations of a message are quarantined and the valid ones
+// applied.
+func Test_Validation_Quarantine(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(1))
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	receiver.AddPeer(sender.GetAddress())
+
+	addr := sender.GetAddress()
+	block := tests.CreateNewBlockOp(addr, "doc1", "1@"+addr)[0]
+
+	unknownType := tests.CreateInsertTextOp(addr, "doc1", "1@"+addr, "", "a", 2)
+	unknownType.Type = "unknown"
+
+	tempRef := tests.CreateInsertTextOp(addr, "doc1", "1@"+addr, "1@temp", "b", 3)
+	malformedRef := tests.CreateInsertTextOp(addr, "doc1", "1@"+addr, "x@y@z", "c", 4)
+
+	unknownBlockType := tests.CreateNewBlockOp(addr, "doc1", "5@"+addr)[0]
+	unknownBlockType.OperationID = 5
+	unknownBlockType.Operation = types.CRDTAddBlock{BlockType: "unknown"}
+
+	// > 1@addr is a block of doc1
+	otherDocument := tests.CreateInsertTextOp(addr, "doc2", "1@"+addr, "1@"+addr, "d", 6)
+
+	// > the runs over the limit are rejected before they are expanded
+	longRange := types.CRDTOperation{
+		Type:        types.CRDTDeleteRangeType,
+		Origin:      addr,
+		OperationID: 7,
+		DocumentID:  "doc1",
+		BlockID:     "1@" + addr,
+		Operation:   types.CRDTDeleteRange{StartID: "1@" + addr, Length: 1 << 62},
+	}
+	longText := tests.CreateInsertTextOp(addr, "doc1", "1@"+addr, "", strings.Repeat("e", 1<<16+1), 8)
+
+	sendOperations(t, sender, receiver, block, unknownType, tempRef, malformedRef, unknownBlockType, otherDocument,
+		longRange, longText)
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the blocks are added under the document, the text was rejected
+	require.Len(t, receiver.GetBlockOps("doc1", "doc1"), 1)
+	require.Empty(t, receiver.GetBlockOps("doc1", "1@"+addr))
+	require.Empty(t, receiver.GetDocumentOps("doc2"))
+
+	quarantined := receiver.GetQuarantinedOperations()
+	require.Len(t, quarantined, 7)
+	for i, opID := range []uint64{2, 3, 4, 5, 6, 7, 8} {
+		require.Equal(t, opID, quarantined[i].Operation.OperationID)
+		require.Equal(t, addr, quarantined[i].Source)
+		require.NotEmpty(t, quarantined[i].Reason)
+	}
+	require.Contains(t, quarantined[0].Reason, "unknown operation type")
+	require.Contains(t, quarantined[1].Reason, "temporary ID")
+	require.Contains(t, quarantined[3].Reason, "unknown block type")
+	require.Contains(t, quarantined[4].Reason, "another document")
+	require.Contains(t, quarantined[5].Reason, "over the limit")
+	require.Contains(t, quarantined[6].Reason, "over the limit")
+
+	receiver.ClearQuarantine()
+	require.Empty(t, receiver.GetQuarantinedOperations())
+}
+
+// A reference to an ID stored under another document is quarantined.
+func Test_Validation_Stored_Other_Document(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(1))
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	receiver.AddPeer(sender.GetAddress())
+
+	addr := sender.GetAddress()
+	sendOperations(t, sender, receiver, tests.CreateNewBlockOp(addr, "doc1", "1@"+addr)[0])
+
+	block := tests.CreateNewBlockOp(addr, "doc2", "5@"+addr)[0]
+	block.OperationID = 5
+	sendOperations(t, sender, receiver, block)
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > doc2 has every operation of addr up to 5, 1@addr is a block of doc1
+	otherDocument := tests.CreateInsertTextOp(addr, "doc2", "1@"+addr, "", "a", 6)
+	sameDocument := tests.CreateInsertTextOp(addr, "doc2", "5@"+addr, "", "b", 7)
+	sendOperations(t, sender, receiver, otherDocument, sameDocument)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Empty(t, receiver.GetBlockOps("doc2", "1@"+addr))
+	require.Len(t, receiver.GetBlockOps("doc2", "5@"+addr), 1)
+
+	quarantined := receiver.GetQuarantinedOperations()
+	require.Len(t, quarantined, 1)
+	require.Equal(t, uint64(6), quarantined[0].Operation.OperationID)
+	require.Contains(t, quarantined[0].Reason, "another document")
+}
+
+// The operations of a rumor must have been created by the origin of the
+// rumor.
+func Test_Validation_Rumor_Origin(t *testing.T) {
+	transp := channel.NewTransport()
+
+	receiver := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithTotalPeers(1))
+	defer receiver.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	receiver.AddPeer(sender.GetAddress())
+
+	addr := sender.GetAddress()
+	ops := []types.CRDTOperation{
+		tests.CreateNewBlockOp(addr, "doc1", "1@"+addr)[0],
+		tests.CreateNewBlockOp("127.0.0.1:1", "doc1", "1@127.0.0.1:1")[0],
+	}
+
+	crdtMsg := types.CRDTOperationsMessage{Operations: ops}
+	msg, err := receiver.GetRegistry().MarshalMessage(&crdtMsg)
+	require.NoError(t, err)
+
+	rumors := types.RumorsMessage{Rumors: []types.Rumor{{Origin: addr, Sequence: 1, Msg: &msg}}}
+	transpMsg, err := receiver.GetRegistry().MarshalMessage(&rumors)
+	require.NoError(t, err)
+
+	header := transport.NewHeader(addr, addr, receiver.GetAddr())
+	err = sender.Send(receiver.GetAddr(), transport.Packet{Header: &header, Msg: &transpMsg}, 0)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	blockOps := receiver.GetBlockOps("doc1", "doc1")
+	require.Len(t, blockOps, 1)
+	require.Equal(t, addr, blockOps[0].Origin)
+
+	quarantined := receiver.GetQuarantinedOperations()
+	require.Len(t, quarantined, 1)
+	require.Equal(t, "127.0.0.1:1", quarantined[0].Operation.Origin)
+	require.Contains(t, quarantined[0].Reason, "rumor origin")
+}
+
+// sendOperations sends operations in a CRDTOperationsMessage from a socket to
+// a node.
+func sendOperations(t *testing.T, sender transport.ClosableSocket, receiver z.TestNode,
+	ops ...types.CRDTOperation) {
+
+	crdtMsg := types.CRDTOperationsMessage{Operations: ops}
+	transpMsg, err := receiver.GetRegistry().MarshalMessage(&crdtMsg)
+	require.NoError(t, err)
+
+	header := transport.NewHeader(sender.GetAddress(), sender.GetAddress(), receiver.GetAddr())
+	err = sender.Send(receiver.GetAddr(), transport.Packet{Header: &header, Msg: &transpMsg}, 0)
+	require.NoError(t, err)
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/peer/tests"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A viewer syncs the documents but refuses to edit them. The editors compact
+// without waiting for its version and reject the operations claiming it as
+// origin.
+func Test_Viewer_Node(t *testing.T) {
+	transp := channel.NewTransport()
+
+	editor := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithAntiEntropy(time.Millisecond*50))
+	defer editor.Stop()
+
+	viewer := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithReadOnly())
+	defer viewer.Stop()
+
+	editor.AddPeer(viewer.GetAddr())
+	viewer.AddPeer(editor.GetAddr())
+
+	// > the viewer advertises its role with its rumors
+	empty, err := viewer.GetRegistry().MarshalMessage(&types.EmptyMessage{})
+	require.NoError(t, err)
+	require.NoError(t, viewer.Broadcast(empty))
+
+	require.False(t, editor.IsReadOnly())
+	require.True(t, viewer.IsReadOnly())
+
+	_, err = viewer.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc1", "1@temp", "viewer"),
+	})
+	require.Error(t, err)
+	require.Error(t, viewer.SetDocumentMetadata("doc1", types.MetadataTitle, "viewer"))
+	require.Error(t, viewer.EncryptDocument("doc1"))
+
+	_, err = editor.CommitTransaction(types.CRDTOperationsMessage{
+		Operations: paragraphOps("doc1", "1@temp", "Hello"),
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > the viewer renders the document of the editor
+	expected, err := editor.CompileDocument("doc1")
+	require.NoError(t, err)
+	doc, err := viewer.CompileDocument("doc1")
+	require.NoError(t, err)
+	require.Equal(t, expected, doc)
+
+	// > the viewer never reported its version, the text is compacted anyway
+	stats, err := editor.CompactDocument("doc1")
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.OperationsBefore)
+	require.Equal(t, 1, stats.OperationsAfter)
+
+	// > an operation claiming the viewer as origin is rejected
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+	editor.AddPeer(sender.GetAddress())
+
+	forged := tests.CreateNewBlockOp(viewer.GetAddr(), "doc2", "1@"+viewer.GetAddr())[0]
+	sendOperations(t, sender, editor, forged)
+
+	time.Sleep(time.Millisecond * 300)
+
+	require.Empty(t, editor.GetDocumentOps("doc2"))
+	quarantined := editor.GetQuarantinedOperations()
+	require.Len(t, quarantined, 1)
+	require.Contains(t, quarantined[0].Reason, "read-only")
+}
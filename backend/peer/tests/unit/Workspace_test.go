@@ -0,0 +1,167 @@
+package unit
+
+import (
+	z "Node-tion/backend/internal/testing"
+	"Node-tion/backend/transport"
+	"Node-tion/backend/transport/channel"
+	"Node-tion/backend/types"
+	"Node-tion/backend/workspace"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// An invite token holds the address of a member and the key of the workspace.
+func Test_Workspace_Invite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspace.key")
+
+	key, err := workspace.LoadOrCreateKey(path)
+	require.NoError(t, err)
+	require.Len(t, key, workspace.KeySize)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, err := workspace.LoadOrCreateKey(path)
+	require.NoError(t, err)
+	require.Equal(t, key, loaded)
+
+	token := workspace.Invite{Address: "127.0.0.1:1", Secret: key}.Token()
+	invite, err := workspace.ParseInvite(token)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:1", invite.Address)
+	require.Equal(t, key, invite.Secret)
+
+	_, err = workspace.ParseInvite("127.0.0.1:1")
+	require.Error(t, err)
+	_, err = workspace.ParseInvite(workspace.Invite{Address: "127.0.0.1:1"}.Token())
+	require.Error(t, err)
+}
+
+// The packets of the nodes outside of the workspace are dropped, a node joins
+// the workspace with an invite.
+func Test_Workspace_Membership(t *testing.T) {
+	transp := channel.NewTransport()
+
+	key, err := workspace.GenerateKey()
+	require.NoError(t, err)
+	otherKey, err := workspace.GenerateKey()
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithWorkspaceKey(key))
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithWorkspaceKey(key))
+	defer node2.Stop()
+
+	outsider := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer outsider.Stop()
+
+	stranger := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithWorkspaceKey(otherKey))
+	defer stranger.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+	outsider.AddPeer(node1.GetAddr())
+	stranger.AddPeer(node1.GetAddr())
+
+	chat := func(node z.TestNode, text string) {
+		msg, err := node.GetRegistry().MarshalMessage(&types.ChatMessage{Message: text})
+		require.NoError(t, err)
+		require.NoError(t, node.Broadcast(msg))
+	}
+
+	chat(node2, "member")
+	chat(outsider, "outsider")
+	chat(stranger, "stranger")
+
+	time.Sleep(time.Millisecond * 300)
+
+	chats := node1.GetChatMsgs()
+	require.Len(t, chats, 1)
+	require.Equal(t, "member", chats[0].Message)
+	require.NotContains(t, node1.GetRoutingTable(), outsider.GetAddr())
+	require.NotContains(t, node1.GetRoutingTable(), stranger.GetAddr())
+
+	// > a packet whose content was changed after its MAC was set is dropped
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	payload, err := node1.GetRegistry().MarshalMessage(&types.ChatMessage{Message: "forged"})
+	require.NoError(t, err)
+	header := transport.NewHeader(sender.GetAddress(), sender.GetAddress(), node1.GetAddr())
+	pkt := transport.Packet{Header: &header, Msg: &payload}
+	header.MAC, err = workspace.MAC(key, pkt)
+	require.NoError(t, err)
+	header.Source = node2.GetAddr()
+
+	err = sender.Send(node1.GetAddr(), pkt, 0)
+	require.NoError(t, err)
+
+	// > the outsider joins with an invite of node1
+	token, err := node1.GetInvite()
+	require.NoError(t, err)
+	_, err = outsider.GetInvite()
+	require.Error(t, err)
+
+	err = outsider.JoinWorkspace(token)
+	require.NoError(t, err)
+	chat(outsider, "joined")
+
+	time.Sleep(time.Millisecond * 300)
+
+	// > its earlier rumor now goes through too, the forged packet and the
+	// stranger never do
+	texts := make([]string, 0)
+	for _, msg := range node1.GetChatMsgs() {
+		texts = append(texts, msg.Message)
+	}
+	require.ElementsMatch(t, []string{"member", "outsider", "joined"}, texts)
+}
+
+// A packet of the workspace received again is dropped as a replay, so is a
+// packet without counter, the packets delivered out of order go through.
+func Test_Workspace_Replay(t *testing.T) {
+	transp := channel.NewTransport()
+
+	key, err := workspace.GenerateKey()
+	require.NoError(t, err)
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithWorkspaceKey(key))
+	defer node1.Stop()
+
+	sender, err := z.NewSenderSocket(transp, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	send := func(text string, counter uint64) {
+		payload, err := node1.GetRegistry().MarshalMessage(&types.ChatMessage{Message: text})
+		require.NoError(t, err)
+		header := transport.NewHeader(sender.GetAddress(), sender.GetAddress(), node1.GetAddr())
+		header.Counter = counter
+		pkt := transport.Packet{Header: &header, Msg: &payload}
+		header.MAC, err = workspace.MAC(key, pkt)
+		require.NoError(t, err)
+
+		err = sender.Send(node1.GetAddr(), pkt, 0)
+		require.NoError(t, err)
+	}
+
+	send("first", 10)
+	send("first", 10)
+	send("late", 9)
+	send("unnumbered", 0)
+	send("second", 11)
+	send("late", 9)
+
+	time.Sleep(time.Millisecond * 300)
+
+	texts := make([]string, 0)
+	for _, msg := range node1.GetChatMsgs() {
+		texts = append(texts, msg.Message)
+	}
+	require.ElementsMatch(t, []string{"first", "late", "second"}, texts)
+}
@@ -54,3 +54,17 @@ func CreateNewBlockOp(addr, docID, blockID string) []types.CRDTOperation {
 	ops := []types.CRDTOperation{crdtOp}
 	return ops
 }
+
+func CreateInsertTextOp(addr, docID, blockID, afterID, text string, opID uint64) types.CRDTOperation {
+	return types.CRDTOperation{
+		Type:        types.CRDTInsertTextType,
+		Origin:      addr,
+		OperationID: opID,
+		DocumentID:  docID,
+		BlockID:     blockID,
+		Operation: types.CRDTInsertText{
+			AfterID: afterID,
+			Text:    text,
+		},
+	}
+}
@@ -18,7 +18,7 @@ var counter uint32 // initialized by default to 0
 // NewTransport returns a channel-based transport service.
 func NewTransport() transport.Transport {
 	return &Transport{
-		incomings: make(map[string]chan transport.Packet),
+		incomings: make(map[string]chan delivery),
 		traffic:   traffic.NewTraffic(),
 	}
 }
@@ -29,7 +29,7 @@ func NewTransport() transport.Transport {
 // - implements transport.ClosableSocket
 type Transport struct {
 	sync.RWMutex
-	incomings map[string]chan transport.Packet
+	incomings map[string]chan delivery
 	traffic   *traffic.Traffic
 }
 
@@ -41,7 +41,7 @@ func (t *Transport) CreateSocket(address string) (transport.ClosableSocket, erro
 		port := atomic.AddUint32(&counter, 1)
 		address = fmt.Sprintf("%s:%d", address, port)
 	}
-	t.incomings[address] = make(chan transport.Packet, 100)
+	t.incomings[address] = make(chan delivery, 100)
 	t.Unlock()
 
 	return &Socket{
@@ -64,9 +64,17 @@ func (t *Transport) MustCreate(address string) transport.ClosableSocket {
 	return socket
 }
 
+// delivery is a packet in the channel of a socket, with the address of the
+// socket that sent it.
+type delivery struct {
+	pkt  transport.Packet
+	from string
+}
+
 // Socket provide a network layer using channels.
 //
 // - implements transport.Socket
+// - implements transport.RemoteSocket
 type Socket struct {
 	*Transport
 	myAddr string
@@ -103,7 +111,7 @@ func (s *Socket) Send(dest string, pkt transport.Packet, timeout time.Duration)
 	s.outs.Lock()
 	defer s.outs.Unlock()
 	select {
-	case to <- pkt.Copy():
+	case to <- delivery{pkt: pkt.Copy(), from: s.myAddr}:
 	case <-time.After(timeout):
 		return transport.TimeoutError(timeout)
 	}
@@ -116,17 +124,23 @@ func (s *Socket) Send(dest string, pkt transport.Packet, timeout time.Duration)
 
 // Recv implements transport.Socket.
 func (s *Socket) Recv(timeout time.Duration) (transport.Packet, error) {
+	pkt, _, err := s.RecvFrom(timeout)
+	return pkt, err
+}
+
+// RecvFrom implements transport.RemoteSocket.
+func (s *Socket) RecvFrom(timeout time.Duration) (transport.Packet, string, error) {
 	s.RLock()
 	myChan := s.incomings[s.myAddr]
 	s.RUnlock()
 
 	select {
 	case <-time.After(timeout):
-		return transport.Packet{}, transport.TimeoutError(timeout)
-	case pkt := <-myChan:
-		s.traffic.LogRecv(pkt.Header.RelayedBy, s.myAddr, pkt)
-		s.ins.add(pkt)
-		return pkt, nil
+		return transport.Packet{}, "", transport.TimeoutError(timeout)
+	case d := <-myChan:
+		s.traffic.LogRecv(d.pkt.Header.RelayedBy, s.myAddr, d.pkt)
+		s.ins.add(d.pkt)
+		return d.pkt, d.from, nil
 	}
 }
 
@@ -21,7 +21,7 @@ var counter uint32 // initialized by default to 0
 // NewTransport returns a channel-based transport service.
 func NewTransport() transport.Transport {
 	return &PerfTransport{
-		incomings: make(map[string]chan transport.Packet),
+		incomings: make(map[string]chan delivery),
 		traffic:   traffic.NewTraffic(),
 	}
 }
@@ -32,7 +32,7 @@ func NewTransport() transport.Transport {
 // - implements transport.ClosableSocket
 type PerfTransport struct {
 	sync.RWMutex
-	incomings map[string]chan transport.Packet
+	incomings map[string]chan delivery
 	traffic   *traffic.Traffic
 }
 
@@ -44,7 +44,7 @@ func (t *PerfTransport) CreateSocket(address string) (transport.ClosableSocket,
 		port := atomic.AddUint32(&counter, 1)
 		address = fmt.Sprintf("%s:%d", address, port)
 	}
-	t.incomings[address] = make(chan transport.Packet, 100)
+	t.incomings[address] = make(chan delivery, 100)
 	t.Unlock()
 
 	return &PerfSocket{
@@ -64,9 +64,17 @@ func (t *PerfTransport) MustCreate(address string) transport.ClosableSocket {
 	return socket
 }
 
+// delivery is a packet in the channel of a socket, with the address of the
+// socket that sent it.
+type delivery struct {
+	pkt  transport.Packet
+	from string
+}
+
 // PerfSocket provide a performance-focused network layer using channels.
 //
 // - implements transport.Socket
+// - implements transport.RemoteSocket
 type PerfSocket struct {
 	*PerfTransport
 	myAddr string
@@ -98,7 +106,7 @@ func (s *PerfSocket) Send(dest string, pkt transport.Packet, timeout time.Durati
 	}
 
 	select {
-	case to <- pkt.Copy():
+	case to <- delivery{pkt: pkt.Copy(), from: s.myAddr}:
 	case <-time.After(timeout):
 		return transport.TimeoutError(timeout)
 	}
@@ -108,15 +116,21 @@ func (s *PerfSocket) Send(dest string, pkt transport.Packet, timeout time.Durati
 
 // Recv implements transport.Socket.
 func (s *PerfSocket) Recv(timeout time.Duration) (transport.Packet, error) {
+	pkt, _, err := s.RecvFrom(timeout)
+	return pkt, err
+}
+
+// RecvFrom implements transport.RemoteSocket.
+func (s *PerfSocket) RecvFrom(timeout time.Duration) (transport.Packet, string, error) {
 	s.RLock()
 	myChan := s.incomings[s.myAddr]
 	s.RUnlock()
 
 	select {
 	case <-time.After(timeout):
-		return transport.Packet{}, transport.TimeoutError(timeout)
-	case pkt := <-myChan:
-		return pkt, nil
+		return transport.Packet{}, "", transport.TimeoutError(timeout)
+	case d := <-myChan:
+		return d.pkt, d.from, nil
 	}
 }
 
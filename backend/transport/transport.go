@@ -43,6 +43,17 @@ type Socket interface {
 	GetOuts() []Packet
 }
 
+// RemoteSocket is implemented by the sockets that know the address each
+// packet is received from. Unlike the header of the packet, its sender does
+// not choose it.
+type RemoteSocket interface {
+	Socket
+
+	// RecvFrom is Recv, also returning the address the packet was received
+	// from.
+	RecvFrom(timeout time.Duration) (Packet, string, error)
+}
+
 // ClosableSocket augments the Socket interface with a close function. We
 // differentiate it because a gossiper shouldn't have access to the close
 // function of a socket.
@@ -138,6 +149,16 @@ type Header struct {
 	// Destination is empty in the case of a broadcast, otherwise contains the
 	// destination address.
 	Destination string
+
+	// MAC authenticates the packet within a workspace, see workspace.Socket.
+	// It is set by each node sending the packet, and empty outside of a
+	// workspace.
+	MAC []byte
+
+	// Counter numbers the packets sent by RelayedBy within a workspace, a
+	// packet received twice is dropped, see workspace.Socket. It is covered
+	// by the MAC.
+	Counter uint64
 }
 
 func (h Header) String() string {
@@ -156,6 +177,9 @@ func (h Header) String() string {
 
 // Copy returns the copy of header
 func (h Header) Copy() Header {
+	if h.MAC != nil {
+		h.MAC = append([]byte{}, h.MAC...)
+	}
 	return h
 }
 
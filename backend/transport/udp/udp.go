@@ -45,6 +45,7 @@ func (n *UDP) CreateSocket(address string) (transport.ClosableSocket, error) {
 //
 // - implements transport.Socket
 // - implements transport.ClosableSocket
+// - implements transport.RemoteSocket
 type Socket struct {
 	conn *net.UDPConn
 	addr string
@@ -102,26 +103,33 @@ func (s *Socket) Send(dest string, pkt transport.Packet, timeout time.Duration)
 // the timeout is reached. In the case the timeout is reached, return a
 // TimeoutErr.
 func (s *Socket) Recv(timeout time.Duration) (transport.Packet, error) {
+	pkt, _, err := s.RecvFrom(timeout)
+	return pkt, err
+}
+
+// RecvFrom implements transport.RemoteSocket. The address is the one of the
+// UDP datagram.
+func (s *Socket) RecvFrom(timeout time.Duration) (transport.Packet, string, error) {
 	buf := make([]byte, bufSize)
 
 	if timeout > 0 {
 		err := s.conn.SetReadDeadline(time.Now().Add(timeout))
 		if err != nil {
-			return transport.Packet{}, transport.TimeoutError(timeout)
+			return transport.Packet{}, "", transport.TimeoutError(timeout)
 		}
 	}
 
 	// read the packet
-	n, _, err := s.conn.ReadFromUDP(buf)
+	n, remote, err := s.conn.ReadFromUDP(buf)
 	if err != nil {
-		return transport.Packet{}, transport.TimeoutError(timeout)
+		return transport.Packet{}, "", transport.TimeoutError(timeout)
 	}
 
 	pkt := transport.Packet{}
 	// unmarshal the packet
 	err = pkt.Unmarshal(buf[:n])
 	if err != nil {
-		return transport.Packet{}, transport.TimeoutError(timeout)
+		return transport.Packet{}, "", transport.TimeoutError(timeout)
 	}
 
 	// add the packet to the ins
@@ -129,7 +137,7 @@ func (s *Socket) Recv(timeout time.Duration) (transport.Packet, error) {
 	s.ins = append(s.ins, pkt.Copy()) // make a deep copy of the packet
 	s.mu.Unlock()
 
-	return pkt, nil
+	return pkt, remote.String(), nil
 }
 
 // GetAddress implements transport.Socket. It returns the address assigned. Can
@@ -27,6 +27,216 @@ func (c CRDTOperationsMessage) String() string {
 // HTML implements types.Message.
 func (c CRDTOperationsMessage) HTML() string { return c.String() }
 
+// -----------------------------------------------------------------------------
+// CRDTVersionMessage
+
+// NewEmpty implements types.Message.
+func (c CRDTVersionMessage) NewEmpty() Message {
+	return &CRDTVersionMessage{}
+}
+
+// Name implements types.Message.
+func (c CRDTVersionMessage) Name() string {
+	return "crdtversion"
+}
+
+// String implements types.Message.
+func (c CRDTVersionMessage) String() string {
+	return fmt.Sprintf("crdtversion{%d documents}", len(c.VersionVectors))
+}
+
+// HTML implements types.Message.
+func (c CRDTVersionMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// CRDTSnapshotRequestMessage
+
+// NewEmpty implements types.Message.
+func (c CRDTSnapshotRequestMessage) NewEmpty() Message {
+	return &CRDTSnapshotRequestMessage{}
+}
+
+// Name implements types.Message.
+func (c CRDTSnapshotRequestMessage) Name() string {
+	return "crdtsnapshotrequest"
+}
+
+// String implements types.Message.
+func (c CRDTSnapshotRequestMessage) String() string {
+	return fmt.Sprintf("crdtsnapshotrequest{id:%s, doc:%s}", c.RequestID, c.DocumentID)
+}
+
+// HTML implements types.Message.
+func (c CRDTSnapshotRequestMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// CRDTSnapshotReplyMessage
+
+// NewEmpty implements types.Message.
+func (c CRDTSnapshotReplyMessage) NewEmpty() Message {
+	return &CRDTSnapshotReplyMessage{}
+}
+
+// Name implements types.Message.
+func (c CRDTSnapshotReplyMessage) Name() string {
+	return "crdtsnapshotreply"
+}
+
+// String implements types.Message.
+func (c CRDTSnapshotReplyMessage) String() string {
+	return fmt.Sprintf("crdtsnapshotreply{id:%s, doc:%s, metahash:%s}", c.RequestID, c.DocumentID, c.Metahash)
+}
+
+// HTML implements types.Message.
+func (c CRDTSnapshotReplyMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// CRDTSyncRequestMessage
+
+// NewEmpty implements types.Message.
+func (c CRDTSyncRequestMessage) NewEmpty() Message {
+	return &CRDTSyncRequestMessage{}
+}
+
+// Name implements types.Message.
+func (c CRDTSyncRequestMessage) Name() string {
+	return "crdtsyncrequest"
+}
+
+// String implements types.Message.
+func (c CRDTSyncRequestMessage) String() string {
+	return fmt.Sprintf("crdtsyncrequest{doc:%s, %d origins}", c.DocumentID, len(c.VersionVector))
+}
+
+// HTML implements types.Message.
+func (c CRDTSyncRequestMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// EncryptedCRDTOperationsMessage
+
+// NewEmpty implements types.Message.
+func (c EncryptedCRDTOperationsMessage) NewEmpty() Message {
+	return &EncryptedCRDTOperationsMessage{}
+}
+
+// Name implements types.Message.
+func (c EncryptedCRDTOperationsMessage) Name() string {
+	return "encryptedcrdtoperations"
+}
+
+// String implements types.Message.
+func (c EncryptedCRDTOperationsMessage) String() string {
+	return fmt.Sprintf("encryptedcrdtoperations{doc:%s, %d bytes}", c.DocumentID, len(c.Ciphertext))
+}
+
+// HTML implements types.Message.
+func (c EncryptedCRDTOperationsMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// ReliableMessage
+
+// NewEmpty implements types.Message.
+func (c ReliableMessage) NewEmpty() Message {
+	return &ReliableMessage{}
+}
+
+// Name implements types.Message.
+func (c ReliableMessage) Name() string {
+	return "reliable"
+}
+
+// String implements types.Message.
+func (c ReliableMessage) String() string {
+	return fmt.Sprintf("reliable{id:%s}", c.MessageID)
+}
+
+// HTML implements types.Message.
+func (c ReliableMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// ReliableAckMessage
+
+// NewEmpty implements types.Message.
+func (c ReliableAckMessage) NewEmpty() Message {
+	return &ReliableAckMessage{}
+}
+
+// Name implements types.Message.
+func (c ReliableAckMessage) Name() string {
+	return "reliableack"
+}
+
+// String implements types.Message.
+func (c ReliableAckMessage) String() string {
+	return fmt.Sprintf("reliableack{id:%s}", c.MessageID)
+}
+
+// HTML implements types.Message.
+func (c ReliableAckMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// DocumentKeyMessage
+
+// NewEmpty implements types.Message.
+func (c DocumentKeyMessage) NewEmpty() Message {
+	return &DocumentKeyMessage{}
+}
+
+// Name implements types.Message.
+func (c DocumentKeyMessage) Name() string {
+	return "documentkey"
+}
+
+// String implements types.Message.
+func (c DocumentKeyMessage) String() string {
+	return fmt.Sprintf("documentkey{doc:%s, to:%s}", c.DocumentID, c.Recipient)
+}
+
+// HTML implements types.Message.
+func (c DocumentKeyMessage) HTML() string { return c.String() }
+
+// -----------------------------------------------------------------------------
+// DocumentSearchRequestMessage
+
+// NewEmpty implements types.Message.
+func (d DocumentSearchRequestMessage) NewEmpty() Message {
+	return &DocumentSearchRequestMessage{}
+}
+
+// Name implements types.Message.
+func (d DocumentSearchRequestMessage) Name() string {
+	return "documentsearchrequest"
+}
+
+// String implements types.Message.
+func (d DocumentSearchRequestMessage) String() string {
+	return fmt.Sprintf("documentsearchrequest{%s %d}", d.Query, d.Budget)
+}
+
+// HTML implements types.Message.
+func (d DocumentSearchRequestMessage) HTML() string { return d.String() }
+
+// -----------------------------------------------------------------------------
+// DocumentSearchReplyMessage
+
+// NewEmpty implements types.Message.
+func (d DocumentSearchReplyMessage) NewEmpty() Message {
+	return &DocumentSearchReplyMessage{}
+}
+
+// Name implements types.Message.
+func (d DocumentSearchReplyMessage) Name() string {
+	return "documentsearchreply"
+}
+
+// String implements types.Message.
+func (d DocumentSearchReplyMessage) String() string {
+	return fmt.Sprintf("documentsearchreply{id:%s, peer:%s, %d documents}", d.RequestID, d.Peer, len(d.Responses))
+}
+
+// HTML implements types.Message.
+func (d DocumentSearchReplyMessage) HTML() string { return d.String() }
+
 // ---------------------Data Strutures Functions------------------------
 // TextStyle
 
@@ -74,6 +284,25 @@ func (s *StyledText) ToJSON() string {
 	return JSON
 }
 
+// PageMention
+
+func (m *PageMention) ToJSON() string {
+	JSON := "{"
+	JSON += "\"type\": \"" + "pageMention" + "\","
+	JSON += "\"charIds\": ["
+	for _, charID := range m.CharIDs {
+		JSON += "\"" + charID + "\","
+	}
+	JSON = JSON[:len(JSON)-1] // Remove the additional ","
+	JSON += "],"
+	JSON += "\"text\": \"" + m.Text + "\","
+	JSON += "\"documentId\": \"" + m.DocumentID + "\","
+	JSON += "\"blockId\": \"" + m.BlockID + "\","
+	JSON += "\"styles\": " + m.Styles.ToJSON()
+	JSON += "}"
+	return JSON
+}
+
 // Link
 
 func (l *Link) ToJSON() string {
@@ -271,12 +500,51 @@ func (b *TableBlock) ToJSON() string {
 	return ""
 }
 
+// TransclusionBlock
+
+func (b *TransclusionBlock) AddContent(content []CRDTInsertChar, style map[string]TextStyle) {
+	// Do nothing, the content is the one of the referenced block
+}
+
+func (b *TransclusionBlock) AddChildren(children []BlockType) {
+	b.Children = append(b.Children, children...)
+}
+
+func (b *TransclusionBlock) ToJSON() string {
+	JSON := "{"
+	JSON += "\"id\": \"" + b.ID + "\","
+	JSON += "\"type\": \"" + "transclusion" + "\","
+	// Props
+	JSON += PROPS
+	JSON += "\"sourceDocument\": \"" + b.Default.SourceDocument + "\","
+	JSON += "\"sourceBlock\": \"" + b.Default.SourceBlock + "\","
+	JSON += "\"error\": \"" + b.Error + "\""
+	JSON += "},"
+	// Content
+	JSON += CONTENT
+	if b.Content != nil {
+		JSON += SerializeBlock(b.Content) + ","
+	}
+	JSON = JSON[:len(JSON)-1] // Remove the additional ","
+	JSON += "],"
+	// Children
+	JSON += CHILDREN
+	for _, child := range b.Children {
+		JSON += SerializeBlock(child) + ","
+	}
+	JSON = JSON[:len(JSON)-1] // Remove the additional ","
+	JSON += "]}"
+
+	return JSON
+}
+
 // Utils
 
 func compareTextStyle(a TextStyle, b TextStyle) bool {
 	if a.Bold != b.Bold || a.Italic != b.Italic || a.Underline != b.Underline ||
 		a.Strikethrough != b.Strikethrough || a.TextColor != b.TextColor ||
-		a.BackgroundColor != b.BackgroundColor {
+		a.BackgroundColor != b.BackgroundColor || a.MentionDocument != b.MentionDocument ||
+		a.MentionBlock != b.MentionBlock {
 		return false
 	}
 
@@ -342,6 +610,8 @@ func SerializeBlock(block BlockType) string {
 		return b.ToJSON()
 	case *TableBlock:
 		return b.ToJSON()
+	case *TransclusionBlock:
+		return b.ToJSON()
 	default:
 		return "{}" // Fallback for unknown types
 	}
@@ -361,6 +631,8 @@ func AddContent(block BlockType, content []CRDTInsertChar, style map[string]Text
 		b.AddContent(content, style)
 	case *TableBlock:
 		b.AddContent(content, style)
+	case *TransclusionBlock:
+		b.AddContent(content, style)
 	}
 }
 
@@ -378,6 +650,8 @@ func AddChildren(block BlockType, children []BlockType) {
 		b.AddChildren(children)
 	case *TableBlock:
 		b.AddChildren(children)
+	case *TransclusionBlock:
+		b.AddChildren(children)
 	}
 }
 
@@ -385,9 +659,41 @@ func SerializeInlineContent(content InlineContent) string {
 	switch c := content.(type) {
 	case *StyledText:
 		return c.ToJSON()
+	case *PageMention:
+		return c.ToJSON()
 	case *Link:
 		return c.ToJSON()
 	default:
 		return "{}" // Fallback for unknown types
 	}
 }
+
+// Error implements error.
+func (e TransactionError) Error() string {
+	return fmt.Sprintf("invalid operation %d (temporary ID %d, type %q): %v", e.Index, e.OperationID, e.Type, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// BlockID returns the ID of the block.
+func (b BlockFactory) BlockID() string {
+	return b.ID
+}
+
+// ChildBlocks returns the children of the block.
+func (b BlockFactory) ChildBlocks() []BlockFactory {
+	return b.Children
+}
+
+// BlockID returns the ID of the block.
+func (b DocumentBlock) BlockID() string {
+	return b.ID
+}
+
+// ChildBlocks returns the children of the block.
+func (b DocumentBlock) ChildBlocks() []DocumentBlock {
+	return b.Children
+}
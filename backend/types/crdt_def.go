@@ -23,6 +23,8 @@ type TextStyle struct {
 	Strikethrough   bool
 	TextColor       string
 	BackgroundColor string
+	MentionDocument string // set by a mention mark, see PageMention
+	MentionBlock    string
 }
 
 type BlockTypeName string
@@ -34,6 +36,7 @@ const (
 	NumberedListBlockType BlockTypeName = "numberedListItem"
 	ImageBlockType        BlockTypeName = "image"
 	TableBlockType        BlockTypeName = "table"
+	TransclusionBlockType BlockTypeName = "transclusion"
 )
 
 const ( // CRDTOp Operation Types
@@ -44,6 +47,71 @@ const ( // CRDTOp Operation Types
 	CRDTDeleteCharType  = "delete"
 	CRDTAddMarkType     = "addMark"
 	CRDTRemoveMarkType  = "removeMark"
+	CRDTInsertTextType  = "insertText"
+	CRDTDeleteRangeType = "deleteRange"
+	CRDTSetMetadataType = "setMetadata"
+	CRDTSetStatusType   = "setStatus"
+	CRDTMovePageType    = "movePage"
+	CRDTSetPropertyType = "setProperty"
+	CRDTSetCellType     = "setCell"
+	CRDTSetAccessType   = "setAccess"
+)
+
+// MetadataBlockID is the BlockID under which the metadata operations of a
+// document are stored.
+const MetadataBlockID = "metadata"
+
+const ( // Document Statuses
+	DocumentActive   = "active"
+	DocumentArchived = "archived" // hidden, restorable at any time
+	DocumentDeleted  = "deleted"  // hidden, restorable until the retention expires
+)
+
+const ( // Document Roles, from the most to the least privileged
+	RoleOwner     = "owner"     // edits the document and its access list
+	RoleEditor    = "editor"    // edits the document
+	RoleCommenter = "commenter" // reads the document and annotates it with marks
+	RoleViewer    = "viewer"    // reads the document
+)
+
+const ( // Metadata Fields
+	MetadataTitle     = "title"
+	MetadataIcon      = "icon"
+	MetadataCover     = "cover"
+	MetadataCreatedBy = "createdBy"
+	MetadataCreatedAt = "createdAt" // unix time in seconds
+	MetadataTags      = "tags"      // JSON array of strings
+	MetadataKind      = "kind"      // DocumentPage or DocumentDatabase
+	MetadataDatabase  = "database"  // database of a row
+	MetadataPrivate   = "private"   // "true" if only the members receive the document
+)
+
+const ( // Document Kinds
+	DocumentPage     = "page"
+	DocumentDatabase = "database"
+)
+
+const ( // Database Property Types
+	PropertyText     = "text"
+	PropertyNumber   = "number"
+	PropertySelect   = "select"
+	PropertyDate     = "date"     // 2006-01-02 or RFC 3339
+	PropertyCheckbox = "checkbox" // true or false
+	PropertyRelation = "relation" // JSON array of row IDs
+)
+
+// TitleProperty is the ID under which the title of a row can be filtered and
+// sorted like a text property.
+const TitleProperty = "title"
+
+const ( // Database Filter Operators
+	FilterEquals      = "equals"
+	FilterNotEquals   = "notEquals"
+	FilterContains    = "contains"
+	FilterGreaterThan = "greaterThan"
+	FilterLessThan    = "lessThan"
+	FilterIsEmpty     = "isEmpty"
+	FilterIsNotEmpty  = "isNotEmpty"
 )
 
 const ( // Mark Types
@@ -53,6 +121,7 @@ const ( // Mark Types
 	Strikethrough   = "strikethrough"
 	TextColor       = "textColor"
 	BackgroundColor = "backgroundColor"
+	Mention         = "mention"
 )
 
 const StyledTextType = "styledText"
@@ -84,6 +153,47 @@ type BlockFactory struct {
 	Children  []BlockFactory
 }
 
+// DocumentBlock is a visible block of a document with the characters of its
+// text: CharIDs[i] and Styles[i] are the ID and the style of the i-th
+// character of Text. Only the text blocks have characters.
+type DocumentBlock struct {
+	ID        string
+	BlockType BlockTypeName
+	Props     DefaultBlockProps
+	Text      string
+	CharIDs   []string
+	Styles    []TextStyle
+	Children  []DocumentBlock
+}
+
+// TransactionResult is the outcome of a saved transaction: its operations
+// with their real IDs, and the real ID each temporary ID ("<n>@temp") of the
+// transaction was given.
+type TransactionResult struct {
+	Operations []CRDTOperation
+	IDs        map[string]string
+}
+
+// QuarantinedOperation is an operation received from another peer and
+// rejected by the validation, with the reason of the rejection. Source is the
+// peer the operation was received from and Timestamp the unix time in
+// seconds of the rejection.
+type QuarantinedOperation struct {
+	Operation CRDTOperation
+	Source    string
+	Reason    string
+	Timestamp int64
+}
+
+// TransactionError tells which operation of a transaction is invalid. Index
+// is the position of the operation in the transaction and OperationID its
+// temporary ID.
+type TransactionError struct {
+	Index       int
+	OperationID uint64
+	Type        string
+	Err         error
+}
 
 // InlineContent is an interface that defines operations on inline content.
 type InlineContent interface{}
@@ -104,6 +214,17 @@ type StyledText struct {
 	Styles  TextStyle
 }
 
+// PageMention implements InlineContent. It is the text covered by a mention
+// mark, which references a document and optionally one of its blocks.
+type PageMention struct {
+	InlineContent
+	CharIDs    []string
+	Text       string
+	Styles     TextStyle
+	DocumentID string
+	BlockID    string
+}
+
 // Link implements InlineContent.
 type Link struct {
 	InlineContent
@@ -167,11 +288,25 @@ type TableBlock struct {
 	Children []BlockType
 }
 
+// TransclusionBlock implements BlockType. Its content is the live compiled
+// content of the referenced block, Error is set instead if the block cannot
+// be found or if including it would create a cycle.
+type TransclusionBlock struct {
+	BlockType
+	Default  DefaultBlockProps
+	ID       string
+	Content  BlockType
+	Error    string
+	Children []BlockType
+}
+
 type DefaultBlockProps struct {
 	BackgroundColor string
 	TextColor       string
 	TextAlignment   TextAlignment
 	Level           HeadingLevel
+	SourceDocument  string // block included by a transclusion block
+	SourceBlock     string
 }
 
 // -------------------------------------------------------------------
@@ -184,6 +319,15 @@ type CRDTOperation struct {
 	DocumentID  string // OperationID@Origin that creates the document
 	BlockID     string // OperationID@Origin that creates the block
 	Operation   CRDTOp
+	// Signature is the signature of the operation by the identity of its
+	// origin, see identity.Identity.
+	Signature []byte
+	// Original is the signed run an insertText operation was cut from, e.g.
+	// by a compaction, its signature covers the characters of the operation.
+	Original *CRDTOperation
+	// Audit links the operation to the batch its origin committed it with,
+	// see AuditBatch. It is covered by the signature.
+	Audit *AuditLink
 }
 
 type CRDTAddBlock struct {
@@ -228,6 +372,80 @@ type CRDTDeleteChar struct {
 	RemovedID string
 }
 
+// CRDTInsertText implements CRDTOp. It inserts a run of characters with a
+// single operation: the i-th character of Text implicitly gets the ID
+// (OperationID+i)@Origin, so a run of k characters reserves k operation IDs.
+type CRDTInsertText struct {
+	CRDTOp
+	OpID    string
+	AfterID string
+	Text    string
+}
+
+// CRDTDeleteRange implements CRDTOp. It removes the Length characters whose
+// IDs follow StartID, i.e. n@Origin .. (n+Length-1)@Origin for StartID n@Origin.
+type CRDTDeleteRange struct {
+	CRDTOp
+	OpID    string
+	StartID string
+	Length  uint64
+}
+
+// CRDTSetMetadata implements CRDTOp. Each metadata field of a document is a
+// last-writer-wins register: the operation with the highest OperationID wins,
+// ties are broken by the highest Origin.
+type CRDTSetMetadata struct {
+	CRDTOp
+	Field string
+	Value string
+}
+
+// CRDTSetStatus implements CRDTOp. The status of a document is a
+// last-writer-wins register stored with its metadata. Timestamp is the unix
+// time in seconds at which the status was set, the retention of a deleted
+// document starts from it.
+type CRDTSetStatus struct {
+	CRDTOp
+	Status    string
+	Timestamp int64
+}
+
+// CRDTMovePage implements CRDTOp. It moves the page of its document under
+// ParentID, the workspace root if empty. Siblings are ordered by Position,
+// ties are broken by the document ID. Moves are replayed in the order of
+// their (OperationID, Origin, DocumentID), a move that would create a cycle
+// is skipped.
+type CRDTMovePage struct {
+	CRDTOp
+	ParentID string
+	Position float64
+}
+
+// CRDTSetProperty implements CRDTOp. Each property of the schema of a
+// database is a last-writer-wins register stored with its metadata.
+type CRDTSetProperty struct {
+	CRDTOp
+	Property DatabaseProperty
+}
+
+// CRDTSetCell implements CRDTOp. Each cell of a row is a last-writer-wins
+// register stored with the metadata of the row document.
+type CRDTSetCell struct {
+	CRDTOp
+	PropertyID string
+	Value      string
+}
+
+// CRDTSetAccess implements CRDTOp. The role of each member of the access list
+// of a document is a last-writer-wins register stored with its metadata. An
+// empty Role revokes the access of the member. Only the owners of a document
+// can change its access list.
+type CRDTSetAccess struct {
+	CRDTOp
+	Member string
+	Role   string
+}
+
 // CRDTAddMark implements CRDTOp.
 type CRDTAddMark struct {
 	CRDTOp
@@ -248,6 +466,309 @@ type CRDTRemoveMark struct {
 }
 
 type MarkOptions struct {
-	Color string
-	Href  string
+	Color      string
+	Href       string
+	DocumentID string // document referenced by a mention
+	BlockID    string // optional block referenced by a mention
+}
+
+// -------------------------------------------------------------------
+// Compaction
+
+// VersionVector tells, for a given origin, the highest operation ID applied
+// from that origin.
+type VersionVector map[string]uint64
+
+// SnapshotRun is a run of consecutive characters folded into a block
+// snapshot. Like for CRDTInsertText, the i-th character of Text has the ID
+// (n+i)@Origin for StartID n@Origin.
+type SnapshotRun struct {
+	StartID string
+	Text    string
+	Styles  TextStyle
+	Deleted bool
+}
+
+// BlockSnapshot is the compacted content of a block: the characters produced
+// by its causally stable operations, in document order. Tombstones are only
+// kept while a remaining operation references them, Anchors maps the dropped
+// ones to the closest preceding character so that late operations can still
+// be placed.
+type BlockSnapshot struct {
+	Runs    []SnapshotRun
+	Anchors map[string]string
+}
+
+// CompactionStats reports the effect of a compaction on a document. Sizes are
+// the length of the JSON encoding of the operations and snapshots.
+type CompactionStats struct {
+	DocumentID        string
+	OperationsBefore  int
+	OperationsAfter   int
+	TombstonesDropped int
+	BlocksDropped     int
+	BytesBefore       int
+	BytesAfter        int
+}
+
+// DocumentSnapshot is the state of a document sent to bootstrap a peer: the
+// operations left by the last compaction, the block snapshots and the version
+// vector they correspond to.
+type DocumentSnapshot struct {
+	DocumentID    string
+	VersionVector VersionVector
+	Operations    map[string][]CRDTOperation
+	Blocks        map[string]BlockSnapshot
+	// Compacted tells that operations may have been folded or dropped by a
+	// compaction, the per-origin sequences then have expected gaps.
+	Compacted bool
+}
+
+// DocumentACL is the access list of a document. The creator of the document
+// is always an owner. A document without members is not restricted: every
+// peer can read and edit it.
+type DocumentACL struct {
+	DocumentID string
+	Creator    string
+	Members    map[string]string // member origin -> role
+}
+
+// MembershipEntry is an entry of the membership log of a document: an access
+// operation, with the epoch of the document key when it was applied. Each
+// entry hashes the previous one and the access operations are signed by the
+// owners who made them, so the log cannot be altered unnoticed.
+type MembershipEntry struct {
+	Index     uint
+	Operation CRDTOperation // the CRDTSetAccess operation
+	KeyEpoch  uint
+	PrevHash  []byte
+	Hash      []byte
+}
+
+// DocumentSummary is the metadata of a document, as shown in the document
+// list.
+type DocumentSummary struct {
+	DocumentID string
+	Title      string
+	Icon       string
+	Cover      string
+	CreatedBy  string
+	CreatedAt  int64
+	Tags       []string
+	Status     string
+	StatusTime int64
+	Kind       string
+	Database   string
+	Private    bool
+}
+
+// PageNode is a page of the workspace tree with its subpages.
+type PageNode struct {
+	DocumentID string
+	Title      string
+	Icon       string
+	Children   []PageNode
+}
+
+// Backlink is a reference to a document from a block of another document,
+// either a page mention or a transclusion. TargetBlock is the referenced
+// block, if any.
+type Backlink struct {
+	SourceDocument string
+	SourceBlock    string
+	TargetBlock    string
+	Type           string // Mention or TransclusionBlockType
+}
+
+// -------------------------------------------------------------------
+// Databases
+
+// DatabaseProperty is a typed column of a database. Options are the values
+// of a select property, RelationDatabase is the database whose rows a
+// relation property references. A removed property is kept as Deleted.
+type DatabaseProperty struct {
+	ID               string
+	Name             string
+	Type             string
+	Options          []string
+	RelationDatabase string
+	Deleted          bool
+}
+
+// DatabaseRow is a row document of a database with its cells by property ID.
+type DatabaseRow struct {
+	DocumentID string
+	Title      string
+	Cells      map[string]string
+}
+
+// DatabaseFilter keeps the rows whose cell of a property matches Value with
+// the given operator, see the Filter* operators.
+type DatabaseFilter struct {
+	PropertyID string
+	Operator   string
+	Value      string
+}
+
+// DatabaseSort orders the rows by the cells of a property.
+type DatabaseSort struct {
+	PropertyID string
+	Descending bool
+}
+
+// DatabaseQuery selects the rows matching all the filters, ordered by the
+// sorts in turn. Rows are ordered by creation time otherwise.
+type DatabaseQuery struct {
+	Filters []DatabaseFilter
+	Sorts   []DatabaseSort
+}
+
+// -------------------------------------------------------------------
+// Full-text search
+
+// SearchHit is a block matching a full-text query. BlockID is empty when the
+// title of the document matches. Hits are ranked by decreasing Score.
+type SearchHit struct {
+	DocumentID string
+	BlockID    string
+	Score      float64
+	Snippet    string
+}
+
+// DocumentSearchResult is a document matching a full-text query on the
+// network. Snippet is taken from the best hit of the document and Peers are
+// the addresses of the peers hosting it.
+type DocumentSearchResult struct {
+	DocumentID string
+	Title      string
+	Snippet    string
+	Score      float64
+	Peers      []string
+}
+
+// -------------------------------------------------------------------
+// Find and replace
+
+// FindOptions describes the text to look for in the documents. Query is a
+// regular expression if Regex is set, a literal text otherwise. The active
+// documents are searched if DocumentID is empty.
+type FindOptions struct {
+	Query      string
+	Regex      bool
+	IgnoreCase bool
+	DocumentID string
+}
+
+// TextMatch is an occurrence of a FindOptions query in a block. Start and End
+// are character offsets in the text of the block, CharIDs are the IDs of the
+// matched characters.
+type TextMatch struct {
+	DocumentID string
+	BlockID    string
+	Start      int
+	End        int
+	Text       string
+	CharIDs    []string
+}
+
+// -------------------------------------------------------------------
+// Op-log integrity
+
+const ( // Integrity Issue Kinds
+	DanglingReferenceIssue = "danglingReference"
+	DuplicateIDIssue       = "duplicateID"
+	SequenceGapIssue       = "sequenceGap"
+	ParentLoopIssue        = "parentLoop"
+)
+
+// IntegrityIssue is a problem found in the operation log of a document.
+// BlockID is the key the operation is stored under and Reference is the ID
+// at fault: the missing block or character, the duplicated ID, the missing
+// predecessor of a gap or the parent closing a loop.
+type IntegrityIssue struct {
+	Kind        string
+	BlockID     string
+	OperationID string
+	Reference   string
+	Detail      string
+}
+
+// IntegrityReport lists the issues found in the operation log of a document.
+type IntegrityReport struct {
+	DocumentID string
+	Operations int
+	Issues     []IntegrityIssue
+}
+
+// RepairOptions tells how to repair a document: RequestMissing asks the
+// neighbors for the operations the log misses, DropOrphans removes the
+// operations whose references are still dangling afterwards, along with the
+// duplicates.
+type RepairOptions struct {
+	RequestMissing bool
+	DropOrphans    bool
+}
+
+// -------------------------------------------------------------------
+// Audit log
+
+// AuditLink tells the batch an operation was committed with: Prev is the hash
+// of the previous batch of its origin in the document, empty for the first
+// one, First the lowest operation ID of the batch and Size the number of
+// operations of the batch.
+type AuditLink struct {
+	Prev  []byte
+	First uint64
+	Size  int
+}
+
+// AuditBatch is a batch of operations an author committed together in a
+// document, sorted by ID. Its hash covers the hash of the previous batch of
+// the author, so the batches of each author form a chain and none can be
+// altered, dropped or reordered unnoticed. Index is the position of the batch
+// in the chain and ReceivedAt the unix time in seconds it was first applied.
+// A Pruned batch stands for the start of the chain dropped by the compaction:
+// it only holds the index and hash of the last batch dropped.
+type AuditBatch struct {
+	DocumentID string
+	Author     string
+	Index      uint
+	PrevHash   []byte
+	Size       int
+	Operations []CRDTOperation
+	Hash       []byte
+	ReceivedAt int64
+	Pruned     bool
+}
+
+// AuditAuthor sums up the chain of an author in a document. Head is the hash
+// of its last batch.
+type AuditAuthor struct {
+	Author     string
+	Batches    int
+	Operations int
+	Head       []byte
+}
+
+// AuditAnchor is a state hash of a document tagged in the naming store, and
+// so in the blockchain when there are several peers. Timestamp is the unix
+// time in seconds of the anchoring.
+type AuditAnchor struct {
+	DocumentID string
+	Name       string
+	StateHash  []byte
+	Timestamp  int64
+}
+
+// AuditReport is the audit log of a document, with the chain of each author,
+// its anchors and the problems found by the verification. StateHash covers
+// the heads of the chains and the content of the document.
+type AuditReport struct {
+	DocumentID  string
+	GeneratedAt int64
+	StateHash   []byte
+	Authors     []AuditAuthor
+	Batches     []AuditBatch
+	Anchors     []AuditAnchor
+	Problems    []string
 }
@@ -21,9 +21,21 @@ type RumorsMessage struct {
 
 // Rumor wraps a message to ensure delivery to all peers-
 type Rumor struct {
-	// Origin is the address of the node that initiated the rumor
+	// Origin is the address of the node that initiated the rumor, or the ID
+	// of its identity if it has one
 	Origin string
 
+	// Address is the address of the node that initiated the rumor when its
+	// origin is an identity, empty otherwise
+	Address string
+
+	// ReadOnly tells that the node that initiated the rumor never originates
+	// CRDT operations
+	ReadOnly bool
+
+	// Signature is the signature of the rumor by the identity of its origin
+	Signature []byte
+
 	// Sequence is the unique ID of the packet from packet's creator point of
 	// view. Each time a sender creates a packet, it must increment its sequence
 	// number and include it. Start from 1.
@@ -77,4 +89,142 @@ type PrivateMessage struct {
 // - implements types.Message
 type CRDTOperationsMessage struct {
 	Operations []CRDTOperation
-}
\ No newline at end of file
+}
+// CRDTVersionMessage describes a message that contains the version vector of
+// every document known by a peer. It is used to find out which operations are
+// causally stable, i.e. applied by every known peer.
+//
+// - implements types.Message
+type CRDTVersionMessage struct {
+	VersionVectors map[string]VersionVector
+}
+
+// CRDTSnapshotRequestMessage describes a message sent by a peer to get the
+// compacted state of a document from a neighbor.
+//
+// - implements types.Message
+type CRDTSnapshotRequestMessage struct {
+	RequestID  string
+	DocumentID string
+}
+
+// CRDTSnapshotReplyMessage describes the answer to a
+// CRDTSnapshotRequestMessage. The DocumentSnapshot is uploaded by the
+// replying peer and must be downloaded with Metahash. Metahash is empty if the
+// peer does not know the document.
+//
+// - implements types.Message
+type CRDTSnapshotReplyMessage struct {
+	RequestID     string
+	DocumentID    string
+	Metahash      string
+	VersionVector VersionVector
+}
+
+// CRDTSyncRequestMessage describes a message sent by a peer to get the
+// operations of a document that are newer than its version vector. They are
+// sent back with a CRDTOperationsMessage.
+//
+// - implements types.Message
+type CRDTSyncRequestMessage struct {
+	DocumentID    string
+	VersionVector VersionVector
+}
+
+// EncryptedCRDTOperationsMessage describes a CRDTOperationsMessage with the
+// operations of a single document, encrypted with the key of the document.
+// The peers without the key relay it like any rumor, but cannot read it.
+// Epoch tells which key was used, it changes each time the key is rotated.
+//
+// - implements types.Message
+type EncryptedCRDTOperationsMessage struct {
+	DocumentID string
+	Epoch      uint
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// ReliableMessage describes a message unicast to a peer until it acknowledges
+// it with a ReliableAckMessage. It carries the operations of the private
+// documents, which are never gossiped.
+//
+// - implements types.Message
+type ReliableMessage struct {
+	MessageID string
+	Msg       *transport.Message
+}
+
+// ReliableAckMessage describes the acknowledgement of a ReliableMessage.
+//
+// - implements types.Message
+type ReliableAckMessage struct {
+	MessageID string
+}
+
+// DocumentKeyMessage describes a message giving the key of a document to a
+// collaborator. The key is sealed for the identity of the collaborator, see
+// identity.Seal, and the message is sent within a ReliableMessage. Epoch is
+// the epoch of the key. The message is signed by Sender, an owner of the
+// document.
+//
+// - implements types.Message
+type DocumentKeyMessage struct {
+	DocumentID string
+	Recipient  string
+	Epoch      uint
+	SealedKey  []byte
+	Sender     string
+	Signature  []byte
+}
+
+// DocumentSearchRequestMessage describes a request to search the documents of
+// the peers for a full-text query. Like a SearchRequestMessage, it is
+// forwarded to the neighbors while there is budget left.
+//
+// - implements types.Message
+type DocumentSearchRequestMessage struct {
+	// RequestID must be a unique identifier. Use xid.New().String() to generate
+	// it.
+	RequestID string
+	// Origin is the address of the peer that initiated the search request.
+	Origin string
+
+	Query  string
+	Budget uint
+}
+
+// DocumentSearchReplyMessage describes the documents of a peer matching a
+// DocumentSearchRequestMessage.
+//
+// - implements types.Message
+type DocumentSearchReplyMessage struct {
+	// RequestID must be the same as the RequestID set in the
+	// DocumentSearchRequestMessage.
+	RequestID string
+	// Peer is the address of the peer hosting the documents.
+	Peer string
+
+	Responses []DocumentSearchResult
+}
+
+// RejectedRumor is a rumor dropped because its signature is missing or
+// invalid. Source is the peer the rumor was received from and Timestamp the
+// unix time in seconds of the rejection.
+type RejectedRumor struct {
+	Origin    string
+	Sequence  uint
+	Source    string
+	Reason    string
+	Timestamp int64
+}
+
+// RateLimitStats counts the packets of a source accepted and dropped by the
+// rate limits, the drops per type of message. BannedUntil is the unix time in
+// seconds until which the source is banned, 0 if it never was.
+type RateLimitStats struct {
+	Source      string
+	Accepted    uint
+	Dropped     map[string]uint
+	Bans        uint
+	BannedUntil int64
+}
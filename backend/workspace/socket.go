@@ -0,0 +1,185 @@
+package workspace
+
+import (
+	"Node-tion/backend/transport"
+	"sync"
+	"time"
+)
+
+// replayWindow is how many counters below the highest one received from a
+// sender are still accepted, for the packets delivered out of order.
+const replayWindow = 64
+
+// Socket authenticates the packets of a socket with the key of a workspace:
+// the packets sent carry their MAC and the packets received without a valid
+// one are dropped. Every packet sent is numbered with a counter under the
+// MAC, and the packets whose counter was already received from their sender
+// are dropped as replays. Without key, the packets go through unchanged.
+//
+// - implements transport.Socket
+// - implements transport.RemoteSocket
+type Socket struct {
+	transport.Socket
+
+	mu       sync.RWMutex
+	key      Key
+	rejected uint
+	counter  uint64
+	windows  map[string]*replayState
+}
+
+// replayState holds the highest counter received from a sender, and which of
+// the replayWindow counters below it were received: bit i is the counter
+// highest-i.
+type replayState struct {
+	highest uint64
+	seen    uint64
+}
+
+// NewSocket returns a socket authenticating the packets of sock with key,
+// which can be nil. The counters start at the current time, so they keep
+// increasing when the node restarts.
+func NewSocket(sock transport.Socket, key Key) *Socket {
+	return &Socket{
+		Socket:  sock,
+		key:     key,
+		counter: uint64(time.Now().UnixNano()),
+		windows: make(map[string]*replayState),
+	}
+}
+
+// Key returns the key of the workspace, nil if there is none.
+func (s *Socket) Key() Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.key
+}
+
+// SetKey sets the key of the workspace. The packets of the previous workspace
+// are dropped from now on.
+func (s *Socket) SetKey(key Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key = key
+}
+
+// Rejected returns the number of packets dropped so far.
+func (s *Socket) Rejected() uint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.rejected
+}
+
+// Send implements transport.Socket. The header of the packet is copied before
+// its MAC is set.
+func (s *Socket) Send(dest string, pkt transport.Packet, timeout time.Duration) error {
+	key := s.Key()
+	if key == nil {
+		return s.Socket.Send(dest, pkt, timeout)
+	}
+
+	header := pkt.Header.Copy()
+	s.mu.Lock()
+	s.counter++
+	header.Counter = s.counter
+	s.mu.Unlock()
+	pkt.Header = &header
+
+	mac, err := MAC(key, pkt)
+	if err != nil {
+		return err
+	}
+	header.MAC = mac
+
+	return s.Socket.Send(dest, pkt, timeout)
+}
+
+// Recv implements transport.Socket. It waits for the next packet with a valid
+// MAC and a counter not received yet, the others are dropped.
+func (s *Socket) Recv(timeout time.Duration) (transport.Packet, error) {
+	pkt, _, err := s.RecvFrom(timeout)
+	return pkt, err
+}
+
+// RecvFrom implements transport.RemoteSocket. The address is empty if the
+// underlying socket cannot tell it.
+func (s *Socket) RecvFrom(timeout time.Duration) (transport.Packet, string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := timeout
+		if timeout > 0 {
+			remaining = time.Until(deadline)
+			if remaining <= 0 {
+				return transport.Packet{}, "", transport.TimeoutError(timeout)
+			}
+		}
+
+		pkt, remote, err := s.recvFrom(remaining)
+		if err != nil {
+			return pkt, "", err
+		}
+
+		key := s.Key()
+		if key == nil {
+			return pkt, remote, nil
+		}
+		valid := Verify(key, pkt) == nil
+
+		s.mu.Lock()
+		if valid && s.fresh(*pkt.Header) {
+			s.mu.Unlock()
+			return pkt, remote, nil
+		}
+		s.rejected++
+		s.mu.Unlock()
+	}
+}
+
+// recvFrom receives a packet from the underlying socket, with its address if
+// the socket knows it.
+func (s *Socket) recvFrom(timeout time.Duration) (transport.Packet, string, error) {
+	if sock, ok := s.Socket.(transport.RemoteSocket); ok {
+		return sock.RecvFrom(timeout)
+	}
+	pkt, err := s.Socket.Recv(timeout)
+	return pkt, "", err
+}
+
+// fresh tells whether the counter of a packet was not received yet from its
+// sender, and marks it as received. The packets without counter are never
+// fresh. The caller must hold the lock.
+func (s *Socket) fresh(header transport.Header) bool {
+	if header.Counter == 0 {
+		return false
+	}
+	state, exists := s.windows[header.RelayedBy]
+	if !exists {
+		state = &replayState{}
+		s.windows[header.RelayedBy] = state
+	}
+
+	switch {
+	case header.Counter > state.highest:
+		shift := header.Counter - state.highest
+		if shift >= replayWindow {
+			state.seen = 0
+		} else {
+			state.seen <<= shift
+		}
+		state.seen |= 1
+		state.highest = header.Counter
+		return true
+	case state.highest-header.Counter >= replayWindow:
+		return false
+	default:
+		bit := uint64(1) << (state.highest - header.Counter)
+		if state.seen&bit != 0 {
+			return false
+		}
+		state.seen |= bit
+		return true
+	}
+}
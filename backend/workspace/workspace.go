@@ -0,0 +1,141 @@
+// Package workspace restricts the gossip to the nodes sharing a secret key.
+// Every packet sent by a node of a workspace carries a MAC computed with the
+// key over its header and its message, and the packets without a valid MAC
+// are dropped before they reach the node. The key is given to a new member
+// with an invite token holding the address of a member and the key.
+package workspace
+
+import (
+	"Node-tion/backend/transport"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeySize is the size of a workspace key, in bytes.
+const KeySize = 32
+
+// invitePrefix starts every invite token, to tell it from other strings.
+const invitePrefix = "nodetion-invite:"
+
+// encoding encodes the keys and the invite tokens.
+var encoding = base64.RawURLEncoding
+
+// Key is the shared secret of a workspace.
+type Key []byte
+
+// GenerateKey returns the key of a new workspace.
+func GenerateKey() (Key, error) {
+	key := make(Key, KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey loads the key stored in a file, or generates one and stores
+// it if the file does not exist yet. The file is readable by its owner only.
+func LoadOrCreateKey(path string) (Key, error) {
+	buf, err := os.ReadFile(path)
+	if err == nil {
+		key, err := encoding.DecodeString(strings.TrimSpace(string(buf)))
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("invalid workspace key file %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read workspace key: %w", err)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace key folder: %w", err)
+	}
+	err = os.WriteFile(path, []byte(encoding.EncodeToString(key)+"\n"), 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store workspace key: %w", err)
+	}
+	return key, nil
+}
+
+// Invite is what a node needs to join a workspace: the address of a member to
+// gossip with and the key of the workspace.
+type Invite struct {
+	Address string
+	Secret  Key
+}
+
+// Token encodes the invite into a string that can be shared.
+func (i Invite) Token() string {
+	buf, _ := json.Marshal(i)
+	return invitePrefix + encoding.EncodeToString(buf)
+}
+
+// ParseInvite decodes an invite token.
+func ParseInvite(token string) (Invite, error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, invitePrefix) {
+		return Invite{}, fmt.Errorf("not an invite token")
+	}
+	buf, err := encoding.DecodeString(strings.TrimPrefix(token, invitePrefix))
+	if err != nil {
+		return Invite{}, fmt.Errorf("invalid invite token: %w", err)
+	}
+
+	var invite Invite
+	err = json.Unmarshal(buf, &invite)
+	if err != nil {
+		return Invite{}, fmt.Errorf("invalid invite token: %w", err)
+	}
+	if invite.Address == "" || len(invite.Secret) != KeySize {
+		return Invite{}, fmt.Errorf("incomplete invite token")
+	}
+	return invite, nil
+}
+
+// MAC returns the authentication code of a packet: an HMAC-SHA256 over its
+// header, counter included and MAC excluded, and its message.
+func MAC(key Key, pkt transport.Packet) ([]byte, error) {
+	if pkt.Header == nil {
+		return nil, fmt.Errorf("packet without header")
+	}
+	header := pkt.Header.Copy()
+	header.MAC = nil
+
+	buf, err := transport.Packet{Header: &header, Msg: pkt.Msg}.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode packet: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(buf)
+	return mac.Sum(nil), nil
+}
+
+// Verify checks the authentication code of a packet.
+func Verify(key Key, pkt transport.Packet) error {
+	if pkt.Header == nil || len(pkt.Header.MAC) == 0 {
+		return fmt.Errorf("unauthenticated packet")
+	}
+	expected, err := MAC(key, pkt)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, pkt.Header.MAC) {
+		return fmt.Errorf("invalid MAC")
+	}
+	return nil
+}
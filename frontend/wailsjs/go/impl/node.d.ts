@@ -2,10 +2,10 @@
 // This file is automatically generated. DO NOT EDIT
 import {types} from '../models';
 import {transport} from '../models';
+import {time} from '../models';
 import {peer} from '../models';
 import {regexp} from '../models';
 import {sync} from '../models';
-import {time} from '../models';
 import {io} from '../models';
 
 export function AckMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
@@ -14,6 +14,10 @@ export function AckTicker(arg1:transport.Header,arg2:transport.Message):Promise<
 
 export function AddBlockAndName(arg1:types.BlockchainBlock):Promise<void>;
 
+export function AddDatabaseProperty(arg1:string,arg2:types.DatabaseProperty):Promise<string>;
+
+export function AddDatabaseRow(arg1:string,arg2:string):Promise<string>;
+
 export function AddNewDocument(arg1:string):Promise<void>;
 
 export function AddPeer(arg1:Array<string>):Promise<void>;
@@ -22,14 +26,30 @@ export function AddRequest(arg1:string):Promise<boolean>;
 
 export function AddSinglePeer(arg1:string):Promise<void>;
 
+export function AnchorDocument(arg1:string):Promise<types.AuditAnchor>;
+
 export function AntiEntropyTicker():Promise<void>;
 
 export function ApplyOperation(arg1:types.CRDTOperation):Promise<void>;
 
+export function ArchiveDocument(arg1:string):Promise<void>;
+
+export function AuditAnchorTicker():Promise<void>;
+
+export function BootstrapDocument(arg1:string,arg2:string,arg3:time.Duration):Promise<void>;
+
 export function Broadcast(arg1:transport.Message):Promise<void>;
 
 export function CRDTOperationsMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
+export function CRDTSnapshotReplyMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
+export function CRDTSnapshotRequestMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
+export function CRDTSyncRequestMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
+export function CRDTVersionMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
 export function CastAndSetOperation(arg1:types.CRDTOperation,arg2:types.CRDTOp):Promise<void>;
 
 export function CastAndSetProps(arg1:types.BlockTypeName,arg2:any):Promise<types.BlockType>;
@@ -38,10 +58,24 @@ export function CastOperation(arg1:types.CRDTOperation):Promise<void>;
 
 export function ChatMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
+export function CheckDocument(arg1:string):Promise<types.IntegrityReport>;
+
+export function ClearQuarantine():Promise<void>;
+
+export function CommitTransaction(arg1:types.CRDTOperationsMessage):Promise<types.TransactionResult>;
+
+export function CompactDocument(arg1:string):Promise<types.CompactionStats>;
+
+export function CompactionTicker():Promise<void>;
+
 export function CompileDocument(arg1:string):Promise<string>;
 
 export function CreateBudgetMap(arg1:number,arg2:number):Promise<{[key: number]: number}>;
 
+export function CreateDatabase(arg1:string):Promise<string>;
+
+export function CreateSubpage(arg1:string,arg2:string):Promise<string>;
+
 export function DataReplyMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
 export function DataRequestMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
@@ -50,68 +84,138 @@ export function DeleteAck(arg1:string):Promise<void>;
 
 export function DeleteDataReplyChan(arg1:string):Promise<void>;
 
+export function DeleteDocument(arg1:string):Promise<void>;
+
+export function DeleteDocumentSearchReplyChan(arg1:string):Promise<void>;
+
 export function DeleteSearchReplyChan(arg1:string):Promise<void>;
 
+export function DeleteSnapshotReplyChan(arg1:string):Promise<void>;
+
+export function DocumentKeyMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
+export function DocumentSearchReplyMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
+export function DocumentSearchRequestMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
 export function Download(arg1:string):Promise<Array<number>>;
 
 export function DownloadElement(arg1:string):Promise<Array<number>>;
 
 export function EmptyMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
+export function EncryptDocument(arg1:string):Promise<void>;
+
+export function EncryptedCRDTOperationsMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
 export function ExpandRing(arg1:peer.ExpandingRing,arg2:string,arg3:regexp.Regexp,arg4:number,arg5:sync.WaitGroup,arg6:any):Promise<void>;
 
+export function ExportAuditReport(arg1:string):Promise<types.AuditReport>;
+
 export function ExportCRDTAddBlock(arg1:types.CRDTAddBlock):Promise<void>;
 
 export function ExportCRDTAddMark(arg1:types.CRDTAddMark):Promise<void>;
 
 export function ExportCRDTDeleteChar(arg1:types.CRDTDeleteChar):Promise<void>;
 
+export function ExportCRDTDeleteRange(arg1:types.CRDTDeleteRange):Promise<void>;
+
 export function ExportCRDTInsertChar(arg1:types.CRDTInsertChar):Promise<void>;
 
+export function ExportCRDTInsertText(arg1:types.CRDTInsertText):Promise<void>;
+
 export function ExportCRDTRemoveBlock(arg1:types.CRDTRemoveBlock):Promise<void>;
 
 export function ExportCRDTRemoveMark(arg1:types.CRDTRemoveMark):Promise<void>;
 
 export function ExportCRDTUpdateBlock(arg1:types.CRDTUpdateBlock):Promise<void>;
 
+export function ExportDocumentLog(arg1:string):Promise<types.DocumentSnapshot>;
+
+export function FindInDocuments(arg1:types.FindOptions):Promise<Array<types.TextMatch>>;
+
+export function ForwardDocumentSearchRequest(arg1:number,arg2:string,arg3:types.DocumentSearchRequestMessage):Promise<void>;
+
 export function ForwardSearchRequest(arg1:number,arg2:string,arg3:regexp.Regexp,arg4:types.SearchRequestMessage):Promise<void>;
 
 export function GetAck(arg1:string):Promise<any|boolean>;
 
 export function GetAddress():Promise<string>;
 
+export function GetAuditLog(arg1:string):Promise<Array<types.AuditBatch>>;
+
+export function GetBacklinks(arg1:string):Promise<Array<types.Backlink>>;
+
 export function GetBlockOps(arg1:string,arg2:string):Promise<Array<types.CRDTOperation>>;
 
+export function GetBlockSnapshot(arg1:string,arg2:string):Promise<types.BlockSnapshot>;
+
 export function GetCRDTState(arg1:string):Promise<number>;
 
 export function GetCatalog():Promise<peer.Catalog>;
 
+export function GetCompactionStats(arg1:string):Promise<types.CompactionStats>;
+
+export function GetDatabaseSchema(arg1:string):Promise<Array<types.DatabaseProperty>>;
+
+export function GetDocumentACL(arg1:string):Promise<types.DocumentACL>;
+
+export function GetDocumentBlocks(arg1:string):Promise<Array<types.DocumentBlock>>;
+
 export function GetDocumentList():Promise<Array<string>>;
 
+export function GetDocumentMetadata(arg1:string):Promise<types.DocumentSummary>;
+
 export function GetDocumentOps(arg1:string):Promise<{[key: string]: Array<types.CRDTOperation>}>;
 
+export function GetDocumentSummaries():Promise<Array<types.DocumentSummary>>;
+
+export function GetDocumentSummariesByStatus(arg1:string):Promise<Array<types.DocumentSummary>>;
+
 export function GetEditor():Promise<peer.Editor>;
 
 export function GetFileInfo(arg1:string,arg2:string):Promise<types.FileInfo>;
 
+export function GetIdentity():Promise<string>;
+
+export function GetInvite():Promise<string>;
+
+export function GetMembershipLog(arg1:string):Promise<Array<types.MembershipEntry>>;
+
 export function GetNeighbors(arg1:Array<string>):Promise<Array<string>>;
 
+export function GetPageTree():Promise<Array<types.PageNode>>;
+
+export function GetQuarantinedOperations():Promise<Array<types.QuarantinedOperation>>;
+
 export function GetRandNeighsFromBudget(arg1:number,arg2:Array<string>):Promise<Array<string>>;
 
 export function GetRandomNeighborFromRoutingTable(arg1:string):Promise<string>;
 
 export function GetRandomPeerFromCatalog(arg1:string):Promise<string>;
 
+export function GetRateLimitStats():Promise<Array<types.RateLimitStats>>;
+
+export function GetRejectedRumors():Promise<Array<types.RejectedRumor>>;
+
 export function GetRoutingTable():Promise<peer.RoutingTable>;
 
-export function GetTmpID(arg1:number):Promise<number>;
+export function GetVersionVector(arg1:string):Promise<types.VersionVector>;
 
 export function HeartbeatTicker():Promise<void>;
 
 export function HexEncode(arg1:Array<number>):Promise<string>;
 
+export function IsDocumentEncrypted(arg1:string):Promise<boolean>;
+
+export function IsReadOnly():Promise<boolean>;
+
+export function JoinWorkspace(arg1:string):Promise<void>;
+
 export function Listen():Promise<void>;
 
+export function MovePage(arg1:string,arg2:string,arg3:number):Promise<void>;
+
 export function PaxosAcceptMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
 export function PaxosLoop(arg1:string,arg2:string):Promise<void>;
@@ -128,34 +232,70 @@ export function ProcessMsg(arg1:transport.Packet):Promise<void>;
 
 export function ProcessRumor(arg1:types.Rumor,arg2:transport.Packet):Promise<void>;
 
+export function PurgeExpiredDocuments():Promise<Array<string>>;
+
+export function PurgeTicker():Promise<void>;
+
+export function QueryDatabase(arg1:string,arg2:types.DatabaseQuery):Promise<Array<types.DatabaseRow>>;
+
 export function RelayMsg(arg1:transport.Packet):Promise<void>;
 
+export function ReliableAckMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
+export function ReliableMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
+
 export function RemoteDownload(arg1:string):Promise<Array<number>>;
 
+export function RemoveDatabaseProperty(arg1:string,arg2:string):Promise<void>;
+
+export function RemoveDocumentMember(arg1:string,arg2:string):Promise<void>;
+
 export function RemovePeerFromCatalog(arg1:string,arg2:string):Promise<void>;
 
+export function RepairDocument(arg1:string,arg2:types.RepairOptions,arg3:time.Duration):Promise<types.IntegrityReport>;
+
+export function ReplaceInDocuments(arg1:types.FindOptions,arg2:string):Promise<number>;
+
 export function Resolve(arg1:string):Promise<string>;
 
+export function ResolveIdentity(arg1:string):Promise<string|boolean>;
+
+export function RestoreDocument(arg1:string):Promise<void>;
+
+export function RevokeDocumentAccess(arg1:string,arg2:string):Promise<void>;
+
 export function RumorsMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
 export function SaveTransactions(arg1:types.CRDTOperationsMessage):Promise<void>;
 
 export function SearchAll(arg1:regexp.Regexp,arg2:number,arg3:time.Duration):Promise<Array<string>>;
 
+export function SearchDocuments(arg1:string,arg2:number):Promise<Array<types.SearchHit>>;
+
 export function SearchFirst(arg1:regexp.Regexp,arg2:peer.ExpandingRing):Promise<string>;
 
 export function SearchFirstLocal(arg1:regexp.Regexp):Promise<string>;
 
 export function SearchMatch(arg1:regexp.Regexp):Promise<Array<string>>;
 
+export function SearchNetwork(arg1:string,arg2:peer.ExpandingRing):Promise<Array<types.DocumentSearchResult>>;
+
 export function SearchReplyMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
 export function SearchRequestMessageCallback(arg1:types.Message,arg2:transport.Packet):Promise<void>;
 
 export function SendAckMessage(arg1:transport.Packet):Promise<void>;
 
+export function SendCRDTSnapshotRequestMessage(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function SendCRDTSyncRequestMessage(arg1:string,arg2:string,arg3:types.VersionVector):Promise<void>;
+
+export function SendCRDTVersionMessages():Promise<void>;
+
 export function SendDataRequestMessage(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function SendDocumentSearchRequestMessage(arg1:string,arg2:string,arg3:number,arg4:string,arg5:string):Promise<void>;
+
 export function SendHeartbeat():Promise<void>;
 
 export function SendMsg(arg1:string,arg2:types.Message):Promise<void>;
@@ -174,10 +314,26 @@ export function SetAck(arg1:string,arg2:any):Promise<void>;
 
 export function SetDataReplyChan(arg1:string,arg2:any):Promise<void>;
 
+export function SetDatabaseCell(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function SetDatabaseProperty(arg1:string,arg2:types.DatabaseProperty):Promise<void>;
+
+export function SetDocumentMetadata(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function SetDocumentPrivate(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetDocumentSearchReplyChan(arg1:string,arg2:any):Promise<void>;
+
 export function SetRoutingEntry(arg1:string,arg2:string):Promise<void>;
 
 export function SetSearchReplyChan(arg1:string,arg2:any):Promise<void>;
 
+export function SetSnapshotReplyChan(arg1:string,arg2:any):Promise<void>;
+
+export function ShareDocument(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function ShareDocumentKey(arg1:string,arg2:Array<string>):Promise<void>;
+
 export function SplitMetafile(arg1:Array<number>):Promise<Array<string>>;
 
 export function Start():Promise<void>;
@@ -203,3 +359,7 @@ export function UpdateCatalog(arg1:string,arg2:string):Promise<void>;
 export function UpdateEditor(arg1:Array<types.CRDTOperation>):Promise<void>;
 
 export function Upload(arg1:io.Reader):Promise<string>;
+
+export function VerifyAuditLog(arg1:Array<types.AuditBatch>):Promise<void>;
+
+export function VerifyMembershipLog(arg1:Array<types.MembershipEntry>):Promise<void>;
@@ -14,6 +14,14 @@ export function AddBlockAndName(arg1) {
   return window['go']['impl']['node']['AddBlockAndName'](arg1);
 }
 
+export function AddDatabaseProperty(arg1, arg2) {
+  return window['go']['impl']['node']['AddDatabaseProperty'](arg1, arg2);
+}
+
+export function AddDatabaseRow(arg1, arg2) {
+  return window['go']['impl']['node']['AddDatabaseRow'](arg1, arg2);
+}
+
 export function AddNewDocument(arg1) {
   return window['go']['impl']['node']['AddNewDocument'](arg1);
 }
@@ -30,6 +38,10 @@ export function AddSinglePeer(arg1) {
   return window['go']['impl']['node']['AddSinglePeer'](arg1);
 }
 
+export function AnchorDocument(arg1) {
+  return window['go']['impl']['node']['AnchorDocument'](arg1);
+}
+
 export function AntiEntropyTicker() {
   return window['go']['impl']['node']['AntiEntropyTicker']();
 }
@@ -38,6 +50,18 @@ export function ApplyOperation(arg1) {
   return window['go']['impl']['node']['ApplyOperation'](arg1);
 }
 
+export function ArchiveDocument(arg1) {
+  return window['go']['impl']['node']['ArchiveDocument'](arg1);
+}
+
+export function AuditAnchorTicker() {
+  return window['go']['impl']['node']['AuditAnchorTicker']();
+}
+
+export function BootstrapDocument(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['BootstrapDocument'](arg1, arg2, arg3);
+}
+
 export function Broadcast(arg1) {
   return window['go']['impl']['node']['Broadcast'](arg1);
 }
@@ -46,6 +70,22 @@ export function CRDTOperationsMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['CRDTOperationsMessageCallback'](arg1, arg2);
 }
 
+export function CRDTSnapshotReplyMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['CRDTSnapshotReplyMessageCallback'](arg1, arg2);
+}
+
+export function CRDTSnapshotRequestMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['CRDTSnapshotRequestMessageCallback'](arg1, arg2);
+}
+
+export function CRDTSyncRequestMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['CRDTSyncRequestMessageCallback'](arg1, arg2);
+}
+
+export function CRDTVersionMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['CRDTVersionMessageCallback'](arg1, arg2);
+}
+
 export function CastAndSetOperation(arg1, arg2) {
   return window['go']['impl']['node']['CastAndSetOperation'](arg1, arg2);
 }
@@ -62,6 +102,26 @@ export function ChatMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['ChatMessageCallback'](arg1, arg2);
 }
 
+export function CheckDocument(arg1) {
+  return window['go']['impl']['node']['CheckDocument'](arg1);
+}
+
+export function ClearQuarantine() {
+  return window['go']['impl']['node']['ClearQuarantine']();
+}
+
+export function CommitTransaction(arg1) {
+  return window['go']['impl']['node']['CommitTransaction'](arg1);
+}
+
+export function CompactDocument(arg1) {
+  return window['go']['impl']['node']['CompactDocument'](arg1);
+}
+
+export function CompactionTicker() {
+  return window['go']['impl']['node']['CompactionTicker']();
+}
+
 export function CompileDocument(arg1) {
   return window['go']['impl']['node']['CompileDocument'](arg1);
 }
@@ -70,6 +130,14 @@ export function CreateBudgetMap(arg1, arg2) {
   return window['go']['impl']['node']['CreateBudgetMap'](arg1, arg2);
 }
 
+export function CreateDatabase(arg1) {
+  return window['go']['impl']['node']['CreateDatabase'](arg1);
+}
+
+export function CreateSubpage(arg1, arg2) {
+  return window['go']['impl']['node']['CreateSubpage'](arg1, arg2);
+}
+
 export function DataReplyMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['DataReplyMessageCallback'](arg1, arg2);
 }
@@ -86,10 +154,34 @@ export function DeleteDataReplyChan(arg1) {
   return window['go']['impl']['node']['DeleteDataReplyChan'](arg1);
 }
 
+export function DeleteDocument(arg1) {
+  return window['go']['impl']['node']['DeleteDocument'](arg1);
+}
+
+export function DeleteDocumentSearchReplyChan(arg1) {
+  return window['go']['impl']['node']['DeleteDocumentSearchReplyChan'](arg1);
+}
+
 export function DeleteSearchReplyChan(arg1) {
   return window['go']['impl']['node']['DeleteSearchReplyChan'](arg1);
 }
 
+export function DeleteSnapshotReplyChan(arg1) {
+  return window['go']['impl']['node']['DeleteSnapshotReplyChan'](arg1);
+}
+
+export function DocumentKeyMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['DocumentKeyMessageCallback'](arg1, arg2);
+}
+
+export function DocumentSearchReplyMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['DocumentSearchReplyMessageCallback'](arg1, arg2);
+}
+
+export function DocumentSearchRequestMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['DocumentSearchRequestMessageCallback'](arg1, arg2);
+}
+
 export function Download(arg1) {
   return window['go']['impl']['node']['Download'](arg1);
 }
@@ -102,10 +194,22 @@ export function EmptyMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['EmptyMessageCallback'](arg1, arg2);
 }
 
+export function EncryptDocument(arg1) {
+  return window['go']['impl']['node']['EncryptDocument'](arg1);
+}
+
+export function EncryptedCRDTOperationsMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['EncryptedCRDTOperationsMessageCallback'](arg1, arg2);
+}
+
 export function ExpandRing(arg1, arg2, arg3, arg4, arg5, arg6) {
   return window['go']['impl']['node']['ExpandRing'](arg1, arg2, arg3, arg4, arg5, arg6);
 }
 
+export function ExportAuditReport(arg1) {
+  return window['go']['impl']['node']['ExportAuditReport'](arg1);
+}
+
 export function ExportCRDTAddBlock(arg1) {
   return window['go']['impl']['node']['ExportCRDTAddBlock'](arg1);
 }
@@ -118,10 +222,18 @@ export function ExportCRDTDeleteChar(arg1) {
   return window['go']['impl']['node']['ExportCRDTDeleteChar'](arg1);
 }
 
+export function ExportCRDTDeleteRange(arg1) {
+  return window['go']['impl']['node']['ExportCRDTDeleteRange'](arg1);
+}
+
 export function ExportCRDTInsertChar(arg1) {
   return window['go']['impl']['node']['ExportCRDTInsertChar'](arg1);
 }
 
+export function ExportCRDTInsertText(arg1) {
+  return window['go']['impl']['node']['ExportCRDTInsertText'](arg1);
+}
+
 export function ExportCRDTRemoveBlock(arg1) {
   return window['go']['impl']['node']['ExportCRDTRemoveBlock'](arg1);
 }
@@ -134,6 +246,18 @@ export function ExportCRDTUpdateBlock(arg1) {
   return window['go']['impl']['node']['ExportCRDTUpdateBlock'](arg1);
 }
 
+export function ExportDocumentLog(arg1) {
+  return window['go']['impl']['node']['ExportDocumentLog'](arg1);
+}
+
+export function FindInDocuments(arg1) {
+  return window['go']['impl']['node']['FindInDocuments'](arg1);
+}
+
+export function ForwardDocumentSearchRequest(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['ForwardDocumentSearchRequest'](arg1, arg2, arg3);
+}
+
 export function ForwardSearchRequest(arg1, arg2, arg3, arg4) {
   return window['go']['impl']['node']['ForwardSearchRequest'](arg1, arg2, arg3, arg4);
 }
@@ -146,10 +270,22 @@ export function GetAddress() {
   return window['go']['impl']['node']['GetAddress']();
 }
 
+export function GetAuditLog(arg1) {
+  return window['go']['impl']['node']['GetAuditLog'](arg1);
+}
+
+export function GetBacklinks(arg1) {
+  return window['go']['impl']['node']['GetBacklinks'](arg1);
+}
+
 export function GetBlockOps(arg1, arg2) {
   return window['go']['impl']['node']['GetBlockOps'](arg1, arg2);
 }
 
+export function GetBlockSnapshot(arg1, arg2) {
+  return window['go']['impl']['node']['GetBlockSnapshot'](arg1, arg2);
+}
+
 export function GetCRDTState(arg1) {
   return window['go']['impl']['node']['GetCRDTState'](arg1);
 }
@@ -158,14 +294,42 @@ export function GetCatalog() {
   return window['go']['impl']['node']['GetCatalog']();
 }
 
+export function GetCompactionStats(arg1) {
+  return window['go']['impl']['node']['GetCompactionStats'](arg1);
+}
+
+export function GetDatabaseSchema(arg1) {
+  return window['go']['impl']['node']['GetDatabaseSchema'](arg1);
+}
+
+export function GetDocumentACL(arg1) {
+  return window['go']['impl']['node']['GetDocumentACL'](arg1);
+}
+
+export function GetDocumentBlocks(arg1) {
+  return window['go']['impl']['node']['GetDocumentBlocks'](arg1);
+}
+
 export function GetDocumentList() {
   return window['go']['impl']['node']['GetDocumentList']();
 }
 
+export function GetDocumentMetadata(arg1) {
+  return window['go']['impl']['node']['GetDocumentMetadata'](arg1);
+}
+
 export function GetDocumentOps(arg1) {
   return window['go']['impl']['node']['GetDocumentOps'](arg1);
 }
 
+export function GetDocumentSummaries() {
+  return window['go']['impl']['node']['GetDocumentSummaries']();
+}
+
+export function GetDocumentSummariesByStatus(arg1) {
+  return window['go']['impl']['node']['GetDocumentSummariesByStatus'](arg1);
+}
+
 export function GetEditor() {
   return window['go']['impl']['node']['GetEditor']();
 }
@@ -174,10 +338,30 @@ export function GetFileInfo(arg1, arg2) {
   return window['go']['impl']['node']['GetFileInfo'](arg1, arg2);
 }
 
+export function GetIdentity() {
+  return window['go']['impl']['node']['GetIdentity']();
+}
+
+export function GetInvite() {
+  return window['go']['impl']['node']['GetInvite']();
+}
+
+export function GetMembershipLog(arg1) {
+  return window['go']['impl']['node']['GetMembershipLog'](arg1);
+}
+
 export function GetNeighbors(arg1) {
   return window['go']['impl']['node']['GetNeighbors'](arg1);
 }
 
+export function GetPageTree() {
+  return window['go']['impl']['node']['GetPageTree']();
+}
+
+export function GetQuarantinedOperations() {
+  return window['go']['impl']['node']['GetQuarantinedOperations']();
+}
+
 export function GetRandNeighsFromBudget(arg1, arg2) {
   return window['go']['impl']['node']['GetRandNeighsFromBudget'](arg1, arg2);
 }
@@ -190,12 +374,20 @@ export function GetRandomPeerFromCatalog(arg1) {
   return window['go']['impl']['node']['GetRandomPeerFromCatalog'](arg1);
 }
 
+export function GetRateLimitStats() {
+  return window['go']['impl']['node']['GetRateLimitStats']();
+}
+
+export function GetRejectedRumors() {
+  return window['go']['impl']['node']['GetRejectedRumors']();
+}
+
 export function GetRoutingTable() {
   return window['go']['impl']['node']['GetRoutingTable']();
 }
 
-export function GetTmpID(arg1) {
-  return window['go']['impl']['node']['GetTmpID'](arg1);
+export function GetVersionVector(arg1) {
+  return window['go']['impl']['node']['GetVersionVector'](arg1);
 }
 
 export function HeartbeatTicker() {
@@ -206,10 +398,26 @@ export function HexEncode(arg1) {
   return window['go']['impl']['node']['HexEncode'](arg1);
 }
 
+export function IsDocumentEncrypted(arg1) {
+  return window['go']['impl']['node']['IsDocumentEncrypted'](arg1);
+}
+
+export function IsReadOnly() {
+  return window['go']['impl']['node']['IsReadOnly']();
+}
+
+export function JoinWorkspace(arg1) {
+  return window['go']['impl']['node']['JoinWorkspace'](arg1);
+}
+
 export function Listen() {
   return window['go']['impl']['node']['Listen']();
 }
 
+export function MovePage(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['MovePage'](arg1, arg2, arg3);
+}
+
 export function PaxosAcceptMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['PaxosAcceptMessageCallback'](arg1, arg2);
 }
@@ -242,22 +450,70 @@ export function ProcessRumor(arg1, arg2) {
   return window['go']['impl']['node']['ProcessRumor'](arg1, arg2);
 }
 
+export function PurgeExpiredDocuments() {
+  return window['go']['impl']['node']['PurgeExpiredDocuments']();
+}
+
+export function PurgeTicker() {
+  return window['go']['impl']['node']['PurgeTicker']();
+}
+
+export function QueryDatabase(arg1, arg2) {
+  return window['go']['impl']['node']['QueryDatabase'](arg1, arg2);
+}
+
 export function RelayMsg(arg1) {
   return window['go']['impl']['node']['RelayMsg'](arg1);
 }
 
+export function ReliableAckMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['ReliableAckMessageCallback'](arg1, arg2);
+}
+
+export function ReliableMessageCallback(arg1, arg2) {
+  return window['go']['impl']['node']['ReliableMessageCallback'](arg1, arg2);
+}
+
 export function RemoteDownload(arg1) {
   return window['go']['impl']['node']['RemoteDownload'](arg1);
 }
 
+export function RemoveDatabaseProperty(arg1, arg2) {
+  return window['go']['impl']['node']['RemoveDatabaseProperty'](arg1, arg2);
+}
+
+export function RemoveDocumentMember(arg1, arg2) {
+  return window['go']['impl']['node']['RemoveDocumentMember'](arg1, arg2);
+}
+
 export function RemovePeerFromCatalog(arg1, arg2) {
   return window['go']['impl']['node']['RemovePeerFromCatalog'](arg1, arg2);
 }
 
+export function RepairDocument(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['RepairDocument'](arg1, arg2, arg3);
+}
+
+export function ReplaceInDocuments(arg1, arg2) {
+  return window['go']['impl']['node']['ReplaceInDocuments'](arg1, arg2);
+}
+
 export function Resolve(arg1) {
   return window['go']['impl']['node']['Resolve'](arg1);
 }
 
+export function ResolveIdentity(arg1) {
+  return window['go']['impl']['node']['ResolveIdentity'](arg1);
+}
+
+export function RestoreDocument(arg1) {
+  return window['go']['impl']['node']['RestoreDocument'](arg1);
+}
+
+export function RevokeDocumentAccess(arg1, arg2) {
+  return window['go']['impl']['node']['RevokeDocumentAccess'](arg1, arg2);
+}
+
 export function RumorsMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['RumorsMessageCallback'](arg1, arg2);
 }
@@ -270,6 +526,10 @@ export function SearchAll(arg1, arg2, arg3) {
   return window['go']['impl']['node']['SearchAll'](arg1, arg2, arg3);
 }
 
+export function SearchDocuments(arg1, arg2) {
+  return window['go']['impl']['node']['SearchDocuments'](arg1, arg2);
+}
+
 export function SearchFirst(arg1, arg2) {
   return window['go']['impl']['node']['SearchFirst'](arg1, arg2);
 }
@@ -282,6 +542,10 @@ export function SearchMatch(arg1) {
   return window['go']['impl']['node']['SearchMatch'](arg1);
 }
 
+export function SearchNetwork(arg1, arg2) {
+  return window['go']['impl']['node']['SearchNetwork'](arg1, arg2);
+}
+
 export function SearchReplyMessageCallback(arg1, arg2) {
   return window['go']['impl']['node']['SearchReplyMessageCallback'](arg1, arg2);
 }
@@ -294,10 +558,26 @@ export function SendAckMessage(arg1) {
   return window['go']['impl']['node']['SendAckMessage'](arg1);
 }
 
+export function SendCRDTSnapshotRequestMessage(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['SendCRDTSnapshotRequestMessage'](arg1, arg2, arg3);
+}
+
+export function SendCRDTSyncRequestMessage(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['SendCRDTSyncRequestMessage'](arg1, arg2, arg3);
+}
+
+export function SendCRDTVersionMessages() {
+  return window['go']['impl']['node']['SendCRDTVersionMessages']();
+}
+
 export function SendDataRequestMessage(arg1, arg2, arg3) {
   return window['go']['impl']['node']['SendDataRequestMessage'](arg1, arg2, arg3);
 }
 
+export function SendDocumentSearchRequestMessage(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['impl']['node']['SendDocumentSearchRequestMessage'](arg1, arg2, arg3, arg4, arg5);
+}
+
 export function SendHeartbeat() {
   return window['go']['impl']['node']['SendHeartbeat']();
 }
@@ -334,6 +614,26 @@ export function SetDataReplyChan(arg1, arg2) {
   return window['go']['impl']['node']['SetDataReplyChan'](arg1, arg2);
 }
 
+export function SetDatabaseCell(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['SetDatabaseCell'](arg1, arg2, arg3);
+}
+
+export function SetDatabaseProperty(arg1, arg2) {
+  return window['go']['impl']['node']['SetDatabaseProperty'](arg1, arg2);
+}
+
+export function SetDocumentMetadata(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['SetDocumentMetadata'](arg1, arg2, arg3);
+}
+
+export function SetDocumentPrivate(arg1, arg2) {
+  return window['go']['impl']['node']['SetDocumentPrivate'](arg1, arg2);
+}
+
+export function SetDocumentSearchReplyChan(arg1, arg2) {
+  return window['go']['impl']['node']['SetDocumentSearchReplyChan'](arg1, arg2);
+}
+
 export function SetRoutingEntry(arg1, arg2) {
   return window['go']['impl']['node']['SetRoutingEntry'](arg1, arg2);
 }
@@ -342,6 +642,18 @@ export function SetSearchReplyChan(arg1, arg2) {
   return window['go']['impl']['node']['SetSearchReplyChan'](arg1, arg2);
 }
 
+export function SetSnapshotReplyChan(arg1, arg2) {
+  return window['go']['impl']['node']['SetSnapshotReplyChan'](arg1, arg2);
+}
+
+export function ShareDocument(arg1, arg2, arg3) {
+  return window['go']['impl']['node']['ShareDocument'](arg1, arg2, arg3);
+}
+
+export function ShareDocumentKey(arg1, arg2) {
+  return window['go']['impl']['node']['ShareDocumentKey'](arg1, arg2);
+}
+
 export function SplitMetafile(arg1) {
   return window['go']['impl']['node']['SplitMetafile'](arg1);
 }
@@ -393,3 +705,11 @@ export function UpdateEditor(arg1) {
 export function Upload(arg1) {
   return window['go']['impl']['node']['Upload'](arg1);
 }
+
+export function VerifyAuditLog(arg1) {
+  return window['go']['impl']['node']['VerifyAuditLog'](arg1);
+}
+
+export function VerifyMembershipLog(arg1) {
+  return window['go']['impl']['node']['VerifyMembershipLog'](arg1);
+}
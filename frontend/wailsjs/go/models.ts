@@ -63,6 +63,8 @@ export namespace transport {
 	    Source: string;
 	    RelayedBy: string;
 	    Destination: string;
+	    MAC: number[];
+	    Counter: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new Header(source);
@@ -75,6 +77,8 @@ export namespace transport {
 	        this.Source = source["Source"];
 	        this.RelayedBy = source["RelayedBy"];
 	        this.Destination = source["Destination"];
+	        this.MAC = source["MAC"];
+	        this.Counter = source["Counter"];
 	    }
 	}
 	export class Message {
@@ -128,6 +132,305 @@ export namespace transport {
 
 export namespace types {
 	
+	export class AuditAnchor {
+	    DocumentID: string;
+	    Name: string;
+	    StateHash: number[];
+	    Timestamp: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditAnchor(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Name = source["Name"];
+	        this.StateHash = source["StateHash"];
+	        this.Timestamp = source["Timestamp"];
+	    }
+	}
+	export class AuditAuthor {
+	    Author: string;
+	    Batches: number;
+	    Operations: number;
+	    Head: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditAuthor(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Author = source["Author"];
+	        this.Batches = source["Batches"];
+	        this.Operations = source["Operations"];
+	        this.Head = source["Head"];
+	    }
+	}
+	export class AuditLink {
+	    Prev: number[];
+	    First: number;
+	    Size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditLink(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Prev = source["Prev"];
+	        this.First = source["First"];
+	        this.Size = source["Size"];
+	    }
+	}
+	export class CRDTOperation {
+	    Type: string;
+	    Origin: string;
+	    OperationID: number;
+	    DocumentID: string;
+	    BlockID: string;
+	    Operation: any;
+	    Signature: number[];
+	    Original?: CRDTOperation;
+	    Audit?: AuditLink;
+	
+	    static createFrom(source: any = {}) {
+	        return new CRDTOperation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Type = source["Type"];
+	        this.Origin = source["Origin"];
+	        this.OperationID = source["OperationID"];
+	        this.DocumentID = source["DocumentID"];
+	        this.BlockID = source["BlockID"];
+	        this.Operation = source["Operation"];
+	        this.Signature = source["Signature"];
+	        this.Original = this.convertValues(source["Original"], CRDTOperation);
+	        this.Audit = this.convertValues(source["Audit"], AuditLink);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuditBatch {
+	    DocumentID: string;
+	    Author: string;
+	    Index: number;
+	    PrevHash: number[];
+	    Size: number;
+	    Operations: CRDTOperation[];
+	    Hash: number[];
+	    ReceivedAt: number;
+	    Pruned: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditBatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Author = source["Author"];
+	        this.Index = source["Index"];
+	        this.PrevHash = source["PrevHash"];
+	        this.Size = source["Size"];
+	        this.Operations = this.convertValues(source["Operations"], CRDTOperation);
+	        this.Hash = source["Hash"];
+	        this.ReceivedAt = source["ReceivedAt"];
+	        this.Pruned = source["Pruned"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class AuditReport {
+	    DocumentID: string;
+	    GeneratedAt: number;
+	    StateHash: number[];
+	    Authors: AuditAuthor[];
+	    Batches: AuditBatch[];
+	    Anchors: AuditAnchor[];
+	    Problems: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.GeneratedAt = source["GeneratedAt"];
+	        this.StateHash = source["StateHash"];
+	        this.Authors = this.convertValues(source["Authors"], AuditAuthor);
+	        this.Batches = this.convertValues(source["Batches"], AuditBatch);
+	        this.Anchors = this.convertValues(source["Anchors"], AuditAnchor);
+	        this.Problems = source["Problems"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Backlink {
+	    SourceDocument: string;
+	    SourceBlock: string;
+	    TargetBlock: string;
+	    Type: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Backlink(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.SourceDocument = source["SourceDocument"];
+	        this.SourceBlock = source["SourceBlock"];
+	        this.TargetBlock = source["TargetBlock"];
+	        this.Type = source["Type"];
+	    }
+	}
+	export class TextStyle {
+	    Bold: boolean;
+	    Italic: boolean;
+	    Underline: boolean;
+	    Strikethrough: boolean;
+	    TextColor: string;
+	    BackgroundColor: string;
+	    MentionDocument: string;
+	    MentionBlock: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TextStyle(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Bold = source["Bold"];
+	        this.Italic = source["Italic"];
+	        this.Underline = source["Underline"];
+	        this.Strikethrough = source["Strikethrough"];
+	        this.TextColor = source["TextColor"];
+	        this.BackgroundColor = source["BackgroundColor"];
+	        this.MentionDocument = source["MentionDocument"];
+	        this.MentionBlock = source["MentionBlock"];
+	    }
+	}
+	export class SnapshotRun {
+	    StartID: string;
+	    Text: string;
+	    Styles: TextStyle;
+	    Deleted: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SnapshotRun(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.StartID = source["StartID"];
+	        this.Text = source["Text"];
+	        this.Styles = this.convertValues(source["Styles"], TextStyle);
+	        this.Deleted = source["Deleted"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BlockSnapshot {
+	    Runs: SnapshotRun[];
+	    Anchors: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new BlockSnapshot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Runs = this.convertValues(source["Runs"], SnapshotRun);
+	        this.Anchors = source["Anchors"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class PaxosValue {
 	    Filename: string;
 	    Metahash: string;
@@ -183,6 +486,8 @@ export namespace types {
 	    TextColor: string;
 	    TextAlignment: string;
 	    Level: number;
+	    SourceDocument: string;
+	    SourceBlock: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new DefaultBlockProps(source);
@@ -194,6 +499,8 @@ export namespace types {
 	        this.TextColor = source["TextColor"];
 	        this.TextAlignment = source["TextAlignment"];
 	        this.Level = source["Level"];
+	        this.SourceDocument = source["SourceDocument"];
+	        this.SourceBlock = source["SourceBlock"];
 	    }
 	}
 	export class CRDTAddBlock {
@@ -239,6 +546,8 @@ export namespace types {
 	export class MarkOptions {
 	    Color: string;
 	    Href: string;
+	    DocumentID: string;
+	    BlockID: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new MarkOptions(source);
@@ -248,6 +557,8 @@ export namespace types {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.Color = source["Color"];
 	        this.Href = source["Href"];
+	        this.DocumentID = source["DocumentID"];
+	        this.BlockID = source["BlockID"];
 	    }
 	}
 	export class MarkEnd {
@@ -334,6 +645,24 @@ export namespace types {
 	        this.RemovedID = source["RemovedID"];
 	    }
 	}
+	export class CRDTDeleteRange {
+	    CRDTOp: any;
+	    OpID: string;
+	    StartID: string;
+	    Length: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CRDTDeleteRange(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.CRDTOp = source["CRDTOp"];
+	        this.OpID = source["OpID"];
+	        this.StartID = source["StartID"];
+	        this.Length = source["Length"];
+	    }
+	}
 	export class CRDTInsertChar {
 	    CRDTOp: any;
 	    OpID: string;
@@ -352,28 +681,25 @@ export namespace types {
 	        this.Character = source["Character"];
 	    }
 	}
-	export class CRDTOperation {
-	    Type: string;
-	    Origin: string;
-	    OperationID: number;
-	    DocumentID: string;
-	    BlockID: string;
-	    Operation: any;
+	export class CRDTInsertText {
+	    CRDTOp: any;
+	    OpID: string;
+	    AfterID: string;
+	    Text: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new CRDTOperation(source);
+	        return new CRDTInsertText(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.Type = source["Type"];
-	        this.Origin = source["Origin"];
-	        this.OperationID = source["OperationID"];
-	        this.DocumentID = source["DocumentID"];
-	        this.BlockID = source["BlockID"];
-	        this.Operation = source["Operation"];
+	        this.CRDTOp = source["CRDTOp"];
+	        this.OpID = source["OpID"];
+	        this.AfterID = source["AfterID"];
+	        this.Text = source["Text"];
 	    }
 	}
+	
 	export class CRDTOperationsMessage {
 	    Operations: CRDTOperation[];
 	
@@ -498,29 +824,561 @@ export namespace types {
 		    return a;
 		}
 	}
-	
-	export class FileInfo {
-	    Name: string;
-	    Metahash: string;
-	    Chunks: number[][];
+	export class CompactionStats {
+	    DocumentID: string;
+	    OperationsBefore: number;
+	    OperationsAfter: number;
+	    TombstonesDropped: number;
+	    BlocksDropped: number;
+	    BytesBefore: number;
+	    BytesAfter: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new FileInfo(source);
+	        return new CompactionStats(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.Name = source["Name"];
-	        this.Metahash = source["Metahash"];
-	        this.Chunks = source["Chunks"];
+	        this.DocumentID = source["DocumentID"];
+	        this.OperationsBefore = source["OperationsBefore"];
+	        this.OperationsAfter = source["OperationsAfter"];
+	        this.TombstonesDropped = source["TombstonesDropped"];
+	        this.BlocksDropped = source["BlocksDropped"];
+	        this.BytesBefore = source["BytesBefore"];
+	        this.BytesAfter = source["BytesAfter"];
+	    }
+	}
+	export class DatabaseFilter {
+	    PropertyID: string;
+	    Operator: string;
+	    Value: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PropertyID = source["PropertyID"];
+	        this.Operator = source["Operator"];
+	        this.Value = source["Value"];
+	    }
+	}
+	export class DatabaseProperty {
+	    ID: string;
+	    Name: string;
+	    Type: string;
+	    Options: string[];
+	    RelationDatabase: string;
+	    Deleted: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseProperty(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.Name = source["Name"];
+	        this.Type = source["Type"];
+	        this.Options = source["Options"];
+	        this.RelationDatabase = source["RelationDatabase"];
+	        this.Deleted = source["Deleted"];
+	    }
+	}
+	export class DatabaseSort {
+	    PropertyID: string;
+	    Descending: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseSort(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PropertyID = source["PropertyID"];
+	        this.Descending = source["Descending"];
+	    }
+	}
+	export class DatabaseQuery {
+	    Filters: DatabaseFilter[];
+	    Sorts: DatabaseSort[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Filters = this.convertValues(source["Filters"], DatabaseFilter);
+	        this.Sorts = this.convertValues(source["Sorts"], DatabaseSort);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DatabaseRow {
+	    DocumentID: string;
+	    Title: string;
+	    Cells: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new DatabaseRow(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Title = source["Title"];
+	        this.Cells = source["Cells"];
+	    }
+	}
+	
+	
+	export class DocumentACL {
+	    DocumentID: string;
+	    Creator: string;
+	    Members: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new DocumentACL(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Creator = source["Creator"];
+	        this.Members = source["Members"];
+	    }
+	}
+	export class DocumentBlock {
+	    ID: string;
+	    BlockType: string;
+	    Props: DefaultBlockProps;
+	    Text: string;
+	    CharIDs: string[];
+	    Styles: TextStyle[];
+	    Children: DocumentBlock[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DocumentBlock(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.BlockType = source["BlockType"];
+	        this.Props = this.convertValues(source["Props"], DefaultBlockProps);
+	        this.Text = source["Text"];
+	        this.CharIDs = source["CharIDs"];
+	        this.Styles = this.convertValues(source["Styles"], TextStyle);
+	        this.Children = this.convertValues(source["Children"], DocumentBlock);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DocumentSearchRequestMessage {
+	    RequestID: string;
+	    Origin: string;
+	    Query: string;
+	    Budget: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DocumentSearchRequestMessage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.RequestID = source["RequestID"];
+	        this.Origin = source["Origin"];
+	        this.Query = source["Query"];
+	        this.Budget = source["Budget"];
+	    }
+	}
+	export class DocumentSearchResult {
+	    DocumentID: string;
+	    Title: string;
+	    Snippet: string;
+	    Score: number;
+	    Peers: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DocumentSearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Title = source["Title"];
+	        this.Snippet = source["Snippet"];
+	        this.Score = source["Score"];
+	        this.Peers = source["Peers"];
+	    }
+	}
+	export class DocumentSnapshot {
+	    DocumentID: string;
+	    VersionVector: {[key: string]: number};
+	    Operations: {[key: string]: CRDTOperation[]};
+	    Blocks: {[key: string]: BlockSnapshot};
+	    Compacted: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DocumentSnapshot(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.VersionVector = source["VersionVector"];
+	        this.Operations = source["Operations"];
+	        this.Blocks = this.convertValues(source["Blocks"], BlockSnapshot, true);
+	        this.Compacted = source["Compacted"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DocumentSummary {
+	    DocumentID: string;
+	    Title: string;
+	    Icon: string;
+	    Cover: string;
+	    CreatedBy: string;
+	    CreatedAt: number;
+	    Tags: string[];
+	    Status: string;
+	    StatusTime: number;
+	    Kind: string;
+	    Database: string;
+	    Private: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new DocumentSummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Title = source["Title"];
+	        this.Icon = source["Icon"];
+	        this.Cover = source["Cover"];
+	        this.CreatedBy = source["CreatedBy"];
+	        this.CreatedAt = source["CreatedAt"];
+	        this.Tags = source["Tags"];
+	        this.Status = source["Status"];
+	        this.StatusTime = source["StatusTime"];
+	        this.Kind = source["Kind"];
+	        this.Database = source["Database"];
+	        this.Private = source["Private"];
+	    }
+	}
+	export class FileInfo {
+	    Name: string;
+	    Metahash: string;
+	    Chunks: number[][];
+	
+	    static createFrom(source: any = {}) {
+	        return new FileInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Name = source["Name"];
+	        this.Metahash = source["Metahash"];
+	        this.Chunks = source["Chunks"];
+	    }
+	}
+	export class FindOptions {
+	    Query: string;
+	    Regex: boolean;
+	    IgnoreCase: boolean;
+	    DocumentID: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FindOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Query = source["Query"];
+	        this.Regex = source["Regex"];
+	        this.IgnoreCase = source["IgnoreCase"];
+	        this.DocumentID = source["DocumentID"];
+	    }
+	}
+	export class IntegrityIssue {
+	    Kind: string;
+	    BlockID: string;
+	    OperationID: string;
+	    Reference: string;
+	    Detail: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityIssue(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Kind = source["Kind"];
+	        this.BlockID = source["BlockID"];
+	        this.OperationID = source["OperationID"];
+	        this.Reference = source["Reference"];
+	        this.Detail = source["Detail"];
 	    }
 	}
+	export class IntegrityReport {
+	    DocumentID: string;
+	    Operations: number;
+	    Issues: IntegrityIssue[];
+	
+	    static createFrom(source: any = {}) {
+	        return new IntegrityReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Operations = source["Operations"];
+	        this.Issues = this.convertValues(source["Issues"], IntegrityIssue);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	
+	export class MembershipEntry {
+	    Index: number;
+	    Operation: CRDTOperation;
+	    KeyEpoch: number;
+	    PrevHash: number[];
+	    Hash: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new MembershipEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Index = source["Index"];
+	        this.Operation = this.convertValues(source["Operation"], CRDTOperation);
+	        this.KeyEpoch = source["KeyEpoch"];
+	        this.PrevHash = source["PrevHash"];
+	        this.Hash = source["Hash"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PageNode {
+	    DocumentID: string;
+	    Title: string;
+	    Icon: string;
+	    Children: PageNode[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PageNode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.Title = source["Title"];
+	        this.Icon = source["Icon"];
+	        this.Children = this.convertValues(source["Children"], PageNode);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class QuarantinedOperation {
+	    Operation: CRDTOperation;
+	    Source: string;
+	    Reason: string;
+	    Timestamp: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new QuarantinedOperation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Operation = this.convertValues(source["Operation"], CRDTOperation);
+	        this.Source = source["Source"];
+	        this.Reason = source["Reason"];
+	        this.Timestamp = source["Timestamp"];
+	    }
 	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RateLimitStats {
+	    Source: string;
+	    Accepted: number;
+	    Dropped: {[key: string]: number};
+	    Bans: number;
+	    BannedUntil: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RateLimitStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Source = source["Source"];
+	        this.Accepted = source["Accepted"];
+	        this.Dropped = source["Dropped"];
+	        this.Bans = source["Bans"];
+	        this.BannedUntil = source["BannedUntil"];
+	    }
+	}
+	export class RejectedRumor {
+	    Origin: string;
+	    Sequence: number;
+	    Source: string;
+	    Reason: string;
+	    Timestamp: number;
 	
+	    static createFrom(source: any = {}) {
+	        return new RejectedRumor(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Origin = source["Origin"];
+	        this.Sequence = source["Sequence"];
+	        this.Source = source["Source"];
+	        this.Reason = source["Reason"];
+	        this.Timestamp = source["Timestamp"];
+	    }
+	}
+	export class RepairOptions {
+	    RequestMissing: boolean;
+	    DropOrphans: boolean;
 	
+	    static createFrom(source: any = {}) {
+	        return new RepairOptions(source);
+	    }
 	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.RequestMissing = source["RequestMissing"];
+	        this.DropOrphans = source["DropOrphans"];
+	    }
+	}
 	export class Rumor {
 	    Origin: string;
+	    Address: string;
+	    ReadOnly: boolean;
+	    Signature: number[];
 	    Sequence: number;
 	    Msg?: transport.Message;
 	
@@ -531,6 +1389,9 @@ export namespace types {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.Origin = source["Origin"];
+	        this.Address = source["Address"];
+	        this.ReadOnly = source["ReadOnly"];
+	        this.Signature = source["Signature"];
 	        this.Sequence = source["Sequence"];
 	        this.Msg = this.convertValues(source["Msg"], transport.Message);
 	    }
@@ -553,6 +1414,24 @@ export namespace types {
 		    return a;
 		}
 	}
+	export class SearchHit {
+	    DocumentID: string;
+	    BlockID: string;
+	    Score: number;
+	    Snippet: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchHit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.BlockID = source["BlockID"];
+	        this.Score = source["Score"];
+	        this.Snippet = source["Snippet"];
+	    }
+	}
 	export class SearchRequestMessage {
 	    RequestID: string;
 	    Origin: string;
@@ -571,6 +1450,62 @@ export namespace types {
 	        this.Budget = source["Budget"];
 	    }
 	}
+	
+	export class TextMatch {
+	    DocumentID: string;
+	    BlockID: string;
+	    Start: number;
+	    End: number;
+	    Text: string;
+	    CharIDs: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TextMatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.DocumentID = source["DocumentID"];
+	        this.BlockID = source["BlockID"];
+	        this.Start = source["Start"];
+	        this.End = source["End"];
+	        this.Text = source["Text"];
+	        this.CharIDs = source["CharIDs"];
+	    }
+	}
+	
+	export class TransactionResult {
+	    Operations: CRDTOperation[];
+	    IDs: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new TransactionResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Operations = this.convertValues(source["Operations"], CRDTOperation);
+	        this.IDs = source["IDs"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
 
@@ -5,6 +5,7 @@ go 1.22.4
 toolchain go1.23.1
 
 require (
+	filippo.io/edwards25519 v1.1.0
 	github.com/rs/xid v1.6.0
 	github.com/rs/zerolog v1.33.0
 	github.com/stretchr/testify v1.9.0
@@ -17,6 +17,7 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options/mac"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
 
+	"Node-tion/backend/identity"
 	"Node-tion/backend/peer"
 	"Node-tion/backend/peer/impl"
 	"Node-tion/backend/registry/standard"
@@ -94,6 +95,22 @@ func main() {
 
 	storage := inmemory.NewPersistency()
 
+	// the identity is kept across runs, the node keeps its origin when the
+	// gateway interface changes
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		log.Fatalf("Error finding the configuration folder: %v", err)
+	}
+	idPath := filepath.Join(configDir, "Node-tion", "identity.key")
+	id, err := identity.LoadOrCreate(idPath)
+	if err != nil {
+		log.Fatalf("Error loading identity: %v", err)
+	}
+	counters, err := identity.LoadCounters(idPath + ".counters")
+	if err != nil {
+		log.Fatalf("Error loading counters: %v", err)
+	}
+
 	conf := peer.Configuration{
 		Socket:              sock,
 		MessageRegistry:     standard.NewRegistry(),
@@ -114,6 +131,8 @@ func main() {
 		},
 		PaxosID:            0,
 		PaxosProposerRetry: 0,
+		Identity:           &id,
+		Counters:           counters,
 	}
 
 	node := peerFactory(conf)